@@ -0,0 +1,164 @@
+package libstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ownerSuffix is appended to a key to name the key recording its owner.
+const ownerSuffix = ".libstore-owner"
+
+// aclOps restricts every key to the principal that created it.
+type aclOps struct {
+	ops           Ops
+	principalFrom func(ctx context.Context) string
+}
+
+// NewACLOps wraps ops so that every key belongs to the principal that created it
+// and only that principal can read, write, delete or list it. principalFrom
+// extracts the principal of a call from its context; an empty principal is denied.
+//
+// The owner of a key is stored in the underlying Ops as the entry of a companion
+// key named after it with a ".libstore-owner" suffix, so ownership works on every
+// backend and moves with the data. Companion keys are hidden from List, and keys
+// using the suffix are rejected. Keys created without NewACLOps have no owner and
+// are denied to every principal. Operations on a key owned by another principal
+// return a PermissionError. List reads the owner of every key, so it costs one Read
+// per key.
+func NewACLOps(ops Ops, principalFrom func(ctx context.Context) string) Ops {
+	return aclOps{ops: ops, principalFrom: principalFrom}
+}
+
+// Unwrap implements Unwrapper.
+func (a aclOps) Unwrap() Ops {
+	return a.ops
+}
+
+func (a aclOps) principal(ctx context.Context) (string, error) {
+	principal := a.principalFrom(ctx)
+	if principal == "" {
+		return "", PermissionError("acl: no principal")
+	}
+	return principal, nil
+}
+
+// authorize returns an error unless the principal of ctx owns key.
+func (a aclOps) authorize(ctx context.Context, key string) error {
+	if strings.HasSuffix(key, ownerSuffix) {
+		return KeyError(fmt.Sprintf("acl: key %s uses the reserved suffix %s", key, ownerSuffix))
+	}
+	principal, err := a.principal(ctx)
+	if err != nil {
+		return err
+	}
+	owner, err := a.ops.Read(ctx, key+ownerSuffix)
+	var notFound KeyNotFoundError
+	if errors.As(err, &notFound) {
+		// Report missing keys as such, and deny existing keys without an owner.
+		if _, err := a.ops.Read(ctx, key); errors.As(err, &notFound) {
+			return err
+		}
+		return PermissionError(fmt.Sprintf("acl: key %s has no owner", key))
+	}
+	if err != nil {
+		return err
+	}
+	if string(owner) != principal {
+		return PermissionError(fmt.Sprintf("acl: key %s is not owned by %s", key, principal))
+	}
+	return nil
+}
+
+// Create implements Ops. It records the principal of ctx as the owner of key.
+func (a aclOps) Create(ctx context.Context, key string) error {
+	if strings.HasSuffix(key, ownerSuffix) {
+		return KeyError(fmt.Sprintf("acl: key %s uses the reserved suffix %s", key, ownerSuffix))
+	}
+	principal, err := a.principal(ctx)
+	if err != nil {
+		return err
+	}
+	if err := a.ops.Create(ctx, key); err != nil {
+		return err
+	}
+	ownerKey := key + ownerSuffix
+	if err := a.ops.Create(ctx, ownerKey); err != nil {
+		return errors.Join(err, a.ops.Delete(ctx, key))
+	}
+	if err := a.ops.Put(ctx, ownerKey, []byte(principal)); err != nil {
+		return errors.Join(err, a.ops.Delete(ctx, ownerKey), a.ops.Delete(ctx, key))
+	}
+	return nil
+}
+
+// ReadAll implements Ops.
+func (a aclOps) ReadAll(ctx context.Context, key string) ([][]byte, error) {
+	if err := a.authorize(ctx, key); err != nil {
+		return nil, err
+	}
+	return a.ops.ReadAll(ctx, key)
+}
+
+// Read implements Ops.
+func (a aclOps) Read(ctx context.Context, key string) ([]byte, error) {
+	if err := a.authorize(ctx, key); err != nil {
+		return nil, err
+	}
+	return a.ops.Read(ctx, key)
+}
+
+// Put implements Ops.
+func (a aclOps) Put(ctx context.Context, key string, entry []byte) error {
+	if err := a.authorize(ctx, key); err != nil {
+		return err
+	}
+	return a.ops.Put(ctx, key, entry)
+}
+
+// Delete implements Ops. It deletes the owner of key along with it.
+func (a aclOps) Delete(ctx context.Context, key string) error {
+	if err := a.authorize(ctx, key); err != nil {
+		return err
+	}
+	if err := a.ops.Delete(ctx, key); err != nil {
+		return err
+	}
+	return a.ops.Delete(ctx, key+ownerSuffix)
+}
+
+// List implements Ops. It lists only the keys owned by the principal of ctx.
+func (a aclOps) List(ctx context.Context) ([]string, error) {
+	principal, err := a.principal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := a.ops.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	keys := []string{}
+	for _, key := range stored {
+		if strings.HasSuffix(key, ownerSuffix) {
+			continue
+		}
+		owner, err := a.ops.Read(ctx, key+ownerSuffix)
+		var notFound KeyNotFoundError
+		if errors.As(err, &notFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if string(owner) == principal {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+var (
+	_ Ops       = aclOps{}
+	_ Unwrapper = aclOps{}
+)
@@ -0,0 +1,87 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+type principalKey struct{}
+
+func asPrincipal(principal string) context.Context {
+	return context.WithValue(context.Background(), principalKey{}, principal)
+}
+
+func principalFrom(ctx context.Context) string {
+	principal, _ := ctx.Value(principalKey{}).(string)
+	return principal
+}
+
+func TestACLOps(t *testing.T) {
+	backend := libstore.NewInMemoryOps()
+	ops := libstore.NewACLOps(backend, principalFrom)
+	alice, bob := asPrincipal("alice"), asPrincipal("bob")
+
+	for _, key := range []string{"a1", "a2"} {
+		if err := ops.Create(alice, key); err != nil {
+			t.Fatalf("Error creating key: %v", err)
+		}
+	}
+	if err := ops.Create(bob, "b1"); err != nil {
+		t.Fatalf("Error creating key: %v", err)
+	}
+	if err := ops.Put(alice, "a1", []byte("secret")); err != nil {
+		t.Fatalf("Error putting entry as owner: %v", err)
+	}
+	if entry, err := ops.Read(alice, "a1"); err != nil || string(entry) != "secret" {
+		t.Errorf("Unexpected entry read by owner: %q, %v", entry, err)
+	}
+
+	var permErr libstore.PermissionError
+	if _, err := ops.Read(bob, "a1"); !errors.As(err, &permErr) {
+		t.Errorf("Expected a PermissionError reading another principal's key, Got: %v", err)
+	}
+	if err := ops.Put(bob, "a1", []byte("overwrite")); !errors.As(err, &permErr) {
+		t.Errorf("Expected a PermissionError writing another principal's key, Got: %v", err)
+	}
+	if err := ops.Delete(bob, "a1"); !errors.As(err, &permErr) {
+		t.Errorf("Expected a PermissionError deleting another principal's key, Got: %v", err)
+	}
+	if _, err := ops.Read(context.Background(), "a1"); !errors.As(err, &permErr) {
+		t.Errorf("Expected a PermissionError without a principal, Got: %v", err)
+	}
+	if code := libstore.NewError(permErr).Code; code != libstore.ErrPermission {
+		t.Errorf("Expected ErrPermission, Got: %v", code)
+	}
+
+	keys, err := ops.List(alice)
+	slices.Sort(keys)
+	if err != nil || !slices.Equal(keys, []string{"a1", "a2"}) {
+		t.Errorf("Unexpected keys listed for alice: %v, %v", keys, err)
+	}
+	keys, err = ops.List(bob)
+	if err != nil || !slices.Equal(keys, []string{"b1"}) {
+		t.Errorf("Unexpected keys listed for bob: %v, %v", keys, err)
+	}
+
+	var notFound libstore.KeyNotFoundError
+	if _, err := ops.Read(alice, "missing"); !errors.As(err, &notFound) {
+		t.Errorf("Expected a KeyNotFoundError, Got: %v", err)
+	}
+	if err := backend.Create(context.Background(), "unowned"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ops.ReadAll(alice, "unowned"); !errors.As(err, &permErr) {
+		t.Errorf("Expected a PermissionError for a key without owner, Got: %v", err)
+	}
+
+	if err := ops.Delete(alice, "a1"); err != nil {
+		t.Fatalf("Error deleting key as owner: %v", err)
+	}
+	if err := ops.Create(bob, "a1"); err != nil {
+		t.Errorf("Expected a deleted key to be free for another principal, Got: %v", err)
+	}
+}
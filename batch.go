@@ -0,0 +1,136 @@
+package libstore
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// BatchEntry is one entry of a batch of writes.
+type BatchEntry struct {
+	Key   string
+	Entry []byte
+}
+
+// BatchPutter is implemented by backends that can write many entries at once.
+type BatchPutter interface {
+	// PutBatch puts every entry of batch, in order, as a single operation. The batch
+	// is applied entirely or not at all.
+	PutBatch(ctx context.Context, batch []BatchEntry) error
+}
+
+// PutBatch puts every entry of batch into ops, in order.
+//
+// Backends implementing BatchPutter apply the batch atomically. For the others the
+// entries are put one at a time, and an error leaves the entries before it written.
+func PutBatch(ctx context.Context, ops Ops, batch []BatchEntry) error {
+	if putter, ok := ops.(BatchPutter); ok {
+		return putter.PutBatch(ctx, batch)
+	}
+	for _, e := range batch {
+		if err := ops.Put(ctx, e.Key, e.Entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BatchCreator is implemented by backends that can create many keys at once.
+type BatchCreator interface {
+	// CreateBatch creates every key of keys that does not exist yet; a key given
+	// more than once is created once. Keys that already exist are left untouched
+	// and listed by the *ExistingKeysError returned once the others are created.
+	// With the FailOnExisting option no key is created if any of them exists.
+	CreateBatch(ctx context.Context, keys []string) error
+}
+
+// ExistingKeysError is returned by CreateBatch for the keys that already existed.
+//
+// errors.As also matches an ExistingKeysError against KeyError, the error Create
+// returns for an existing key.
+type ExistingKeysError struct {
+	// Keys lists the keys that already existed, in the order they were given.
+	Keys []string
+}
+
+func (e *ExistingKeysError) Error() string {
+	return "libstore: " + e.message()
+}
+
+func (e *ExistingKeysError) message() string {
+	return "keys already exist: " + strings.Join(e.Keys, ", ")
+}
+
+// As makes errors.As report an ExistingKeysError as a KeyError.
+func (e *ExistingKeysError) As(target any) bool {
+	if t, ok := target.(*KeyError); ok {
+		*t = KeyError(e.message())
+		return true
+	}
+	return false
+}
+
+// FailOnExisting makes CreateBatch create none of the keys if any of them already
+// exists. It is ignored by other operations.
+func FailOnExisting() OpOption {
+	return func(o *opOptions) {
+		o.failOnExisting = true
+	}
+}
+
+// CreateBatch creates every key of keys in ops that does not exist yet. If some keys
+// already existed, the others are created and an *ExistingKeysError lists them; with
+// the FailOnExisting option none of the keys is created then.
+//
+// Backends implementing BatchCreator create the keys in bulk. For the others each
+// key is created with CreateIfNotExists and, with FailOnExisting, the keys created
+// are deleted again once an existing one is found, so a concurrent reader may see
+// them in the meantime.
+func CreateBatch(ctx context.Context, ops Ops, keys []string) error {
+	if creator, ok := ops.(BatchCreator); ok {
+		return creator.CreateBatch(ctx, keys)
+	}
+	failOnExisting := opOptionsFrom(ctx).failOnExisting
+	var created, existing []string
+	for _, key := range uniqueKeys(keys) {
+		ok, err := CreateIfNotExists(ctx, ops, key)
+		if err != nil {
+			return err
+		}
+		if ok {
+			created = append(created, key)
+			continue
+		}
+		existing = append(existing, key)
+	}
+	if len(existing) > 0 && failOnExisting {
+		var errs []error
+		for _, key := range created {
+			errs = append(errs, ops.Delete(ctx, key))
+		}
+		return errors.Join(append([]error{&ExistingKeysError{Keys: existing}}, errs...)...)
+	}
+	return existingKeysError(existing)
+}
+
+// existingKeysError returns an *ExistingKeysError listing existing, or nil if it is
+// empty.
+func existingKeysError(existing []string) error {
+	if len(existing) == 0 {
+		return nil
+	}
+	return &ExistingKeysError{Keys: existing}
+}
+
+// uniqueKeys returns keys without the repetitions of a key, in order.
+func uniqueKeys(keys []string) []string {
+	seen := make(map[string]bool, len(keys))
+	unique := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if !seen[key] {
+			seen[key] = true
+			unique = append(unique, key)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,53 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestCreateBatch(t *testing.T) {
+	backends := testBackends(t, "InMemory", "Fallback", "S3", "DB")
+	for name, newOps := range backends {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			ops := newOps(t)
+			prefix := testKey(t) + "/"
+			a, b, c, d := prefix+"a", prefix+"b", prefix+"c", prefix+"d"
+
+			if err := libstore.CreateBatch(ctx, ops, []string{a, b, a}); err != nil {
+				t.Fatalf("Error creating keys: %v", err)
+			}
+			if err := ops.Put(ctx, a, []byte("kept")); err != nil {
+				t.Fatal(err)
+			}
+
+			err := libstore.CreateBatch(ctx, ops, []string{c, a, b})
+			var existingErr *libstore.ExistingKeysError
+			if !errors.As(err, &existingErr) || !slices.Equal(existingErr.Keys, []string{a, b}) {
+				t.Fatalf("Expected an ExistingKeysError for %s and %s, Got: %v", a, b, err)
+			}
+			var keyErr libstore.KeyError
+			if !errors.As(err, &keyErr) {
+				t.Errorf("Expected the ExistingKeysError to match KeyError")
+			}
+			if entry, err := ops.Read(ctx, a); err != nil || string(entry) != "kept" {
+				t.Errorf("Expected the existing key to be left untouched, Got: %q, %v", entry, err)
+			}
+			if _, err := ops.ReadAll(ctx, c); err != nil {
+				t.Errorf("Expected the new key to be created despite the collisions, Got: %v", err)
+			}
+
+			err = libstore.CreateBatch(libstore.WithOpOptions(ctx, libstore.FailOnExisting()), ops, []string{d, c})
+			if !errors.As(err, &existingErr) || !slices.Equal(existingErr.Keys, []string{c}) {
+				t.Fatalf("Expected an ExistingKeysError for %s, Got: %v", c, err)
+			}
+			if keys, err := ops.List(ctx); err != nil || slices.Contains(keys, d) {
+				t.Errorf("Expected FailOnExisting to create no key, Got: %v, %v", keys, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+//go:build linux
+
+package libstore
+
+import (
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// birthTime returns when the file at path was created, as reported by statx. File
+// systems that do not record it report the inode change time instead.
+func birthTime(path string) (time.Time, error) {
+	var stx unix.Statx_t
+	if err := unix.Statx(unix.AT_FDCWD, path, 0, unix.STATX_BTIME|unix.STATX_CTIME, &stx); err != nil {
+		return time.Time{}, err
+	}
+	if stx.Mask&unix.STATX_BTIME != 0 {
+		return time.Unix(stx.Btime.Sec, int64(stx.Btime.Nsec)), nil
+	}
+	return time.Unix(stx.Ctime.Sec, int64(stx.Ctime.Nsec)), nil
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package libstore
+
+import (
+	"os"
+	"time"
+)
+
+// birthTime returns the modification time of the file at path, the only timestamp
+// os.Stat reports on every platform.
+func birthTime(path string) (time.Time, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
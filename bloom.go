@@ -0,0 +1,243 @@
+package libstore
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+// countingBloomFilter is a Bloom filter with a counter per slot, so keys can be
+// removed again. Counters saturate, and a saturated counter is never decremented.
+type countingBloomFilter struct {
+	counters []uint8
+	hashes   int
+}
+
+// newCountingBloomFilter returns a filter sized for n keys at a false positive rate
+// of fpRate.
+func newCountingBloomFilter(n int, fpRate float64) *countingBloomFilter {
+	n = max(n, 1)
+	if !(fpRate > 0 && fpRate < 1) {
+		fpRate = 0.01
+	}
+	slots := max(int(math.Ceil(-float64(n)*math.Log(fpRate)/(math.Ln2*math.Ln2))), 1)
+	hashes := max(int(math.Round(float64(slots)/float64(n)*math.Ln2)), 1)
+	return &countingBloomFilter{counters: make([]uint8, slots), hashes: hashes}
+}
+
+// slots calls fn with the slot of each hash of key, derived by double hashing.
+func (f *countingBloomFilter) slots(key string, fn func(slot int)) {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	sum := h.Sum64()
+	h1, h2 := sum&math.MaxUint32, sum>>32|1
+	for i := range uint64(f.hashes) {
+		fn(int((h1 + i*h2) % uint64(len(f.counters))))
+	}
+}
+
+// add records key.
+func (f *countingBloomFilter) add(key string) {
+	f.slots(key, func(slot int) {
+		if f.counters[slot] < math.MaxUint8 {
+			f.counters[slot]++
+		}
+	})
+}
+
+// remove forgets key, which must have been added.
+func (f *countingBloomFilter) remove(key string) {
+	f.slots(key, func(slot int) {
+		if c := f.counters[slot]; c > 0 && c < math.MaxUint8 {
+			f.counters[slot]--
+		}
+	})
+}
+
+// mayContain reports false if key was certainly not added.
+func (f *countingBloomFilter) mayContain(key string) bool {
+	found := true
+	f.slots(key, func(slot int) {
+		found = found && f.counters[slot] > 0
+	})
+	return found
+}
+
+// BloomOps answers reads of keys that do not exist from an in-memory Bloom filter
+// of the keys of the underlying Ops, without a backend round trip.
+type BloomOps struct {
+	ops          Ops
+	expectedKeys int
+	fpRate       float64
+
+	// rebuildMu serializes Rebuild.
+	rebuildMu sync.Mutex
+	mu        sync.RWMutex
+	// filter is nil until the keys of ops are loaded.
+	filter *countingBloomFilter
+	// added collects the keys added while Rebuild lists the keys of ops, to add
+	// them to the new filter too. It is nil outside Rebuild.
+	added []string
+	// skipped counts the reads answered from the filter.
+	skipped atomic.Int64
+}
+
+// NewBloomOps wraps ops with a Bloom filter of its keys, sized for expectedKeys keys
+// at a false positive rate of fpRate, so that Read and ReadAll of a key that does
+// not exist return a KeyNotFoundError without calling ops. Reads of keys the filter
+// may hold, including false positives, go to ops.
+//
+// The filter is loaded with List on the first read, and retried on the next one
+// if List fails, reads going to ops meanwhile. Create adds its key and Delete
+// removes it again: the filter counts the keys of each slot, so deletes do not
+// leave stale slots behind, up to 255 keys per slot beyond which a slot is never
+// cleared. A filter grown well beyond expectedKeys answers fewer misses; Rebuild
+// reloads it from List.
+//
+// Keys created in ops other than through the BloomOps after the filter is loaded
+// read as not found until Rebuild, and deleting them through it can hide other
+// keys, so the BloomOps should be the only writer creating and deleting keys.
+func NewBloomOps(ops Ops, expectedKeys int, fpRate float64) *BloomOps {
+	return &BloomOps{ops: ops, expectedKeys: expectedKeys, fpRate: fpRate}
+}
+
+// Unwrap implements Unwrapper.
+func (b *BloomOps) Unwrap() Ops {
+	return b.ops
+}
+
+// Skipped returns the number of reads answered from the filter without calling the
+// underlying Ops.
+func (b *BloomOps) Skipped() int64 {
+	return b.skipped.Load()
+}
+
+// Rebuild replaces the filter with one holding the keys the underlying Ops lists.
+// Keys created meanwhile are added to it as well; keys deleted meanwhile may stay in
+// it, costing a backend round trip when read.
+func (b *BloomOps) Rebuild(ctx context.Context) error {
+	b.rebuildMu.Lock()
+	defer b.rebuildMu.Unlock()
+	return b.rebuild(ctx)
+}
+
+// rebuild implements Rebuild. The caller must hold rebuildMu.
+func (b *BloomOps) rebuild(ctx context.Context) error {
+	b.mu.Lock()
+	b.added = []string{}
+	b.mu.Unlock()
+
+	keys, err := b.ops.List(ctx)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	added := b.added
+	b.added = nil
+	if err != nil {
+		return err
+	}
+	filter := newCountingBloomFilter(max(b.expectedKeys, len(keys)), b.fpRate)
+	for _, key := range append(keys, added...) {
+		filter.add(key)
+	}
+	b.filter = filter
+	return nil
+}
+
+// load loads the filter unless it is loaded.
+func (b *BloomOps) load(ctx context.Context) error {
+	b.rebuildMu.Lock()
+	defer b.rebuildMu.Unlock()
+
+	b.mu.RLock()
+	loaded := b.filter != nil
+	b.mu.RUnlock()
+	if loaded {
+		return nil
+	}
+	return b.rebuild(ctx)
+}
+
+// absent reports whether key certainly does not exist, loading the filter first if
+// needed. Keys are taken to exist while the filter cannot be loaded.
+func (b *BloomOps) absent(ctx context.Context, key string) bool {
+	b.mu.RLock()
+	loaded := b.filter != nil
+	b.mu.RUnlock()
+	if !loaded && b.load(ctx) != nil {
+		return false
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.filter.mayContain(key) {
+		return false
+	}
+	b.skipped.Add(1)
+	return true
+}
+
+// add adds key to the filter, and to the keys added during a Rebuild.
+func (b *BloomOps) add(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.filter != nil {
+		b.filter.add(key)
+	}
+	if b.added != nil {
+		b.added = append(b.added, key)
+	}
+}
+
+// Create implements libstore.Ops, adding key to the filter. A key that already
+// exists, created past the filter, is added as well.
+func (b *BloomOps) Create(ctx context.Context, key string) error {
+	err := b.ops.Create(ctx, key)
+	var keyErr KeyError
+	if err == nil || errors.As(err, &keyErr) {
+		b.add(key)
+	}
+	return err
+}
+
+// Put implements libstore.Ops.
+func (b *BloomOps) Put(ctx context.Context, key string, entry []byte) error {
+	return b.ops.Put(ctx, key, entry)
+}
+
+// Delete implements libstore.Ops, removing key from the filter.
+func (b *BloomOps) Delete(ctx context.Context, key string) error {
+	if err := b.ops.Delete(ctx, key); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.filter != nil && b.filter.mayContain(key) {
+		b.filter.remove(key)
+	}
+	return nil
+}
+
+// Read implements libstore.Ops, skipping the backend for keys that do not exist.
+func (b *BloomOps) Read(ctx context.Context, key string) ([]byte, error) {
+	if b.absent(ctx, key) {
+		return nil, KeyNotFoundError("key not found: " + key)
+	}
+	return b.ops.Read(ctx, key)
+}
+
+// ReadAll implements libstore.Ops, skipping the backend for keys that do not exist.
+func (b *BloomOps) ReadAll(ctx context.Context, key string) ([][]byte, error) {
+	if b.absent(ctx, key) {
+		return nil, KeyNotFoundError("key not found: " + key)
+	}
+	return b.ops.ReadAll(ctx, key)
+}
+
+// List implements libstore.Ops.
+func (b *BloomOps) List(ctx context.Context) ([]string, error) {
+	return b.ops.List(ctx)
+}
@@ -0,0 +1,68 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestBloomOps(t *testing.T) {
+	ctx := context.Background()
+	backend := libstore.NewInMemoryOps()
+	if err := backend.Create(ctx, "existing"); err != nil {
+		t.Fatal(err)
+	}
+	recorder := newRecordingOps(backend)
+	ops := libstore.NewBloomOps(recorder, 100, 0.01)
+
+	if _, err := ops.ReadAll(ctx, "existing"); err != nil {
+		t.Fatalf("Error reading a key listed by the backend: %v", err)
+	}
+	var notFoundErr libstore.KeyNotFoundError
+	for i := range 100 {
+		if _, err := ops.Read(ctx, fmt.Sprintf("missing%d", i)); !errors.As(err, &notFoundErr) {
+			t.Fatalf("Expected a KeyNotFoundError for a missing key, Got: %v", err)
+		}
+	}
+	// Only false positives, about 1% of the misses, reach the backend.
+	if skipped := ops.Skipped(); skipped < 95 {
+		t.Errorf("Expected most misses to skip the backend, Got: %d of 100", skipped)
+	}
+	if reads := recorder.count("Read"); int64(reads) != 100-ops.Skipped() {
+		t.Errorf("Expected the backend to serve only the possible hits, Got: %d reads", reads)
+	}
+
+	if err := ops.Create(ctx, "new"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ops.Put(ctx, "new", []byte("entry")); err != nil {
+		t.Fatal(err)
+	}
+	if entry, err := ops.Read(ctx, "new"); err != nil || string(entry) != "entry" {
+		t.Errorf("Expected a created key to reach the backend, Got: %q, %v", entry, err)
+	}
+	if err := ops.Delete(ctx, "new"); err != nil {
+		t.Fatal(err)
+	}
+	skipped := ops.Skipped()
+	if _, err := ops.Read(ctx, "new"); !errors.As(err, &notFoundErr) || ops.Skipped() != skipped+1 {
+		t.Errorf("Expected a deleted key to be answered from the filter, Got: %v", err)
+	}
+	if _, err := ops.ReadAll(ctx, "existing"); err != nil {
+		t.Errorf("Expected deleting a key to keep the others in the filter, Got: %v", err)
+	}
+
+	// Keys created past the filter are missed until Rebuild.
+	if err := backend.Create(ctx, "outside"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ops.Rebuild(ctx); err != nil {
+		t.Fatalf("Error rebuilding filter: %v", err)
+	}
+	if _, err := ops.ReadAll(ctx, "outside"); err != nil {
+		t.Errorf("Expected Rebuild to add the keys of the backend, Got: %v", err)
+	}
+}
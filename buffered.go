@@ -0,0 +1,272 @@
+package libstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// bufferedFlushAttempts is how many flushes in a row an entry of a BufferedWriteOps
+// may fail before it is dropped.
+const bufferedFlushAttempts = 5
+
+// BufferedWriteOps buffers Puts in memory and writes them to the underlying Ops in
+// batches. See NewBufferedWriteOps.
+type BufferedWriteOps struct {
+	ops      Ops
+	maxBatch int
+
+	// mu guards the fields below it up to flushMu.
+	mu      sync.Mutex
+	pending []BatchEntry
+	// failures counts the flushes in a row that failed on the first pending entry.
+	failures int
+	flushErr error
+	// dropped holds the errors of the entries dropped since the last Flush.
+	dropped []error
+	closed  bool
+
+	// flushMu is held for writing while a batch moves from pending to the backend, so
+	// readers never see an entry in both places or in neither.
+	flushMu sync.RWMutex
+
+	trigger   chan struct{}
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// NewBufferedWriteOps wraps ops so that Puts are buffered and written with PutBatch
+// every flushInterval, as soon as maxBatch entries are pending, on Flush and on Close.
+// A flushInterval that is not positive disables the periodic flush, leaving the
+// other three.
+//
+// Reads see buffered entries: Read returns the latest buffered entry of a key, and
+// ReadAll appends the buffered entries to those of ops, which matches backends whose
+// Put appends. Create and List go to ops directly, and Delete discards the buffered
+// entries of the key.
+//
+// Buffered entries are not durable: they are lost if the process exits before they
+// are flushed, and a Put returning nil only means the entry was buffered. Errors of
+// background flushes are returned by the next Flush or Close. The entries written
+// before a failure leave the buffer, and the others stay buffered to be retried, so
+// no entry is written twice; an entry that fails bufferedFlushAttempts flushes in a
+// row is dropped, and the next Flush or Close reports it. Put fails with a
+// ClosedError once Close or Drain was called.
+func NewBufferedWriteOps(ops Ops, flushInterval time.Duration, maxBatch int) *BufferedWriteOps {
+	b := &BufferedWriteOps{
+		ops:      ops,
+		maxBatch: max(maxBatch, 1),
+		trigger:  make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go b.flushEvery(flushInterval)
+	return b
+}
+
+func (b *BufferedWriteOps) flushEvery(interval time.Duration) {
+	defer close(b.done)
+	// A nil channel never fires, so without an interval only trigger flushes.
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-tick:
+		case <-b.trigger:
+		}
+		if err := b.flush(context.Background()); err != nil {
+			b.mu.Lock()
+			b.flushErr = err
+			b.mu.Unlock()
+		}
+	}
+}
+
+// Flush writes all buffered entries to the underlying Ops. It also returns the error
+// of any background flush that failed since the last call.
+func (b *BufferedWriteOps) Flush(ctx context.Context) error {
+	err := b.flush(ctx)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	errs := append([]error{b.flushErr}, b.dropped...)
+	err = errors.Join(append(errs, err)...)
+	b.flushErr, b.dropped = nil, nil
+	return err
+}
+
+func (b *BufferedWriteOps) flush(ctx context.Context) error {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+
+	b.mu.Lock()
+	batch := b.pending[:len(b.pending):len(b.pending)]
+	b.mu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+	written, err := b.write(ctx, batch)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	clear(b.pending[:written])
+	b.pending = b.pending[written:]
+	if written > 0 {
+		b.failures = 0
+	}
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+	if b.failures++; b.failures >= bufferedFlushAttempts {
+		dropped := b.pending[0]
+		b.pending = b.pending[1:]
+		b.failures = 0
+		b.dropped = append(b.dropped, fmt.Errorf("%w: %w", EntryError(fmt.Sprintf("dropped an entry of key %s after %d failed flushes", dropped.Key, bufferedFlushAttempts)), err))
+	}
+	return err
+}
+
+// write writes batch to the underlying Ops and returns how many of its entries, from
+// the first, were written. A failed PutBatch writes nothing, so the entries are then
+// put one at a time, which tells the failing entry apart from those before it.
+func (b *BufferedWriteOps) write(ctx context.Context, batch []BatchEntry) (int, error) {
+	if putter, ok := b.ops.(BatchPutter); ok {
+		if err := putter.PutBatch(ctx, batch); err == nil {
+			return len(batch), nil
+		}
+	}
+	for i, e := range batch {
+		if err := b.ops.Put(ctx, e.Key, e.Entry); err != nil {
+			return i, err
+		}
+	}
+	return len(batch), nil
+}
+
+// Drain implements Drainer. It stops the background flusher, flushes the buffered
+// entries and drains the underlying Ops.
+func (b *BufferedWriteOps) Drain(ctx context.Context) error {
+	b.closeOnce.Do(func() {
+		close(b.stop)
+		<-b.done
+		b.mu.Lock()
+		b.closed = true
+		b.mu.Unlock()
+		b.closeErr = b.Flush(ctx)
+		if b.closeErr == nil {
+			b.closeErr = Drain(ctx, b.ops)
+		}
+	})
+	return b.closeErr
+}
+
+// Close flushes the buffered entries and stops the background flusher.
+func (b *BufferedWriteOps) Close() error {
+	return b.Drain(context.Background())
+}
+
+// Unwrap implements Unwrapper.
+func (b *BufferedWriteOps) Unwrap() Ops {
+	return b.ops
+}
+
+// buffered returns the buffered entries of key. The caller must hold flushMu.
+func (b *BufferedWriteOps) buffered(key string) [][]byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var entries [][]byte
+	for _, e := range b.pending {
+		if e.Key == key {
+			entries = append(entries, e.Entry)
+		}
+	}
+	return entries
+}
+
+// Create implements Ops.
+func (b *BufferedWriteOps) Create(ctx context.Context, key string) error {
+	return b.ops.Create(ctx, key)
+}
+
+// ReadAll implements Ops.
+func (b *BufferedWriteOps) ReadAll(ctx context.Context, key string) ([][]byte, error) {
+	b.flushMu.RLock()
+	defer b.flushMu.RUnlock()
+
+	entries, err := b.ops.ReadAll(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return append(entries, b.buffered(key)...), nil
+}
+
+// Read implements Ops.
+func (b *BufferedWriteOps) Read(ctx context.Context, key string) ([]byte, error) {
+	b.flushMu.RLock()
+	defer b.flushMu.RUnlock()
+
+	if entries := b.buffered(key); len(entries) > 0 {
+		return entries[len(entries)-1], nil
+	}
+	return b.ops.Read(ctx, key)
+}
+
+// Put implements Ops. It buffers entry and returns without writing it.
+func (b *BufferedWriteOps) Put(ctx context.Context, key string, entry []byte) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return ClosedError("buffered ops is closed")
+	}
+	b.pending = append(b.pending, BatchEntry{Key: key, Entry: entry})
+	full := len(b.pending) >= b.maxBatch
+	b.mu.Unlock()
+
+	if full {
+		select {
+		case b.trigger <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Delete implements Ops. Buffered entries of the key are discarded.
+func (b *BufferedWriteOps) Delete(ctx context.Context, key string) error {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+
+	b.mu.Lock()
+	kept := b.pending[:0]
+	for _, e := range b.pending {
+		if e.Key != key {
+			kept = append(kept, e)
+		}
+	}
+	clear(b.pending[len(kept):])
+	b.pending = kept
+	b.failures = 0
+	b.mu.Unlock()
+
+	return b.ops.Delete(ctx, key)
+}
+
+// List implements Ops.
+func (b *BufferedWriteOps) List(ctx context.Context) ([]string, error) {
+	return b.ops.List(ctx)
+}
+
+var (
+	_ Ops       = (*BufferedWriteOps)(nil)
+	_ Drainer   = (*BufferedWriteOps)(nil)
+	_ Unwrapper = (*BufferedWriteOps)(nil)
+)
@@ -0,0 +1,193 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cecmp/libstore"
+)
+
+func newBufferedBackend(t *testing.T) *recordingOps {
+	ops, err := libstore.NewFileOps(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ops.Create(context.TODO(), "key"); err != nil {
+		t.Fatal(err)
+	}
+	return newRecordingOps(ops)
+}
+
+func TestBufferedWriteOpsReadYourWrites(t *testing.T) {
+	ctx := context.Background()
+	backend := newBufferedBackend(t)
+	ops := libstore.NewBufferedWriteOps(backend, time.Hour, 100)
+	defer ops.Close()
+
+	if err := backend.Put(ctx, "key", []byte("flushed")); err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range []string{"first", "second"} {
+		if err := ops.Put(ctx, "key", []byte(entry)); err != nil {
+			t.Fatalf("Error putting entry: %v", err)
+		}
+	}
+	if n := backend.count("Put"); n != 1 {
+		t.Errorf("Expected Puts to be buffered, Got: %d backend Puts", n)
+	}
+	if entry, err := ops.Read(ctx, "key"); err != nil || string(entry) != "second" {
+		t.Errorf("Expected the buffered entry, Got: %q, %v", entry, err)
+	}
+	entries, err := ops.ReadAll(ctx, "key")
+	if err != nil || len(entries) != 3 || string(entries[0]) != "flushed" || string(entries[2]) != "second" {
+		t.Errorf("Expected backend and buffered entries, Got: %q, %v", entries, err)
+	}
+
+	if err := ops.Flush(ctx); err != nil {
+		t.Fatalf("Error flushing: %v", err)
+	}
+	if n := backend.count("Put"); n != 3 {
+		t.Errorf("Expected Flush to write 2 entries, Got: %d backend Puts", n)
+	}
+	entries, err = ops.ReadAll(ctx, "key")
+	if err != nil || len(entries) != 3 {
+		t.Errorf("Expected flushed entries to be read once, Got: %q, %v", entries, err)
+	}
+
+	if err := ops.Put(ctx, "key", []byte("discarded")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ops.Delete(ctx, "key"); err != nil {
+		t.Fatalf("Error deleting key: %v", err)
+	}
+	if _, err := ops.Read(ctx, "key"); err == nil {
+		t.Error("Expected buffered entries to be discarded by Delete")
+	}
+}
+
+func TestBufferedWriteOpsFlushTriggers(t *testing.T) {
+	ctx := context.Background()
+	waitForPuts := func(t *testing.T, backend *recordingOps, n int) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for backend.count("Put") < n {
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected %d backend Puts, Got: %d", n, backend.count("Put"))
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	t.Run("Interval", func(t *testing.T) {
+		backend := newBufferedBackend(t)
+		ops := libstore.NewBufferedWriteOps(backend, 10*time.Millisecond, 100)
+		defer ops.Close()
+		if err := ops.Put(ctx, "key", []byte("entry")); err != nil {
+			t.Fatal(err)
+		}
+		waitForPuts(t, backend, 1)
+	})
+
+	t.Run("MaxBatch", func(t *testing.T) {
+		backend := newBufferedBackend(t)
+		ops := libstore.NewBufferedWriteOps(backend, time.Hour, 3)
+		defer ops.Close()
+		for i := range 2 {
+			if err := ops.Put(ctx, "key", []byte{byte(i)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		if n := backend.count("Put"); n != 0 {
+			t.Errorf("Expected no flush below maxBatch, Got: %d backend Puts", n)
+		}
+		if err := ops.Put(ctx, "key", []byte{2}); err != nil {
+			t.Fatal(err)
+		}
+		waitForPuts(t, backend, 3)
+	})
+
+	t.Run("NoInterval", func(t *testing.T) {
+		backend := newBufferedBackend(t)
+		ops := libstore.NewBufferedWriteOps(backend, 0, 2)
+		defer ops.Close()
+		if err := ops.Put(ctx, "key", []byte{0}); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(20 * time.Millisecond)
+		if n := backend.count("Put"); n != 0 {
+			t.Errorf("Expected no periodic flush without an interval, Got: %d backend Puts", n)
+		}
+		if err := ops.Put(ctx, "key", []byte{1}); err != nil {
+			t.Fatal(err)
+		}
+		waitForPuts(t, backend, 2)
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		dir := t.TempDir()
+		backend, err := libstore.NewFileOps(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := backend.Create(ctx, "key"); err != nil {
+			t.Fatal(err)
+		}
+		ops := libstore.NewBufferedWriteOps(backend, time.Hour, 100)
+		if err := ops.Put(ctx, "key", []byte("entry")); err != nil {
+			t.Fatal(err)
+		}
+		if err := ops.Close(); err != nil {
+			t.Fatalf("Error closing: %v", err)
+		}
+		reopened, err := libstore.NewFileOps(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry, err := reopened.Read(ctx, "key"); err != nil || string(entry) != "entry" {
+			t.Errorf("Expected Close to flush, Got: %q, %v", entry, err)
+		}
+	})
+}
+
+func TestBufferedWriteOpsFlushFailure(t *testing.T) {
+	ctx := context.Background()
+	backend := newBufferedBackend(t)
+	ops := libstore.NewBufferedWriteOps(backend, time.Hour, 100)
+	defer ops.Close()
+
+	// The entry of the missing key fails every flush, after the one before it is written.
+	for _, e := range []libstore.BatchEntry{{Key: "key", Entry: []byte("a")}, {Key: "missing", Entry: []byte("x")}, {Key: "key", Entry: []byte("b")}} {
+		if err := ops.Put(ctx, e.Key, e.Entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var notFound libstore.KeyNotFoundError
+	var entryErr libstore.EntryError
+	for i := range 5 {
+		err := ops.Flush(ctx)
+		if !errors.As(err, &notFound) {
+			t.Fatalf("Expected flush %d to fail with a KeyNotFoundError, Got: %v", i+1, err)
+		}
+		if dropped := errors.As(err, &entryErr); dropped != (i == 4) {
+			t.Errorf("Expected the entry to be dropped on flush 5 only, Got: dropped on flush %d: %v", i+1, err)
+		}
+	}
+	if err := ops.Flush(ctx); err != nil {
+		t.Fatalf("Expected the flush after the drop to succeed, Got: %v", err)
+	}
+	entries, err := backend.ReadAll(ctx, "key")
+	if err != nil || len(entries) != 2 || string(entries[0]) != "a" || string(entries[1]) != "b" {
+		t.Errorf("Expected each entry to be written once, Got: %q, %v", entries, err)
+	}
+
+	if err := ops.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var closedErr libstore.ClosedError
+	if err := ops.Put(ctx, "key", []byte("late")); !errors.As(err, &closedErr) {
+		t.Errorf("Expected a ClosedError putting after Close, Got: %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package libstore
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+)
+
+// Consistency selects how fresh the result of a read must be.
+type Consistency int
+
+const (
+	// Eventual allows reads to be served from a cache. It is the default.
+	Eventual Consistency = iota
+	// Strong requires reads to reflect the backend at the time of the call.
+	Strong
+)
+
+// ReadConsistency sets the consistency required by the reads of an operation.
+// Caching wrappers such as NewCacheOps read through to the backend for Strong
+// reads; backends without a cache always read from their source and ignore it.
+func ReadConsistency(consistency Consistency) OpOption {
+	return func(o *opOptions) {
+		o.consistency = consistency
+	}
+}
+
+// cachedEntry is the latest entry of a key as last read from the backend.
+type cachedEntry struct {
+	entry   []byte
+	expires time.Time
+}
+
+// cacheOps serves Read from an in-memory cache of latest entries.
+type cacheOps struct {
+	ops Ops
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cachedEntry
+	// generation counts invalidations, so a Read racing with a write does not
+	// cache the entry it read before the write.
+	generation uint64
+}
+
+// NewCacheOps wraps ops so that Read is served from an in-memory cache of the latest
+// entry of each key, kept for ttl, or until changed through this wrapper if ttl is
+// zero. Writes and deletes through the wrapper invalidate the key; changes made to
+// ops by other writers are only seen once the cached entry expires.
+//
+// Reads made with ReadConsistency(Strong) bypass the cache, reading from ops and
+// refreshing the cached entry.
+func NewCacheOps(ops Ops, ttl time.Duration) Ops {
+	return &cacheOps{ops: ops, ttl: ttl, now: time.Now, entries: map[string]cachedEntry{}}
+}
+
+// Unwrap implements Unwrapper.
+func (c *cacheOps) Unwrap() Ops {
+	return c.ops
+}
+
+func (c *cacheOps) invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+	c.generation++
+}
+
+// Create implements Ops.
+func (c *cacheOps) Create(ctx context.Context, key string) error {
+	c.invalidate(key)
+	return c.ops.Create(ctx, key)
+}
+
+// ReadAll implements Ops. It always reads from the backend.
+func (c *cacheOps) ReadAll(ctx context.Context, key string) ([][]byte, error) {
+	return c.ops.ReadAll(ctx, key)
+}
+
+// lookup returns the cached entry of key, if any, along with the current generation.
+func (c *cacheOps) lookup(key string) ([]byte, bool, uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cached, ok := c.entries[key]
+	if ok && c.ttl > 0 && !c.now().Before(cached.expires) {
+		delete(c.entries, key)
+		ok = false
+	}
+	return cached.entry, ok, c.generation
+}
+
+// Read implements Ops.
+func (c *cacheOps) Read(ctx context.Context, key string) ([]byte, error) {
+	cached, ok, generation := c.lookup(key)
+	if ok && opOptionsFrom(ctx).consistency != Strong {
+		return bytes.Clone(cached), nil
+	}
+
+	entry, err := c.ops.Read(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation == generation {
+		c.entries[key] = cachedEntry{entry: bytes.Clone(entry), expires: c.now().Add(c.ttl)}
+	}
+	return entry, nil
+}
+
+// Put implements Ops.
+func (c *cacheOps) Put(ctx context.Context, key string, entry []byte) error {
+	defer c.invalidate(key)
+	return c.ops.Put(ctx, key, entry)
+}
+
+// Delete implements Ops.
+func (c *cacheOps) Delete(ctx context.Context, key string) error {
+	defer c.invalidate(key)
+	return c.ops.Delete(ctx, key)
+}
+
+// List implements Ops.
+func (c *cacheOps) List(ctx context.Context) ([]string, error) {
+	return c.ops.List(ctx)
+}
+
+var (
+	_ Ops       = (*cacheOps)(nil)
+	_ Unwrapper = (*cacheOps)(nil)
+)
@@ -0,0 +1,60 @@
+package libstore_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestCacheOpsConsistency(t *testing.T) {
+	ctx := context.Background()
+	backend := newRecordingOps(libstore.NewInMemoryOps())
+	ops := libstore.NewCacheOps(backend, 0)
+	if err := ops.Create(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ops.Put(ctx, "key", []byte("v1")); err != nil {
+		t.Fatal(err)
+	}
+
+	for range 3 {
+		if entry, err := ops.Read(ctx, "key"); err != nil || string(entry) != "v1" {
+			t.Fatalf("Unexpected entry: %q, %v", entry, err)
+		}
+	}
+	if n := backend.count("Read"); n != 1 {
+		t.Errorf("Expected eventual reads to be served from the cache, Got: %d backend reads", n)
+	}
+
+	// Another writer changes the backend behind the cache's back.
+	if err := backend.Put(ctx, "key", []byte("v2")); err != nil {
+		t.Fatal(err)
+	}
+	if entry, err := ops.Read(ctx, "key"); err != nil || string(entry) != "v1" {
+		t.Errorf("Expected the cached entry, Got: %q, %v", entry, err)
+	}
+	strong := libstore.WithOpOptions(ctx, libstore.ReadConsistency(libstore.Strong))
+	if entry, err := ops.Read(strong, "key"); err != nil || string(entry) != "v2" {
+		t.Errorf("Expected a strong read to reach the backend, Got: %q, %v", entry, err)
+	}
+	if n := backend.count("Read"); n != 2 {
+		t.Errorf("Expected the strong read to hit the backend despite the cache hit, Got: %d backend reads", n)
+	}
+	// The strong read refreshed the cache.
+	if entry, err := ops.Read(ctx, "key"); err != nil || string(entry) != "v2" {
+		t.Errorf("Expected the refreshed entry, Got: %q, %v", entry, err)
+	}
+
+	if err := ops.Put(ctx, "key", []byte("v3")); err != nil {
+		t.Fatal(err)
+	}
+	if entry, err := ops.Read(ctx, "key"); err != nil || string(entry) != "v3" {
+		t.Errorf("Expected Put to invalidate the cache, Got: %q, %v", entry, err)
+	}
+
+	// Non-cache backends ignore the directive.
+	if entry, err := backend.Read(strong, "key"); err != nil || string(entry) != "v3" {
+		t.Errorf("Unexpected entry: %q, %v", entry, err)
+	}
+}
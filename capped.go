@@ -0,0 +1,37 @@
+package libstore
+
+import (
+	"context"
+	"fmt"
+)
+
+// CappedPutter is implemented by backends that can append an entry and drop the
+// oldest entries beyond a cap in one atomic step.
+type CappedPutter interface {
+	// PutCapped appends entry to key and then deletes the oldest entries until at
+	// most maxEntries remain. It returns a KeyNotFoundError if the key does not exist.
+	PutCapped(ctx context.Context, key string, entry []byte, maxEntries int) error
+}
+
+// PutCapped appends entry to key and keeps only its newest maxEntries entries, as
+// one atomic step, which turns a key into a bounded ring-buffer log. It returns an
+// EntryError if maxEntries is not positive and an UnsupportedError if ops does not
+// implement CappedPutter.
+func PutCapped(ctx context.Context, ops Ops, key string, entry []byte, maxEntries int) error {
+	if err := checkMaxEntries(maxEntries); err != nil {
+		return err
+	}
+	if putter, ok := ops.(CappedPutter); ok {
+		return putter.PutCapped(ctx, key, entry, maxEntries)
+	}
+	return UnsupportedError("PutCapped is not supported by this backend")
+}
+
+// checkMaxEntries returns the EntryError of PutCapped if maxEntries is not positive.
+// Implementations of CappedPutter check it too, as they can be called directly.
+func checkMaxEntries(maxEntries int) error {
+	if maxEntries < 1 {
+		return EntryError(fmt.Sprintf("max entries must be positive, got %d", maxEntries))
+	}
+	return nil
+}
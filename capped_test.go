@@ -0,0 +1,110 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestPutCapped(t *testing.T) {
+	backends := testBackends(t, "InMemory", "File", "DB")
+	for name, newOps := range backends {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			ops := newOps(t)
+			key := filepath.Base(testKey(t))
+			if err := ops.Create(ctx, key); err != nil {
+				t.Fatal(err)
+			}
+			for i := range 5 {
+				if err := libstore.PutCapped(ctx, ops, key, []byte(fmt.Sprint(i)), 3); err != nil {
+					t.Fatalf("Error putting capped entry: %v", err)
+				}
+			}
+			entries, err := ops.ReadAll(ctx, key)
+			if err != nil || len(entries) != 3 {
+				t.Fatalf("Expected 3 entries, Got: %q, %v", entries, err)
+			}
+			for i, want := range []string{"2", "3", "4"} {
+				if string(entries[i]) != want {
+					t.Errorf("Expected the newest entries, Got: %q", entries)
+					break
+				}
+			}
+
+			var notFound libstore.KeyNotFoundError
+			if err := libstore.PutCapped(ctx, ops, key+"-missing", []byte("x"), 3); !errors.As(err, &notFound) {
+				t.Errorf("Expected a KeyNotFoundError, Got: %v", err)
+			}
+			var entryErr libstore.EntryError
+			if err := libstore.PutCapped(ctx, ops, key, []byte("x"), 0); !errors.As(err, &entryErr) {
+				t.Errorf("Expected an EntryError for a cap of 0, Got: %v", err)
+			}
+			for _, maxEntries := range []int{0, -1} {
+				if err := ops.(libstore.CappedPutter).PutCapped(ctx, key, []byte("x"), maxEntries); !errors.As(err, &entryErr) {
+					t.Errorf("Expected an EntryError from the backend for a cap of %d, Got: %v", maxEntries, err)
+				}
+			}
+			if entries, err := ops.ReadAll(ctx, key); err != nil || len(entries) != 3 {
+				t.Errorf("Expected rejected puts to leave the entries alone, Got: %q, %v", entries, err)
+			}
+		})
+	}
+
+	var unsupported libstore.UnsupportedError
+	if err := libstore.PutCapped(context.TODO(), newRecordingOps(libstore.NewInMemoryOps()), "key", nil, 1); !errors.As(err, &unsupported) {
+		t.Errorf("Expected an UnsupportedError, Got: %v", err)
+	}
+}
+
+func TestFilePutCappedTemporaryFile(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	ops, err := libstore.NewFileOps(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// "k.tmp" is the name a temporary file of "k" could naively take.
+	for _, key := range []string{"k", "k.tmp"} {
+		if err := ops.Create(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+		if err := ops.Put(ctx, key, []byte(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := libstore.PutCapped(ctx, ops, "k", []byte("capped"), 1); err != nil {
+		t.Fatalf("Error putting capped entry: %v", err)
+	}
+
+	if entry, err := ops.Read(ctx, "k.tmp"); err != nil || string(entry) != "k.tmp" {
+		t.Errorf("Expected k.tmp to be untouched, Got: %q, %v", entry, err)
+	}
+	if entries, err := ops.ReadAll(ctx, "k"); err != nil || len(entries) != 1 || string(entries[0]) != "capped" {
+		t.Errorf("Expected only the capped entry, Got: %q, %v", entries, err)
+	}
+	names, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 {
+		t.Errorf("Expected no temporary file to be left, Got: %v", names)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "k")); err != nil || info.Mode().Perm() != 0o644 {
+		t.Errorf("Expected the rewritten file to keep mode 0644, Got: %v, %v", info, err)
+	}
+
+	// A temporary file left by a crash is not a key.
+	if err := os.WriteFile(filepath.Join(dir, "%tmp-123"), []byte("partial"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if keys, err := ops.List(ctx); err != nil || !slices.Equal(keys, []string{"k", "k.tmp"}) {
+		t.Errorf("Expected only the keys to be listed, Got: %v, %v", keys, err)
+	}
+}
@@ -0,0 +1,240 @@
+package libstore
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// Namespaces of the keys casOps writes to the underlying Ops.
+const (
+	casKeyPrefix  = "key/"
+	casBlobPrefix = "blob/"
+	casRefPrefix  = "ref/"
+	// casRefScheme starts the entries of logical keys, followed by the Checksum of
+	// the blob they refer to.
+	casRefScheme = "sha256:"
+)
+
+// casOps stores every distinct entry once, under its checksum, and logical keys as
+// references to it.
+type casOps struct {
+	ops Ops
+	// keyLocks serialize the writes to a logical key, hashLocks the reference count
+	// and blob of a checksum. Both are striped, so that collected blobs and deleted
+	// keys leave no lock behind.
+	keyLocks  *keyLocks
+	hashLocks *keyLocks
+}
+
+// NewCASOps wraps ops with content-addressed storage, so that identical entries of
+// any number of keys are stored once. Put stores an entry as a blob named after its
+// Checksum, unless the blob exists, and writes a short reference to it as the entry
+// of the logical key; Read and ReadAll follow the references. In ops, logical keys
+// are stored under "key/", blobs under "blob/" and the number of entries referring
+// to each blob, as a counter, under "ref/".
+//
+// Delete drops the references of a key and deletes a blob along with its counter
+// once no entry refers to it. Entries replaced by Put on backends that do not keep
+// history release their blob likewise. Counters are updated with Increment, so ops
+// must implement Incrementer; Put returns an UnsupportedError otherwise.
+//
+// A blob is only deleted under the lock of its checksum, held by any Put storing
+// it too, so a blob is never collected while a Put of the same content is in
+// flight. These locks are held in process: wrappers sharing ops from several
+// processes can race a Put against the deletion of the blob it refers to.
+func NewCASOps(ops Ops) Ops {
+	return casOps{ops: ops, keyLocks: &keyLocks{}, hashLocks: &keyLocks{}}
+}
+
+// lock locks name in locks and returns the function unlocking it.
+func (c casOps) lock(locks *keyLocks, name string) func() {
+	mu := locks.get(name)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// Unwrap implements Unwrapper.
+func (c casOps) Unwrap() Ops {
+	return c.ops
+}
+
+// Create implements libstore.Ops.
+func (c casOps) Create(ctx context.Context, key string) error {
+	return c.ops.Create(ctx, casKeyPrefix+key)
+}
+
+// Put implements libstore.Ops, storing entry as a blob unless an identical one exists.
+func (c casOps) Put(ctx context.Context, key string, entry []byte) error {
+	if _, ok := c.ops.(Incrementer); !ok {
+		return UnsupportedError("cas: reference counting needs a backend implementing Incrementer")
+	}
+	defer c.lock(c.keyLocks, key)()
+
+	before, err := c.refs(ctx, key)
+	if err != nil {
+		return err
+	}
+	sum := Checksum(entry)
+	if err := c.retain(ctx, sum, entry); err != nil {
+		return err
+	}
+	if err := c.ops.Put(ctx, casKeyPrefix+key, []byte(casRefScheme+sum)); err != nil {
+		return errors.Join(err, c.release(ctx, sum))
+	}
+
+	// Backends that do not keep history dropped the references Put replaced.
+	after, err := c.refs(ctx, key)
+	if err != nil {
+		return err
+	}
+	kept := map[string]int{}
+	for _, ref := range after {
+		kept[ref]++
+	}
+	var errs []error
+	for _, ref := range before {
+		if kept[ref] > 0 {
+			kept[ref]--
+			continue
+		}
+		errs = append(errs, c.release(ctx, ref))
+	}
+	return errors.Join(errs...)
+}
+
+// Read implements libstore.Ops, reading the blob the latest entry refers to.
+func (c casOps) Read(ctx context.Context, key string) ([]byte, error) {
+	ref, err := c.ops.Read(ctx, casKeyPrefix+key)
+	if err != nil {
+		return nil, err
+	}
+	sum, err := parseCASRef(key, ref)
+	if err != nil {
+		return nil, err
+	}
+	return c.ops.Read(ctx, casBlobPrefix+sum)
+}
+
+// ReadAll implements libstore.Ops, reading the blobs the entries refer to.
+func (c casOps) ReadAll(ctx context.Context, key string) ([][]byte, error) {
+	refs, err := c.refs(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([][]byte, len(refs))
+	for i, sum := range refs {
+		if entries[i], err = c.ops.Read(ctx, casBlobPrefix+sum); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// Delete implements libstore.Ops, collecting the blobs no other entry refers to.
+func (c casOps) Delete(ctx context.Context, key string) error {
+	defer c.lock(c.keyLocks, key)()
+
+	refs, err := c.refs(ctx, key)
+	if err != nil {
+		return err
+	}
+	if err := c.ops.Delete(ctx, casKeyPrefix+key); err != nil {
+		return err
+	}
+	var errs []error
+	for _, sum := range refs {
+		errs = append(errs, c.release(ctx, sum))
+	}
+	return errors.Join(errs...)
+}
+
+// List implements libstore.Ops, listing the logical keys.
+func (c casOps) List(ctx context.Context) ([]string, error) {
+	keys, err := c.ops.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	logical := []string{}
+	for _, key := range keys {
+		if key, ok := strings.CutPrefix(key, casKeyPrefix); ok {
+			logical = append(logical, key)
+		}
+	}
+	return logical, nil
+}
+
+// refs returns the checksums the entries of key refer to, oldest first.
+func (c casOps) refs(ctx context.Context, key string) ([]string, error) {
+	entries, err := c.ops.ReadAll(ctx, casKeyPrefix+key)
+	if err != nil {
+		return nil, err
+	}
+	refs := make([]string, len(entries))
+	for i, entry := range entries {
+		if refs[i], err = parseCASRef(key, entry); err != nil {
+			return nil, err
+		}
+	}
+	return refs, nil
+}
+
+// retain counts one more reference to the blob of sum, storing entry as the blob if
+// it does not exist yet.
+func (c casOps) retain(ctx context.Context, sum string, entry []byte) error {
+	defer c.lock(c.hashLocks, sum)()
+
+	if _, err := CreateIfNotExists(ctx, c.ops, casRefPrefix+sum); err != nil {
+		return err
+	}
+	if _, err := Increment(ctx, c.ops, casRefPrefix+sum, 1); err != nil {
+		return err
+	}
+	if err := c.storeBlob(ctx, sum, entry); err != nil {
+		_, undoErr := Increment(ctx, c.ops, casRefPrefix+sum, -1)
+		return errors.Join(err, undoErr)
+	}
+	return nil
+}
+
+// storeBlob stores entry as the blob of sum unless it is already stored. The caller
+// must hold the lock of sum.
+func (c casOps) storeBlob(ctx context.Context, sum string, entry []byte) error {
+	created, err := CreateIfNotExists(ctx, c.ops, casBlobPrefix+sum)
+	if err != nil {
+		return err
+	}
+	if !created {
+		// A Put interrupted between creating the blob and writing it left it empty.
+		_, err := c.ops.Read(ctx, casBlobPrefix+sum)
+		var entryErr EntryError
+		if err == nil || !errors.As(err, &entryErr) {
+			return err
+		}
+	}
+	return c.ops.Put(ctx, casBlobPrefix+sum, entry)
+}
+
+// release counts one reference less to the blob of sum, deleting the blob and its
+// counter once none is left.
+func (c casOps) release(ctx context.Context, sum string) error {
+	defer c.lock(c.hashLocks, sum)()
+
+	refs, err := Increment(ctx, c.ops, casRefPrefix+sum, -1)
+	if err != nil {
+		return err
+	}
+	if refs > 0 {
+		return nil
+	}
+	return errors.Join(c.ops.Delete(ctx, casBlobPrefix+sum), c.ops.Delete(ctx, casRefPrefix+sum))
+}
+
+// parseCASRef returns the checksum the entry of a logical key refers to.
+func parseCASRef(key string, ref []byte) (string, error) {
+	sum, ok := strings.CutPrefix(string(ref), casRefScheme)
+	if !ok {
+		return "", EntryError("cas: entry of key " + key + " is not a reference")
+	}
+	return sum, nil
+}
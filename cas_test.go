@@ -0,0 +1,122 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+// blobs lists the blobs stored in backend by NewCASOps.
+func blobs(t *testing.T, backend libstore.Ops) []string {
+	t.Helper()
+	keys, err := backend.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var blobs []string
+	for _, key := range keys {
+		if strings.HasPrefix(key, "blob/") {
+			blobs = append(blobs, key)
+		}
+	}
+	return blobs
+}
+
+func TestCASOps(t *testing.T) {
+	backends := testBackends(t, "InMemory", "File")
+	for name, newOps := range backends {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			backend := newOps(t)
+			ops := libstore.NewCASOps(backend)
+			template := []byte("shared template")
+			for _, key := range []string{"a", "b"} {
+				if err := ops.Create(ctx, key); err != nil {
+					t.Fatal(err)
+				}
+				if err := ops.Put(ctx, key, template); err != nil {
+					t.Fatalf("Error putting entry: %v", err)
+				}
+			}
+			if got := blobs(t, backend); len(got) != 1 {
+				t.Errorf("Expected one blob for identical entries, Got: %v", got)
+			}
+			if keys, err := ops.List(ctx); err != nil || !slices.Equal(keys, []string{"a", "b"}) {
+				t.Errorf("Expected the logical keys a and b, Got: %v, %v", keys, err)
+			}
+
+			if err := ops.Delete(ctx, "a"); err != nil {
+				t.Fatalf("Error deleting key: %v", err)
+			}
+			if value, err := ops.Read(ctx, "b"); err != nil || string(value) != string(template) {
+				t.Errorf("Expected the shared blob to survive, Got: %q, %v", value, err)
+			}
+			if err := ops.Delete(ctx, "b"); err != nil {
+				t.Fatalf("Error deleting key: %v", err)
+			}
+			if keys, err := backend.List(ctx); err != nil || len(keys) != 0 {
+				t.Errorf("Expected every blob and counter to be collected, Got: %v, %v", keys, err)
+			}
+
+			var notFound libstore.KeyNotFoundError
+			if err := ops.Put(ctx, "missing", template); !errors.As(err, &notFound) {
+				t.Errorf("Expected a KeyNotFoundError for a missing key, Got: %v", err)
+			}
+			if got := blobs(t, backend); len(got) != 0 {
+				t.Errorf("Expected no blob for a failed Put, Got: %v", got)
+			}
+		})
+	}
+}
+
+func TestCASOpsReplacedEntries(t *testing.T) {
+	ctx := context.Background()
+	// Put replaces the entries of InMemoryOps, releasing the blob of the old one.
+	backend := libstore.NewInMemoryOps()
+	ops := libstore.NewCASOps(backend)
+	if err := ops.Create(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range []string{"first", "second"} {
+		if err := ops.Put(ctx, "key", []byte(entry)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := blobs(t, backend), []string{"blob/" + libstore.Checksum([]byte("second"))}; !slices.Equal(got, want) {
+		t.Errorf("Expected only the blob of the latest entry, Got: %v", got)
+	}
+	if value, err := ops.Read(ctx, "key"); err != nil || string(value) != "second" {
+		t.Errorf("Expected the latest entry, Got: %q, %v", value, err)
+	}
+
+	// Files keep every entry, so both blobs stay referenced.
+	files, err := libstore.NewFileOps(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops = libstore.NewCASOps(files)
+	if err := ops.Create(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range []string{"first", "second", "first"} {
+		if err := ops.Put(ctx, "key", []byte(entry)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := ops.ReadAll(ctx, "key")
+	if want := [][]byte{[]byte("first"), []byte("second"), []byte("first")}; err != nil || !slices.EqualFunc(entries, want, slices.Equal) {
+		t.Errorf("Expected every entry, Got: %q, %v", entries, err)
+	}
+	if got := blobs(t, files); len(got) != 2 {
+		t.Errorf("Expected two blobs, Got: %v", got)
+	}
+
+	var unsupported libstore.UnsupportedError
+	if err := libstore.NewCASOps(libstore.NewNullOps()).Put(ctx, "key", []byte("entry")); !errors.As(err, &unsupported) {
+		t.Errorf("Expected an UnsupportedError without Incrementer, Got: %v", err)
+	}
+}
@@ -0,0 +1,214 @@
+package libstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// cassandraIdentifier matches the unquoted keyspace and table names NewCassandraOps
+// accepts, since they cannot be passed as bind parameters.
+var cassandraIdentifier = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{0,47}$`)
+
+// cassandraOps stores keys in a Cassandra or ScyllaDB table.
+type cassandraOps struct {
+	session *gocql.Session
+	entries string
+	keys    string
+}
+
+// NewCassandraOps initializes an Ops backed by Cassandra or ScyllaDB, for append-heavy
+// workloads that need writes to scale across a cluster.
+//
+// Parameters:
+//   - session: An open gocql session.
+//   - keyspace: The existing keyspace holding the tables.
+//   - table: The name of the entries table; keys are tracked in table_keys.
+//
+// Returns:
+//   - An Ops storing every key as one partition of the entries table.
+//   - A LocationError if keyspace or table is not a plain identifier, or if the
+//     tables cannot be created.
+//
+// Entries are clustered by a timeuuid version, so concurrent Puts never contend for a
+// version number, and Read fetches the newest row of the partition. Entries written
+// by clients with skewed clocks are ordered by their writer's clock.
+//
+// Note:
+// Cassandra cannot list the partition keys of a table without scanning every node,
+// so keys are also recorded in the table_keys table, which Create and Delete maintain.
+// List still scans that table in full; its cost grows with the number of keys, not
+// with the number of entries. Put checks that the key exists before writing, so a Put
+// racing with a Delete may leave entries behind for a key that is no longer listed.
+func NewCassandraOps(session *gocql.Session, keyspace, table string) (Ops, error) {
+	for _, name := range []string{keyspace, table} {
+		if !cassandraIdentifier.MatchString(name) {
+			return nil, LocationError(fmt.Sprintf("cassandra: invalid identifier %q", name))
+		}
+	}
+	c := cassandraOps{
+		session: session,
+		entries: keyspace + "." + table,
+		keys:    keyspace + "." + table + "_keys",
+	}
+
+	for _, stmt := range []string{
+		`CREATE TABLE IF NOT EXISTS ` + c.entries + ` (
+			key text,
+			version timeuuid,
+			value blob,
+			PRIMARY KEY (key, version)
+		) WITH CLUSTERING ORDER BY (version DESC)`,
+		`CREATE TABLE IF NOT EXISTS ` + c.keys + ` (
+			key text PRIMARY KEY,
+			created_at timestamp
+		)`,
+	} {
+		if err := session.Query(stmt).Exec(); err != nil {
+			return nil, fmt.Errorf("%w: %w", LocationError("cassandra: failed to create table"), err)
+		}
+	}
+	return c, nil
+}
+
+// cassandraError wraps an error returned by Cassandra in a BackendError carrying its
+// protocol error code.
+func cassandraError(op string, err error) error {
+	e := &BackendError{Backend: "cassandra", Op: op, Err: err}
+	var reqErr gocql.RequestError
+	if errors.As(err, &reqErr) {
+		e.Code = fmt.Sprintf("0x%04X", reqErr.Code())
+	}
+	return annotateTimeout(op, e)
+}
+
+// exists reports whether key has been created.
+func (c cassandraOps) exists(ctx context.Context, key string) (bool, error) {
+	var found string
+	err := c.session.Query(`SELECT key FROM `+c.keys+` WHERE key = ?`, key).WithContext(ctx).Scan(&found)
+	if errors.Is(err, gocql.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, cassandraError("failed to check if key exists", err)
+	}
+	return true, nil
+}
+
+// Create implements Ops. The key is registered with a lightweight transaction, so
+// concurrent Creates of the same key cannot both succeed.
+func (c cassandraOps) Create(ctx context.Context, key string) error {
+	applied, err := c.session.Query(`INSERT INTO `+c.keys+` (key, created_at) VALUES (?, ?) IF NOT EXISTS`, key, time.Now()).
+		WithContext(ctx).MapScanCAS(map[string]any{})
+	if err != nil {
+		return cassandraError("failed to create key", err)
+	}
+	if !applied {
+		return KeyError("key already exists: " + key)
+	}
+	return nil
+}
+
+// ReadAll implements Ops.
+func (c cassandraOps) ReadAll(ctx context.Context, key string) ([][]byte, error) {
+	iter := c.session.Query(`SELECT value FROM `+c.entries+` WHERE key = ? ORDER BY version ASC`, key).WithContext(ctx).Iter()
+	values := [][]byte{}
+	var value []byte
+	for iter.Scan(&value) {
+		values = append(values, value)
+		value = nil
+	}
+	if err := iter.Close(); err != nil {
+		return nil, cassandraError("failed to read whole content", err)
+	}
+	if len(values) == 0 {
+		exists, err := c.exists(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, KeyNotFoundError("key not found: " + key)
+		}
+	}
+	return values, nil
+}
+
+// Read implements Ops.
+func (c cassandraOps) Read(ctx context.Context, key string) ([]byte, error) {
+	var value []byte
+	err := c.session.Query(`SELECT value FROM `+c.entries+` WHERE key = ? LIMIT 1`, key).WithContext(ctx).Scan(&value)
+	if errors.Is(err, gocql.ErrNotFound) {
+		exists, err := c.exists(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, KeyNotFoundError("key not found: " + key)
+		}
+		return nil, EntryError("no entries found for key: " + key)
+	}
+	if err != nil {
+		return nil, cassandraError("failed to read last entry", err)
+	}
+	return value, nil
+}
+
+// Put implements Ops. It appends entry as a new version of the key.
+func (c cassandraOps) Put(ctx context.Context, key string, entry []byte) error {
+	exists, err := c.exists(ctx, key)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return KeyNotFoundError("key not found: " + key)
+	}
+	if entry == nil {
+		entry = []byte{}
+	}
+	err = c.session.Query(`INSERT INTO `+c.entries+` (key, version, value) VALUES (?, ?, ?)`, key, gocql.TimeUUID(), entry).WithContext(ctx).Exec()
+	if err != nil {
+		return cassandraError("failed to insert entry", err)
+	}
+	return nil
+}
+
+// Delete implements Ops. The partition and the key are removed in one logged batch.
+func (c cassandraOps) Delete(ctx context.Context, key string) error {
+	exists, err := c.exists(ctx, key)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return KeyNotFoundError("key not found: " + key)
+	}
+	batch := c.session.NewBatch(gocql.LoggedBatch).WithContext(ctx)
+	batch.Query(`DELETE FROM `+c.entries+` WHERE key = ?`, key)
+	batch.Query(`DELETE FROM `+c.keys+` WHERE key = ?`, key)
+	if err := c.session.ExecuteBatch(batch); err != nil {
+		return cassandraError("failed to delete key", err)
+	}
+	return nil
+}
+
+// List implements Ops. It scans the keys table, page by page, and sorts the keys, as
+// the table returns them in token order.
+func (c cassandraOps) List(ctx context.Context) ([]string, error) {
+	iter := c.session.Query(`SELECT key FROM ` + c.keys).WithContext(ctx).Iter()
+	keys := []string{}
+	var key string
+	for iter.Scan(&key) {
+		keys = append(keys, key)
+	}
+	if err := iter.Close(); err != nil {
+		return nil, cassandraError("failed to list keys", err)
+	}
+	slices.Sort(keys)
+	return keys, nil
+}
+
+var _ Ops = cassandraOps{}
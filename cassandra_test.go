@@ -0,0 +1,56 @@
+package libstore_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cecmp/libstore"
+	"github.com/cecmp/libstore/libstoretest"
+	"github.com/gocql/gocql"
+)
+
+// newTestCassandraOps connects to the Cassandra or ScyllaDB hosts listed, comma
+// separated, in LIBSTORE_TEST_CASSANDRA, skipping the test when it is not set. Every
+// call uses fresh tables in the libstore_test keyspace.
+func newTestCassandraOps(t *testing.T) libstore.Ops {
+	t.Helper()
+	hosts := os.Getenv("LIBSTORE_TEST_CASSANDRA")
+	if hosts == "" {
+		t.Skip("LIBSTORE_TEST_CASSANDRA not set")
+	}
+	cluster := gocql.NewCluster(strings.Split(hosts, ",")...)
+	session, err := cluster.CreateSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(session.Close)
+	err = session.Query(`CREATE KEYSPACE IF NOT EXISTS libstore_test
+		WITH replication = {'class': 'SimpleStrategy', 'replication_factor': 1}`).Exec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops, err := libstore.NewCassandraOps(session, "libstore_test", fmt.Sprintf("files_%d", time.Now().UnixNano()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ops
+}
+
+func TestCassandraOpsConformance(t *testing.T) {
+	ops := newTestCassandraOps(t)
+	libstoretest.RunConformance(t, func() libstore.Ops { return ops })
+}
+
+func TestCassandraOpsRejectsInvalidIdentifiers(t *testing.T) {
+	for _, name := range [][2]string{{"ks; DROP", "files"}, {"ks", "files-1"}, {"", "files"}} {
+		_, err := libstore.NewCassandraOps(nil, name[0], name[1])
+		var locationErr libstore.LocationError
+		if !errors.As(err, &locationErr) {
+			t.Errorf("Expected a LocationError for %q, Got: %v", name, err)
+		}
+	}
+}
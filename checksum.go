@@ -0,0 +1,45 @@
+package libstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Checksum returns the hex-encoded SHA-256 digest identifying the content of an entry.
+func Checksum(entry []byte) string {
+	sum := sha256.Sum256(entry)
+	return hex.EncodeToString(sum[:])
+}
+
+// ChecksumReader is implemented by backends that can look up an entry by its checksum
+// without scanning the whole history of the key.
+type ChecksumReader interface {
+	// ReadByChecksum returns the latest version of key whose Checksum equals checksum.
+	ReadByChecksum(ctx context.Context, key string, checksum string) ([]byte, error)
+}
+
+// ReadByChecksum returns the latest version of key whose Checksum equals checksum.
+//
+// Backends implementing ChecksumReader answer directly; for the others every entry of
+// the key is read and hashed. It returns an EntryError if no version matches.
+func ReadByChecksum(ctx context.Context, ops Ops, key string, checksum string) ([]byte, error) {
+	if reader, ok := ops.(ChecksumReader); ok {
+		return reader.ReadByChecksum(ctx, key, checksum)
+	}
+	entries, err := ops.ReadAll(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return matchChecksum(entries, key, checksum)
+}
+
+// matchChecksum returns the last of entries whose Checksum equals checksum.
+func matchChecksum(entries [][]byte, key string, checksum string) ([]byte, error) {
+	for i := len(entries) - 1; i >= 0; i-- {
+		if Checksum(entries[i]) == checksum {
+			return entries[i], nil
+		}
+	}
+	return nil, EntryError("no entry with checksum " + checksum + " found for key: " + key)
+}
@@ -0,0 +1,47 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestChecksum(t *testing.T) {
+	if got := libstore.Checksum(nil); got != "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" {
+		t.Errorf("Expected the SHA-256 of no bytes, Got: %s", got)
+	}
+}
+
+func TestReadByChecksum(t *testing.T) {
+	for name, newOps := range testBackends(t, "File", "DB") {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			ops := newOps(t)
+			key := filepath.Base(testKey(t))
+			if err := ops.Create(ctx, key); err != nil {
+				t.Fatal(err)
+			}
+			for _, entry := range []string{"v1", "v2", "v3"} {
+				if err := ops.Put(ctx, key, []byte(entry)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			entry, err := libstore.ReadByChecksum(ctx, ops, key, libstore.Checksum([]byte("v2")))
+			if err != nil || string(entry) != "v2" {
+				t.Errorf("Expected the entry matching the checksum, Got: %q, %v", entry, err)
+			}
+			var entryErr libstore.EntryError
+			if _, err := libstore.ReadByChecksum(ctx, ops, key, libstore.Checksum([]byte("v4"))); !errors.As(err, &entryErr) {
+				t.Errorf("Expected an EntryError for a checksum no entry has, Got: %v", err)
+			}
+			var notFound libstore.KeyNotFoundError
+			if _, err := libstore.ReadByChecksum(ctx, ops, key+"-missing", libstore.Checksum([]byte("v1"))); !errors.As(err, &notFound) {
+				t.Errorf("Expected a KeyNotFoundError for a missing key, Got: %v", err)
+			}
+		})
+	}
+}
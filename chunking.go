@@ -0,0 +1,223 @@
+package libstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// FlagChunked is the format header flag marking a chunk manifest written by
+// NewChunkingOps in place of an entry too large for one backend value.
+const FlagChunked uint8 = 1 << 1
+
+// chunkKeyMarker separates a key from the name of one of its chunks. It is made of
+// characters every backend accepts in keys.
+const chunkKeyMarker = ".libstore-chunk."
+
+// chunkManifest describes an entry stored in chunks.
+type chunkManifest struct {
+	// Checksum is the Checksum of the whole entry. Its prefix names the chunks.
+	Checksum string `json:"checksum"`
+	// Chunks is the number of chunks.
+	Chunks int `json:"chunks"`
+	// Size is the length of the whole entry in bytes.
+	Size int `json:"size"`
+}
+
+// chunkKey returns the key storing chunk i of the entry m describes.
+func (m chunkManifest) chunkKey(key string, i int) string {
+	return fmt.Sprintf("%s%s%s.%d", key, chunkKeyMarker, m.Checksum[:16], i)
+}
+
+// chunkingOps splits entries larger than chunkSize across several keys.
+type chunkingOps struct {
+	ops       Ops
+	chunkSize int
+}
+
+// NewChunkingOps wraps ops so that entries of any size can be stored on backends
+// capping the size of a value. An entry that does not fit in chunkSize bytes, along
+// with its format header, is split into chunks of chunkSize bytes, each stored as
+// the only entry of a derived key, and the key itself receives a small manifest
+// recording the chunk count, total size and checksum of the entry. Read and ReadAll
+// reassemble the chunks transparently and return an EntryError if they do not match
+// their manifest.
+//
+// Chunks are written before the manifest, so a failed Put leaves unreferenced chunks
+// but never a manifest missing them. Delete removes the key and then the chunks of
+// all its entries. List leaves chunk keys out.
+//
+// chunkSize must leave room for the manifest, which takes about 130 bytes. Every
+// entry is stored with a format header; entries written without one are read back
+// unchanged. On backends whose Put replaces the previous entry, such as S3Ops, the
+// chunks of a replaced entry are left behind.
+func NewChunkingOps(ops Ops, chunkSize int) Ops {
+	return chunkingOps{ops: ops, chunkSize: max(chunkSize, 1)}
+}
+
+// Unwrap implements Unwrapper.
+func (c chunkingOps) Unwrap() Ops {
+	return c.ops
+}
+
+// manifestOf decodes entry, returning the manifest it holds or, reporting false, the
+// payload of an entry that is not a manifest.
+func manifestOf(entry []byte) (chunkManifest, []byte, bool, error) {
+	payload, info, err := DecodeFormat(entry)
+	if err != nil || info.Flags&FlagChunked == 0 {
+		return chunkManifest{}, payload, false, err
+	}
+	var m chunkManifest
+	if err := json.Unmarshal(payload, &m); err != nil {
+		return chunkManifest{}, nil, false, fmt.Errorf("%w: %w", EntryError("chunking: invalid manifest"), err)
+	}
+	if len(m.Checksum) < 16 || m.Chunks < 1 || m.Size < 0 {
+		return chunkManifest{}, nil, false, EntryError("chunking: invalid manifest")
+	}
+	return m, nil, true, nil
+}
+
+// decode returns the entry stored as entry, reading its chunks if it is a manifest.
+func (c chunkingOps) decode(ctx context.Context, key string, entry []byte) ([]byte, error) {
+	m, payload, chunked, err := manifestOf(entry)
+	if err != nil || !chunked {
+		return payload, err
+	}
+	whole := make([]byte, 0, m.Size)
+	for i := range m.Chunks {
+		chunk, err := c.ops.Read(ctx, m.chunkKey(key, i))
+		var notFound KeyNotFoundError
+		if errors.As(err, &notFound) {
+			return nil, fmt.Errorf("%w: %w", EntryError(fmt.Sprintf("chunking: chunk %d of key %s is missing", i, key)), err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		whole = append(whole, chunk...)
+	}
+	if len(whole) != m.Size {
+		return nil, EntryError(fmt.Sprintf("chunking: key %s holds %d bytes in chunks, manifest records %d", key, len(whole), m.Size))
+	}
+	if Checksum(whole) != m.Checksum {
+		return nil, EntryError("chunking: checksum mismatch for key " + key)
+	}
+	return whole, nil
+}
+
+// Create implements Ops.
+func (c chunkingOps) Create(ctx context.Context, key string) error {
+	return c.ops.Create(ctx, key)
+}
+
+// ReadAll implements Ops.
+func (c chunkingOps) ReadAll(ctx context.Context, key string) ([][]byte, error) {
+	entries, err := c.ops.ReadAll(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	// Some backends return their own slice, which must not be overwritten.
+	decoded := make([][]byte, len(entries))
+	for i, entry := range entries {
+		if decoded[i], err = c.decode(ctx, key, entry); err != nil {
+			return nil, err
+		}
+	}
+	return decoded, nil
+}
+
+// Read implements Ops.
+func (c chunkingOps) Read(ctx context.Context, key string) ([]byte, error) {
+	entry, err := c.ops.Read(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return c.decode(ctx, key, entry)
+}
+
+// Put implements Ops.
+func (c chunkingOps) Put(ctx context.Context, key string, entry []byte) error {
+	if formatHeaderLen+len(entry) <= c.chunkSize {
+		return c.ops.Put(ctx, key, EncodeFormat(0, entry))
+	}
+	// Check that key exists, so a Put that will fail does not leave chunks behind.
+	if _, err := c.ops.Read(ctx, key); err != nil {
+		var entryErr EntryError
+		if !errors.As(err, &entryErr) {
+			return err
+		}
+	}
+
+	m := chunkManifest{
+		Checksum: Checksum(entry),
+		Chunks:   (len(entry) + c.chunkSize - 1) / c.chunkSize,
+		Size:     len(entry),
+	}
+	for i := range m.Chunks {
+		chunk := entry[i*c.chunkSize : min((i+1)*c.chunkSize, len(entry))]
+		chunkKey := m.chunkKey(key, i)
+		if _, err := CreateIfNotExists(ctx, c.ops, chunkKey); err != nil {
+			return err
+		}
+		if err := c.ops.Put(ctx, chunkKey, chunk); err != nil {
+			return err
+		}
+	}
+	manifest, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("%w: %w", OpsInternalError("chunking: failed to encode manifest"), err)
+	}
+	return c.ops.Put(ctx, key, EncodeFormat(FlagChunked, manifest))
+}
+
+// Delete implements Ops.
+func (c chunkingOps) Delete(ctx context.Context, key string) error {
+	entries, err := c.ops.ReadAll(ctx, key)
+	if err != nil {
+		return err
+	}
+	if err := c.ops.Delete(ctx, key); err != nil {
+		return err
+	}
+	deleted := map[string]bool{}
+	for _, entry := range entries {
+		m, _, chunked, err := manifestOf(entry)
+		if err != nil || !chunked {
+			continue
+		}
+		for i := range m.Chunks {
+			chunkKey := m.chunkKey(key, i)
+			if deleted[chunkKey] {
+				continue
+			}
+			deleted[chunkKey] = true
+			err := c.ops.Delete(ctx, chunkKey)
+			var notFound KeyNotFoundError
+			if err != nil && !errors.As(err, &notFound) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// List implements Ops, leaving out the keys holding chunks.
+func (c chunkingOps) List(ctx context.Context) ([]string, error) {
+	stored, err := c.ops.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(stored))
+	for _, key := range stored {
+		if !strings.Contains(key, chunkKeyMarker) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+var (
+	_ Ops       = chunkingOps{}
+	_ Unwrapper = chunkingOps{}
+)
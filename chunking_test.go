@@ -0,0 +1,98 @@
+package libstore_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+// sizeLimitOps rejects entries larger than limit, like a backend capping value sizes.
+type sizeLimitOps struct {
+	libstore.Ops
+	limit int
+}
+
+func (s sizeLimitOps) Put(ctx context.Context, key string, entry []byte) error {
+	if len(entry) > s.limit {
+		return libstore.EntryError(fmt.Sprintf("entry of %d bytes exceeds the limit of %d", len(entry), s.limit))
+	}
+	return s.Ops.Put(ctx, key, entry)
+}
+
+func TestChunkingOps(t *testing.T) {
+	const chunkSize = 256
+	ctx := context.Background()
+	for _, size := range []int{0, 10, chunkSize - 6, chunkSize, 3 * chunkSize, 3*chunkSize + 1, 10*chunkSize - 1} {
+		t.Run(fmt.Sprint(size), func(t *testing.T) {
+			backend := libstore.NewInMemoryOps()
+			ops := libstore.NewChunkingOps(sizeLimitOps{Ops: backend, limit: chunkSize}, chunkSize)
+			entry := bytes.Repeat([]byte("0123456789abcdef"), size/16+1)[:size]
+
+			if err := ops.Create(ctx, "key"); err != nil {
+				t.Fatal(err)
+			}
+			if err := ops.Put(ctx, "key", entry); err != nil {
+				t.Fatalf("Put() error = %v", err)
+			}
+			got, err := ops.Read(ctx, "key")
+			if err != nil || !bytes.Equal(got, entry) {
+				t.Errorf("Read() = %d bytes, %v, want the %d bytes put", len(got), err, size)
+			}
+			entries, err := ops.ReadAll(ctx, "key")
+			if err != nil || len(entries) != 1 || !bytes.Equal(entries[0], entry) {
+				t.Errorf("ReadAll() = %d entries, %v, want the entry put", len(entries), err)
+			}
+
+			if keys, err := ops.List(ctx); err != nil || !slices.Equal(keys, []string{"key"}) {
+				t.Errorf("List() = %q, %v, want only the key", keys, err)
+			}
+			if err := ops.Delete(ctx, "key"); err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+			if keys, _ := backend.List(ctx); len(keys) != 0 {
+				t.Errorf("Expected Delete to remove every chunk, Got: %q", keys)
+			}
+		})
+	}
+}
+
+func TestChunkingOpsCorruptChunk(t *testing.T) {
+	ctx := context.Background()
+	backend := libstore.NewInMemoryOps()
+	ops := libstore.NewChunkingOps(backend, 256)
+	if err := ops.Create(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ops.Put(ctx, "key", bytes.Repeat([]byte("x"), 1000)); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := backend.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := slices.IndexFunc(keys, func(key string) bool { return strings.HasPrefix(key, "key.") })
+	if i < 0 {
+		t.Fatalf("Expected chunk keys in the backend, Got: %q", keys)
+	}
+	if err := backend.Put(ctx, keys[i], bytes.Repeat([]byte("y"), 256)); err != nil {
+		t.Fatal(err)
+	}
+	var entryErr libstore.EntryError
+	if _, err := ops.Read(ctx, "key"); !errors.As(err, &entryErr) {
+		t.Errorf("Read() of a corrupt chunk error = %v, want EntryError", err)
+	}
+
+	if err := backend.Delete(ctx, keys[i]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ops.Read(ctx, "key"); !errors.As(err, &entryErr) {
+		t.Errorf("Read() of a missing chunk error = %v, want EntryError", err)
+	}
+}
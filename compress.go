@@ -0,0 +1,168 @@
+package libstore
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+)
+
+// FlagCompressed is the format header flag marking a DEFLATE-compressed payload.
+const FlagCompressed uint8 = 1 << 0
+
+// FlagBase64 is the format header flag marking a base64-encoded payload.
+const FlagBase64 uint8 = 1 << 2
+
+// compressStore compresses entries on their way to the underlying Ops.
+type compressStore struct {
+	ops       Ops
+	threshold int
+	level     int
+}
+
+// CompressOption configures NewCompressStore.
+type CompressOption func(*compressStore)
+
+// WithCompressThreshold stores entries shorter than threshold bytes uncompressed.
+// Compressing small entries costs CPU and often makes them larger.
+func WithCompressThreshold(threshold int) CompressOption {
+	return func(c *compressStore) {
+		c.threshold = threshold
+	}
+}
+
+// WithCompressLevel sets the DEFLATE compression level, flate.DefaultCompression by default.
+func WithCompressLevel(level int) CompressOption {
+	return func(c *compressStore) {
+		c.level = level
+	}
+}
+
+// NewCompressStore wraps ops so that entries are compressed with DEFLATE before they
+// are stored and decompressed when they are read.
+//
+// Every stored entry carries a format header whose FlagCompressed bit tells whether
+// its payload is compressed. Entries below the threshold set by WithCompressThreshold,
+// and entries that would not shrink, are stored uncompressed. A compressed payload
+// holding a line feed or carriage return, which backends separating entries by lines,
+// such as the file backend, would split, is stored base64-encoded with the FlagBase64
+// bit set, and counts as shrinking only if it still does once encoded. Entries written without
+// a header, for instance before compression was enabled, are read back unchanged,
+// unless they are gzip or zstd entries, which are decompressed as by Decompress.
+func NewCompressStore(ops Ops, opts ...CompressOption) (Ops, error) {
+	c := compressStore{ops: ops, level: flate.DefaultCompression}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if c.level < flate.HuffmanOnly || c.level > flate.BestCompression {
+		return nil, OpsInternalError(fmt.Sprintf("compress: invalid level %d", c.level))
+	}
+	return c, nil
+}
+
+// Unwrap implements Unwrapper.
+func (c compressStore) Unwrap() Ops {
+	return c.ops
+}
+
+func (c compressStore) encode(entry []byte) ([]byte, error) {
+	if len(entry) < c.threshold {
+		return EncodeFormat(0, entry), nil
+	}
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, c.level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(entry); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	payload, flags := buf.Bytes(), FlagCompressed
+	if bytes.ContainsAny(payload, "\r\n") {
+		payload, flags = base64.StdEncoding.AppendEncode(nil, payload), flags|FlagBase64
+	}
+	if len(payload) >= len(entry) {
+		return EncodeFormat(0, entry), nil
+	}
+	return EncodeFormat(flags, payload), nil
+}
+
+func (c compressStore) decode(entry []byte) ([]byte, error) {
+	payload, info, err := DecodeFormat(entry)
+	if err != nil {
+		return nil, err
+	}
+	if info.Version == 0 {
+		return Decompress(payload)
+	}
+	if info.Flags&FlagCompressed == 0 {
+		return payload, nil
+	}
+	if info.Flags&FlagBase64 != 0 {
+		if payload, err = base64.StdEncoding.AppendDecode(nil, payload); err != nil {
+			return nil, fmt.Errorf("%w: %w", EntryError("compress: failed to decode entry"), err)
+		}
+	}
+	plain, err := io.ReadAll(flate.NewReader(bytes.NewReader(payload)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", EntryError("compress: failed to decompress entry"), err)
+	}
+	return plain, nil
+}
+
+// Create implements Ops.
+func (c compressStore) Create(ctx context.Context, key string) error {
+	return c.ops.Create(ctx, key)
+}
+
+// ReadAll implements Ops.
+func (c compressStore) ReadAll(ctx context.Context, key string) ([][]byte, error) {
+	entries, err := c.ops.ReadAll(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	for i, entry := range entries {
+		if entries[i], err = c.decode(entry); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// Read implements Ops.
+func (c compressStore) Read(ctx context.Context, key string) ([]byte, error) {
+	entry, err := c.ops.Read(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return c.decode(entry)
+}
+
+// Put implements Ops.
+func (c compressStore) Put(ctx context.Context, key string, entry []byte) error {
+	stored, err := c.encode(entry)
+	if err != nil {
+		return fmt.Errorf("%w: %w", OpsInternalError("compress: failed to compress entry"), err)
+	}
+	return c.ops.Put(ctx, key, stored)
+}
+
+// Delete implements Ops.
+func (c compressStore) Delete(ctx context.Context, key string) error {
+	return c.ops.Delete(ctx, key)
+}
+
+// List implements Ops.
+func (c compressStore) List(ctx context.Context) ([]string, error) {
+	return c.ops.List(ctx)
+}
+
+var (
+	_ Ops       = compressStore{}
+	_ Unwrapper = compressStore{}
+)
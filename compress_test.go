@@ -0,0 +1,225 @@
+package libstore_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	mathrand "math/rand"
+	"testing"
+
+	"github.com/cecmp/libstore"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestCompressStoreThreshold(t *testing.T) {
+	ctx := context.Background()
+	backend := libstore.NewInMemoryOps()
+	ops, err := libstore.NewCompressStore(backend, libstore.WithCompressThreshold(64))
+	if err != nil {
+		t.Fatalf("Error creating compress store: %v", err)
+	}
+
+	incompressible := make([]byte, 4096)
+	if _, err := rand.Read(incompressible); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name       string
+		entry      []byte
+		compressed bool
+	}{
+		{"Tiny", bytes.Repeat([]byte("a"), 32), false},
+		{"LargeCompressible", bytes.Repeat([]byte("compressible "), 1000), true},
+		{"Incompressible", incompressible, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if err := ops.Create(ctx, c.name); err != nil {
+				t.Fatal(err)
+			}
+			if err := ops.Put(ctx, c.name, c.entry); err != nil {
+				t.Fatalf("Error putting entry: %v", err)
+			}
+			entry, err := ops.Read(ctx, c.name)
+			if err != nil || !bytes.Equal(entry, c.entry) {
+				t.Fatalf("Round trip failed: %v", err)
+			}
+
+			stored, err := backend.Read(ctx, c.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			info, err := libstore.DetectFormat(stored)
+			if err != nil || info.Version == 0 {
+				t.Fatalf("Expected a format header, Got: %+v, %v", info, err)
+			}
+			if compressed := info.Flags&libstore.FlagCompressed != 0; compressed != c.compressed {
+				t.Errorf("Expected compressed to be %t, Got: %t", c.compressed, compressed)
+			}
+			if c.compressed && len(stored) >= len(c.entry) {
+				t.Errorf("Expected the stored entry to shrink, Got: %d of %d bytes", len(stored), len(c.entry))
+			}
+		})
+	}
+}
+
+func TestCompressStoreFileOps(t *testing.T) {
+	ctx := context.Background()
+	backend, err := libstore.NewFileOps(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops, err := libstore.NewCompressStore(backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ops.Create(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Random text compresses, but into bytes that hold line feeds. The text is
+	// seeded, since the compressor may store some random texts uncompressed.
+	var want [][]byte
+	rng := mathrand.New(mathrand.NewSource(1))
+	for range 3 {
+		random := make([]byte, 4096)
+		rng.Read(random)
+		want = append(want, []byte(hex.EncodeToString(random)))
+	}
+	want = append(want, bytes.Repeat([]byte("compressible "), 1000))
+	for _, entry := range want {
+		if err := ops.Put(ctx, "key", entry); err != nil {
+			t.Fatalf("Error putting entry: %v", err)
+		}
+	}
+
+	entries, err := ops.ReadAll(ctx, "key")
+	if err != nil || len(entries) != len(want) {
+		t.Fatalf("Expected %d entries, Got: %d, %v", len(want), len(entries), err)
+	}
+	for i := range want {
+		if !bytes.Equal(entries[i], want[i]) {
+			t.Errorf("Round trip of entry %d failed", i)
+		}
+	}
+	stored, err := backend.ReadAll(ctx, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := libstore.DetectFormat(stored[0])
+	if err != nil || info.Flags != libstore.FlagCompressed|libstore.FlagBase64 {
+		t.Errorf("Expected a compressed, base64-encoded entry, Got: %+v, %v", info, err)
+	}
+	if len(stored[0]) >= len(want[0]) {
+		t.Errorf("Expected the stored entry to shrink, Got: %d of %d bytes", len(stored[0]), len(want[0]))
+	}
+}
+
+func TestCompressStoreReadsLegacyEntries(t *testing.T) {
+	ctx := context.Background()
+	backend, err := libstore.NewFileOps(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.Create(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.Put(ctx, "key", []byte("written before compression")); err != nil {
+		t.Fatal(err)
+	}
+
+	ops, err := libstore.NewCompressStore(backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ops.Put(ctx, "key", bytes.Repeat([]byte("new "), 100)); err != nil {
+		t.Fatalf("Error putting entry: %v", err)
+	}
+	entries, err := ops.ReadAll(ctx, "key")
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("Unexpected entries: %q, %v", entries, err)
+	}
+	if string(entries[0]) != "written before compression" || !bytes.Equal(entries[1], bytes.Repeat([]byte("new "), 100)) {
+		t.Errorf("Unexpected entries: %q", entries)
+	}
+}
+
+func TestDecompressOpsMixedEntries(t *testing.T) {
+	ctx := context.Background()
+	plain := bytes.Repeat([]byte("entry "), 100)
+	var gzipped bytes.Buffer
+	w := gzip.NewWriter(&gzipped)
+	if _, err := w.Write(plain); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	encoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zstded := encoder.EncodeAll(plain, nil)
+
+	// Binary entries would be split on newline-framed backends such as fileOps. The
+	// in-memory backend keeps only the latest entry, so each is read right after it
+	// is put.
+	backend := libstore.NewInMemoryOps()
+	if err := backend.Create(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	ops := libstore.NewDecompressOps(backend)
+	compressed, err := libstore.NewCompressStore(backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, entry := range map[string][]byte{"plain": plain, "gzip": gzipped.Bytes(), "zstd": zstded} {
+		if err := backend.Put(ctx, "key", entry); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := ops.Read(ctx, "key"); err != nil || !bytes.Equal(got, plain) {
+			t.Errorf("Expected the %s entry to read back uncompressed, Got: %q, %v", name, got, err)
+		}
+		if entries, err := ops.ReadAll(ctx, "key"); err != nil || len(entries) != 1 || !bytes.Equal(entries[0], plain) {
+			t.Errorf("Expected ReadAll to return the %s entry uncompressed, Got: %q, %v", name, entries, err)
+		}
+		if got, err := compressed.Read(ctx, "key"); err != nil || !bytes.Equal(got, plain) {
+			t.Errorf("Expected the %s entry to read back uncompressed through the compress store, Got: %q, %v", name, got, err)
+		}
+	}
+	if err := compressed.Put(ctx, "key", plain); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := compressed.Read(ctx, "key"); err != nil || !bytes.Equal(got, plain) {
+		t.Errorf("Expected the compress store to read back its own entry, Got: %q, %v", got, err)
+	}
+
+	if err := backend.Put(ctx, "key", gzipped.Bytes()[:10]); err != nil {
+		t.Fatal(err)
+	}
+	var entryErr libstore.EntryError
+	if _, err := ops.Read(ctx, "key"); !errors.As(err, &entryErr) {
+		t.Errorf("Expected an EntryError for a truncated gzip entry, Got: %v", err)
+	}
+}
+
+func TestDetectCompression(t *testing.T) {
+	cases := map[string]string{
+		"\x1f\x8b\x08":     libstore.CompressionGzip,
+		"\x28\xb5\x2f\xfd": libstore.CompressionZstd,
+		"plain":            "",
+		"\x1f":             "",
+		"":                 "",
+	}
+	for entry, want := range cases {
+		if got := libstore.DetectCompression([]byte(entry)); got != want {
+			t.Errorf("Expected %q for %q, Got: %q", want, entry, got)
+		}
+	}
+	if entry, err := libstore.Decompress([]byte("plain")); err != nil || string(entry) != "plain" {
+		t.Errorf("Expected plain entries to pass through, Got: %q, %v", entry, err)
+	}
+}
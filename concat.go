@@ -0,0 +1,38 @@
+package libstore
+
+import (
+	"bytes"
+	"context"
+)
+
+// ConcatReader is implemented by backends that can return the entries of a key as
+// one blob without materializing every entry first.
+type ConcatReader interface {
+	// ReadConcat returns all entries of key, oldest first, joined with sep.
+	// It returns a KeyNotFoundError if the key does not exist.
+	ReadConcat(ctx context.Context, key string, sep []byte) ([]byte, error)
+}
+
+// ReadConcat returns all entries of key, oldest first, joined with sep, for
+// instance to reconstruct a log. A key without entries yields an empty slice, and
+// a missing key a KeyNotFoundError.
+//
+// Backends implementing ConcatReader build the blob themselves. For the others the
+// entries are read with ReadAll and joined; a key holding a single entry, as S3
+// objects do, is returned without copying.
+func ReadConcat(ctx context.Context, ops Ops, key string, sep []byte) ([]byte, error) {
+	if reader, ok := ops.(ConcatReader); ok {
+		return reader.ReadConcat(ctx, key, sep)
+	}
+	entries, err := ops.ReadAll(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	switch len(entries) {
+	case 0:
+		return []byte{}, nil
+	case 1:
+		return entries[0], nil
+	}
+	return bytes.Join(entries, sep), nil
+}
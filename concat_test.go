@@ -0,0 +1,43 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestReadConcat(t *testing.T) {
+	backends := testBackends(t, "File", "DB")
+	// The in-memory store keeps no history, so the fallback wraps a file store.
+	backends["Fallback"] = func(t *testing.T) libstore.Ops { return newRecordingOps(newTestFileOps(t)) }
+	for name, newOps := range backends {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			ops := newOps(t)
+			key := filepath.Base(testKey(t))
+			if err := ops.Create(ctx, key); err != nil {
+				t.Fatal(err)
+			}
+			if blob, err := libstore.ReadConcat(ctx, ops, key, []byte("\n")); err != nil || len(blob) != 0 {
+				t.Errorf("Expected an empty blob for an empty key, Got: %q, %v", blob, err)
+			}
+			for _, entry := range []string{"one", "two", "three"} {
+				if err := ops.Put(ctx, key, []byte(entry)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for sep, want := range map[string]string{"\n": "one\ntwo\nthree", ", ": "one, two, three", "": "onetwothree"} {
+				if blob, err := libstore.ReadConcat(ctx, ops, key, []byte(sep)); err != nil || string(blob) != want {
+					t.Errorf("Expected %q joining with %q, Got: %q, %v", want, sep, blob, err)
+				}
+			}
+			var notFound libstore.KeyNotFoundError
+			if _, err := libstore.ReadConcat(ctx, ops, key+"-missing", nil); !errors.As(err, &notFound) {
+				t.Errorf("Expected a KeyNotFoundError, Got: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package libstore_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/cecmp/libstore"
+	"github.com/cecmp/libstore/libstoretest"
+)
+
+func TestFileOpsConformance(t *testing.T) {
+	libstoretest.RunConformance(t, func() libstore.Ops {
+		ops, err := libstore.NewFileOps(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ops
+	})
+}
+
+func TestGitOpsConformance(t *testing.T) {
+	libstoretest.RunConformance(t, func() libstore.Ops {
+		ops, err := libstore.NewGitOps(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ops
+	})
+}
+
+func TestLogFileOpsConformance(t *testing.T) {
+	libstoretest.RunConformance(t, func() libstore.Ops {
+		ops, err := libstore.NewLogFileOps(filepath.Join(t.TempDir(), "segment"), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { ops.(*libstore.LogFileOps).Close() })
+		return ops
+	})
+}
+
+func TestDBOpsConformance(t *testing.T) {
+	ops := newTestDBOps(t)
+	libstoretest.RunConformance(t, func() libstore.Ops { return ops })
+}
+
+func TestInMemoryOpsConformance(t *testing.T) {
+	libstoretest.RunConformance(t, func() libstore.Ops { return libstore.NewInMemoryOps() }, libstoretest.PutReplaces())
+}
+
+func TestS3OpsConformance(t *testing.T) {
+	ops := newFakeS3Ops(t)
+	libstoretest.RunConformance(t, func() libstore.Ops { return ops },
+		libstoretest.PutReplaces(), libstoretest.PutCreates(), libstoretest.DeleteMissingSucceeds())
+}
@@ -0,0 +1,108 @@
+package libstore
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// CopyOption configures CopyStore.
+type CopyOption func(*copyOptions)
+
+type copyOptions struct {
+	overwrite bool
+	progress  func(key string, copied int)
+}
+
+// CopyOverwrite makes CopyStore replace keys that already exist in the destination
+// instead of skipping them.
+func CopyOverwrite() CopyOption {
+	return func(o *copyOptions) {
+		o.overwrite = true
+	}
+}
+
+// CopyProgress registers a callback invoked after each key is copied, with the number
+// of keys copied so far. It may be called concurrently from several goroutines.
+func CopyProgress(progress func(key string, copied int)) CopyOption {
+	return func(o *copyOptions) {
+		o.progress = progress
+	}
+}
+
+// CopyStore copies every key of src, with all its entries, into dst.
+//
+// Parameters:
+//   - ctx: Context for managing request lifecycles. Cancelling it stops the copy.
+//   - src: The Ops to copy from. Its keys are streamed via ListSeq.
+//   - dst: The Ops to copy to.
+//   - concurrency: The maximum number of keys copied at the same time.
+//   - opts: Options such as CopyOverwrite and CopyProgress.
+//
+// Keys already present in dst are skipped unless CopyOverwrite is given, in which
+// case they are deleted and copied again. The first error stops the copy and is returned.
+func CopyStore(ctx context.Context, src, dst Ops, concurrency int, opts ...CopyOption) error {
+	var o copyOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(concurrency, 1))
+
+	var mu sync.Mutex
+	copied := 0
+	for key, err := range ListSeq(ctx, src) {
+		if err != nil {
+			// A failed copy cancels ctx, which also ends the listing; report the cause.
+			if werr := g.Wait(); werr != nil {
+				return werr
+			}
+			return err
+		}
+		g.Go(func() error {
+			done, err := copyKey(ctx, src, dst, key, o.overwrite)
+			if err != nil || !done || o.progress == nil {
+				return err
+			}
+			mu.Lock()
+			copied++
+			n := copied
+			mu.Unlock()
+			o.progress(key, n)
+			return nil
+		})
+	}
+	return g.Wait()
+}
+
+// copyKey copies all entries of key from src to dst and reports whether it did.
+func copyKey(ctx context.Context, src, dst Ops, key string, overwrite bool) (bool, error) {
+	created, err := CreateIfNotExists(ctx, dst, key)
+	if err != nil {
+		return false, err
+	}
+	if !created {
+		if !overwrite {
+			return false, nil
+		}
+		if err := dst.Delete(ctx, key); err != nil {
+			return false, err
+		}
+		if err := dst.Create(ctx, key); err != nil {
+			return false, err
+		}
+	}
+
+	entries, err := src.ReadAll(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range entries {
+		if err := dst.Put(ctx, key, entry); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,72 @@
+package libstore_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestCopyStore(t *testing.T) {
+	src, err := libstore.NewFileOps(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := libstore.NewFileOps(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		if err := src.Create(context.TODO(), key); err != nil {
+			t.Fatalf("Error creating key: %v", err)
+		}
+		for _, entry := range []string{key + "1", key + "2"} {
+			if err := src.Put(context.TODO(), key, []byte(entry)); err != nil {
+				t.Fatalf("Error putting entry: %v", err)
+			}
+		}
+	}
+	if err := dst.Create(context.TODO(), "b"); err != nil {
+		t.Fatalf("Error creating key: %v", err)
+	}
+	if err := dst.Put(context.TODO(), "b", []byte("kept")); err != nil {
+		t.Fatalf("Error putting entry: %v", err)
+	}
+
+	var copied []string
+	err = libstore.CopyStore(context.TODO(), src, dst, 1, libstore.CopyProgress(func(key string, n int) {
+		copied = append(copied, key)
+	}))
+	if err != nil {
+		t.Fatalf("Error copying store: %v", err)
+	}
+	if len(copied) != 2 {
+		t.Errorf("Expected 2 copied keys, Got: %v", copied)
+	}
+
+	for key, expected := range map[string][]string{"a": {"a1", "a2"}, "b": {"kept"}, "c": {"c1", "c2"}} {
+		entries, err := dst.ReadAll(context.TODO(), key)
+		if err != nil {
+			t.Fatalf("Error reading key %s: %v", key, err)
+		}
+		if len(entries) != len(expected) {
+			t.Fatalf("Content len mismatch for %s. Expected: %d Got: %d", key, len(expected), len(entries))
+		}
+		for i, entry := range entries {
+			if string(entry) != expected[i] {
+				t.Errorf("Content mismatch for %s. Expected: %s Got: %s", key, expected[i], entry)
+			}
+		}
+	}
+
+	if err := libstore.CopyStore(context.TODO(), src, dst, 4, libstore.CopyOverwrite()); err != nil {
+		t.Fatalf("Error copying store: %v", err)
+	}
+	entries, err := dst.ReadAll(context.TODO(), "b")
+	if err != nil {
+		t.Fatalf("Error reading key: %v", err)
+	}
+	if len(entries) != 2 || string(entries[1]) != "b2" {
+		t.Errorf("Expected overwritten entries, Got: %q", entries)
+	}
+}
@@ -0,0 +1,26 @@
+package libstore
+
+import "context"
+
+// EntryCounter is implemented by backends that can count the entries of a key
+// without reading them.
+type EntryCounter interface {
+	// Count returns the number of entries of key. It returns a KeyNotFoundError if
+	// the key does not exist.
+	Count(ctx context.Context, key string) (int, error)
+}
+
+// Count returns the number of entries of key, the length ReadAll would return.
+//
+// Backends implementing EntryCounter count the entries where they are stored, such
+// as the database backend with a COUNT query; for the others every entry is read.
+func Count(ctx context.Context, ops Ops, key string) (int, error) {
+	if counter, ok := ops.(EntryCounter); ok {
+		return counter.Count(ctx, key)
+	}
+	entries, err := ops.ReadAll(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
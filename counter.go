@@ -0,0 +1,54 @@
+package libstore
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Incrementer is implemented by backends that can add to a counter atomically.
+type Incrementer interface {
+	// Increment adds delta to the counter held by the latest entry of key, writes
+	// the result as a new entry and returns it. A key without entries counts from 0.
+	Increment(ctx context.Context, key string, delta int64) (int64, error)
+}
+
+// Increment atomically adds delta to the counter stored in key and returns the new
+// value. Counters are stored as entries holding a base 10 integer, as written by
+// strconv.FormatInt, so they can be read and set with Read and Put; a key without
+// entries counts from 0. It returns an EntryError if the latest entry is not an
+// integer or the sum overflows an int64, and a KeyNotFoundError if key does not
+// exist.
+//
+// It returns an UnsupportedError if ops does not implement Incrementer, as a Read
+// followed by a Put would lose concurrent increments.
+func Increment(ctx context.Context, ops Ops, key string, delta int64) (int64, error) {
+	if incrementer, ok := ops.(Incrementer); ok {
+		return incrementer.Increment(ctx, key, delta)
+	}
+	return 0, UnsupportedError("Increment is not supported by this backend")
+}
+
+// addCounter adds delta to the counter stored as entry, nil for a key without
+// entries, and returns the new value.
+func addCounter(key string, entry []byte, delta int64) (int64, error) {
+	var value int64
+	if entry != nil {
+		var err error
+		value, err = strconv.ParseInt(strings.TrimSpace(string(entry)), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %w", EntryError("counter: entry of key "+key+" is not an integer"), err)
+		}
+	}
+	if (delta > 0 && value > math.MaxInt64-delta) || (delta < 0 && value < math.MinInt64-delta) {
+		return 0, EntryError(fmt.Sprintf("counter: adding %d to %d overflows key %s", delta, value, key))
+	}
+	return value + delta, nil
+}
+
+// formatCounter returns the entry storing a counter.
+func formatCounter(value int64) []byte {
+	return strconv.AppendInt(nil, value, 10)
+}
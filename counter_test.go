@@ -0,0 +1,80 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+// testIncrement checks Increment on a fresh ops, including concurrent increments.
+func testIncrement(t *testing.T, ops libstore.Ops, key string) {
+	t.Helper()
+	ctx := context.Background()
+	var notFound libstore.KeyNotFoundError
+	if _, err := libstore.Increment(ctx, ops, key, 1); !errors.As(err, &notFound) {
+		t.Errorf("Expected a KeyNotFoundError for a missing key, Got: %v", err)
+	}
+	if err := ops.Create(ctx, key); err != nil {
+		t.Fatalf("Error creating key: %v", err)
+	}
+	if value, err := libstore.Increment(ctx, ops, key, 5); err != nil || value != 5 {
+		t.Errorf("Increment() on a key without entries = %d, %v, want 5", value, err)
+	}
+	if value, err := libstore.Increment(ctx, ops, key, -7); err != nil || value != -2 {
+		t.Errorf("Increment() = %d, %v, want -2", value, err)
+	}
+
+	const workers, increments = 8, 10
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range increments {
+				if _, err := libstore.Increment(ctx, ops, key, 1); err != nil {
+					t.Errorf("Error incrementing: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if entry, err := ops.Read(ctx, key); err != nil || string(entry) != "78" {
+		t.Errorf("Expected every concurrent increment to count, Got: %q, %v", entry, err)
+	}
+
+	var entryErr libstore.EntryError
+	if _, err := libstore.Increment(ctx, ops, key, math.MaxInt64); !errors.As(err, &entryErr) {
+		t.Errorf("Expected an EntryError on overflow, Got: %v", err)
+	}
+	if err := ops.Put(ctx, key, []byte("not a number")); err != nil {
+		t.Fatalf("Error putting entry: %v", err)
+	}
+	if _, err := libstore.Increment(ctx, ops, key, 1); !errors.As(err, &entryErr) {
+		t.Errorf("Expected an EntryError for a non-integer entry, Got: %v", err)
+	}
+}
+
+func TestIncrementInMemory(t *testing.T) {
+	testIncrement(t, libstore.NewInMemoryOps(), "counter")
+}
+
+func TestIncrementFile(t *testing.T) {
+	ops, err := libstore.NewFileOps(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testIncrement(t, ops, "counter")
+}
+
+func TestIncrementUnsupported(t *testing.T) {
+	ops := newRecordingOps(libstore.NewInMemoryOps())
+	var unsupported libstore.UnsupportedError
+	if _, err := libstore.Increment(context.Background(), ops, "counter", 1); !errors.As(err, &unsupported) {
+		t.Errorf("Expected an UnsupportedError, Got: %v", err)
+	}
+}
@@ -0,0 +1,34 @@
+package libstore
+
+import (
+	"context"
+	"errors"
+)
+
+// IdempotentCreator is implemented by backends that can atomically create a key
+// only if it does not exist yet.
+type IdempotentCreator interface {
+	// CreateIfNotExists creates the given key unless it already exists.
+	// It reports whether the key was created by this call.
+	CreateIfNotExists(ctx context.Context, key string) (created bool, err error)
+}
+
+// CreateIfNotExists creates key unless it already exists and reports whether it was created.
+//
+// Backends implementing IdempotentCreator do so atomically. For the others Create is
+// called and a KeyError is taken to mean the key exists, which is only as reliable as
+// the backend's own duplicate detection.
+func CreateIfNotExists(ctx context.Context, ops Ops, key string) (bool, error) {
+	if creator, ok := ops.(IdempotentCreator); ok {
+		return creator.CreateIfNotExists(ctx, key)
+	}
+	err := ops.Create(ctx, key)
+	var keyErr KeyError
+	if errors.As(err, &keyErr) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,24 @@
+package libstore
+
+import (
+	"context"
+	"time"
+)
+
+// CreatedAtReader is implemented by backends that know when a key was created.
+type CreatedAtReader interface {
+	// CreatedAt returns when key was last created, which later writes do not change.
+	// It returns a KeyNotFoundError if the key does not exist.
+	CreatedAt(ctx context.Context, key string) (time.Time, error)
+}
+
+// CreatedAt returns when key was created, as opposed to when it was last written,
+// for instance to compute the age of an account. A key that was deleted and created
+// again reports its latest creation. It returns an UnsupportedError if ops does not
+// implement CreatedAtReader.
+func CreatedAt(ctx context.Context, ops Ops, key string) (time.Time, error) {
+	if reader, ok := ops.(CreatedAtReader); ok {
+		return reader.CreatedAt(ctx, key)
+	}
+	return time.Time{}, UnsupportedError("CreatedAt is not supported by this backend")
+}
@@ -0,0 +1,69 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestCreatedAt(t *testing.T) {
+	// Each backend returns the store and a key that is new in it.
+	backends := map[string]func(t *testing.T) (libstore.Ops, string){
+		"InMemory": func(t *testing.T) (libstore.Ops, string) { return libstore.NewInMemoryOps(), "account" },
+		"File": func(t *testing.T) (libstore.Ops, string) {
+			ops, err := libstore.NewFileOps(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			return ops, "account"
+		},
+		"DB": func(t *testing.T) (libstore.Ops, string) { return newTestDBOps(t), testKey(t) },
+	}
+
+	for name, newOps := range backends {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			ops, key := newOps(t)
+
+			var notFound libstore.KeyNotFoundError
+			if _, err := libstore.CreatedAt(ctx, ops, key); !errors.As(err, &notFound) {
+				t.Errorf("CreatedAt() on missing key error = %v, want KeyNotFoundError", err)
+			}
+
+			before := time.Now().Add(-time.Second)
+			if err := ops.Create(ctx, key); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			created, err := libstore.CreatedAt(ctx, ops, key)
+			if err != nil {
+				t.Fatalf("CreatedAt() error = %v", err)
+			}
+			if created.Before(before) || created.After(time.Now().Add(time.Second)) {
+				t.Errorf("CreatedAt() = %v, want around %v", created, before)
+			}
+
+			time.Sleep(20 * time.Millisecond)
+			if err := ops.Put(ctx, key, []byte("entry")); err != nil {
+				t.Fatalf("Put() error = %v", err)
+			}
+			if again, err := libstore.CreatedAt(ctx, ops, key); err != nil || !again.Equal(created) {
+				t.Errorf("CreatedAt() after Put = %v, %v, want %v", again, err, created)
+			}
+		})
+	}
+}
+
+func TestCreatedAtUnsupported(t *testing.T) {
+	var unsupported libstore.UnsupportedError
+	_, err := libstore.CreatedAt(context.Background(), newRecordingOps(libstore.NewInMemoryOps()), "key")
+	if !errors.As(err, &unsupported) {
+		t.Errorf("CreatedAt() error = %v, want UnsupportedError", err)
+	}
+	_, err = libstore.CreatedAt(context.Background(), newFakeS3Ops(t), "key")
+	if !errors.As(err, &unsupported) {
+		t.Errorf("CreatedAt() on S3 without native versioning error = %v, want UnsupportedError", err)
+	}
+}
@@ -0,0 +1,32 @@
+package libstore
+
+import (
+	"context"
+)
+
+// CreateIf creates key holding entry if cond reports true, for provisioning gated on
+// state outside the store.
+//
+// cond is evaluated right before the write to keep the window in which its answer
+// can go stale short. If it reports false, CreateIf returns a PreconditionError
+// without creating key; if it fails, its error is returned. The key and entry are
+// then written with GetOrCreate, atomically on backends implementing GetOrCreator.
+// If key already exists it is left untouched and CreateIf returns a KeyError, or an
+// EntryError if the existing key holds no entries.
+func CreateIf(ctx context.Context, ops Ops, key string, cond func(ctx context.Context) (bool, error), entry []byte) error {
+	ok, err := cond(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return PreconditionError("precondition not met for key: " + key)
+	}
+	_, created, err := GetOrCreate(ctx, ops, key, entry)
+	if err != nil {
+		return err
+	}
+	if !created {
+		return KeyError("key already exists: " + key)
+	}
+	return nil
+}
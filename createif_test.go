@@ -0,0 +1,78 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestCreateIf(t *testing.T) {
+	ctx := context.Background()
+	condErr := errors.New("lookup failed")
+	tests := []struct {
+		name    string
+		cond    func(context.Context) (bool, error)
+		wantErr func(error) bool
+		created bool
+	}{
+		{
+			name:    "true",
+			cond:    func(context.Context) (bool, error) { return true, nil },
+			wantErr: func(err error) bool { return err == nil },
+			created: true,
+		},
+		{
+			name: "false",
+			cond: func(context.Context) (bool, error) { return false, nil },
+			wantErr: func(err error) bool {
+				var preconditionErr libstore.PreconditionError
+				return errors.As(err, &preconditionErr)
+			},
+		},
+		{
+			name:    "error",
+			cond:    func(context.Context) (bool, error) { return false, condErr },
+			wantErr: func(err error) bool { return errors.Is(err, condErr) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, ops := range map[string]libstore.Ops{
+				"InMemory": libstore.NewInMemoryOps(),
+				"Fallback": newRecordingOps(libstore.NewInMemoryOps()),
+			} {
+				err := libstore.CreateIf(ctx, ops, "key", tt.cond, []byte("entry"))
+				if !tt.wantErr(err) {
+					t.Errorf("%s: Unexpected error: %v", name, err)
+				}
+				value, err := ops.Read(ctx, "key")
+				if tt.created && (err != nil || string(value) != "entry") {
+					t.Errorf("%s: Expected the key to hold the entry, Got: %q, %v", name, value, err)
+				}
+				var notFound libstore.KeyNotFoundError
+				if !tt.created && !errors.As(err, &notFound) {
+					t.Errorf("%s: Expected the key not to be created, Got: %v", name, err)
+				}
+			}
+		})
+	}
+
+	ops := libstore.NewInMemoryOps()
+	if err := ops.Create(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ops.Put(ctx, "key", []byte("first")); err != nil {
+		t.Fatal(err)
+	}
+	err := libstore.CreateIf(ctx, ops, "key", func(context.Context) (bool, error) { return true, nil }, []byte("second"))
+	var keyErr libstore.KeyError
+	if !errors.As(err, &keyErr) {
+		t.Errorf("Expected a KeyError for an existing key, Got: %v", err)
+	}
+	if value, _ := ops.Read(ctx, "key"); string(value) != "first" {
+		t.Errorf("Expected the existing key to be untouched, Got: %q", value)
+	}
+}
@@ -0,0 +1,153 @@
+package libstore
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"io"
+
+	"github.com/cecmp/libcipher"
+	"golang.org/x/crypto/hkdf"
+)
+
+// deterministicMeta is sealed into deterministic vaults in place of a timestamp,
+// which would make the vaults of equal entries differ.
+const deterministicMeta = "libstore/deterministic"
+
+// gcmNonceSize is the size of the nonces of AES-GCM.
+const gcmNonceSize = 12
+
+// CryptStoreOption configures NewCryptStoreGCM and NewCryptStoreCBC.
+type CryptStoreOption func(*cryptStoreOptions)
+
+type cryptStoreOptions struct {
+	deterministicKey []byte
+}
+
+// WithDeterministicEncryption lets Puts made with the Deterministic option encrypt
+// their entry deterministically under key, so that equal entries are stored as
+// equal vaults and can be found by comparing ciphertexts at the backend, for
+// instance against the vault DeterministicVault returns. Other Puts keep the
+// randomized encryption of the store.
+//
+// Deterministic encryption leaks equality: anyone reading the backend learns which
+// entries are equal, across keys and over time, and can tell whether a guessed
+// entry is stored once it obtains its vault. Mark only the values that must be
+// searched, and only if their equality may be revealed. Deterministic vaults do not
+// seal the time of the write either, so History reports the backend's time for them.
+//
+// Entries are encrypted with AES-GCM under a nonce derived from the entry with
+// HMAC-SHA256, a synthetic IV construction, using two subkeys derived from key with
+// HKDF-SHA256. key must be at least 16 bytes and should differ from the key of the
+// store.
+func WithDeterministicEncryption(key []byte) CryptStoreOption {
+	return func(o *cryptStoreOptions) {
+		o.deterministicKey = key
+	}
+}
+
+// Deterministic makes the Put of a CryptStore configured with
+// WithDeterministicEncryption encrypt the entry deterministically. CryptStores
+// without it return an UnsupportedError rather than store a vault that cannot be
+// searched; other backends ignore it.
+func Deterministic() OpOption {
+	return func(o *opOptions) {
+		o.deterministic = true
+	}
+}
+
+// deterministicCipher encrypts entries deterministically.
+type deterministicCipher struct {
+	encryptionKey []byte
+	macKey        []byte
+	decryptor     libcipher.Decryptor
+}
+
+// newDeterministicCipher derives the subkeys of a deterministicCipher from key.
+func newDeterministicCipher(key []byte) (*deterministicCipher, error) {
+	if _, err := libcipher.NewGCMDecryptor(key); err != nil {
+		return nil, err
+	}
+	encryptionKey, err := deriveDeterministicKey(key, "encryption")
+	if err != nil {
+		return nil, err
+	}
+	macKey, err := deriveDeterministicKey(key, "mac")
+	if err != nil {
+		return nil, err
+	}
+	d := &deterministicCipher{encryptionKey: encryptionKey, macKey: macKey}
+	decryptor, err := libcipher.NewGCMDecryptor(d.encryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	d.decryptor = decryptor
+	return d, nil
+}
+
+// deriveDeterministicKey derives the subkey of key named info.
+func deriveDeterministicKey(key []byte, info string) ([]byte, error) {
+	subkey := make([]byte, derivedKeySize)
+	kdf := hkdf.New(sha256.New, key, nil, []byte("libstore/cryptstore/deterministic/"+info))
+	if _, err := io.ReadFull(kdf, subkey); err != nil {
+		return nil, fmt.Errorf("%w: %w", DecryptionError("failed to derive deterministic subkey"), err)
+	}
+	return subkey, nil
+}
+
+// seal encrypts entry under the nonce derived from it.
+func (d *deterministicCipher) seal(entry []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, d.macKey)
+	mac.Write([]byte(deterministicMeta))
+	mac.Write(entry)
+	nonce := mac.Sum(nil)[:gcmNonceSize]
+	encryptor, err := libcipher.NewGCMEncryptor(d.encryptionKey, bytes.NewReader(nonce))
+	if err != nil {
+		return nil, err
+	}
+	vault, err := encryptor.Crypt(entry, []byte(deterministicMeta))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", DecryptionError("failed to encrypt entry"), err)
+	}
+	return vault, nil
+}
+
+// open decrypts a vault written by seal.
+func (d *deterministicCipher) open(vault []byte) ([]byte, error) {
+	res, meta, err := d.decryptor.Crypt(vault)
+	if err != nil {
+		return nil, err
+	}
+	if string(meta) != deterministicMeta {
+		return nil, ValidationError("failed to validate deterministic sealing")
+	}
+	return res, nil
+}
+
+// newCryptStore returns a CryptStore on ops with the given ciphers and options.
+func newCryptStore(ops Ops, encryptor libcipher.Encryptor, decryptor libcipher.Decryptor, opts []CryptStoreOption) (Ops, error) {
+	var o cryptStoreOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	m := CryptStore{storeOps: ops, encryptor: encryptor, decryptor: decryptor}
+	if o.deterministicKey != nil {
+		deterministic, err := newDeterministicCipher(o.deterministicKey)
+		if err != nil {
+			return nil, err
+		}
+		m.deterministic = deterministic
+	}
+	return m, nil
+}
+
+// DeterministicVault returns the vault a Put made with the Deterministic option
+// stores for entry, to search the backend for it. It returns an UnsupportedError if
+// m was not configured with WithDeterministicEncryption.
+func (m CryptStore) DeterministicVault(entry []byte) ([]byte, error) {
+	if m.deterministic == nil {
+		return nil, UnsupportedError("deterministic encryption is not enabled for this store")
+	}
+	return m.deterministic.seal(entry)
+}
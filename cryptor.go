@@ -16,6 +16,8 @@ type CryptStore struct {
 	storeOps  Ops
 	encryptor libcipher.Encryptor
 	decryptor libcipher.Decryptor
+	// deterministic is set by WithDeterministicEncryption.
+	deterministic *deterministicCipher
 }
 
 type (
@@ -43,6 +45,7 @@ func (e TimestampError) Error() string {
 //   - integrityKey: A byte slice representing the key used for HMAC integrity checks.
 //   - calculateMAC: A function returning a new hash.Hash used for generating the MAC.
 //   - rand: An io.Reader used as a source of randomness, typically crypto/rand.Reader.
+//   - opts: Options such as WithDeterministicEncryption.
 //
 // Returns:
 //   - An Ops instance that wraps the provided storage operations with CBC-HMAC encryption.
@@ -50,7 +53,7 @@ func (e TimestampError) Error() string {
 //
 // The function sets up an encryptor and decryptor using the specified keys and MAC function.
 // It then returns a CryptStore that applies these operations on the provided Ops.
-func NewCryptStoreCBC(ops Ops, encyptionKey []byte, integrityKey []byte, calculateMAC func() hash.Hash, rand io.Reader) (Ops, error) {
+func NewCryptStoreCBC(ops Ops, encyptionKey []byte, integrityKey []byte, calculateMAC func() hash.Hash, rand io.Reader, opts ...CryptStoreOption) (Ops, error) {
 	encryptor, err := libcipher.NewCBCHMACEncryptor(encyptionKey, integrityKey, calculateMAC, rand)
 	if err != nil {
 		return nil, err
@@ -59,7 +62,7 @@ func NewCryptStoreCBC(ops Ops, encyptionKey []byte, integrityKey []byte, calcula
 	if err != nil {
 		return nil, err
 	}
-	return CryptStore{storeOps: ops, encryptor: encryptor, decryptor: decryptor}, nil
+	return newCryptStore(ops, encryptor, decryptor, opts)
 }
 
 // NewCryptStoreGCM initializes a new CryptStore instance using GCM encryption.
@@ -68,6 +71,7 @@ func NewCryptStoreCBC(ops Ops, encyptionKey []byte, integrityKey []byte, calcula
 //   - ops: An instance of Ops that defines the underlying storage operations.
 //   - encryptionKey: A byte slice representing the encryption key used for GCM encryption.
 //   - rand: An io.Reader used as a source of randomness, typically crypto/rand.Reader.
+//   - opts: Options such as WithDeterministicEncryption.
 //
 // Returns:
 //   - An Ops instance that wraps the provided storage operations with GCM encryption.
@@ -75,7 +79,7 @@ func NewCryptStoreCBC(ops Ops, encyptionKey []byte, integrityKey []byte, calcula
 //
 // The function sets up an encryptor and decryptor using the specified encryption key.
 // It then returns a CryptStore that applies these operations on the provided Ops.
-func NewCryptStoreGCM(ops Ops, encyptionKey []byte, rand io.Reader) (Ops, error) {
+func NewCryptStoreGCM(ops Ops, encyptionKey []byte, rand io.Reader, opts ...CryptStoreOption) (Ops, error) {
 	encryptor, err := libcipher.NewGCMEncryptor(encyptionKey, rand)
 	if err != nil {
 		return nil, err
@@ -85,15 +89,14 @@ func NewCryptStoreGCM(ops Ops, encyptionKey []byte, rand io.Reader) (Ops, error)
 		return nil, err
 	}
 
-	return CryptStore{storeOps: ops, encryptor: encryptor, decryptor: decryptor}, nil
+	return newCryptStore(ops, encryptor, decryptor, opts)
 }
 
 // Put implements libstore.Ops.
 func (m CryptStore) Put(ctx context.Context, key string, entry []byte) error {
-	ts := []byte(time.Now().UTC().Format(tsFormat))
-	vault, err := m.encryptor.Crypt(entry, ts)
+	vault, err := m.seal(ctx, entry, time.Now())
 	if err != nil {
-		return fmt.Errorf("%w: %w", DecryptionError("failed to encrypt entry"), err)
+		return err
 	}
 	err = m.storeOps.Put(ctx, key, vault)
 	if err != nil {
@@ -103,6 +106,40 @@ func (m CryptStore) Put(ctx context.Context, key string, entry []byte) error {
 	return nil
 }
 
+// PutAt implements libstore.TimestampPutter.
+// The timestamp is sealed into the vault and passed on to the underlying Ops when it
+// records write times. Timestamps after now are rejected with a ValidationError, as
+// Read would refuse to open such a vault.
+func (m CryptStore) PutAt(ctx context.Context, key string, entry []byte, ts time.Time) error {
+	if ts.After(time.Now()) {
+		return ValidationError("timestamp is in the future")
+	}
+	vault, err := m.seal(ctx, entry, ts)
+	if err != nil {
+		return err
+	}
+	if putter, ok := m.storeOps.(TimestampPutter); ok {
+		return putter.PutAt(ctx, key, vault, ts)
+	}
+	return m.storeOps.Put(ctx, key, vault)
+}
+
+// seal encrypts entry with ts as authenticated metadata, or deterministically if ctx
+// carries the Deterministic option.
+func (m CryptStore) seal(ctx context.Context, entry []byte, ts time.Time) ([]byte, error) {
+	if opOptionsFrom(ctx).deterministic {
+		if m.deterministic == nil {
+			return nil, UnsupportedError("deterministic encryption is not enabled for this store")
+		}
+		return m.deterministic.seal(entry)
+	}
+	vault, err := m.encryptor.Crypt(entry, []byte(ts.UTC().Format(tsFormat)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", DecryptionError("failed to encrypt entry"), err)
+	}
+	return vault, nil
+}
+
 // Create implements libstore.Ops.
 func (m CryptStore) Create(ctx context.Context, key string) error {
 	err := m.storeOps.Create(ctx, key)
@@ -133,24 +170,19 @@ func (m CryptStore) List(ctx context.Context) ([]string, error) {
 	return res, nil
 }
 
+// Unwrap implements Unwrapper.
+func (m CryptStore) Unwrap() Ops {
+	return m.storeOps
+}
+
 // Read implements libstore.Ops.
 func (m CryptStore) Read(ctx context.Context, key string) ([]byte, error) {
 	vault, err := m.storeOps.Read(ctx, key)
 	if err != nil {
 		return nil, err
 	}
-	res, meta, err := m.decryptor.Crypt(vault)
-	if err != nil {
-		return nil, err
-	}
-	ts, err := time.Parse(tsFormat, string(meta))
-	if err != nil {
-		return nil, err
-	}
-	if ts.After(time.Now().UTC()) {
-		return nil, ValidationError("failed to validate sealing")
-	}
-	return res, nil
+	res, _, err := m.open(vault)
+	return res, err
 }
 
 // ReadAll implements libstore.Ops.
@@ -160,19 +192,68 @@ func (m CryptStore) ReadAll(ctx context.Context, key string) ([][]byte, error) {
 		return nil, err
 	}
 	res := make([][]byte, len(vaults))
-	var meta []byte
 	for i := range vaults {
-		res[i], meta, err = m.decryptor.Crypt(vaults[i])
+		res[i], _, err = m.open(vaults[i])
 		if err != nil {
 			return nil, err
 		}
-		ts, err := time.Parse(tsFormat, string(meta))
+	}
+	return res, nil
+}
+
+// History implements libstore.HistoryReader.
+// Versions are numbered by the underlying Ops, and CreatedAt is the timestamp sealed
+// into each vault, which unlike a backend's own record cannot be altered unnoticed.
+func (m CryptStore) History(ctx context.Context, key string) ([]VersionInfo, error) {
+	history, err := History(ctx, m.storeOps, key)
+	if err != nil {
+		return nil, err
+	}
+	for i := range history {
+		var ts time.Time
+		history[i].Value, ts, err = m.open(history[i].Value)
 		if err != nil {
 			return nil, err
 		}
-		if ts.After(time.Now().UTC()) {
-			return nil, ValidationError("failed to validate sealing")
+		if !ts.IsZero() {
+			history[i].CreatedAt = ts
 		}
 	}
-	return res, nil
+	return history, nil
+}
+
+// open decrypts vault and returns the entry along with its sealed timestamp, which is
+// zero for deterministic vaults.
+func (m CryptStore) open(vault []byte) ([]byte, time.Time, error) {
+	res, meta, err := m.decryptor.Crypt(vault)
+	if err != nil {
+		if m.deterministic != nil {
+			if res, detErr := m.deterministic.open(vault); detErr == nil {
+				return res, time.Time{}, nil
+			}
+		}
+		return nil, time.Time{}, err
+	}
+	ts, err := time.Parse(tsFormat, string(meta))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if ts.After(time.Now().UTC()) {
+		return nil, time.Time{}, ValidationError("failed to validate sealing")
+	}
+	return res, ts, nil
+}
+
+// ListModifiedSince implements libstore.ModifiedSinceLister when the underlying Ops does.
+func (m CryptStore) ListModifiedSince(ctx context.Context, since time.Time) ([]string, error) {
+	lister, ok := m.storeOps.(ModifiedSinceLister)
+	if !ok {
+		return nil, UnsupportedError("list modified since is not supported by the underlying store")
+	}
+	return lister.ListModifiedSince(ctx, since)
+}
+
+// CreateIfNotExists implements libstore.IdempotentCreator.
+func (m CryptStore) CreateIfNotExists(ctx context.Context, key string) (bool, error) {
+	return CreateIfNotExists(ctx, m.storeOps, key)
 }
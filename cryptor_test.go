@@ -0,0 +1,168 @@
+package libstore_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestCryptStorePutAt(t *testing.T) {
+	ops, err := libstore.NewCryptStoreGCM(libstore.NewInMemoryOps(), bytes.Repeat([]byte{0x42}, 32), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ops.Create(context.TODO(), "key"); err != nil {
+		t.Fatalf("Error creating key: %v", err)
+	}
+	putter := ops.(libstore.TimestampPutter)
+
+	if err := putter.PutAt(context.TODO(), "key", []byte("legacy"), time.Now().Add(-24*time.Hour)); err != nil {
+		t.Fatalf("Error putting entry with a past timestamp: %v", err)
+	}
+	value, err := ops.Read(context.TODO(), "key")
+	if err != nil || string(value) != "legacy" {
+		t.Fatalf("Expected to read the imported entry, Got: %q, %v", value, err)
+	}
+
+	err = putter.PutAt(context.TODO(), "key", []byte("future"), time.Now().Add(time.Hour))
+	var validationErr libstore.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Errorf("Expected a ValidationError for a future timestamp, Got: %v", err)
+	}
+}
+
+func TestScrub(t *testing.T) {
+	backend := libstore.NewInMemoryOps()
+	ops, err := libstore.NewCryptStoreGCM(backend, bytes.Repeat([]byte{0x42}, 32), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"good", "tampered"} {
+		if err := ops.Create(context.TODO(), key); err != nil {
+			t.Fatal(err)
+		}
+		if err := ops.Put(context.TODO(), key, []byte("secret")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	vault, err := backend.Read(context.TODO(), "tampered")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vault = bytes.Clone(vault)
+	vault[len(vault)-1] ^= 0xff
+	if err := backend.Put(context.TODO(), "tampered", vault); err != nil {
+		t.Fatal(err)
+	}
+
+	var failed []string
+	summary, err := libstore.Scrub(context.TODO(), ops, func(key string, err error) {
+		failed = append(failed, key)
+	})
+	if err != nil {
+		t.Fatalf("Error scrubbing: %v", err)
+	}
+	if summary.Scanned != 2 || summary.Failed != 1 || len(failed) != 1 || failed[0] != "tampered" {
+		t.Errorf("Unexpected scrub result: %+v, failed %v", summary, failed)
+	}
+}
+
+func TestCryptStoreHistory(t *testing.T) {
+	// Vaults are binary, so they need a backend that keeps every entry intact.
+	backend, err := libstore.NewLogFileOps(filepath.Join(t.TempDir(), "segment"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.(*libstore.LogFileOps).Close()
+	ops, err := libstore.NewCryptStoreGCM(backend, bytes.Repeat([]byte{0x42}, 32), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ops.Create(context.TODO(), "key"); err != nil {
+		t.Fatalf("Error creating key: %v", err)
+	}
+	imported := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+	if err := ops.(libstore.TimestampPutter).PutAt(context.TODO(), "key", []byte("imported"), imported); err != nil {
+		t.Fatal(err)
+	}
+	if err := ops.Put(context.TODO(), "key", []byte("latest")); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := libstore.History(context.TODO(), ops, "key")
+	if err != nil || len(history) != 2 {
+		t.Fatalf("Unexpected history: %+v, %v", history, err)
+	}
+	if history[0].Version != 1 || string(history[0].Value) != "imported" || !history[0].CreatedAt.Equal(imported) {
+		t.Errorf("Unexpected first version: %+v", history[0])
+	}
+	if history[1].Version != 2 || string(history[1].Value) != "latest" || history[1].CreatedAt.Before(imported) {
+		t.Errorf("Unexpected second version: %+v", history[1])
+	}
+
+	var notFound libstore.KeyNotFoundError
+	if _, err := libstore.History(context.TODO(), ops, "missing"); !errors.As(err, &notFound) {
+		t.Errorf("Expected a KeyNotFoundError, Got: %v", err)
+	}
+}
+
+func TestCryptStoreDeterministic(t *testing.T) {
+	ctx := context.Background()
+	backend := libstore.NewInMemoryOps()
+	ops, err := libstore.NewCryptStoreGCM(backend, bytes.Repeat([]byte{0x42}, 32), rand.Reader,
+		libstore.WithDeterministicEncryption(bytes.Repeat([]byte{0x24}, 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	deterministic := libstore.WithOpOptions(ctx, libstore.Deterministic())
+	vaults := map[string][]byte{}
+	for key, putCtx := range map[string]context.Context{"a": deterministic, "b": deterministic, "randomized-a": ctx, "randomized-b": ctx} {
+		if err := ops.Create(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+		if err := ops.Put(putCtx, key, []byte("token")); err != nil {
+			t.Fatalf("Error putting entry: %v", err)
+		}
+		if vaults[key], err = backend.Read(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+		if entry, err := ops.Read(ctx, key); err != nil || string(entry) != "token" {
+			t.Errorf("Expected to read back the entry of %s, Got: %q, %v", key, entry, err)
+		}
+	}
+
+	if !bytes.Equal(vaults["a"], vaults["b"]) {
+		t.Errorf("Expected equal entries to be stored as equal vaults in deterministic mode")
+	}
+	if bytes.Equal(vaults["randomized-a"], vaults["randomized-b"]) {
+		t.Errorf("Expected equal entries to be stored as different vaults in the default mode")
+	}
+	if bytes.Contains(vaults["a"], []byte("token")) {
+		t.Errorf("Expected the deterministic vault not to hold the plaintext")
+	}
+	want, err := ops.(libstore.CryptStore).DeterministicVault([]byte("token"))
+	if err != nil || !bytes.Equal(want, vaults["a"]) {
+		t.Errorf("Expected DeterministicVault to return the stored vault, Got: %x, %v", want, err)
+	}
+	if other, err := ops.(libstore.CryptStore).DeterministicVault([]byte("other")); err != nil || bytes.Equal(other, want) {
+		t.Errorf("Expected different entries to get different vaults, Got: %v", err)
+	}
+
+	plain, err := libstore.NewCryptStoreGCM(libstore.NewInMemoryOps(), bytes.Repeat([]byte{0x42}, 32), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := plain.Create(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	var unsupportedErr libstore.UnsupportedError
+	if err := plain.Put(deterministic, "key", []byte("token")); !errors.As(err, &unsupportedErr) {
+		t.Errorf("Expected an UnsupportedError without WithDeterministicEncryption, Got: %v", err)
+	}
+}
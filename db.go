@@ -3,14 +3,45 @@ package libstore
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"iter"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 // dbOps provides database operations for interacting with a PostgreSQL database.
 type dbOps struct {
-	db *sql.DB
+	db   *sql.DB
+	now  func() time.Time
+	lazy bool
+}
+
+// DBOption configures the Ops returned by NewDBOps.
+type DBOption func(*dbOps)
+
+// WithDBClock sets the clock that dates the rows written by the Ops, instead of
+// time.Now. The rows are dated by the application rather than by the database
+// server, so their created_at agrees with timestamps the application takes itself,
+// and tests can freeze it.
+func WithDBClock(now func() time.Time) DBOption {
+	return func(d *dbOps) {
+		d.now = now
+	}
+}
+
+// WithDBLazyConnect makes NewDBOps return without connecting to the database. The
+// table is then created and migrated when the first operation opens a connection,
+// and that operation returns the error NewDBOps would have returned. A failed check
+// is reported again by the operations of the next few seconds before it is retried,
+// so an unavailable database is not probed on every call.
+func WithDBLazyConnect() DBOption {
+	return func(d *dbOps) {
+		d.lazy = true
+	}
 }
 
 // NewDBOps initializes a new dbOps instance with a connection to a PostgreSQL database.
@@ -25,14 +56,47 @@ type dbOps struct {
 //
 // The function opens a connection to the PostgreSQL database using the provided connection string,
 // and ensures that the necessary table ('FILES') exists by creating it if it does not.
+// Tables created by earlier versions are migrated to carry checksum, metadata and
+// fence token columns and a unique index on the creation row of each key. Rows are
+// dated with time.Now unless WithDBClock is given. With WithDBLazyConnect, the
+// connection and the table are only checked by the first operation.
 //
 // Note:
 // The function returns an OpsInternalError if any step of the initialization fails.
-func NewDBOps(ctx context.Context, conn string) (Ops, error) {
+func NewDBOps(ctx context.Context, conn string, opts ...DBOption) (Ops, error) {
+	d := dbOps{
+		now: time.Now,
+	}
+	for _, opt := range opts {
+		opt(&d)
+	}
+	if d.lazy {
+		connector, err := pq.NewConnector(conn)
+		if err != nil {
+			return nil, dbError("failed to open database connection", err)
+		}
+		init := newLazyInit("postgres", func(ctx context.Context) error {
+			db := sql.OpenDB(connector)
+			defer db.Close()
+			return setupDB(ctx, db)
+		})
+		d.db = sql.OpenDB(lazyConnector{Connector: connector, init: init})
+		return d, nil
+	}
+
 	db, err := sql.Open("postgres", conn)
 	if err != nil {
-		return nil, fmt.Errorf("%w: %w", OpsInternalError("failed to open database connection"), err)
+		return nil, dbError("failed to open database connection", err)
 	}
+	if err := setupDB(ctx, db); err != nil {
+		return nil, err
+	}
+	d.db = db
+	return d, nil
+}
+
+// setupDB creates the FILES table if it does not exist and migrates it.
+func setupDB(ctx context.Context, db *sql.DB) error {
 	query := `
 		CREATE TABLE IF NOT EXISTS FILES (
 				id SERIAL PRIMARY KEY,
@@ -42,44 +106,184 @@ func NewDBOps(ctx context.Context, conn string) (Ops, error) {
 				created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 		);
 	`
+	_, err := db.ExecContext(ctx, query)
+	if err != nil {
+		return dbError("failed to create table", err)
+	}
+	query = `
+		ALTER TABLE FILES ADD COLUMN IF NOT EXISTS checksum TEXT;
+		CREATE INDEX IF NOT EXISTS files_key_checksum_idx ON FILES (key, checksum);
+		ALTER TABLE FILES ADD COLUMN IF NOT EXISTS metadata JSONB;
+		CREATE INDEX IF NOT EXISTS files_metadata_idx ON FILES USING GIN (metadata) WHERE version = 0;
+		ALTER TABLE FILES ADD COLUMN IF NOT EXISTS fence_token BIGINT;
+	`
 	_, err = db.ExecContext(ctx, query)
 	if err != nil {
-		return nil, fmt.Errorf("%w: %w", OpsInternalError("failed to create table"), err)
+		return dbError("failed to migrate table", err)
+	}
+	if err := migrateCreatedIndex(ctx, db); err != nil {
+		return err
+	}
+	if err := migrateUniqueVersions(ctx, db); err != nil {
+		return err
+	}
+	return migrateFinalized(ctx, db)
+}
+
+// lazyConnector opens connections for WithDBLazyConnect, setting up the table
+// before the first one.
+type lazyConnector struct {
+	driver.Connector
+	init *lazyInit
+}
+
+// Connect implements driver.Connector.
+func (c lazyConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	if err := c.init.wait(ctx); err != nil {
+		return nil, err
+	}
+	return c.Connector.Connect(ctx)
+}
+
+// finalizedSQLState is the SQLSTATE raised by the function of finalizedFunction.
+const finalizedSQLState = "LS001"
+
+// finalizedFunction adds the finalized flag, kept on the version 0 row of a key, and
+// the function of finalizedTrigger, which rejects every insert, delete or rename
+// touching a finalized key, so all write paths honor it. Updates leaving the key
+// unchanged, such as Finalize itself or PutMetadata, are allowed.
+const finalizedFunction = `
+	ALTER TABLE FILES ADD COLUMN IF NOT EXISTS finalized BOOLEAN NOT NULL DEFAULT false;
+	CREATE OR REPLACE FUNCTION files_reject_finalized() RETURNS trigger AS $$
+	BEGIN
+		IF TG_OP = 'UPDATE' AND NEW.key = OLD.key THEN
+			RETURN NEW;
+		END IF;
+		IF TG_OP <> 'INSERT' AND EXISTS (SELECT 1 FROM FILES WHERE key = OLD.key AND version = 0 AND finalized) THEN
+			RAISE EXCEPTION 'key % is finalized', OLD.key USING ERRCODE = 'LS001';
+		END IF;
+		IF TG_OP <> 'DELETE' AND EXISTS (SELECT 1 FROM FILES WHERE key = NEW.key AND version = 0 AND finalized) THEN
+			RAISE EXCEPTION 'key % is finalized', NEW.key USING ERRCODE = 'LS001';
+		END IF;
+		IF TG_OP = 'DELETE' THEN
+			RETURN OLD;
+		END IF;
+		RETURN NEW;
+	END
+	$$ LANGUAGE plpgsql;
+`
+
+// finalizedTrigger runs the function of finalizedFunction on every write.
+const finalizedTrigger = `
+	CREATE TRIGGER files_reject_finalized
+		BEFORE INSERT OR UPDATE OR DELETE ON FILES
+		FOR EACH ROW EXECUTE FUNCTION files_reject_finalized();
+`
+
+// finalizedMigrationLock is the key of the advisory lock serializing migrateFinalized
+// across the processes sharing the database.
+const finalizedMigrationLock = 0x6c696273746f7265
+
+// migrateFinalized installs finalizedFunction, replacing an older version of the
+// function, and creates finalizedTrigger unless it is already in place. It runs in a
+// transaction holding an advisory lock, so concurrent NewDBOps calls, which would
+// otherwise race on replacing the function or creating the trigger, take turns.
+func migrateFinalized(ctx context.Context, db *sql.DB) (err error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return dbError("failed to begin migration transaction", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		} else if cerr := tx.Commit(); cerr != nil {
+			err = dbError("failed to commit migration transaction", cerr)
+		}
+	}()
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", int64(finalizedMigrationLock)); err != nil {
+		return dbError("failed to lock migration", err)
+	}
+	if _, err := tx.ExecContext(ctx, finalizedFunction); err != nil {
+		return dbError("failed to install finalized function", err)
+	}
+	var exists bool
+	err = tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'files_reject_finalized')").Scan(&exists)
+	if err != nil {
+		return dbError("failed to check finalized trigger", err)
+	}
+	if exists {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx, finalizedTrigger); err != nil {
+		return dbError("failed to install finalized trigger", err)
+	}
+	return nil
+}
+
+// Finalize implements Finalizer by setting the finalized flag of key.
+func (d dbOps) Finalize(ctx context.Context, key string) error {
+	result, err := d.db.ExecContext(ctx, "UPDATE FILES SET finalized = true WHERE key = $1 AND version = 0", key)
+	if err != nil {
+		return dbError("failed to finalize key", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return dbError("failed to determine rows affected", err)
 	}
+	if rowsAffected == 0 {
+		return KeyNotFoundError("key not found: " + key)
+	}
+	return nil
+}
 
-	return dbOps{
-		db: db,
-	}, nil
+// Close closes the database connection pool.
+func (d dbOps) Close() error {
+	return d.db.Close()
 }
 
 // Create implements Ops.
 func (d dbOps) Create(ctx context.Context, key string) error {
+	return createKey(ctx, d.db, key, d.now())
+}
+
+// createKey inserts the row of a new key through q, created at createdAt.
+func createKey(ctx context.Context, q dbExecer, key string, createdAt time.Time) error {
 	// Check if the key already exists
 	var existingKey string
-	err := d.db.QueryRowContext(ctx, "SELECT key FROM FILES WHERE key = $1", key).Scan(&existingKey)
+	err := q.QueryRowContext(ctx, "SELECT key FROM FILES WHERE key = $1", key).Scan(&existingKey)
 	if err != nil && err != sql.ErrNoRows {
-		return (fmt.Errorf("%w: %w", OpsInternalError("failed to check existing key"), err))
+		return dbError("failed to check existing key", err)
 	}
 	if existingKey != "" {
 		return KeyError("key already exists: " + key)
 	}
 
-	_, err = d.db.ExecContext(ctx, "INSERT INTO FILES (key, value, version) VALUES ($1, NULL, 0)", key)
+	_, err = q.ExecContext(ctx, "INSERT INTO FILES (key, value, version, created_at) VALUES ($1, NULL, 0, $2)", key, createdAt)
+	// A concurrent Create inserted the key since the check.
+	if isUniqueViolation(err) {
+		return fmt.Errorf("%w: %w", KeyError("key already exists: "+key), err)
+	}
 	if err != nil {
-		return fmt.Errorf("%w: %w", OpsInternalError("failed to create key"), err)
+		return dbError("failed to create key", err)
 	}
 	return nil
 }
 
 // Delete implements Ops.
 func (d dbOps) Delete(ctx context.Context, key string) error {
-	result, err := d.db.ExecContext(ctx, "DELETE FROM FILES WHERE key = $1", key)
+	return deleteKey(ctx, d.db, key)
+}
+
+// deleteKey deletes every row of key through q.
+func deleteKey(ctx context.Context, q dbExecer, key string) error {
+	result, err := q.ExecContext(ctx, "DELETE FROM FILES WHERE key = $1", key)
 	if err != nil {
-		return fmt.Errorf("%w: %w", OpsInternalError("failed to delete key"), err)
+		return dbError("failed to delete key", err)
 	}
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("%w: %w", OpsInternalError("failed to determine rows affected"), err)
+		return dbError("failed to determine rows affected", err)
 	}
 	if rowsAffected == 0 {
 		return KeyNotFoundError("key not found: " + key)
@@ -87,11 +291,19 @@ func (d dbOps) Delete(ctx context.Context, key string) error {
 	return nil
 }
 
-// List implements Ops.
+// List implements Ops. Keys are ordered with the C collation, so the order is by
+// bytes whatever the locale of the database.
 func (d dbOps) List(ctx context.Context) ([]string, error) {
-	rows, err := d.db.QueryContext(ctx, "SELECT key FROM FILES")
+	return listKeys(ctx, d.db)
+}
+
+// listKeys returns every key through q, ordered by bytes.
+func listKeys(ctx context.Context, q dbQuerier) ([]string, error) {
+	// SELECT DISTINCT can only be ordered by its select list, so the collation is
+	// set there.
+	rows, err := q.QueryContext(ctx, `SELECT DISTINCT key COLLATE "C" AS key FROM FILES ORDER BY key`)
 	if err != nil {
-		return nil, fmt.Errorf("%w : %w", OpsInternalError("failed to list keys"), err)
+		return nil, dbError("failed to list keys", err)
 	}
 	defer rows.Close()
 
@@ -99,56 +311,512 @@ func (d dbOps) List(ctx context.Context) ([]string, error) {
 	for rows.Next() {
 		var key string
 		if err := rows.Scan(&key); err != nil {
-			return nil, fmt.Errorf("%w : %w", OpsInternalError("failed to scan key"), err)
+			return nil, dbError("failed to scan key", err)
 		}
 		keys = append(keys, key)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("%w: %w", OpsInternalError("rows iteration error"), err)
+		return nil, dbError("rows iteration error", err)
 	}
 	return keys, nil
 }
 
 // Read implements Ops.
 func (d dbOps) Read(ctx context.Context, key string) ([]byte, error) {
+	return readLast(ctx, d.db, key)
+}
+
+// ReadAll implements Ops.
+func (d dbOps) ReadAll(ctx context.Context, key string) ([][]byte, error) {
+	return readAll(ctx, d.db, key)
+}
+
+// ReadConcat implements ConcatReader. Postgres joins the entries, so only the
+// blob crosses the connection.
+func (d dbOps) ReadConcat(ctx context.Context, key string, sep []byte) ([]byte, error) {
+	var rows int64
+	var blob []byte
+	err := d.db.QueryRowContext(ctx, `
+		SELECT COUNT(*), string_agg(COALESCE(value, ''::bytea), $2::bytea ORDER BY version) FILTER (WHERE version > 0)
+		FROM FILES WHERE key = $1`, key, sep).Scan(&rows, &blob)
+	if err != nil {
+		return nil, dbError("failed to concatenate entries", err)
+	}
+	if rows == 0 {
+		return nil, KeyNotFoundError("key not found: " + key)
+	}
+	if blob == nil {
+		blob = []byte{}
+	}
+	return blob, nil
+}
+
+// Count implements EntryCounter with a COUNT over the rows of key.
+func (d dbOps) Count(ctx context.Context, key string) (int, error) {
+	var rows, entries int
+	err := d.db.QueryRowContext(ctx,
+		"SELECT COUNT(*), COUNT(*) FILTER (WHERE version > 0) FROM FILES WHERE key = $1", key).Scan(&rows, &entries)
+	if err != nil {
+		return 0, dbError("failed to count entries", err)
+	}
+	if rows == 0 {
+		return 0, KeyNotFoundError("key not found: " + key)
+	}
+	return entries, nil
+}
+
+// dbQuerier is satisfied by both *sql.DB and *sql.Tx.
+type dbQuerier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// dbExecer is a dbQuerier that can also run statements, as *sql.DB and *sql.Tx can.
+type dbExecer interface {
+	dbQuerier
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// readLast reads the latest entry of key through q.
+func readLast(ctx context.Context, q dbQuerier, key string) ([]byte, error) {
 	var value []byte
-	err := d.db.QueryRowContext(ctx, "SELECT value FROM FILES WHERE key = $1 ORDER BY version DESC LIMIT 1", key).Scan(&value)
+	var version int64
+	err := q.QueryRowContext(ctx, "SELECT value, version FROM FILES WHERE key = $1 ORDER BY version DESC LIMIT 1", key).Scan(&value, &version)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, KeyNotFoundError("key not found: " + key)
 		}
-		return nil, fmt.Errorf("%w: %w", OpsInternalError("failed to read last entry"), err)
+		return nil, dbError("failed to read last entry", err)
+	}
+	// Only the row inserted by Create exists.
+	if version == 0 {
+		return nil, EntryError("no entries found for key: " + key)
 	}
 	return value, nil
 }
 
-// ReadAll implements Ops.
-func (d dbOps) ReadAll(ctx context.Context, key string) ([][]byte, error) {
-	rows, err := d.db.QueryContext(ctx, "SELECT value FROM FILES WHERE key = $1 ORDER BY version ASC", key)
+// ReadPrefix implements PrefixReader. Postgres cuts the value, so only the prefix
+// crosses the connection.
+func (d dbOps) ReadPrefix(ctx context.Context, key string, n int) ([]byte, error) {
+	if err := checkPrefixLength(n); err != nil {
+		return nil, err
+	}
+	var value []byte
+	var version int64
+	err := d.db.QueryRowContext(ctx, "SELECT substring(value from 1 for $2), version FROM FILES WHERE key = $1 ORDER BY version DESC LIMIT 1", key, n).Scan(&value, &version)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, KeyNotFoundError("key not found: " + key)
+		}
+		return nil, dbError("failed to read entry prefix", err)
+	}
+	if version == 0 {
+		return nil, EntryError("no entries found for key: " + key)
+	}
+	return value, nil
+}
+
+// ReadWithToken implements TokenReader. The token is the version of the latest
+// entry and the id of its row, so a key deleted and created again does not reuse
+// the tokens of its former entries.
+func (d dbOps) ReadWithToken(ctx context.Context, key string) ([]byte, string, error) {
+	entry, token, _, err := d.readIfNoneMatch(ctx, key, "")
+	return entry, token, err
+}
+
+// ReadIfNoneMatch implements ConditionalReader, leaving the value of an unchanged
+// entry in the database.
+func (d dbOps) ReadIfNoneMatch(ctx context.Context, key string, token string) ([]byte, bool, error) {
+	entry, _, unchanged, err := d.readIfNoneMatch(ctx, key, token)
+	return entry, unchanged, err
+}
+
+// readIfNoneMatch reads the latest entry of key and its token, unless the token is
+// token.
+func (d dbOps) readIfNoneMatch(ctx context.Context, key string, token string) ([]byte, string, bool, error) {
+	var value []byte
+	var current string
+	var version int64
+	err := d.db.QueryRowContext(ctx, `
+		SELECT CASE WHEN version || '.' || id = $2 THEN NULL ELSE value END, version || '.' || id, version
+		FROM FILES WHERE key = $1 ORDER BY version DESC LIMIT 1
+	`, key, token).Scan(&value, &current, &version)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, "", false, KeyNotFoundError("key not found: " + key)
+		}
+		return nil, "", false, dbError("failed to read entry", err)
+	}
+	if version == 0 {
+		return nil, "", false, EntryError("no entries found for key: " + key)
+	}
+	if current == token {
+		return nil, current, true, nil
+	}
+	return value, current, false, nil
+}
+
+// readAll reads all entries of key in version order through q.
+func readAll(ctx context.Context, q dbQuerier, key string) ([][]byte, error) {
+	rows, err := q.QueryContext(ctx, "SELECT value, version FROM FILES WHERE key = $1 ORDER BY version ASC", key)
 	if err != nil {
-		return nil, fmt.Errorf("%w: %w", OpsInternalError("failed to read whole content"), err)
+		return nil, dbError("failed to read whole content", err)
 	}
 	defer rows.Close()
 
+	found := false
 	var values [][]byte
 	for rows.Next() {
 		var value []byte
-		if err := rows.Scan(&value); err != nil {
-			return nil, fmt.Errorf("%w: %w", OpsInternalError("failed to scan value"), err)
+		var version int64
+		if err := rows.Scan(&value, &version); err != nil {
+			return nil, dbError("failed to scan value", err)
+		}
+		found = true
+		// The row inserted by Create marks the key but holds no entry.
+		if version > 0 {
+			values = append(values, value)
 		}
-		values = append(values, value)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("%w: %w", OpsInternalError("rows iteration error"), err)
+		return nil, dbError("rows iteration error", err)
+	}
+	if !found {
+		return nil, KeyNotFoundError("key not found: " + key)
 	}
 	return values, nil
 }
 
+// History implements HistoryReader, reporting the version and created_at of every entry.
+func (d dbOps) History(ctx context.Context, key string) ([]VersionInfo, error) {
+	rows, err := d.db.QueryContext(ctx, "SELECT version, value, created_at FROM FILES WHERE key = $1 ORDER BY version ASC", key)
+	if err != nil {
+		return nil, dbError("failed to read history", err)
+	}
+	defer rows.Close()
+
+	found := false
+	history := []VersionInfo{}
+	for rows.Next() {
+		var info VersionInfo
+		var createdAt sql.NullTime
+		if err := rows.Scan(&info.Version, &info.Value, &createdAt); err != nil {
+			return nil, dbError("failed to scan version", err)
+		}
+		found = true
+		if info.Version > 0 {
+			info.CreatedAt = createdAt.Time
+			history = append(history, info)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError("rows iteration error", err)
+	}
+	if !found {
+		return nil, KeyNotFoundError("key not found: " + key)
+	}
+	return history, nil
+}
+
+// HistoryPage implements HistoryPager, reading only the rows of the page.
+func (d dbOps) HistoryPage(ctx context.Context, key string, beforeVersion int64, limit int) ([]VersionInfo, int64, error) {
+	if err := checkPageLimit(limit); err != nil {
+		return nil, 0, err
+	}
+	// One row more than the page tells whether another page follows: a version,
+	// or the row inserted by Create after the oldest version.
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT version, value, created_at FROM FILES
+		WHERE key = $1 AND ($2::bigint <= 0 OR version < $2::bigint)
+		ORDER BY version DESC
+		LIMIT $3`, key, beforeVersion, limit+1)
+	if err != nil {
+		return nil, 0, dbError("failed to read history page", err)
+	}
+	defer rows.Close()
+
+	found := false
+	page := []VersionInfo{}
+	for rows.Next() {
+		var info VersionInfo
+		var createdAt sql.NullTime
+		if err := rows.Scan(&info.Version, &info.Value, &createdAt); err != nil {
+			return nil, 0, dbError("failed to scan version", err)
+		}
+		found = true
+		if info.Version > 0 {
+			info.CreatedAt = createdAt.Time
+			page = append(page, info)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, dbError("rows iteration error", err)
+	}
+	// The row inserted by Create precedes every version, so it is read for any key
+	// that exists.
+	if !found {
+		return nil, 0, KeyNotFoundError("key not found: " + key)
+	}
+	if len(page) > limit {
+		return page[:limit], page[limit-1].Version, nil
+	}
+	return page, 0, nil
+}
+
 // Put implements Ops.
 func (d dbOps) Put(ctx context.Context, key string, entry []byte) error {
+	return d.put(ctx, key, entry, d.now())
+}
+
+// PutAt implements TimestampPutter, recording ts as the created_at of the new version.
+func (d dbOps) PutAt(ctx context.Context, key string, entry []byte, ts time.Time) error {
+	return d.put(ctx, key, entry, ts)
+}
+
+// CreatedAt implements CreatedAtReader with the created_at of the row inserted by
+// Create, so entries imported with PutAt under earlier timestamps do not move it.
+func (d dbOps) CreatedAt(ctx context.Context, key string) (time.Time, error) {
+	var createdAt time.Time
+	err := d.db.QueryRowContext(ctx, "SELECT created_at FROM FILES WHERE key = $1 ORDER BY version ASC LIMIT 1", key).Scan(&createdAt)
+	if err == sql.ErrNoRows {
+		return time.Time{}, KeyNotFoundError("key not found: " + key)
+	}
+	if err != nil {
+		return time.Time{}, dbError("failed to read creation time", err)
+	}
+	return createdAt, nil
+}
+
+// PutAtVersion implements VersionPutter. The unique (key, version) index rejects a
+// version that is already taken.
+func (d dbOps) PutAtVersion(ctx context.Context, key string, version int64, entry []byte) error {
+	return d.inTx(ctx, func(tx *sql.Tx) error {
+		var exists bool
+		err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM FILES WHERE key = $1)", key).Scan(&exists)
+		if err != nil {
+			return dbError("failed to check if key exists", err)
+		}
+		if !exists {
+			return KeyNotFoundError("key not found: " + key)
+		}
+		_, err = tx.ExecContext(ctx, "INSERT INTO FILES (key, value, version, checksum, created_at) VALUES ($1, $2, $3, $4, $5)",
+			key, entry, version, Checksum(entry), d.now())
+		if isUniqueViolation(err) {
+			return fmt.Errorf("%w: %w", ConflictError(fmt.Sprintf("version %d of key %s already exists", version, key)), err)
+		}
+		if err != nil {
+			return dbError("failed to insert entry", err)
+		}
+		return nil
+	})
+}
+
+// Rename implements Renamer by rewriting the key of every row of oldKey in one
+// transaction; the values themselves are not copied.
+func (d dbOps) Rename(ctx context.Context, oldKey, newKey string) error {
+	return d.inTx(ctx, func(tx *sql.Tx) error {
+		var exists bool
+		err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM FILES WHERE key = $1)", newKey).Scan(&exists)
+		if err != nil {
+			return dbError("failed to check if key exists", err)
+		}
+		if exists {
+			return KeyError("key already exists: " + newKey)
+		}
+		result, err := tx.ExecContext(ctx, "UPDATE FILES SET key = $2 WHERE key = $1", oldKey, newKey)
+		// newKey was created since the check.
+		if isUniqueViolation(err) {
+			return fmt.Errorf("%w: %w", KeyError("key already exists: "+newKey), err)
+		}
+		if err != nil {
+			return dbError("failed to rename key", err)
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return dbError("failed to determine rows affected", err)
+		}
+		if rowsAffected == 0 {
+			return KeyNotFoundError("key not found: " + oldKey)
+		}
+		return nil
+	})
+}
+
+// VerifyIntegrity implements IntegrityVerifier from the version numbers stored for
+// key. Versions imported with PutAtVersion may leave gaps on purpose.
+func (d dbOps) VerifyIntegrity(ctx context.Context, key string) (IntegrityReport, error) {
+	rows, err := d.db.QueryContext(ctx,
+		"SELECT version, count(*) FROM FILES WHERE key = $1 GROUP BY version ORDER BY version", key)
+	if err != nil {
+		return IntegrityReport{}, dbError("failed to count versions", err)
+	}
+	defer rows.Close()
+
+	var counts []versionCount
+	exists := false
+	for rows.Next() {
+		var c versionCount
+		if err := rows.Scan(&c.version, &c.count); err != nil {
+			return IntegrityReport{}, dbError("failed to scan version count", err)
+		}
+		exists = true
+		// Version 0 marks the creation of the key and holds no entry.
+		if c.version > 0 {
+			counts = append(counts, c)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return IntegrityReport{}, dbError("rows iteration error", err)
+	}
+	if !exists {
+		return IntegrityReport{}, KeyNotFoundError("key not found: " + key)
+	}
+	return integrityReport(counts), nil
+}
+
+// migrateCreatedIndex adds the UNIQUE index on the version 0 row of each key, which
+// makes creating a key atomic. Tables written before it may hold several version 0
+// rows for a key from concurrent Creates; all but the oldest are dropped before it
+// is created.
+func migrateCreatedIndex(ctx context.Context, db *sql.DB) error {
+	var exists bool
+	err := db.QueryRowContext(ctx, "SELECT to_regclass('files_key_created_idx') IS NOT NULL").Scan(&exists)
+	if err != nil {
+		return dbError("failed to check creation index", err)
+	}
+	if exists {
+		return nil
+	}
+	query := `
+		DELETE FROM FILES f USING FILES o
+		WHERE f.version = 0 AND o.version = 0 AND f.key = o.key AND f.id > o.id;
+		CREATE UNIQUE INDEX IF NOT EXISTS files_key_created_idx ON FILES (key) WHERE version = 0;
+	`
+	if _, err := db.ExecContext(ctx, query); err != nil {
+		return dbError("failed to add creation index", err)
+	}
+	return nil
+}
+
+// migrateUniqueVersions adds the UNIQUE (key, version) index. Tables written before
+// it may hold duplicate versions from concurrent Puts; the entries of the affected
+// keys are renumbered from 1, in version and insertion order, before it is created.
+func migrateUniqueVersions(ctx context.Context, db *sql.DB) error {
+	var exists bool
+	err := db.QueryRowContext(ctx, "SELECT to_regclass('files_key_version_idx') IS NOT NULL").Scan(&exists)
+	if err != nil {
+		return dbError("failed to check version index", err)
+	}
+	if exists {
+		return nil
+	}
+	query := `
+		UPDATE FILES f SET version = r.rn
+		FROM (
+			SELECT id, ROW_NUMBER() OVER (PARTITION BY key ORDER BY version, id) AS rn
+			FROM FILES
+			WHERE version > 0 AND key IN (
+				SELECT key FROM FILES WHERE version > 0 GROUP BY key, version HAVING COUNT(*) > 1
+			)
+		) r
+		WHERE f.id = r.id;
+		CREATE UNIQUE INDEX IF NOT EXISTS files_key_version_idx ON FILES (key, version);
+	`
+	if _, err := db.ExecContext(ctx, query); err != nil {
+		return dbError("failed to add version index", err)
+	}
+	return nil
+}
+
+// maxPutAttempts bounds how often put retries after losing a version race.
+const maxPutAttempts = 100
+
+// put inserts entry as the next version of key, created at createdAt.
+func (d dbOps) put(ctx context.Context, key string, entry []byte, createdAt time.Time) error {
+	return d.inVersionTx(ctx, func(tx *sql.Tx) error {
+		return insertNext(ctx, tx, key, entry, createdAt)
+	})
+}
+
+// inVersionTx runs fn in a transaction. Concurrent writers may compute the same next
+// version; the loser hits the UNIQUE (key, version) index and fn is retried in a new
+// transaction, with a fresh MAX(version).
+func (d dbOps) inVersionTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	return retryTx(ctx, func() error {
+		return d.inTx(ctx, fn)
+	})
+}
+
+// maxSerializationAttempts bounds how often a transaction is retried after Postgres
+// aborted it with a serialization failure or a deadlock.
+const maxSerializationAttempts = 5
+
+// retryTx runs attempt until it succeeds or fails with an error retrying cannot fix.
+// Unique violations from a lost version race are retried at once, up to
+// maxPutAttempts times. Serialization failures and deadlocks, which Postgres reports
+// under contention at REPEATABLE READ and SERIALIZABLE, are retried up to
+// maxSerializationAttempts times with a growing pause, and then reported as a
+// ConflictError rather than an internal error.
+func retryTx(ctx context.Context, attempt func() error) error {
+	var races, serializations int
+	for {
+		err := attempt()
+		switch {
+		case isUniqueViolation(err):
+			if races++; races == maxPutAttempts {
+				return dbError("failed to allocate a version", err)
+			}
+		case isSerializationFailure(err):
+			if serializations++; serializations == maxSerializationAttempts {
+				return fmt.Errorf("%w: %w", ConflictError("transaction kept conflicting with concurrent transactions"), err)
+			}
+			select {
+			case <-ctx.Done():
+				return annotateTimeout("waiting to retry transaction", ctx.Err())
+			case <-time.After(time.Duration(serializations) * 10 * time.Millisecond):
+			}
+		default:
+			return err
+		}
+	}
+}
+
+// dbError wraps an error returned by Postgres in a BackendError carrying its SQLSTATE.
+// Queries abandoned with their context or cut by statement_timeout are also reported
+// as a TimeoutError, and writes to a finalized key as an ImmutableError.
+func dbError(op string, err error) error {
+	e := &BackendError{Backend: "postgres", Op: op, Err: err}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		e.Code = string(pqErr.Code)
+	}
+	switch e.Code {
+	case "57014":
+		return fmt.Errorf("%w: %w", TimeoutError(op+": query canceled"), e)
+	case finalizedSQLState:
+		return fmt.Errorf("%w: %w", ImmutableError(op+": "+pqErr.Message), e)
+	}
+	return annotateTimeout(op, e)
+}
+
+// isUniqueViolation reports whether err is a PostgreSQL unique_violation.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// isSerializationFailure reports whether err is a PostgreSQL serialization_failure
+// or deadlock_detected, after which the transaction can be retried.
+func isSerializationFailure(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && (pqErr.Code == "40001" || pqErr.Code == "40P01")
+}
+
+// inTx runs fn in a transaction, committing it if fn succeeds.
+func (d dbOps) inTx(ctx context.Context, fn func(tx *sql.Tx) error) (err error) {
 	tx, err := d.db.BeginTx(ctx, &sql.TxOptions{})
 	if err != nil {
-		return fmt.Errorf("%w: %w", OpsInternalError("failed to begin transaction"), err)
+		return dbError("failed to begin transaction", err)
 	}
 
 	defer func() {
@@ -157,25 +825,748 @@ func (d dbOps) Put(ctx context.Context, key string, entry []byte) error {
 			panic(r)
 		} else if err != nil {
 			_ = tx.Rollback()
-		} else {
-			err = tx.Commit()
+		} else if cerr := tx.Commit(); cerr != nil {
+			err = dbError("failed to commit transaction", cerr)
 		}
 	}()
 
+	// A Durable write waits for the commit to be flushed even if the server
+	// defaults to asynchronous commits.
+	if opOptionsFrom(ctx).durable {
+		if _, err := tx.ExecContext(ctx, "SET LOCAL synchronous_commit TO on"); err != nil {
+			return dbError("failed to enable synchronous commit", err)
+		}
+	}
+	return fn(tx)
+}
+
+// insertNext inserts entry as the next version of key through tx.
+func insertNext(ctx context.Context, tx *sql.Tx, key string, entry []byte, createdAt time.Time) error {
 	// Increment the version
-	var maxVersion int64
-	err = tx.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM FILES WHERE key = $1", key).Scan(&maxVersion)
-	if err != nil && err != sql.ErrNoRows {
-		return fmt.Errorf("%w: %w", OpsInternalError("failed to get max version"), err)
+	var maxVersion sql.NullInt64
+	err := tx.QueryRowContext(ctx, "SELECT MAX(version) FROM FILES WHERE key = $1", key).Scan(&maxVersion)
+	if err != nil {
+		return dbError("failed to get max version", err)
+	}
+	if !maxVersion.Valid {
+		return KeyNotFoundError("key not found: " + key)
 	}
 
 	// Insert the new version
-	_, err = tx.ExecContext(ctx, "INSERT INTO FILES (key, value, version) VALUES ($1, $2, $3)", key, entry, maxVersion+1)
+	_, err = tx.ExecContext(ctx, "INSERT INTO FILES (key, value, version, checksum, created_at) VALUES ($1, $2, $3, $4, $5)",
+		key, entry, maxVersion.Int64+1, Checksum(entry), createdAt)
 	if err != nil {
-		return fmt.Errorf("%w: %w", OpsInternalError("failed to replace entry"), err)
+		return dbError("failed to replace entry", err)
 	}
+	return nil
+}
+
+// PutBatch implements BatchPutter, inserting every entry in one transaction.
+func (d dbOps) PutBatch(ctx context.Context, batch []BatchEntry) error {
+	return d.inVersionTx(ctx, func(tx *sql.Tx) error {
+		for _, e := range batch {
+			if err := insertNext(ctx, tx, e.Key, e.Entry, d.now()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// PutFenced implements FencedPutter. The highest fence token of a key is kept on its
+// version 0 row, which is locked while the token is checked and the entry inserted
+// in one transaction.
+func (d dbOps) PutFenced(ctx context.Context, key string, entry []byte, fenceToken int64) error {
+	return d.inVersionTx(ctx, func(tx *sql.Tx) error {
+		var highest sql.NullInt64
+		err := tx.QueryRowContext(ctx, "SELECT fence_token FROM FILES WHERE key = $1 AND version = 0 FOR UPDATE", key).Scan(&highest)
+		if err == sql.ErrNoRows {
+			return KeyNotFoundError("key not found: " + key)
+		}
+		if err != nil {
+			return dbError("failed to read fence token", err)
+		}
+		if highest.Valid {
+			if err := checkFence(key, highest.Int64, fenceToken); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE FILES SET fence_token = $2 WHERE key = $1 AND version = 0", key, fenceToken); err != nil {
+			return dbError("failed to record fence token", err)
+		}
+		return insertNext(ctx, tx, key, entry, d.now())
+	})
+}
+
+// PutCapped implements CappedPutter. The entry is inserted and the versions beyond
+// the newest maxEntries deleted in one transaction.
+func (d dbOps) PutCapped(ctx context.Context, key string, entry []byte, maxEntries int) error {
+	return d.inVersionTx(ctx, func(tx *sql.Tx) error {
+		return putCapped(ctx, tx, key, entry, maxEntries, d.now())
+	})
+}
 
+// putCapped inserts entry as the next version of key through tx and deletes the
+// versions beyond the newest maxEntries.
+func putCapped(ctx context.Context, tx *sql.Tx, key string, entry []byte, maxEntries int, createdAt time.Time) error {
+	if err := checkMaxEntries(maxEntries); err != nil {
+		return err
+	}
+	if err := insertNext(ctx, tx, key, entry, createdAt); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, `
+		DELETE FROM FILES
+		WHERE key = $1 AND version > 0
+		AND version <= (SELECT MAX(version) FROM FILES WHERE key = $1) - $2`, key, maxEntries)
+	if err != nil {
+		return dbError("failed to drop old entries", err)
+	}
 	return nil
 }
 
-var _ Ops = dbOps{}
+// PutMetadata implements MetadataStore. The metadata is stored as JSONB on the row
+// inserted by Create.
+func (d dbOps) PutMetadata(ctx context.Context, key string, meta Metadata) error {
+	if meta == nil {
+		meta = Metadata{}
+	}
+	encoded, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("%w: %w", EntryError("failed to encode metadata"), err)
+	}
+	result, err := d.db.ExecContext(ctx, "UPDATE FILES SET metadata = $2::jsonb WHERE key = $1 AND version = 0", key, string(encoded))
+	if err != nil {
+		return dbError("failed to put metadata", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return dbError("failed to determine rows affected", err)
+	}
+	if rowsAffected == 0 {
+		return KeyNotFoundError("key not found: " + key)
+	}
+	return nil
+}
+
+// ReadMetadata implements MetadataStore.
+func (d dbOps) ReadMetadata(ctx context.Context, key string) (Metadata, error) {
+	var encoded []byte
+	err := d.db.QueryRowContext(ctx, "SELECT metadata FROM FILES WHERE key = $1 AND version = 0", key).Scan(&encoded)
+	if err == sql.ErrNoRows {
+		return nil, KeyNotFoundError("key not found: " + key)
+	}
+	if err != nil {
+		return nil, dbError("failed to read metadata", err)
+	}
+	meta := Metadata{}
+	if encoded != nil {
+		if err := json.Unmarshal(encoded, &meta); err != nil {
+			return nil, fmt.Errorf("%w: %w", EntryError("failed to decode metadata"), err)
+		}
+	}
+	return meta, nil
+}
+
+// ListWhere implements WhereLister. The predicate becomes a WHERE clause on the
+// metadata column, with fields and values passed as parameters; equality uses JSONB
+// containment so it can use the GIN index.
+func (d dbOps) ListWhere(ctx context.Context, predicate MetaPredicate) ([]string, error) {
+	query := "SELECT key FROM FILES WHERE version = 0"
+	var args []any
+	param := func(v string) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d::text", len(args))
+	}
+	for _, c := range predicate {
+		switch c.Op {
+		case MetaEquals:
+			query += fmt.Sprintf(" AND metadata @> jsonb_build_object(%s, %s)", param(c.Field), param(c.Value))
+		case MetaNotEquals:
+			query += fmt.Sprintf(" AND (metadata ->> %s) IS DISTINCT FROM %s", param(c.Field), param(c.Value))
+		case MetaExists:
+			query += fmt.Sprintf(" AND metadata ? %s", param(c.Field))
+		default:
+			return nil, KeyError(fmt.Sprintf("metadata: unknown operator %d on field %s", c.Op, c.Field))
+		}
+	}
+
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, dbError("failed to list keys by metadata", err)
+	}
+	defer rows.Close()
+
+	keys := []string{}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, dbError("failed to scan key", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError("rows iteration error", err)
+	}
+	return keys, nil
+}
+
+// ListChildren implements ChildLister, cutting every key after the first delimiter
+// past prefix and collapsing the cut keys in SQL.
+func (d dbOps) ListChildren(ctx context.Context, prefix, delimiter string) ([]string, []string, error) {
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT child, is_prefix FROM (
+			SELECT DISTINCT
+				CASE WHEN pos > 0 THEN left(key, length($1::text) + pos + length($2::text) - 1) ELSE key END AS child,
+				pos > 0 AS is_prefix
+			FROM (
+				SELECT key,
+					CASE WHEN $2::text = '' THEN 0 ELSE strpos(substr(key, length($1::text) + 1), $2::text) END AS pos
+				FROM FILES
+				WHERE version = 0 AND left(key, length($1::text)) = $1::text
+			) AS matches
+		) AS children
+		ORDER BY child COLLATE "C"`, prefix, delimiter)
+	if err != nil {
+		return nil, nil, dbError("failed to list children", err)
+	}
+	defer rows.Close()
+
+	keys, commonPrefixes := []string{}, []string{}
+	for rows.Next() {
+		var child string
+		var isPrefix bool
+		if err := rows.Scan(&child, &isPrefix); err != nil {
+			return nil, nil, dbError("failed to scan child", err)
+		}
+		if isPrefix {
+			commonPrefixes = append(commonPrefixes, child)
+		} else {
+			keys = append(keys, child)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, dbError("rows iteration error", err)
+	}
+	return keys, commonPrefixes, nil
+}
+
+// ListPrefixPage implements PrefixPageLister with a keyset query over the keys under
+// prefix. Its cursors are the last key of a page.
+func (d dbOps) ListPrefixPage(ctx context.Context, prefix, cursor string, limit int) ([]string, string, error) {
+	if err := checkPageLimit(limit); err != nil {
+		return nil, "", err
+	}
+	// One key more than the page tells whether another page follows.
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT key FROM FILES
+		WHERE version = 0 AND left(key, length($1::text)) = $1::text AND key > $2::text COLLATE "C"
+		ORDER BY key COLLATE "C"
+		LIMIT $3`, prefix, cursor, limit+1)
+	if err != nil {
+		return nil, "", dbError("failed to list keys", err)
+	}
+	defer rows.Close()
+
+	keys := []string{}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, "", dbError("failed to scan key", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", dbError("rows iteration error", err)
+	}
+	if len(keys) > limit {
+		return keys[:limit], keys[limit-1], nil
+	}
+	return keys, "", nil
+}
+
+// ListModifiedSince implements ModifiedSinceLister.
+func (d dbOps) ListModifiedSince(ctx context.Context, since time.Time) ([]string, error) {
+	rows, err := d.db.QueryContext(ctx, "SELECT key FROM FILES GROUP BY key HAVING MAX(created_at) > $1", since)
+	if err != nil {
+		return nil, dbError("failed to list modified keys", err)
+	}
+	defer rows.Close()
+
+	keys := []string{}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, dbError("failed to scan key", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError("rows iteration error", err)
+	}
+	return keys, nil
+}
+
+// ReadByChecksum implements ChecksumReader.
+// Entries written before checksums were recorded are hashed on the fly.
+func (d dbOps) ReadByChecksum(ctx context.Context, key string, checksum string) ([]byte, error) {
+	var value []byte
+	err := d.db.QueryRowContext(ctx, "SELECT value FROM FILES WHERE key = $1 AND checksum = $2 ORDER BY version DESC LIMIT 1", key, checksum).Scan(&value)
+	if err == nil {
+		return value, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, dbError("failed to read entry by checksum", err)
+	}
+
+	var exists bool
+	err = d.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM FILES WHERE key = $1)", key).Scan(&exists)
+	if err != nil {
+		return nil, dbError("failed to check existing key", err)
+	}
+	if !exists {
+		return nil, KeyNotFoundError("key not found: " + key)
+	}
+
+	rows, err := d.db.QueryContext(ctx, "SELECT value FROM FILES WHERE key = $1 AND version > 0 AND checksum IS NULL ORDER BY version ASC", key)
+	if err != nil {
+		return nil, dbError("failed to read legacy entries", err)
+	}
+	defer rows.Close()
+
+	var values [][]byte
+	for rows.Next() {
+		var value []byte
+		if err := rows.Scan(&value); err != nil {
+			return nil, dbError("failed to scan value", err)
+		}
+		values = append(values, value)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError("rows iteration error", err)
+	}
+	return matchChecksum(values, key, checksum)
+}
+
+// CreateIfNotExists implements IdempotentCreator.
+func (d dbOps) CreateIfNotExists(ctx context.Context, key string) (bool, error) {
+	return createKeyIfNotExists(ctx, d.db, key, d.now())
+}
+
+// createKeyIfNotExists inserts the row of key through q unless key exists, and
+// reports whether it did.
+func createKeyIfNotExists(ctx context.Context, q dbExecer, key string, createdAt time.Time) (bool, error) {
+	result, err := q.ExecContext(ctx, `
+		INSERT INTO FILES (key, value, version, created_at)
+		SELECT $1, NULL, 0, $2
+		WHERE NOT EXISTS (SELECT 1 FROM FILES WHERE key = $1)
+		ON CONFLICT (key) WHERE version = 0 DO NOTHING`, key, createdAt)
+	if err != nil {
+		return false, dbError("failed to create key", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, dbError("failed to determine rows affected", err)
+	}
+	return rowsAffected == 1, nil
+}
+
+// CreateBatch implements BatchCreator with one multi-row INSERT, skipping the keys
+// that exist. With FailOnExisting the INSERT is rolled back if it skipped any.
+func (d dbOps) CreateBatch(ctx context.Context, keys []string) error {
+	keys = uniqueKeys(keys)
+	var existing []string
+	err := d.inTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `
+			INSERT INTO FILES (key, value, version, created_at)
+			SELECT batch.key, NULL, 0, $2 FROM unnest($1::text[]) AS batch(key)
+			WHERE NOT EXISTS (SELECT 1 FROM FILES WHERE FILES.key = batch.key)
+			ON CONFLICT (key) WHERE version = 0 DO NOTHING
+			RETURNING key`, pq.Array(keys), d.now())
+		if err != nil {
+			return dbError("failed to create keys", err)
+		}
+		defer rows.Close()
+
+		created := make(map[string]bool, len(keys))
+		for rows.Next() {
+			var key string
+			if err := rows.Scan(&key); err != nil {
+				return dbError("failed to scan created key", err)
+			}
+			created[key] = true
+		}
+		if err := rows.Err(); err != nil {
+			return dbError("failed to create keys", err)
+		}
+		for _, key := range keys {
+			if !created[key] {
+				existing = append(existing, key)
+			}
+		}
+		if opOptionsFrom(ctx).failOnExisting {
+			return existingKeysError(existing)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return existingKeysError(existing)
+}
+
+// GetOrCreate implements GetOrCreator. The key and its first entry are inserted in one
+// transaction; if another transaction created the key first, its latest entry is read
+// in the same transaction once that one has committed.
+func (d dbOps) GetOrCreate(ctx context.Context, key string, entry []byte) (value []byte, created bool, err error) {
+	tx, err := d.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return nil, false, dbError("failed to begin transaction", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		if cerr := tx.Commit(); cerr != nil {
+			err = dbError("failed to commit transaction", cerr)
+		}
+	}()
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO FILES (key, value, version, created_at)
+		SELECT $1, NULL, 0, $2
+		WHERE NOT EXISTS (SELECT 1 FROM FILES WHERE key = $1)
+		ON CONFLICT (key) WHERE version = 0 DO NOTHING`, key, d.now())
+	if err != nil {
+		return nil, false, dbError("failed to create key", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, false, dbError("failed to determine rows affected", err)
+	}
+	if rowsAffected == 0 {
+		value, err := readLast(ctx, tx, key)
+		return value, false, err
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO FILES (key, value, version, checksum, created_at) VALUES ($1, $2, 1, $3, $4)",
+		key, entry, Checksum(entry), d.now())
+	if err != nil {
+		return nil, false, dbError("failed to insert entry", err)
+	}
+	return entry, true, nil
+}
+
+// SizeHistogram implements SizeHistogrammer with a single width_bucket query.
+func (d dbOps) SizeHistogram(ctx context.Context, buckets []int64) (map[int64]int, error) {
+	h := newSizeHistogram(buckets)
+	// width_bucket takes inclusive lower bounds and returns how many of them the size
+	// reaches; shifting the upper bounds by one makes that the index of the bucket.
+	lower := make([]int64, len(h.bounds))
+	for i, bound := range h.bounds {
+		lower[i] = bound + 1
+	}
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT width_bucket(COALESCE(octet_length(value), 0)::BIGINT, $1::BIGINT[]) AS bucket, COUNT(*)
+		FROM FILES WHERE version > 0 GROUP BY bucket`, pq.Array(lower))
+	if err != nil {
+		return nil, dbError("failed to compute size histogram", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bucket, count int
+		if err := rows.Scan(&bucket, &count); err != nil {
+			return nil, dbError("failed to scan size bucket", err)
+		}
+		h.counts[h.boundAt(bucket)] += count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError("failed to compute size histogram", err)
+	}
+	return h.counts, nil
+}
+
+// PutAndGetPrevious implements PreviousPutter. The latest entry is read and the new
+// version inserted in one transaction; if a concurrent Put takes the version first,
+// the transaction is retried so the entry returned is always the one superseded.
+func (d dbOps) PutAndGetPrevious(ctx context.Context, key string, entry []byte) ([]byte, error) {
+	var previous []byte
+	err := retryTx(ctx, func() error {
+		var err error
+		previous, err = d.putAndGetPreviousOnce(ctx, key, entry)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return previous, nil
+}
+
+func (d dbOps) putAndGetPreviousOnce(ctx context.Context, key string, entry []byte) (previous []byte, err error) {
+	tx, err := d.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return nil, dbError("failed to begin transaction", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		if cerr := tx.Commit(); cerr != nil {
+			err = dbError("failed to commit transaction", cerr)
+		}
+	}()
+
+	var version int64
+	err = tx.QueryRowContext(ctx, "SELECT value, version FROM FILES WHERE key = $1 ORDER BY version DESC LIMIT 1", key).Scan(&previous, &version)
+	if err == sql.ErrNoRows {
+		return nil, KeyNotFoundError("key not found: " + key)
+	}
+	if err != nil {
+		return nil, dbError("failed to read last entry", err)
+	}
+	if version == 0 {
+		previous = nil
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO FILES (key, value, version, checksum, created_at) VALUES ($1, $2, $3, $4, $5)",
+		key, entry, version+1, Checksum(entry), d.now())
+	if err != nil {
+		return nil, dbError("failed to insert entry", err)
+	}
+	return previous, nil
+}
+
+// Increment implements Incrementer. The creation row of the key is locked, so
+// concurrent increments of the key queue up instead of failing, and the new value
+// is appended as the next version.
+func (d dbOps) Increment(ctx context.Context, key string, delta int64) (int64, error) {
+	var value int64
+	err := d.inVersionTx(ctx, func(tx *sql.Tx) error {
+		var locked int
+		err := tx.QueryRowContext(ctx, "SELECT 1 FROM FILES WHERE key = $1 AND version = 0 FOR UPDATE", key).Scan(&locked)
+		if err == sql.ErrNoRows {
+			return KeyNotFoundError("key not found: " + key)
+		}
+		if err != nil {
+			return dbError("failed to lock key", err)
+		}
+		var latest []byte
+		var version int64
+		err = tx.QueryRowContext(ctx, "SELECT value, version FROM FILES WHERE key = $1 ORDER BY version DESC LIMIT 1", key).Scan(&latest, &version)
+		if err != nil {
+			return dbError("failed to read last entry", err)
+		}
+		if version == 0 {
+			latest = nil
+		} else if latest == nil {
+			latest = []byte{}
+		}
+		if value, err = addCounter(key, latest, delta); err != nil {
+			return err
+		}
+		entry := formatCounter(value)
+		_, err = tx.ExecContext(ctx, "INSERT INTO FILES (key, value, version, checksum, created_at) VALUES ($1, $2, $3, $4, $5)",
+			key, entry, version+1, Checksum(entry), d.now())
+		if err != nil {
+			return dbError("failed to insert entry", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return value, nil
+}
+
+// ListSeq implements SeqLister using keyset pagination over the key column.
+func (d dbOps) ListSeq(ctx context.Context) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		after := ""
+		for {
+			keys, err := d.listPage(ctx, after, listPageSize)
+			if err != nil {
+				yield("", err)
+				return
+			}
+			for _, key := range keys {
+				if !yield(key, nil) {
+					return
+				}
+			}
+			if len(keys) < listPageSize {
+				return
+			}
+			after = keys[len(keys)-1]
+		}
+	}
+}
+
+// listPage lists up to limit distinct keys sorting after the given key by bytes.
+func (d dbOps) listPage(ctx context.Context, after string, limit int) ([]string, error) {
+	// Ordered as List, whatever the collation of the database.
+	rows, err := d.db.QueryContext(ctx, `SELECT DISTINCT key COLLATE "C" AS key FROM FILES WHERE key > $1::text COLLATE "C" ORDER BY key LIMIT $2`, after, limit)
+	if err != nil {
+		return nil, dbError("failed to list keys", err)
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, dbError("failed to scan key", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError("rows iteration error", err)
+	}
+	return keys, nil
+}
+
+// DeleteIfVersion implements VersionedDeleter.
+// Only the versions seen by the check are deleted; if a concurrent Put committed a
+// newer version in the meantime, the transaction is rolled back with a ConflictError.
+func (d dbOps) DeleteIfVersion(ctx context.Context, key string, expectedVersion int64) (err error) {
+	tx, err := d.db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return dbError("failed to begin transaction", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		if cerr := tx.Commit(); cerr != nil {
+			err = dbError("failed to commit transaction", cerr)
+		}
+	}()
+
+	var version sql.NullInt64
+	err = tx.QueryRowContext(ctx, "SELECT MAX(version) FROM FILES WHERE key = $1", key).Scan(&version)
+	if err != nil {
+		return dbError("failed to get max version", err)
+	}
+	if !version.Valid {
+		return KeyNotFoundError("key not found: " + key)
+	}
+	if version.Int64 != expectedVersion {
+		return ConflictError(fmt.Sprintf("version conflict for key %s: expected %d, found %d", key, expectedVersion, version.Int64))
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM FILES WHERE key = $1 AND version <= $2", key, expectedVersion)
+	if err != nil {
+		return dbError("failed to delete key", err)
+	}
+	var remaining bool
+	err = tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM FILES WHERE key = $1)", key).Scan(&remaining)
+	if err != nil {
+		return dbError("failed to check remaining versions", err)
+	}
+	if remaining {
+		return ConflictError(fmt.Sprintf("version conflict for key %s: a newer version was written concurrently", key))
+	}
+	return nil
+}
+
+// Snapshot implements Snapshotter using a read-only REPEATABLE READ transaction,
+// which sees the database as of its first query for its whole lifetime. That query
+// is run here, so the snapshot is taken before Snapshot returns rather than on the
+// first read.
+func (d dbOps) Snapshot(ctx context.Context) (Snapshot, error) {
+	tx, err := d.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, dbError("failed to begin snapshot transaction", err)
+	}
+	if _, err := tx.ExecContext(ctx, "SELECT 1"); err != nil {
+		_ = tx.Rollback()
+		return nil, dbError("failed to take snapshot", err)
+	}
+	return dbSnapshot{tx: tx}, nil
+}
+
+// dbSnapshot reads from a single database transaction.
+type dbSnapshot struct {
+	tx *sql.Tx
+}
+
+// Read implements Snapshot.
+func (s dbSnapshot) Read(ctx context.Context, key string) ([]byte, error) {
+	return readLast(ctx, s.tx, key)
+}
+
+// ReadAll implements Snapshot.
+func (s dbSnapshot) ReadAll(ctx context.Context, key string) ([][]byte, error) {
+	return readAll(ctx, s.tx, key)
+}
+
+// Close implements Snapshot.
+func (s dbSnapshot) Close() error {
+	if err := s.tx.Rollback(); err != nil && err != sql.ErrTxDone {
+		return dbError("failed to release snapshot", err)
+	}
+	return nil
+}
+
+// ReadAllKeys implements BulkReader with a single query.
+func (d dbOps) ReadAllKeys(ctx context.Context) (map[string][]byte, error) {
+	rows, err := d.db.QueryContext(ctx, "SELECT DISTINCT ON (key) key, value FROM FILES WHERE version > 0 ORDER BY key, version DESC")
+	if err != nil {
+		return nil, dbError("failed to read all keys", err)
+	}
+	defer rows.Close()
+
+	values := map[string][]byte{}
+	for rows.Next() {
+		var key string
+		var value []byte
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, dbError("failed to scan entry", err)
+		}
+		values[key] = value
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dbError("failed to read all keys", err)
+	}
+	return values, nil
+}
+
+var (
+	_ Ops                 = dbOps{}
+	_ BulkReader          = dbOps{}
+	_ Snapshotter         = dbOps{}
+	_ TimestampPutter     = dbOps{}
+	_ VersionedDeleter    = dbOps{}
+	_ SeqLister           = dbOps{}
+	_ IdempotentCreator   = dbOps{}
+	_ BatchCreator        = dbOps{}
+	_ FencedPutter        = dbOps{}
+	_ GetOrCreator        = dbOps{}
+	_ PreviousPutter      = dbOps{}
+	_ Incrementer         = dbOps{}
+	_ BatchPutter         = dbOps{}
+	_ SizeHistogrammer    = dbOps{}
+	_ ChecksumReader      = dbOps{}
+	_ ModifiedSinceLister = dbOps{}
+	_ ConcatReader        = dbOps{}
+	_ HistoryReader       = dbOps{}
+	_ CappedPutter        = dbOps{}
+	_ MetadataStore       = dbOps{}
+	_ PrefixReader        = dbOps{}
+	_ VersionPutter       = dbOps{}
+	_ CreatedAtReader     = dbOps{}
+	_ TokenReader         = dbOps{}
+	_ ConditionalReader   = dbOps{}
+	_ WhereLister         = dbOps{}
+	_ ChildLister         = dbOps{}
+	_ PrefixPageLister    = dbOps{}
+	_ txContextRunner     = dbOps{}
+	_ EntryCounter        = dbOps{}
+	_ HistoryPager        = dbOps{}
+	_ Renamer             = dbOps{}
+	_ IntegrityVerifier   = dbOps{}
+	_ Finalizer           = dbOps{}
+)
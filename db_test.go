@@ -0,0 +1,575 @@
+package libstore_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cecmp/libstore"
+	"github.com/lib/pq"
+)
+
+// newTestDBOps connects to the PostgreSQL database given by the LIBSTORE_TEST_POSTGRES
+// connection string, skipping the test when it is not set.
+func newTestDBOps(t *testing.T) libstore.Ops {
+	t.Helper()
+	conn := os.Getenv("LIBSTORE_TEST_POSTGRES")
+	if conn == "" {
+		t.Skip("LIBSTORE_TEST_POSTGRES not set")
+	}
+	ops, err := libstore.NewDBOps(context.TODO(), conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ops
+}
+
+// testKey returns a key unique to the running test, so tests can share a database.
+func testKey(t *testing.T) string {
+	return fmt.Sprintf("%s-%d", t.Name(), time.Now().UnixNano())
+}
+
+func TestDBDeleteIfVersion(t *testing.T) {
+	ops := newTestDBOps(t)
+	deleter := ops.(libstore.VersionedDeleter)
+	key := testKey(t)
+
+	if err := ops.Create(context.TODO(), key); err != nil {
+		t.Fatalf("Error creating key: %v", err)
+	}
+	if err := ops.Put(context.TODO(), key, []byte("v1")); err != nil {
+		t.Fatalf("Error putting entry: %v", err)
+	}
+
+	// A concurrent writer moves the key to version 2 after the caller observed version 1.
+	if err := ops.Put(context.TODO(), key, []byte("v2")); err != nil {
+		t.Fatalf("Error putting entry: %v", err)
+	}
+	err := deleter.DeleteIfVersion(context.TODO(), key, 1)
+	var conflictErr libstore.ConflictError
+	if !errors.As(err, &conflictErr) {
+		t.Fatalf("Expected a ConflictError, Got: %v", err)
+	}
+	if value, err := ops.Read(context.TODO(), key); err != nil || string(value) != "v2" {
+		t.Fatalf("Expected key to survive a conflicting delete, Got: %q, %v", value, err)
+	}
+
+	if err := deleter.DeleteIfVersion(context.TODO(), key, 2); err != nil {
+		t.Fatalf("Error deleting key: %v", err)
+	}
+	err = deleter.DeleteIfVersion(context.TODO(), key, 2)
+	var notFoundErr libstore.KeyNotFoundError
+	if !errors.As(err, &notFoundErr) {
+		t.Errorf("Expected a KeyNotFoundError, Got: %v", err)
+	}
+}
+
+func TestDBConcurrentPutVersions(t *testing.T) {
+	ops := newTestDBOps(t)
+	key := testKey(t)
+	if err := ops.Create(context.TODO(), key); err != nil {
+		t.Fatalf("Error creating key: %v", err)
+	}
+
+	const writers, puts = 8, 25
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*puts)
+	for w := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range puts {
+				if err := ops.Put(context.TODO(), key, []byte(fmt.Sprintf("%d-%d", w, i))); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Error putting entry: %v", err)
+	}
+
+	versions, err := libstore.DBVersions(context.TODO(), ops, key)
+	if err != nil {
+		t.Fatalf("Error reading versions: %v", err)
+	}
+	if len(versions) != writers*puts {
+		t.Fatalf("Expected %d versions, Got: %d", writers*puts, len(versions))
+	}
+	for i, version := range versions {
+		if version != int64(i+1) {
+			t.Fatalf("Expected contiguous unique versions, Got %d at position %d", version, i)
+		}
+	}
+}
+
+func TestDBWriteAllRollsBack(t *testing.T) {
+	ctx := context.Background()
+	ops := newTestDBOps(t)
+	key := testKey(t)
+	if err := ops.Create(ctx, key); err != nil {
+		t.Fatalf("Error creating key: %v", err)
+	}
+	if err := ops.Put(ctx, key, []byte("before")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The transaction fails on the missing key after key has been written to.
+	err := libstore.WriteAll(ctx, ops, map[string][]byte{key: []byte("after"), key + "~missing": []byte("x")})
+	var notFound libstore.KeyNotFoundError
+	if !errors.As(err, &notFound) {
+		t.Fatalf("Expected a KeyNotFoundError, Got: %v", err)
+	}
+	entries, err := ops.ReadAll(ctx, key)
+	if err != nil || len(entries) != 1 || string(entries[0]) != "before" {
+		t.Errorf("Expected no partial write, Got: %q, %v", entries, err)
+	}
+}
+
+func TestRetryTxSerializationFailures(t *testing.T) {
+	for _, code := range []pq.ErrorCode{"40001", "40P01"} {
+		calls := 0
+		err := libstore.RetryTx(context.TODO(), func() error {
+			if calls++; calls < 3 {
+				return libstore.DBError("failed to insert entry", &pq.Error{Code: code})
+			}
+			return nil
+		})
+		if err != nil || calls != 3 {
+			t.Errorf("Expected %s to be retried until success, Got: %v after %d calls", code, err, calls)
+		}
+	}
+
+	calls := 0
+	err := libstore.RetryTx(context.TODO(), func() error {
+		calls++
+		return libstore.DBError("failed to insert entry", &pq.Error{Code: "40001"})
+	})
+	var conflict libstore.ConflictError
+	var backendErr *libstore.BackendError
+	if !errors.As(err, &conflict) || !errors.As(err, &backendErr) || backendErr.Code != "40001" {
+		t.Errorf("Expected a ConflictError wrapping the serialization failure, Got: %v", err)
+	}
+	if calls < 2 || calls > 10 {
+		t.Errorf("Expected a bounded number of retries, Got: %d calls", calls)
+	}
+
+	calls = 0
+	cause := libstore.DBError("failed to insert entry", &pq.Error{Code: "23502"})
+	if err := libstore.RetryTx(context.TODO(), func() error { calls++; return cause }); err != cause || calls != 1 {
+		t.Errorf("Expected other errors to be returned at once, Got: %v after %d calls", err, calls)
+	}
+}
+
+// TestDBSerializablePut runs concurrent Puts under SERIALIZABLE isolation, where
+// Postgres aborts the transactions racing for the next version.
+func TestDBSerializablePut(t *testing.T) {
+	conn := os.Getenv("LIBSTORE_TEST_POSTGRES")
+	if conn == "" {
+		t.Skip("LIBSTORE_TEST_POSTGRES not set")
+	}
+	if strings.Contains(conn, "://") {
+		sep := "?"
+		if strings.Contains(conn, "?") {
+			sep = "&"
+		}
+		conn += sep + "default_transaction_isolation=serializable"
+	} else {
+		conn += " default_transaction_isolation=serializable"
+	}
+	ops, err := libstore.NewDBOps(context.TODO(), conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := testKey(t)
+	if err := ops.Create(context.TODO(), key); err != nil {
+		t.Fatalf("Error creating key: %v", err)
+	}
+
+	const writers, puts = 4, 10
+	var wg sync.WaitGroup
+	var succeeded atomic.Int64
+	for w := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range puts {
+				err := ops.Put(context.TODO(), key, []byte(fmt.Sprintf("%d-%d", w, i)))
+				var conflict libstore.ConflictError
+				switch {
+				case err == nil:
+					succeeded.Add(1)
+				case !errors.As(err, &conflict):
+					t.Errorf("Expected serialization failures to be retried or reported as ConflictError, Got: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	versions, err := libstore.DBVersions(context.TODO(), ops, key)
+	if err != nil {
+		t.Fatalf("Error reading versions: %v", err)
+	}
+	if int64(len(versions)) != succeeded.Load() {
+		t.Errorf("Expected %d versions, Got: %d", succeeded.Load(), len(versions))
+	}
+}
+
+func TestDBPutDurable(t *testing.T) {
+	ops := newTestDBOps(t)
+	key := testKey(t)
+	if err := ops.Create(context.TODO(), key); err != nil {
+		t.Fatalf("Error creating key: %v", err)
+	}
+	ctx := libstore.WithOpOptions(context.TODO(), libstore.Durable())
+	if err := ops.Put(ctx, key, []byte("critical")); err != nil {
+		t.Fatalf("Error putting durable entry: %v", err)
+	}
+	if entry, err := ops.Read(context.TODO(), key); err != nil || string(entry) != "critical" {
+		t.Errorf("Unexpected entry: %q, %v", entry, err)
+	}
+}
+
+func TestDBHistory(t *testing.T) {
+	ops := newTestDBOps(t)
+	key := testKey(t)
+	if err := ops.Create(context.TODO(), key); err != nil {
+		t.Fatalf("Error creating key: %v", err)
+	}
+	imported := time.Now().Add(-time.Hour).Truncate(time.Microsecond)
+	if err := ops.(libstore.TimestampPutter).PutAt(context.TODO(), key, []byte("imported"), imported); err != nil {
+		t.Fatal(err)
+	}
+	if err := ops.Put(context.TODO(), key, []byte("latest")); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := libstore.History(context.TODO(), ops, key)
+	if err != nil || len(history) != 2 {
+		t.Fatalf("Unexpected history: %+v, %v", history, err)
+	}
+	if history[0].Version != 1 || string(history[0].Value) != "imported" || !history[0].CreatedAt.Equal(imported) {
+		t.Errorf("Unexpected first version: %+v", history[0])
+	}
+	if history[1].Version != 2 || string(history[1].Value) != "latest" || !history[1].CreatedAt.After(imported) {
+		t.Errorf("Unexpected second version: %+v", history[1])
+	}
+}
+
+func TestDBPutAtVersion(t *testing.T) {
+	ops := newTestDBOps(t)
+	key := testKey(t)
+	if err := ops.Create(context.TODO(), key); err != nil {
+		t.Fatalf("Error creating key: %v", err)
+	}
+	for _, v := range []int64{7, 3, 12} {
+		if err := libstore.PutAtVersion(context.TODO(), ops, key, v, []byte(fmt.Sprintf("v%d", v))); err != nil {
+			t.Fatalf("Error putting version %d: %v", v, err)
+		}
+	}
+
+	entries, err := ops.ReadAll(context.TODO(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, string(e))
+	}
+	if want := []string{"v3", "v7", "v12"}; !slices.Equal(got, want) {
+		t.Errorf("Expected entries in version order %v, Got: %v", want, got)
+	}
+
+	var conflict libstore.ConflictError
+	if err := libstore.PutAtVersion(context.TODO(), ops, key, 7, []byte("again")); !errors.As(err, &conflict) {
+		t.Errorf("Expected a ConflictError for a taken version, Got: %v", err)
+	}
+	var notFound libstore.KeyNotFoundError
+	if err := libstore.PutAtVersion(context.TODO(), ops, key+"-missing", 1, []byte("x")); !errors.As(err, &notFound) {
+		t.Errorf("Expected a KeyNotFoundError, Got: %v", err)
+	}
+
+	// Put continues after the highest imported version.
+	if err := ops.Put(context.TODO(), key, []byte("next")); err != nil {
+		t.Fatal(err)
+	}
+	history, err := libstore.History(context.TODO(), ops, key)
+	if err != nil || history[len(history)-1].Version != 13 {
+		t.Errorf("Expected Put to write version 13, Got: %+v, %v", history, err)
+	}
+}
+
+func TestDBRename(t *testing.T) {
+	ops := newTestDBOps(t)
+	key := testKey(t)
+	if err := ops.Create(context.TODO(), key); err != nil {
+		t.Fatalf("Error creating key: %v", err)
+	}
+	for _, entry := range []string{"v1", "v2", "v3"} {
+		if err := ops.Put(context.TODO(), key, []byte(entry)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before, err := libstore.History(context.TODO(), ops, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renamed := key + "-renamed"
+	if err := libstore.Rename(context.TODO(), ops, key, renamed); err != nil {
+		t.Fatalf("Error renaming key: %v", err)
+	}
+	after, err := libstore.History(context.TODO(), ops, renamed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.EqualFunc(before, after, func(a, b libstore.VersionInfo) bool {
+		return a.Version == b.Version && a.CreatedAt.Equal(b.CreatedAt) && bytes.Equal(a.Value, b.Value)
+	}) {
+		t.Errorf("Expected the history to survive the rename, Got: %+v, want %+v", after, before)
+	}
+	created, err := libstore.CreatedAt(context.TODO(), ops, renamed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var notFound libstore.KeyNotFoundError
+	if _, err := ops.ReadAll(context.TODO(), key); !errors.As(err, &notFound) {
+		t.Errorf("Expected the old key to be gone, Got: %v", err)
+	}
+	if err := libstore.Rename(context.TODO(), ops, key, key+"-other"); !errors.As(err, &notFound) {
+		t.Errorf("Expected a KeyNotFoundError renaming a missing key, Got: %v", err)
+	}
+
+	if err := ops.Create(context.TODO(), key); err != nil {
+		t.Fatal(err)
+	}
+	var keyErr libstore.KeyError
+	if err := libstore.Rename(context.TODO(), ops, key, renamed); !errors.As(err, &keyErr) {
+		t.Errorf("Expected a KeyError renaming onto an existing key, Got: %v", err)
+	}
+	if again, err := libstore.CreatedAt(context.TODO(), ops, renamed); err != nil || !again.Equal(created) {
+		t.Errorf("Expected the refused rename to leave the target untouched, Got: %v, %v", again, err)
+	}
+}
+
+func TestDBVerifyIntegrity(t *testing.T) {
+	ops := newTestDBOps(t)
+	key := testKey(t)
+	if err := ops.Create(context.TODO(), key); err != nil {
+		t.Fatalf("Error creating key: %v", err)
+	}
+	for _, entry := range []string{"v1", "v2"} {
+		if err := ops.Put(context.TODO(), key, []byte(entry)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	report, err := libstore.VerifyIntegrity(context.TODO(), ops, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Contiguous() || report.Versions != 2 || report.MaxVersion != 2 {
+		t.Errorf("Expected versions 1..2, Got: %+v", report)
+	}
+
+	// Versions 3 to 5 are skipped.
+	if err := libstore.PutAtVersion(context.TODO(), ops, key, 6, []byte("v6")); err != nil {
+		t.Fatal(err)
+	}
+	report, err = libstore.VerifyIntegrity(context.TODO(), ops, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []libstore.VersionRange{{From: 3, To: 5}}
+	if report.Contiguous() || !slices.Equal(report.Gaps, want) || report.Versions != 3 || report.MaxVersion != 6 {
+		t.Errorf("Expected the gap %v, Got: %+v", want, report)
+	}
+
+	var notFound libstore.KeyNotFoundError
+	if _, err := libstore.VerifyIntegrity(context.TODO(), ops, key+"-missing"); !errors.As(err, &notFound) {
+		t.Errorf("Expected a KeyNotFoundError, Got: %v", err)
+	}
+}
+
+func TestDBFinalize(t *testing.T) {
+	ops := newTestDBOps(t)
+	key, other := testKey(t), testKey(t)+"-other"
+	for _, k := range []string{key, other} {
+		if err := ops.Create(context.TODO(), k); err != nil {
+			t.Fatalf("Error creating key: %v", err)
+		}
+		if err := ops.Put(context.TODO(), k, []byte("v1")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := libstore.Finalize(context.TODO(), ops, key); err != nil {
+		t.Fatalf("Error finalizing key: %v", err)
+	}
+
+	var immutable libstore.ImmutableError
+	if err := ops.Put(context.TODO(), key, []byte("v2")); !errors.As(err, &immutable) {
+		t.Errorf("Expected an ImmutableError for Put, Got: %v", err)
+	}
+	if err := libstore.PutAtVersion(context.TODO(), ops, key, 5, []byte("v5")); !errors.As(err, &immutable) {
+		t.Errorf("Expected an ImmutableError for PutAtVersion, Got: %v", err)
+	}
+	if err := ops.Delete(context.TODO(), key); !errors.As(err, &immutable) {
+		t.Errorf("Expected an ImmutableError for Delete, Got: %v", err)
+	}
+	if err := libstore.Rename(context.TODO(), ops, key, key+"-renamed"); !errors.As(err, &immutable) {
+		t.Errorf("Expected an ImmutableError for Rename, Got: %v", err)
+	}
+	if entries, err := ops.ReadAll(context.TODO(), key); err != nil || len(entries) != 1 || string(entries[0]) != "v1" {
+		t.Errorf("Expected reads to keep working, Got: %q, %v", entries, err)
+	}
+
+	if err := ops.Put(context.TODO(), other, []byte("v2")); err != nil {
+		t.Errorf("Expected other keys to stay writable, Got: %v", err)
+	}
+}
+
+func TestDBClock(t *testing.T) {
+	conn := os.Getenv("LIBSTORE_TEST_POSTGRES")
+	if conn == "" {
+		t.Skip("LIBSTORE_TEST_POSTGRES not set")
+	}
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	ops, err := libstore.NewDBOps(context.TODO(), conn, libstore.WithDBClock(clock.Now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := testKey(t)
+	if err := ops.Create(context.TODO(), key); err != nil {
+		t.Fatalf("Error creating key: %v", err)
+	}
+	clock.Advance(time.Hour)
+	if err := ops.Put(context.TODO(), key, []byte("entry")); err != nil {
+		t.Fatal(err)
+	}
+
+	created, err := libstore.CreatedAt(context.TODO(), ops, key)
+	if err != nil || !created.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the key to be created at the frozen time, Got: %v, %v", created, err)
+	}
+	history, err := libstore.History(context.TODO(), ops, key)
+	if err != nil || len(history) != 1 || !history[0].CreatedAt.Equal(clock.Now()) {
+		t.Errorf("Expected the entry to be dated by the clock, Got: %+v, %v", history, err)
+	}
+	keys, err := ops.(libstore.ModifiedSinceLister).ListModifiedSince(context.TODO(), time.Date(2024, 1, 1, 0, 30, 0, 0, time.UTC))
+	if err != nil || !slices.Contains(keys, key) {
+		t.Errorf("Expected %s to be modified after the frozen creation, Got: %v, %v", key, keys, err)
+	}
+}
+
+func TestDBLazyConnect(t *testing.T) {
+	ctx := context.Background()
+	// Nothing listens on port 1, so connecting fails at once.
+	ops, err := libstore.NewDBOps(ctx, "postgres://libstore@127.0.0.1:1/libstore?sslmode=disable", libstore.WithDBLazyConnect())
+	if err != nil {
+		t.Fatalf("NewDBOps() error = %v, want nil until the first operation", err)
+	}
+	defer ops.(io.Closer).Close()
+	for range 2 {
+		var locationErr libstore.LocationError
+		if _, err := ops.Read(ctx, "key"); !errors.As(err, &locationErr) {
+			t.Errorf("Expected a LocationError for the unreachable database, Got: %T %v", err, err)
+		}
+	}
+
+	conn := os.Getenv("LIBSTORE_TEST_POSTGRES")
+	if conn == "" {
+		t.Skip("LIBSTORE_TEST_POSTGRES not set")
+	}
+	ops, err = libstore.NewDBOps(ctx, conn, libstore.WithDBLazyConnect())
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := testKey(t)
+	if err := ops.Create(ctx, key); err != nil {
+		t.Fatalf("Error creating key: %v", err)
+	}
+	if err := ops.Put(ctx, key, []byte("entry")); err != nil {
+		t.Fatalf("Error putting entry: %v", err)
+	}
+	if entry, err := ops.Read(ctx, key); err != nil || string(entry) != "entry" {
+		t.Errorf("Unexpected entry: %q, %v", entry, err)
+	}
+}
+
+func TestDBReadWithToken(t *testing.T) {
+	ops := newTestDBOps(t)
+	testReadWithToken(t, ops, testKey(t))
+}
+
+func TestDBIncrement(t *testing.T) {
+	ops := newTestDBOps(t)
+	testIncrement(t, ops, testKey(t))
+}
+
+func TestDBConcurrentCreate(t *testing.T) {
+	ops := newTestDBOps(t)
+	key := testKey(t)
+	defer ops.Delete(context.TODO(), key)
+
+	var created atomic.Int32
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := ops.Create(context.TODO(), key)
+			var keyErr libstore.KeyError
+			switch {
+			case err == nil:
+				created.Add(1)
+			case !errors.As(err, &keyErr):
+				t.Errorf("Expected a KeyError for a lost Create, Got: %T %v", err, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if created.Load() != 1 {
+		t.Errorf("Expected exactly one Create to succeed, Got: %d", created.Load())
+	}
+	entries, err := ops.ReadAll(context.TODO(), key)
+	if err != nil || len(entries) != 0 {
+		t.Errorf("Expected a single empty key, Got: %q, %v", entries, err)
+	}
+}
+
+func TestDBConcurrentNewDBOps(t *testing.T) {
+	conn := os.Getenv("LIBSTORE_TEST_POSTGRES")
+	if conn == "" {
+		t.Skip("LIBSTORE_TEST_POSTGRES not set")
+	}
+	// Every call migrates the table; none may fail on another replacing the finalized
+	// function or creating its trigger at the same time.
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var ops libstore.Ops
+			if ops, errs[i] = libstore.NewDBOps(context.TODO(), conn); errs[i] == nil {
+				errs[i] = libstore.Drain(context.TODO(), ops)
+			}
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		t.Errorf("Expected concurrent NewDBOps calls to succeed, Got: %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package libstore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// dbTx is the TxOps of a transaction of dbOps. Its operations run in the
+// transaction, which commits once the function given to withTx returns.
+type dbTx struct {
+	tx  *sql.Tx
+	now func() time.Time
+}
+
+// withTx implements txContextRunner. fn runs in a transaction committed if it
+// succeeds and rolled back otherwise. A transaction losing a version race or aborted
+// by a serialization failure is retried, running fn again, as the writes of dbOps
+// are.
+func (d dbOps) withTx(ctx context.Context, fn func(tx TxOps) error) error {
+	return d.inVersionTx(ctx, func(tx *sql.Tx) error {
+		return fn(dbTx{tx: tx, now: d.now})
+	})
+}
+
+// Create implements libstore.Ops.
+func (t dbTx) Create(ctx context.Context, key string) error {
+	return createKey(ctx, t.tx, key, t.now())
+}
+
+// CreateIfNotExists implements IdempotentCreator without aborting the transaction
+// when key exists.
+func (t dbTx) CreateIfNotExists(ctx context.Context, key string) (bool, error) {
+	return createKeyIfNotExists(ctx, t.tx, key, t.now())
+}
+
+// ReadAll implements libstore.Ops.
+func (t dbTx) ReadAll(ctx context.Context, key string) ([][]byte, error) {
+	return readAll(ctx, t.tx, key)
+}
+
+// Read implements libstore.Ops.
+func (t dbTx) Read(ctx context.Context, key string) ([]byte, error) {
+	return readLast(ctx, t.tx, key)
+}
+
+// Put implements libstore.Ops.
+func (t dbTx) Put(ctx context.Context, key string, entry []byte) error {
+	return insertNext(ctx, t.tx, key, entry, t.now())
+}
+
+// PutCapped implements CappedPutter.
+func (t dbTx) PutCapped(ctx context.Context, key string, entry []byte, maxEntries int) error {
+	return putCapped(ctx, t.tx, key, entry, maxEntries, t.now())
+}
+
+// Delete implements libstore.Ops.
+func (t dbTx) Delete(ctx context.Context, key string) error {
+	return deleteKey(ctx, t.tx, key)
+}
+
+// List implements libstore.Ops.
+func (t dbTx) List(ctx context.Context) ([]string, error) {
+	return listKeys(ctx, t.tx)
+}
+
+var (
+	_ TxOps             = dbTx{}
+	_ IdempotentCreator = dbTx{}
+	_ CappedPutter      = dbTx{}
+)
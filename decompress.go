@@ -0,0 +1,140 @@
+package libstore
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression formats reported by DetectCompression.
+const (
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+// Magic numbers starting the frames of each compression format.
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// zstdDecoder decodes zstd frames. DecodeAll is safe for concurrent use.
+var zstdDecoder = sync.OnceValues(func() (*zstd.Decoder, error) {
+	return zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+})
+
+// DetectCompression returns the compression format of entry, CompressionGzip or
+// CompressionZstd, recognized by the magic number of its first frame, or "" if
+// entry does not start with a compressed frame.
+func DetectCompression(entry []byte) string {
+	switch {
+	case bytes.HasPrefix(entry, gzipMagic):
+		return CompressionGzip
+	case bytes.HasPrefix(entry, zstdMagic):
+		return CompressionZstd
+	default:
+		return ""
+	}
+}
+
+// Decompress decompresses entry if DetectCompression recognizes its format, and
+// returns it unchanged otherwise. It returns an EntryError if entry starts like a
+// compressed frame but cannot be decompressed.
+func Decompress(entry []byte) ([]byte, error) {
+	var plain []byte
+	var err error
+	switch format := DetectCompression(entry); format {
+	case CompressionGzip:
+		var r *gzip.Reader
+		if r, err = gzip.NewReader(bytes.NewReader(entry)); err == nil {
+			plain, err = io.ReadAll(r)
+		}
+	case CompressionZstd:
+		var d *zstd.Decoder
+		if d, err = zstdDecoder(); err == nil {
+			plain, err = d.DecodeAll(entry, nil)
+		}
+	default:
+		return entry, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", EntryError("decompress: failed to decompress entry"), err)
+	}
+	return plain, nil
+}
+
+// decompressOps decompresses the entries read from the underlying Ops.
+type decompressOps struct {
+	ops Ops
+}
+
+// NewDecompressOps wraps ops so that Read and ReadAll return the gzip and zstd
+// entries they find decompressed, detected with DetectCompression, and the other
+// entries unchanged. Writes go to ops as they are, so compressed and uncompressed
+// entries can be mixed, even under one key, while data is migrated to compression.
+//
+// Compressed entries are binary and may hold line breaks, so on backends that frame
+// entries by newlines, such as the file backend, they are split into several
+// entries that no longer decompress. Use NewCompressStore, which encodes such
+// payloads, to write compressed entries there.
+func NewDecompressOps(ops Ops) Ops {
+	return decompressOps{ops: ops}
+}
+
+// Unwrap implements Unwrapper.
+func (d decompressOps) Unwrap() Ops {
+	return d.ops
+}
+
+// Create implements Ops.
+func (d decompressOps) Create(ctx context.Context, key string) error {
+	return d.ops.Create(ctx, key)
+}
+
+// ReadAll implements Ops.
+func (d decompressOps) ReadAll(ctx context.Context, key string) ([][]byte, error) {
+	entries, err := d.ops.ReadAll(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	for i, entry := range entries {
+		if entries[i], err = Decompress(entry); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// Read implements Ops.
+func (d decompressOps) Read(ctx context.Context, key string) ([]byte, error) {
+	entry, err := d.ops.Read(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return Decompress(entry)
+}
+
+// Put implements Ops.
+func (d decompressOps) Put(ctx context.Context, key string, entry []byte) error {
+	return d.ops.Put(ctx, key, entry)
+}
+
+// Delete implements Ops.
+func (d decompressOps) Delete(ctx context.Context, key string) error {
+	return d.ops.Delete(ctx, key)
+}
+
+// List implements Ops.
+func (d decompressOps) List(ctx context.Context) ([]string, error) {
+	return d.ops.List(ctx)
+}
+
+var (
+	_ Ops       = decompressOps{}
+	_ Unwrapper = decompressOps{}
+)
@@ -0,0 +1,54 @@
+package libstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"hash"
+)
+
+// dedupOps skips writes whose content is identical to the latest entry of the key.
+type dedupOps struct {
+	Ops
+	newHash func() hash.Hash
+}
+
+// NewDedupOps wraps ops so that a Put whose entry hashes to the same SHA-256 digest
+// as the key's latest entry succeeds without writing a new version.
+func NewDedupOps(ops Ops) Ops {
+	return NewDedupOpsWithHash(ops, sha256.New)
+}
+
+// NewDedupOpsWithHash is like NewDedupOps but compares entries using the hash
+// returned by newHash.
+func NewDedupOpsWithHash(ops Ops, newHash func() hash.Hash) Ops {
+	return dedupOps{Ops: ops, newHash: newHash}
+}
+
+// Unwrap implements Unwrapper.
+func (d dedupOps) Unwrap() Ops {
+	return d.Ops
+}
+
+// Put implements Ops.
+func (d dedupOps) Put(ctx context.Context, key string, entry []byte) error {
+	latest, err := d.Ops.Read(ctx, key)
+	if err != nil {
+		var entryErr EntryError
+		if !errors.As(err, &entryErr) {
+			return err
+		}
+	} else if bytes.Equal(d.sum(latest), d.sum(entry)) {
+		return nil
+	}
+	return d.Ops.Put(ctx, key, entry)
+}
+
+func (d dedupOps) sum(entry []byte) []byte {
+	h := d.newHash()
+	h.Write(entry)
+	return h.Sum(nil)
+}
+
+var _ Ops = dedupOps{}
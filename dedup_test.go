@@ -0,0 +1,40 @@
+package libstore_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestDedupOps(t *testing.T) {
+	fileOps, err := libstore.NewFileOps(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops := libstore.NewDedupOps(fileOps)
+	key := "dedup.txt"
+	if err := ops.Create(context.TODO(), key); err != nil {
+		t.Fatalf("Error creating key: %v", err)
+	}
+
+	for _, c := range []string{"payload", "payload", "other", "other", "payload"} {
+		if err := ops.Put(context.TODO(), key, []byte(c)); err != nil {
+			t.Fatalf("Error putting entry: %v", err)
+		}
+	}
+
+	readContent, err := ops.ReadAll(context.TODO(), key)
+	if err != nil {
+		t.Fatalf("Error reading key: %v", err)
+	}
+	expected := []string{"payload", "other", "payload"}
+	if len(readContent) != len(expected) {
+		t.Fatal("Content len mismatch. Expected:", len(expected), "Got", len(readContent))
+	}
+	for i, c := range readContent {
+		if string(c) != expected[i] {
+			t.Error("Content mismatch. Expected:", expected[i], "Got:", string(c))
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package libstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/cecmp/libcipher"
+	"golang.org/x/crypto/hkdf"
+)
+
+// derivedKeySize is the size of the per-key AES-256 subkeys.
+const derivedKeySize = 32
+
+// DerivedCryptStore encrypts each key under its own subkey derived from a master key.
+type DerivedCryptStore struct {
+	storeOps  Ops
+	masterKey []byte
+	rand      io.Reader
+}
+
+// NewDerivedCryptStoreGCM initializes a new DerivedCryptStore instance using GCM encryption
+// with a distinct data key per stored key.
+//
+// Parameters:
+//   - ops: An instance of Ops that defines the underlying storage operations.
+//   - masterKey: A byte slice representing the key from which the per-key subkeys are derived.
+//   - rand: An io.Reader used as a source of randomness, typically crypto/rand.Reader.
+//
+// Returns:
+//   - An Ops instance that wraps the provided storage operations with per-key GCM encryption.
+//   - An error if the master key is not a valid encryption key.
+//
+// Each subkey is derived as HKDF-SHA256(masterKey, keyName), so a compromised subkey
+// only exposes its own key and nonces are drawn from a separate space per key. The
+// subkey is re-derived deterministically from the key name on every operation.
+func NewDerivedCryptStoreGCM(ops Ops, masterKey []byte, rand io.Reader) (Ops, error) {
+	if _, err := libcipher.NewGCMDecryptor(masterKey); err != nil {
+		return nil, err
+	}
+	return DerivedCryptStore{storeOps: ops, masterKey: masterKey, rand: rand}, nil
+}
+
+// cryptStore returns a CryptStore encrypting under the subkey of the given key.
+func (m DerivedCryptStore) cryptStore(key string) (CryptStore, error) {
+	subkey := make([]byte, derivedKeySize)
+	kdf := hkdf.New(sha256.New, m.masterKey, nil, []byte("libstore/cryptstore/"+key))
+	if _, err := io.ReadFull(kdf, subkey); err != nil {
+		return CryptStore{}, fmt.Errorf("%w: %w", DecryptionError("failed to derive subkey"), err)
+	}
+	encryptor, err := libcipher.NewGCMEncryptor(subkey, m.rand)
+	if err != nil {
+		return CryptStore{}, err
+	}
+	decryptor, err := libcipher.NewGCMDecryptor(subkey)
+	if err != nil {
+		return CryptStore{}, err
+	}
+	return CryptStore{storeOps: m.storeOps, encryptor: encryptor, decryptor: decryptor}, nil
+}
+
+// Put implements libstore.Ops.
+func (m DerivedCryptStore) Put(ctx context.Context, key string, entry []byte) error {
+	cs, err := m.cryptStore(key)
+	if err != nil {
+		return err
+	}
+	return cs.Put(ctx, key, entry)
+}
+
+// PutAt implements libstore.TimestampPutter.
+func (m DerivedCryptStore) PutAt(ctx context.Context, key string, entry []byte, ts time.Time) error {
+	cs, err := m.cryptStore(key)
+	if err != nil {
+		return err
+	}
+	return cs.PutAt(ctx, key, entry, ts)
+}
+
+// Create implements libstore.Ops.
+func (m DerivedCryptStore) Create(ctx context.Context, key string) error {
+	return m.storeOps.Create(ctx, key)
+}
+
+// Delete implements libstore.Ops.
+func (m DerivedCryptStore) Delete(ctx context.Context, key string) error {
+	return m.storeOps.Delete(ctx, key)
+}
+
+// List implements libstore.Ops.
+func (m DerivedCryptStore) List(ctx context.Context) ([]string, error) {
+	return m.storeOps.List(ctx)
+}
+
+// Unwrap implements Unwrapper.
+func (m DerivedCryptStore) Unwrap() Ops {
+	return m.storeOps
+}
+
+// Read implements libstore.Ops.
+func (m DerivedCryptStore) Read(ctx context.Context, key string) ([]byte, error) {
+	cs, err := m.cryptStore(key)
+	if err != nil {
+		return nil, err
+	}
+	return cs.Read(ctx, key)
+}
+
+// ReadAll implements libstore.Ops.
+func (m DerivedCryptStore) ReadAll(ctx context.Context, key string) ([][]byte, error) {
+	cs, err := m.cryptStore(key)
+	if err != nil {
+		return nil, err
+	}
+	return cs.ReadAll(ctx, key)
+}
+
+var _ Ops = DerivedCryptStore{}
@@ -0,0 +1,57 @@
+package libstore_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestDerivedCryptStoreGCM(t *testing.T) {
+	backend := libstore.NewInMemoryOps()
+	masterKey := bytes.Repeat([]byte{0x42}, 32)
+	ops, err := libstore.NewDerivedCryptStoreGCM(backend, masterKey, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext := []byte("same secret")
+	for _, key := range []string{"alice", "bob"} {
+		if err := ops.Create(context.TODO(), key); err != nil {
+			t.Fatalf("Error creating key: %v", err)
+		}
+		if err := ops.Put(context.TODO(), key, plaintext); err != nil {
+			t.Fatalf("Error putting entry: %v", err)
+		}
+		value, err := ops.Read(context.TODO(), key)
+		if err != nil {
+			t.Fatalf("Error reading key %s: %v", key, err)
+		}
+		if !bytes.Equal(value, plaintext) {
+			t.Errorf("Plaintext mismatch for %s. Expected: %s Got: %s", key, plaintext, value)
+		}
+	}
+
+	// A vault sealed under one key's subkey must not open under another's.
+	aliceVault, err := backend.Read(context.TODO(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.Put(context.TODO(), "bob", aliceVault); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ops.Read(context.TODO(), "bob"); err == nil {
+		t.Error("Expected an error decrypting a vault sealed under another key's subkey")
+	}
+
+	// The same master key re-derives the same subkeys.
+	reopened, err := libstore.NewDerivedCryptStoreGCM(backend, masterKey, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, err := reopened.Read(context.TODO(), "alice"); err != nil || !bytes.Equal(value, plaintext) {
+		t.Errorf("Expected to decrypt with a re-derived subkey, Got: %q, %v", value, err)
+	}
+}
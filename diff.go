@@ -0,0 +1,187 @@
+package libstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// DiffFormat renders the difference between two versions of key.
+type DiffFormat func(key string, from, to VersionInfo) ([]byte, error)
+
+// Diff returns the difference between versions fromVersion and toVersion of key, as
+// numbered by History, rendered by format. A nil format picks UnifiedDiff when both
+// versions are valid UTF-8 and BinaryDiff otherwise.
+//
+// The versions are fetched with History, so Diff works on every backend. It returns
+// an EntryError if either version does not exist.
+func Diff(ctx context.Context, ops Ops, key string, fromVersion, toVersion int64, format DiffFormat) ([]byte, error) {
+	history, err := History(ctx, ops, key)
+	if err != nil {
+		return nil, err
+	}
+	from, err := findVersion(history, key, fromVersion)
+	if err != nil {
+		return nil, err
+	}
+	to, err := findVersion(history, key, toVersion)
+	if err != nil {
+		return nil, err
+	}
+	if format == nil {
+		format = BinaryDiff
+		if utf8.Valid(from.Value) && utf8.Valid(to.Value) {
+			format = UnifiedDiff
+		}
+	}
+	return format(key, from, to)
+}
+
+// findVersion returns the given version from history.
+func findVersion(history []VersionInfo, key string, version int64) (VersionInfo, error) {
+	for _, v := range history {
+		if v.Version == version {
+			return v, nil
+		}
+	}
+	return VersionInfo{}, EntryError(fmt.Sprintf("diff: version %d of key %s not found", version, key))
+}
+
+// UnifiedDiff is a DiffFormat rendering a line-based unified diff with three lines
+// of context, as written by diff -u, labelling the versions key@N with their write
+// time when the backend records it.
+func UnifiedDiff(key string, from, to VersionInfo) ([]byte, error) {
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        diffLines(from.Value),
+		B:        diffLines(to.Value),
+		FromFile: fmt.Sprintf("%s@%d", key, from.Version),
+		ToFile:   fmt.Sprintf("%s@%d", key, to.Version),
+		FromDate: diffDate(from.CreatedAt),
+		ToDate:   diffDate(to.CreatedAt),
+		Context:  3,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", OpsInternalError("diff: rendering unified diff"), err)
+	}
+	return []byte(diff), nil
+}
+
+// diffLines splits value into lines, keeping their line feeds.
+func diffLines(value []byte) []string {
+	lines := strings.SplitAfter(string(value), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffDate formats t for a unified diff header, or returns "" for the zero time.
+func diffDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// Delta opcodes of BinaryDiff.
+const (
+	deltaCopy byte = 'C'
+	deltaAdd  byte = 'A'
+)
+
+// deltaBlock is the length of the blocks of the old version BinaryDiff looks up.
+const deltaBlock = 16
+
+// BinaryDiff is a DiffFormat rendering a byte-level delta that ApplyBinaryDiff turns
+// back into the newer version. The delta is a sequence of operations, each either
+// 'C' followed by the uvarint offset and length of bytes copied from the older
+// version, or 'A' followed by a uvarint length and that many bytes added.
+//
+// Runs of at least 16 bytes shared with the older version, wherever they are, are
+// copied rather than added; shorter changes cost their length in added bytes.
+func BinaryDiff(key string, from, to VersionInfo) ([]byte, error) {
+	old, data := from.Value, to.Value
+	blocks := make(map[string]int)
+	for off := 0; off+deltaBlock <= len(old); off += deltaBlock {
+		if _, ok := blocks[string(old[off:off+deltaBlock])]; !ok {
+			blocks[string(old[off:off+deltaBlock])] = off
+		}
+	}
+
+	var delta []byte
+	added := 0
+	flush := func(end int) {
+		if end > added {
+			delta = append(delta, deltaAdd)
+			delta = binary.AppendUvarint(delta, uint64(end-added))
+			delta = append(delta, data[added:end]...)
+		}
+	}
+	for i := 0; i+deltaBlock <= len(data); {
+		off, ok := blocks[string(data[i:i+deltaBlock])]
+		if !ok {
+			i++
+			continue
+		}
+		// Extend the match backwards into the pending bytes and forwards.
+		start := i
+		for start > added && off > 0 && old[off-1] == data[start-1] {
+			start--
+			off--
+		}
+		end := i + deltaBlock
+		for end < len(data) && off+end-start < len(old) && old[off+end-start] == data[end] {
+			end++
+		}
+		flush(start)
+		delta = append(delta, deltaCopy)
+		delta = binary.AppendUvarint(delta, uint64(off))
+		delta = binary.AppendUvarint(delta, uint64(end-start))
+		added, i = end, end
+	}
+	flush(len(data))
+	return delta, nil
+}
+
+// ApplyBinaryDiff applies a delta written by BinaryDiff to the older version it was
+// computed from and returns the newer version. It returns an EntryError if the delta
+// is malformed or does not fit old.
+func ApplyBinaryDiff(old, delta []byte) ([]byte, error) {
+	var res []byte
+	r := bytes.NewReader(delta)
+	for r.Len() > 0 {
+		op, _ := r.ReadByte()
+		switch op {
+		case deltaCopy:
+			off, err := binary.ReadUvarint(r)
+			if err != nil {
+				return nil, EntryError("diff: truncated copy offset")
+			}
+			n, err := binary.ReadUvarint(r)
+			if err != nil {
+				return nil, EntryError("diff: truncated copy length")
+			}
+			if off > uint64(len(old)) || n > uint64(len(old))-off {
+				return nil, EntryError(fmt.Sprintf("diff: copy of %d bytes at %d exceeds the %d bytes of the old version", n, off, len(old)))
+			}
+			res = append(res, old[off:off+n]...)
+		case deltaAdd:
+			n, err := binary.ReadUvarint(r)
+			if err != nil || n > uint64(r.Len()) {
+				return nil, EntryError("diff: truncated added bytes")
+			}
+			added := make([]byte, n)
+			_, _ = r.Read(added)
+			res = append(res, added...)
+		default:
+			return nil, EntryError(fmt.Sprintf("diff: unknown operation %q", op))
+		}
+	}
+	return res, nil
+}
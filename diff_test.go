@@ -0,0 +1,92 @@
+package libstore_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+// newVersionedOps returns an Ops keeping every entry of "key", the given versions
+// in order.
+func newVersionedOps(t *testing.T, versions ...[]byte) libstore.Ops {
+	t.Helper()
+	ops := libstore.NewInMemoryOps()
+	if err := ops.Create(context.Background(), "key"); err != nil {
+		t.Fatal(err)
+	}
+	for _, version := range versions {
+		if err := libstore.PutCapped(context.Background(), ops, "key", version, len(versions)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ops
+}
+
+func TestDiffUnified(t *testing.T) {
+	ops := newVersionedOps(t, []byte("one\ntwo\nthree\n"), []byte("one\n2\nthree\nfour\n"))
+	diff, err := libstore.Diff(context.Background(), ops, "key", 1, 2, nil)
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	// The header carries the write time of the latest version, which varies.
+	header, hunk, _ := strings.Cut(string(diff), "@@")
+	if !strings.HasPrefix(header, "--- key@1\n+++ key@2") {
+		t.Errorf("Diff() header = %q, want versions labelled key@1 and key@2", header)
+	}
+	want := " -1,3 +1,4 @@\n one\n-two\n+2\n three\n+four\n"
+	if hunk != want {
+		t.Errorf("Diff() =\n%s\nwant\n%s", diff, want)
+	}
+
+	var entryErr libstore.EntryError
+	if _, err := libstore.Diff(context.Background(), ops, "key", 1, 3, nil); !errors.As(err, &entryErr) {
+		t.Errorf("Diff() with a missing version error = %v, want EntryError", err)
+	}
+}
+
+func TestDiffBinary(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	old := make([]byte, 4096)
+	rng.Read(old)
+	updated := bytes.Clone(old)
+	updated[100] ^= 0xff
+	updated = append(updated[:2000], append([]byte("inserted"), updated[2000:]...)...)
+	updated = append(updated[:3000], updated[3500:]...)
+	updated = append(updated, 0xfe, 0xff)
+
+	ops := newVersionedOps(t, old, updated)
+	for name, format := range map[string]libstore.DiffFormat{"auto": nil, "binary": libstore.BinaryDiff} {
+		delta, err := libstore.Diff(context.Background(), ops, "key", 1, 2, format)
+		if err != nil {
+			t.Fatalf("%s: Diff() error = %v", name, err)
+		}
+		if len(delta) > 200 {
+			t.Errorf("%s: Expected a small delta for a few changes, Got: %d bytes", name, len(delta))
+		}
+		got, err := libstore.ApplyBinaryDiff(old, delta)
+		if err != nil || !bytes.Equal(got, updated) {
+			t.Errorf("%s: ApplyBinaryDiff() did not restore the new version: %v", name, err)
+		}
+	}
+
+	// Unrelated and empty versions round-trip too.
+	for _, pair := range [][2][]byte{{nil, []byte("new")}, {[]byte("old"), nil}, {old, []byte("short")}} {
+		delta, err := libstore.BinaryDiff("key", libstore.VersionInfo{Value: pair[0]}, libstore.VersionInfo{Value: pair[1]})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := libstore.ApplyBinaryDiff(pair[0], delta); err != nil || !bytes.Equal(got, pair[1]) {
+			t.Errorf("ApplyBinaryDiff() = %q, %v, want %q", got, err, pair[1])
+		}
+	}
+
+	var entryErr libstore.EntryError
+	if _, err := libstore.ApplyBinaryDiff([]byte("old"), []byte{'C', 0, 10}); !errors.As(err, &entryErr) {
+		t.Errorf("ApplyBinaryDiff() with an out of range copy error = %v, want EntryError", err)
+	}
+}
@@ -0,0 +1,210 @@
+package libstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+)
+
+// Drainer is implemented by Ops that hold in-flight work or background goroutines and
+// can shut down gracefully.
+type Drainer interface {
+	// Drain stops accepting new operations, waits for in-flight ones to finish, stops
+	// background goroutines and then drains or closes the underlying Ops.
+	Drain(ctx context.Context) error
+}
+
+// Unwrapper is implemented by wrappers to expose the Ops they wrap, so Drain can reach
+// the backend through wrappers that hold no state of their own.
+type Unwrapper interface {
+	Unwrap() Ops
+}
+
+// Drain shuts ops down gracefully. It calls Drain on a Drainer and Close on an
+// io.Closer; otherwise it unwraps an Unwrapper and drains what it wraps. Ops that are
+// none of these hold nothing to release and are left as they are.
+//
+// A stack of wrappers is shut down from the outside in. Every wrapper keeps the Ops
+// it wraps, and one owning resources, such as the flusher of NewBufferedWriteOps or
+// the worker of NewSerializedOps, implements Drainer or io.Closer: it first stops
+// taking calls and releases its own resources, flushing what it buffered, and only
+// then drains the Ops it wraps. Wrappers owning nothing implement Unwrapper instead.
+// Draining the outermost Ops therefore reaches the backend last, once nothing above
+// it has writes left to send.
+func Drain(ctx context.Context, ops Ops) error {
+	switch o := ops.(type) {
+	case Drainer:
+		return o.Drain(ctx)
+	case io.Closer:
+		return o.Close()
+	case Unwrapper:
+		return Drain(ctx, o.Unwrap())
+	default:
+		return nil
+	}
+}
+
+// CloseAll shuts down every ops in turn, in the order given, with Drain and no
+// deadline, so each stack of wrappers is closed from the outside in as Drain
+// describes. Every ops is closed even if closing an earlier one failed, and the
+// errors of all of them are returned joined.
+func CloseAll(ops ...Ops) error {
+	errs := make([]error, 0, len(ops))
+	for _, o := range ops {
+		errs = append(errs, Drain(context.Background(), o))
+	}
+	return errors.Join(errs...)
+}
+
+// drainOps tracks the calls in flight through it so they can be drained.
+type drainOps struct {
+	ops Ops
+
+	mu       sync.Mutex
+	draining bool
+	active   sync.WaitGroup
+
+	// abort is cancelled when a Drain deadline expires, cancelling in-flight calls.
+	abort       context.Context
+	cancelAbort context.CancelFunc
+
+	drainOnce sync.Once
+	drainErr  error
+}
+
+// DrainableOps is an Ops that can be drained and closed. See NewDrainableOps.
+type DrainableOps interface {
+	Ops
+	Drainer
+	io.Closer
+}
+
+// NewDrainableOps wraps ops so that it can be shut down gracefully with Drain or Close.
+//
+// Once draining starts, new calls fail with a ClosedError. Drain waits for the calls
+// already in flight, then drains ops with the package-level Drain, which reaches the
+// backend through the wrappers in between and stops its background goroutines, such
+// as the sweeper of NewInMemoryOpsWithSweeper.
+//
+// If the Drain context ends first, the contexts of the in-flight calls are cancelled,
+// so retries and waits below them give up. Drain still waits for those calls to
+// return before draining ops, and then returns the context's error. A backend that
+// ignores cancellation therefore delays Drain past its deadline.
+func NewDrainableOps(ops Ops) DrainableOps {
+	abort, cancel := context.WithCancel(context.Background())
+	return &drainOps{ops: ops, abort: abort, cancelAbort: cancel}
+}
+
+// begin registers a call, returning its context and the function ending it.
+func (d *drainOps) begin(ctx context.Context) (context.Context, func(), error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.draining {
+		return nil, nil, ClosedError("ops is draining")
+	}
+	d.active.Add(1)
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(d.abort, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+		d.active.Done()
+	}, nil
+}
+
+// Drain implements Drainer. Calling it again returns the result of the first call.
+func (d *drainOps) Drain(ctx context.Context) error {
+	d.drainOnce.Do(func() {
+		d.mu.Lock()
+		d.draining = true
+		d.mu.Unlock()
+
+		idle := make(chan struct{})
+		go func() {
+			d.active.Wait()
+			close(idle)
+		}()
+		var err error
+		select {
+		case <-idle:
+		case <-ctx.Done():
+			err = ctx.Err()
+			d.cancelAbort()
+			<-idle
+		}
+		d.cancelAbort()
+		d.drainErr = errors.Join(err, Drain(ctx, d.ops))
+	})
+	return d.drainErr
+}
+
+// Close drains without a deadline.
+func (d *drainOps) Close() error {
+	return d.Drain(context.Background())
+}
+
+// Unwrap implements Unwrapper.
+func (d *drainOps) Unwrap() Ops {
+	return d.ops
+}
+
+// Create implements Ops.
+func (d *drainOps) Create(ctx context.Context, key string) error {
+	ctx, end, err := d.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer end()
+	return d.ops.Create(ctx, key)
+}
+
+// ReadAll implements Ops.
+func (d *drainOps) ReadAll(ctx context.Context, key string) ([][]byte, error) {
+	ctx, end, err := d.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+	return d.ops.ReadAll(ctx, key)
+}
+
+// Read implements Ops.
+func (d *drainOps) Read(ctx context.Context, key string) ([]byte, error) {
+	ctx, end, err := d.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+	return d.ops.Read(ctx, key)
+}
+
+// Put implements Ops.
+func (d *drainOps) Put(ctx context.Context, key string, entry []byte) error {
+	ctx, end, err := d.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer end()
+	return d.ops.Put(ctx, key, entry)
+}
+
+// Delete implements Ops.
+func (d *drainOps) Delete(ctx context.Context, key string) error {
+	ctx, end, err := d.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer end()
+	return d.ops.Delete(ctx, key)
+}
+
+// List implements Ops.
+func (d *drainOps) List(ctx context.Context) ([]string, error) {
+	ctx, end, err := d.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+	return d.ops.List(ctx)
+}
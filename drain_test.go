@@ -0,0 +1,157 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/cecmp/libstore"
+)
+
+// blockingOps blocks every Put until release is closed or the call's context ends.
+type blockingOps struct {
+	libstore.Ops
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b blockingOps) Put(ctx context.Context, key string, entry []byte) error {
+	b.started <- struct{}{}
+	select {
+	case <-b.release:
+		return b.Ops.Put(ctx, key, entry)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func newBlockingOps(t *testing.T) blockingOps {
+	ops := libstore.NewInMemoryOps()
+	if err := ops.Create(context.TODO(), "key"); err != nil {
+		t.Fatal(err)
+	}
+	return blockingOps{Ops: ops, started: make(chan struct{}), release: make(chan struct{})}
+}
+
+func TestDrainWaitsForInFlight(t *testing.T) {
+	backend := newBlockingOps(t)
+	ops := libstore.NewDrainableOps(backend)
+
+	putErr := make(chan error)
+	go func() { putErr <- ops.Put(context.TODO(), "key", []byte("entry")) }()
+	<-backend.started
+
+	drained := make(chan error)
+	go func() { drained <- ops.Drain(context.TODO()) }()
+
+	// Wait until draining has begun and new calls are refused.
+	var closedErr libstore.ClosedError
+	for !errors.As(ops.Create(context.TODO(), "other"), &closedErr) {
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case err := <-drained:
+		t.Fatalf("Drain returned with a call in flight: %v", err)
+	default:
+	}
+
+	close(backend.release)
+	if err := <-putErr; err != nil {
+		t.Errorf("Error in in-flight Put: %v", err)
+	}
+	if err := <-drained; err != nil {
+		t.Errorf("Error draining: %v", err)
+	}
+}
+
+func TestDrainDeadlineCancelsInFlight(t *testing.T) {
+	backend := newBlockingOps(t)
+	ops := libstore.NewDrainableOps(backend)
+
+	putErr := make(chan error)
+	go func() { putErr <- ops.Put(context.TODO(), "key", []byte("entry")) }()
+	<-backend.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := ops.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the Drain deadline to be reported, Got: %v", err)
+	}
+	if err := <-putErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the in-flight Put to be cancelled, Got: %v", err)
+	}
+}
+
+func TestCloseStopsGoroutines(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	ops := libstore.Chain(libstore.NewInMemoryOpsWithSweeper(time.Millisecond),
+		libstore.WithDrain(),
+		libstore.WithKeyValidation(libstore.FileKeyRules),
+		libstore.WithDedup(),
+	)
+	if err := ops.Create(context.TODO(), "key"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ops.(libstore.DrainableOps).Close(); err != nil {
+		t.Fatalf("Error closing: %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("Goroutines leaked: %d before, %d after Close", before, runtime.NumGoroutine())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// closeRecordingOps records in log when it is closed, along with the entries its
+// ops then holds for key.
+type closeRecordingOps struct {
+	libstore.Ops
+	name string
+	log  *[]string
+	err  error
+}
+
+func (c closeRecordingOps) Close() error {
+	entries, _ := c.Ops.ReadAll(context.Background(), "key")
+	*c.log = append(*c.log, fmt.Sprintf("%s %q", c.name, entries))
+	return c.err
+}
+
+func TestCloseAll(t *testing.T) {
+	ctx := context.Background()
+	var log []string
+	backend := libstore.NewInMemoryOps()
+	if err := backend.Create(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	// The buffered writes only reach the backend when the stack is closed.
+	buffered := libstore.NewBufferedWriteOps(closeRecordingOps{Ops: backend, name: "backend", log: &log}, time.Hour, 100)
+	stack := libstore.Chain(buffered, libstore.WithDrain(), libstore.WithPrefix(""), libstore.WithSerialization())
+	if err := stack.Put(ctx, "key", []byte("buffered")); err != nil {
+		t.Fatal(err)
+	}
+
+	errClose := errors.New("close failed")
+	failing := closeRecordingOps{Ops: libstore.NewInMemoryOps(), name: "failing", log: &log, err: errClose}
+	other := closeRecordingOps{Ops: libstore.NewInMemoryOps(), name: "other", log: &log}
+	if err := libstore.CloseAll(failing, stack, other); !errors.Is(err, errClose) {
+		t.Errorf("Expected the error of the failing Close, Got: %v", err)
+	}
+	want := []string{`failing []`, `backend ["buffered"]`, `other []`}
+	if !slices.Equal(log, want) {
+		t.Errorf("Expected closes %q, Got: %q", want, log)
+	}
+
+	var closedErr libstore.ClosedError
+	if err := stack.Put(ctx, "key", []byte("late")); !errors.As(err, &closedErr) {
+		t.Errorf("Expected a ClosedError after CloseAll, Got: %v", err)
+	}
+}
@@ -0,0 +1,37 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+// TestReadEmptyKey checks that every backend reports a created but never written key
+// the same way, so callers can handle it without knowing the backend.
+func TestReadEmptyKey(t *testing.T) {
+	ctx := context.Background()
+	backends := testBackends(t, "InMemory", "File", "Git", "LogFile", "DB")
+	for name, newOps := range backends {
+		t.Run(name, func(t *testing.T) {
+			ops := newOps(t)
+			// Subtest names contain a slash, which file-based backends treat as a directory.
+			key := filepath.Base(testKey(t))
+			if err := ops.Create(ctx, key); err != nil {
+				t.Fatalf("Error creating key: %v", err)
+			}
+			value, err := ops.Read(ctx, key)
+			var entryErr libstore.EntryError
+			if !errors.As(err, &entryErr) || value != nil {
+				t.Errorf("Expected an EntryError and no value, Got: %q, %v", value, err)
+			}
+			_, err = ops.Read(ctx, key+"-missing")
+			var notFoundErr libstore.KeyNotFoundError
+			if !errors.As(err, &notFoundErr) {
+				t.Errorf("Expected a KeyNotFoundError, Got: %v", err)
+			}
+		})
+	}
+}
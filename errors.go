@@ -1,9 +1,59 @@
 package libstore
 
 import (
+	"context"
 	"errors"
+	"fmt"
 )
 
+// BackendError is an OpsInternalError carrying the structured details of the failed
+// backend call, so callers can act on provider codes without parsing messages.
+//
+// errors.As also matches a BackendError against OpsInternalError, so existing checks
+// for OpsInternalError keep working.
+type BackendError struct {
+	// Backend names the backend that failed, such as "postgres" or "s3".
+	Backend string
+	// Op describes the operation that failed.
+	Op string
+	// Code is the provider-specific error code, such as a Postgres SQLSTATE or an S3
+	// error code. It is empty when the provider reported none.
+	Code string
+	// Err is the error returned by the provider.
+	Err error
+}
+
+func (e *BackendError) Error() string {
+	msg := "libstore: " + e.Op + ": " + e.Err.Error()
+	if e.Code != "" {
+		msg += " (" + e.Backend + " code " + e.Code + ")"
+	}
+	return msg
+}
+
+func (e *BackendError) Unwrap() error {
+	return e.Err
+}
+
+// As makes errors.As report a BackendError as an OpsInternalError.
+func (e *BackendError) As(target any) bool {
+	if t, ok := target.(*OpsInternalError); ok {
+		*t = OpsInternalError(e.Op)
+		return true
+	}
+	return false
+}
+
+// annotateTimeout wraps err in a TimeoutError naming op if err stems from a cancelled
+// or expired context, so timeouts are recognizable whatever the provider wrapped
+// around the context's error. Other errors are returned unchanged.
+func annotateTimeout(op string, err error) error {
+	if !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", TimeoutError(op+": context done"), err)
+}
+
 type ErrorCode int
 
 const (
@@ -13,6 +63,15 @@ const (
 	ErrEntry
 	ErrOpsInternal
 	ErrKeyNotFound
+	ErrUnsupported
+	ErrConflict
+	ErrClosed
+	ErrPermission
+	ErrTimeout
+	ErrPrecondition
+	ErrImmutable
+	ErrVersionLimit
+	ErrFenced
 )
 
 type Error struct {
@@ -28,14 +87,32 @@ func NewError(err error) *Error {
 	switch err.(type) {
 	case LocationError:
 		return &Error{Code: ErrLocation, Message: err.Error()}
-	case KeyError:
+	case KeyError, *ExistingKeysError:
 		return &Error{Code: ErrKey, Message: err.Error()}
 	case EntryError:
 		return &Error{Code: ErrEntry, Message: err.Error()}
-	case OpsInternalError:
+	case OpsInternalError, *BackendError:
 		return &Error{Code: ErrOpsInternal, Message: err.Error()}
 	case KeyNotFoundError:
 		return &Error{Code: ErrKeyNotFound, Message: err.Error()}
+	case UnsupportedError:
+		return &Error{Code: ErrUnsupported, Message: err.Error()}
+	case ConflictError:
+		return &Error{Code: ErrConflict, Message: err.Error()}
+	case ClosedError:
+		return &Error{Code: ErrClosed, Message: err.Error()}
+	case PermissionError:
+		return &Error{Code: ErrPermission, Message: err.Error()}
+	case TimeoutError:
+		return &Error{Code: ErrTimeout, Message: err.Error()}
+	case PreconditionError:
+		return &Error{Code: ErrPrecondition, Message: err.Error()}
+	case ImmutableError:
+		return &Error{Code: ErrImmutable, Message: err.Error()}
+	case VersionLimitError:
+		return &Error{Code: ErrVersionLimit, Message: err.Error()}
+	case FencedError:
+		return &Error{Code: ErrFenced, Message: err.Error()}
 	default:
 		return &Error{Code: ErrUnknown, Message: "unknown error"}
 	}
@@ -53,6 +130,24 @@ func TranslateToError(code int, message string) error {
 		return OpsInternalError(message)
 	case 5:
 		return KeyNotFoundError(message)
+	case 6:
+		return UnsupportedError(message)
+	case 7:
+		return ConflictError(message)
+	case 8:
+		return ClosedError(message)
+	case 9:
+		return PermissionError(message)
+	case 10:
+		return TimeoutError(message)
+	case 11:
+		return PreconditionError(message)
+	case 12:
+		return ImmutableError(message)
+	case 13:
+		return VersionLimitError(message)
+	case 14:
+		return FencedError(message)
 	default:
 		return errors.New(message)
 	}
@@ -0,0 +1,35 @@
+package libstore_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/cecmp/libstore"
+	"github.com/lib/pq"
+)
+
+func TestBackendError(t *testing.T) {
+	cause := &pq.Error{Code: "40001", Message: "could not serialize access"}
+	err := fmt.Errorf("put: %w", libstore.DBError("failed to replace entry", cause))
+
+	var backendErr *libstore.BackendError
+	if !errors.As(err, &backendErr) {
+		t.Fatalf("Expected a BackendError, Got: %v", err)
+	}
+	if backendErr.Backend != "postgres" || backendErr.Op != "failed to replace entry" || backendErr.Code != "40001" {
+		t.Errorf("Unexpected structured fields: %+v", backendErr)
+	}
+
+	var internalErr libstore.OpsInternalError
+	if !errors.As(err, &internalErr) {
+		t.Error("Expected a BackendError to match OpsInternalError")
+	}
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr != cause {
+		t.Error("Expected the provider error to be unwrapped")
+	}
+	if code := libstore.NewError(backendErr).Code; code != libstore.ErrOpsInternal {
+		t.Errorf("Expected ErrOpsInternal, Got: %v", code)
+	}
+}
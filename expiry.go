@@ -0,0 +1,28 @@
+package libstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// PutWithExpiry replaces the entries of key with entry and makes the key expire at
+// expiresAt, for instance to align expiry with the end of a day. Once expired, the
+// key reads as not found. It returns an EntryError if expiresAt is not in the future.
+//
+// Backends implementing ExpiryPutter store the absolute time. Backends implementing
+// only TTLPutter are given the time remaining until expiresAt. For others it returns
+// an UnsupportedError.
+func PutWithExpiry(ctx context.Context, ops Ops, key string, entry []byte, expiresAt time.Time) error {
+	if putter, ok := ops.(ExpiryPutter); ok {
+		return putter.PutWithExpiry(ctx, key, entry, expiresAt)
+	}
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return EntryError(fmt.Sprintf("expiry %s of key %s is not in the future", expiresAt.Format(time.RFC3339), key))
+	}
+	if putter, ok := ops.(TTLPutter); ok {
+		return putter.PutWithTTL(ctx, key, entry, ttl)
+	}
+	return UnsupportedError("expiry is not supported by this backend")
+}
@@ -0,0 +1,28 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestPutWithExpiryFallback(t *testing.T) {
+	ops := newRecordingOps(libstore.NewInMemoryOps())
+	if err := ops.Create(context.TODO(), "key"); err != nil {
+		t.Fatal(err)
+	}
+
+	var unsupported libstore.UnsupportedError
+	err := libstore.PutWithExpiry(context.TODO(), ops, "key", []byte("value"), time.Now().Add(time.Hour))
+	if !errors.As(err, &unsupported) {
+		t.Errorf("Expected an UnsupportedError, Got: %v", err)
+	}
+	var entryErr libstore.EntryError
+	err = libstore.PutWithExpiry(context.TODO(), ops, "key", []byte("value"), time.Now().Add(-time.Hour))
+	if !errors.As(err, &entryErr) {
+		t.Errorf("Expected an EntryError for a past expiry, Got: %v", err)
+	}
+}
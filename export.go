@@ -0,0 +1,119 @@
+package libstore
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// ExportColumn is a column written by ExportCSV.
+type ExportColumn string
+
+const (
+	// ExportKey is the key of the entry.
+	ExportKey ExportColumn = "key"
+	// ExportVersion is the version number of the entry, as reported by History.
+	ExportVersion ExportColumn = "version"
+	// ExportCreatedAt is when the entry was written, in RFC 3339 format, or empty if
+	// the backend does not track it.
+	ExportCreatedAt ExportColumn = "created_at"
+	// ExportSize is the length of the entry in bytes.
+	ExportSize ExportColumn = "size"
+	// ExportValue is the entry itself, base64-encoded.
+	ExportValue ExportColumn = "value"
+)
+
+// defaultExportColumns are the columns written when ExportOpts names none. They
+// leave out the raw values, which may be large or sensitive.
+var defaultExportColumns = []ExportColumn{ExportKey, ExportVersion, ExportCreatedAt, ExportSize}
+
+// ExportOpts configures ExportCSV.
+type ExportOpts struct {
+	// Columns are the columns to write, in order. It defaults to key, version,
+	// created_at and size; add ExportValue to include the raw values.
+	Columns []ExportColumn
+	// NoHeader leaves out the header row naming the columns.
+	NoHeader bool
+}
+
+// ExportCSV writes every version of every key of ops to w as CSV, one row per
+// version, for bulk loading into analytics tools.
+//
+// Keys are walked with ListSeq and the versions of one key at a time are read with
+// History, so memory stays bounded by the largest key rather than by the store. Keys
+// deleted while the export runs are skipped. It returns a KeyError for an unknown
+// column.
+func ExportCSV(ctx context.Context, ops Ops, w io.Writer, opts ExportOpts) error {
+	columns := opts.Columns
+	if len(columns) == 0 {
+		columns = defaultExportColumns
+	}
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		switch column {
+		case ExportKey, ExportVersion, ExportCreatedAt, ExportSize, ExportValue:
+			header[i] = string(column)
+		default:
+			return KeyError(fmt.Sprintf("export: unknown column %q", column))
+		}
+	}
+
+	cw := csv.NewWriter(w)
+	if !opts.NoHeader {
+		if err := cw.Write(header); err != nil {
+			return fmt.Errorf("%w: %w", LocationError("export: writing header"), err)
+		}
+	}
+	row := make([]string, len(columns))
+	for key, err := range ListSeq(ctx, ops) {
+		if err != nil {
+			return err
+		}
+		history, err := History(ctx, ops, key)
+		var notFound KeyNotFoundError
+		if errors.As(err, &notFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, version := range history {
+			for i, column := range columns {
+				row[i] = exportField(column, key, version)
+			}
+			if err := cw.Write(row); err != nil {
+				return fmt.Errorf("%w: %w", LocationError("export: writing row"), err)
+			}
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("%w: %w", LocationError("export: writing rows"), err)
+	}
+	return nil
+}
+
+// exportField formats one column of the row for version of key.
+func exportField(column ExportColumn, key string, version VersionInfo) string {
+	switch column {
+	case ExportKey:
+		return key
+	case ExportVersion:
+		return strconv.FormatInt(version.Version, 10)
+	case ExportCreatedAt:
+		if version.CreatedAt.IsZero() {
+			return ""
+		}
+		return version.CreatedAt.UTC().Format(time.RFC3339Nano)
+	case ExportSize:
+		return strconv.Itoa(len(version.Value))
+	case ExportValue:
+		return base64.StdEncoding.EncodeToString(version.Value)
+	}
+	return ""
+}
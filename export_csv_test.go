@@ -0,0 +1,55 @@
+package libstore_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestExportCSV(t *testing.T) {
+	ctx := context.Background()
+	ops := libstore.NewInMemoryOps()
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	libstore.SetInMemoryClock(ops, clock.Now)
+	for key, entry := range map[string]string{"b": "hello", "a": "x,y"} {
+		if err := ops.Create(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+		if err := ops.Put(ctx, key, []byte(entry)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ops.Create(ctx, "empty"); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := libstore.ExportCSV(ctx, ops, &buf, libstore.ExportOpts{}); err != nil {
+		t.Fatalf("ExportCSV() error = %v", err)
+	}
+	want := "key,version,created_at,size\n" +
+		"a,1,2024-01-01T12:00:00Z,3\n" +
+		"b,1,2024-01-01T12:00:00Z,5\n"
+	if buf.String() != want {
+		t.Errorf("ExportCSV() wrote\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	opts := libstore.ExportOpts{Columns: []libstore.ExportColumn{libstore.ExportKey, libstore.ExportValue}, NoHeader: true}
+	if err := libstore.ExportCSV(ctx, ops, &buf, opts); err != nil {
+		t.Fatalf("ExportCSV() error = %v", err)
+	}
+	if want := "a,eCx5\nb,aGVsbG8=\n"; buf.String() != want {
+		t.Errorf("ExportCSV() with values wrote %q, want %q", buf.String(), want)
+	}
+
+	var keyErr libstore.KeyError
+	err := libstore.ExportCSV(ctx, ops, &buf, libstore.ExportOpts{Columns: []libstore.ExportColumn{"checksum"}})
+	if !errors.As(err, &keyErr) {
+		t.Errorf("ExportCSV() with an unknown column error = %v, want KeyError", err)
+	}
+}
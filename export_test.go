@@ -0,0 +1,60 @@
+package libstore
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+// SetInMemoryClock replaces the clock used by ops for expiry.
+func SetInMemoryClock(ops *InMemoryOps, now func() time.Time) {
+	ops.mu.Lock()
+	defer ops.mu.Unlock()
+	ops.now = now
+}
+
+// InMemoryStoredKeys returns the number of keys held by ops, including expired ones
+// that have not been reclaimed yet.
+func InMemoryStoredKeys(ops *InMemoryOps) int {
+	ops.mu.RLock()
+	defer ops.mu.RUnlock()
+	return len(ops.store)
+}
+
+// DBVersions returns the versions of the entries of key held by a dbOps, oldest first.
+func DBVersions(ctx context.Context, ops Ops, key string) ([]int64, error) {
+	rows, err := ops.(dbOps).db.QueryContext(ctx, "SELECT version FROM FILES WHERE key = $1 AND version > 0 ORDER BY version", key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []int64
+	for rows.Next() {
+		var version int64
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+	return versions, rows.Err()
+}
+
+// DBError exposes dbError for tests.
+var DBError = dbError
+
+// RetryTx exposes retryTx for tests.
+var RetryTx = retryTx
+
+// LazyInitWait returns the wait method of a lazyInit running check.
+func LazyInitWait(backend string, check func(ctx context.Context) error) func(ctx context.Context) error {
+	return newLazyInit(backend, check).wait
+}
+
+// FailMapFile makes fileOps fail to memory-map files with err until t ends.
+func FailMapFile(t testing.TB, err error) {
+	prev := mapFile
+	mapFile = func(*os.File, int) ([]byte, func() error, error) { return nil, nil, err }
+	t.Cleanup(func() { mapFile = prev })
+}
@@ -0,0 +1,42 @@
+package libstore
+
+import (
+	"context"
+	"fmt"
+)
+
+// FencedPutter is implemented by backends that can check a fence token and write an
+// entry in one atomic step.
+type FencedPutter interface {
+	// PutFenced writes entry like Put unless a write with a fence token above
+	// fenceToken was applied to key, in which case it returns a FencedError without
+	// writing. It records fenceToken as the highest token of key otherwise.
+	PutFenced(ctx context.Context, key string, entry []byte, fenceToken int64) error
+}
+
+// PutFenced writes entry to key with a fencing token, such as the epoch of a leader
+// lease, so that writes from a deposed leader are rejected: once a write with a
+// token is applied, writes with a lower token return a FencedError and are not
+// written. Writes with the token last applied, or a higher one, go through.
+//
+// The token is compared and recorded atomically with the write, apart from the
+// metadata of the key: the database backend keeps it on the row that creates the
+// key, InMemoryOps beside the entries, and S3Ops in the user metadata of the object,
+// replaced with a write conditional on its ETag. It returns an UnsupportedError if
+// ops does not implement FencedPutter, as a check and a write in separate calls
+// would let a deposed leader write.
+func PutFenced(ctx context.Context, ops Ops, key string, entry []byte, fenceToken int64) error {
+	if putter, ok := ops.(FencedPutter); ok {
+		return putter.PutFenced(ctx, key, entry, fenceToken)
+	}
+	return UnsupportedError("PutFenced is not supported by this backend")
+}
+
+// checkFence returns a FencedError if fenceToken is below highest, the highest token
+// applied to key.
+func checkFence(key string, highest, fenceToken int64) error {
+	if fenceToken < highest {
+		return FencedError(fmt.Sprintf("fence token %d of write to key %s is below %d", fenceToken, key, highest))
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestPutFenced(t *testing.T) {
+	backends := testBackends(t, "InMemory", "S3", "DB")
+	for name, newOps := range backends {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			ops := newOps(t)
+			key := testKey(t)
+			if err := ops.Create(ctx, key); err != nil {
+				t.Fatal(err)
+			}
+			for _, token := range []int64{1, 3, 3} {
+				if err := libstore.PutFenced(ctx, ops, key, []byte("leader"), token); err != nil {
+					t.Fatalf("Error putting entry with fence token %d: %v", token, err)
+				}
+			}
+
+			err := libstore.PutFenced(ctx, ops, key, []byte("deposed"), 2)
+			var fencedErr libstore.FencedError
+			if !errors.As(err, &fencedErr) {
+				t.Fatalf("Expected a FencedError for a lower fence token, Got: %v", err)
+			}
+			if entry, err := ops.Read(ctx, key); err != nil || string(entry) != "leader" {
+				t.Errorf("Expected the fenced write not to be applied, Got: %q, %v", entry, err)
+			}
+
+			if err := libstore.PutFenced(ctx, ops, key, []byte("successor"), 4); err != nil {
+				t.Fatalf("Error putting entry with a higher fence token: %v", err)
+			}
+			if entry, err := ops.Read(ctx, key); err != nil || string(entry) != "successor" {
+				t.Errorf("Expected the entry of the higher fence token, Got: %q, %v", entry, err)
+			}
+
+			// The token is kept apart from the metadata.
+			store := ops.(libstore.MetadataStore)
+			meta := libstore.Metadata{"owner": "leader"}
+			if err := store.PutMetadata(ctx, key, meta); err != nil {
+				t.Fatalf("Error putting metadata: %v", err)
+			}
+			if got, err := store.ReadMetadata(ctx, key); err != nil || !maps.Equal(got, meta) {
+				t.Errorf("Expected the metadata without the fence token, Got: %v, %v", got, err)
+			}
+			if err := libstore.PutFenced(ctx, ops, key, []byte("deposed"), 3); !errors.As(err, &fencedErr) {
+				t.Errorf("Expected PutMetadata to keep the fence token, Got: %v", err)
+			}
+		})
+	}
+
+	var unsupportedErr libstore.UnsupportedError
+	if err := libstore.PutFenced(context.Background(), newRecordingOps(libstore.NewInMemoryOps()), "key", nil, 1); !errors.As(err, &unsupportedErr) {
+		t.Errorf("Expected an UnsupportedError without FencedPutter, Got: %v", err)
+	}
+	fileOps := newTestFileOps(t)
+	if err := fileOps.Create(context.Background(), "key"); err != nil {
+		t.Fatal(err)
+	}
+	if err := libstore.PutFenced(context.Background(), fileOps, "key", nil, 1); !errors.As(err, &unsupportedErr) {
+		t.Errorf("Expected an UnsupportedError from a backend without an atomic fenced write, Got: %v", err)
+	}
+}
+
+func TestPutFencedConcurrent(t *testing.T) {
+	backends := testBackends(t, "InMemory", "S3", "DB")
+	for name, newOps := range backends {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			ops := newOps(t)
+			key := testKey(t)
+			if err := ops.Create(ctx, key); err != nil {
+				t.Fatal(err)
+			}
+			var mu sync.Mutex
+			var highest int64
+			var wg sync.WaitGroup
+			for token := int64(1); token <= 10; token++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					err := libstore.PutFenced(ctx, ops, key, []byte(fmt.Sprint(token)), token)
+					var fencedErr libstore.FencedError
+					var conflictErr libstore.ConflictError
+					switch {
+					case err == nil:
+						mu.Lock()
+						highest = max(highest, token)
+						mu.Unlock()
+					case !errors.As(err, &fencedErr) && !errors.As(err, &conflictErr):
+						t.Errorf("Error putting entry with fence token %d: %v", token, err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			// No write below the highest token applied may land after it.
+			if entry, err := ops.Read(ctx, key); err != nil || string(entry) != fmt.Sprint(highest) {
+				t.Errorf("Expected the entry of fence token %d, Got: %q, %v", highest, entry, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package libstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+
+	"github.com/cecmp/libcipher"
+)
+
+// fieldCryptStore encrypts selected fields of JSON entries, leaving the rest of each
+// document in plaintext.
+type fieldCryptStore struct {
+	ops       Ops
+	paths     []string
+	encryptor libcipher.Encryptor
+	decryptor libcipher.Decryptor
+}
+
+// NewFieldCryptStoreGCM wraps ops so that the fields of JSON entries at paths are
+// encrypted with GCM, as NewCryptStoreGCM would encrypt whole entries, while the
+// rest of each document is stored in plaintext and stays searchable by the backend.
+//
+// A path names a field by the members leading to it, separated by dots, such as
+// "customer.ssn"; a path running through an array applies to each of its elements.
+// The encrypted field holds the base64 vault of its JSON value, which may be of any
+// type, sealed with its path so it cannot be moved to another field. Fields missing
+// from a document are skipped. Put returns a ValidationError for entries that are
+// not JSON, and Read a DecryptionError for fields that cannot be decrypted.
+//
+// The objects and arrays along the paths are re-encoded, compacted and with their
+// members sorted, but hold the same values.
+func NewFieldCryptStoreGCM(ops Ops, paths []string, encryptionKey []byte, rand io.Reader) (Ops, error) {
+	encryptor, err := libcipher.NewGCMEncryptor(encryptionKey, rand)
+	if err != nil {
+		return nil, err
+	}
+	decryptor, err := libcipher.NewGCMDecryptor(encryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	return fieldCryptStore{ops: ops, paths: paths, encryptor: encryptor, decryptor: decryptor}, nil
+}
+
+// NewFieldCryptStoreCBC is NewFieldCryptStoreGCM with the CBC-HMAC encryption of
+// NewCryptStoreCBC.
+func NewFieldCryptStoreCBC(ops Ops, paths []string, encryptionKey []byte, integrityKey []byte, calculateMAC func() hash.Hash, rand io.Reader) (Ops, error) {
+	encryptor, err := libcipher.NewCBCHMACEncryptor(encryptionKey, integrityKey, calculateMAC, rand)
+	if err != nil {
+		return nil, err
+	}
+	decryptor, err := libcipher.NewCBCHMACDecryptor(encryptionKey, integrityKey, calculateMAC)
+	if err != nil {
+		return nil, err
+	}
+	return fieldCryptStore{ops: ops, paths: paths, encryptor: encryptor, decryptor: decryptor}, nil
+}
+
+// Create implements libstore.Ops.
+func (f fieldCryptStore) Create(ctx context.Context, key string) error {
+	return f.ops.Create(ctx, key)
+}
+
+// Put implements libstore.Ops.
+func (f fieldCryptStore) Put(ctx context.Context, key string, entry []byte) error {
+	if !json.Valid(entry) {
+		return ValidationError("field encryption: entry of key " + key + " is not valid JSON")
+	}
+	doc := json.RawMessage(entry)
+	for _, path := range f.paths {
+		var err error
+		doc, err = transformField(doc, strings.Split(path, "."), func(value json.RawMessage) (json.RawMessage, error) {
+			return f.seal(path, value)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return f.ops.Put(ctx, key, doc)
+}
+
+// Read implements libstore.Ops.
+func (f fieldCryptStore) Read(ctx context.Context, key string) ([]byte, error) {
+	entry, err := f.ops.Read(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return f.open(entry)
+}
+
+// ReadAll implements libstore.Ops.
+func (f fieldCryptStore) ReadAll(ctx context.Context, key string) ([][]byte, error) {
+	entries, err := f.ops.ReadAll(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	res := make([][]byte, len(entries))
+	for i, entry := range entries {
+		if res[i], err = f.open(entry); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// Delete implements libstore.Ops.
+func (f fieldCryptStore) Delete(ctx context.Context, key string) error {
+	return f.ops.Delete(ctx, key)
+}
+
+// List implements libstore.Ops.
+func (f fieldCryptStore) List(ctx context.Context) ([]string, error) {
+	return f.ops.List(ctx)
+}
+
+// Unwrap implements Unwrapper.
+func (f fieldCryptStore) Unwrap() Ops {
+	return f.ops
+}
+
+// seal encrypts the JSON value of the field at path into a JSON string.
+func (f fieldCryptStore) seal(path string, value json.RawMessage) (json.RawMessage, error) {
+	vault, err := f.encryptor.Crypt(value, []byte(path))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", DecryptionError("failed to encrypt field "+path), err)
+	}
+	return marshalField(base64.StdEncoding.EncodeToString(vault))
+}
+
+// open decrypts the fields of entry, a document written by Put.
+func (f fieldCryptStore) open(entry []byte) ([]byte, error) {
+	doc := json.RawMessage(entry)
+	for _, path := range f.paths {
+		var err error
+		doc, err = transformField(doc, strings.Split(path, "."), func(value json.RawMessage) (json.RawMessage, error) {
+			var encoded string
+			if err := json.Unmarshal(value, &encoded); err != nil {
+				return nil, DecryptionError("field " + path + " is not encrypted")
+			}
+			vault, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", DecryptionError("field "+path+" is not encrypted"), err)
+			}
+			res, meta, err := f.decryptor.Crypt(vault)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", DecryptionError("failed to decrypt field "+path), err)
+			}
+			if string(meta) != path {
+				return nil, DecryptionError("field " + path + " holds the vault of field " + string(meta))
+			}
+			return res, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+// transformField replaces the value at path in doc by the result of fn, applying it
+// to every element of the arrays along the path. doc is returned unchanged if it has
+// no value at path.
+func transformField(doc json.RawMessage, path []string, fn func(json.RawMessage) (json.RawMessage, error)) (json.RawMessage, error) {
+	if len(path) == 0 {
+		return fn(doc)
+	}
+	switch trimmed := bytes.TrimSpace(doc); {
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		var elems []json.RawMessage
+		if err := json.Unmarshal(doc, &elems); err != nil {
+			return nil, fmt.Errorf("%w: %w", ValidationError("field encryption: malformed array"), err)
+		}
+		for i := range elems {
+			var err error
+			if elems[i], err = transformField(elems[i], path, fn); err != nil {
+				return nil, err
+			}
+		}
+		return marshalField(elems)
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		var members map[string]json.RawMessage
+		if err := json.Unmarshal(doc, &members); err != nil {
+			return nil, fmt.Errorf("%w: %w", ValidationError("field encryption: malformed object"), err)
+		}
+		value, ok := members[path[0]]
+		if !ok {
+			return doc, nil
+		}
+		value, err := transformField(value, path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		members[path[0]] = value
+		return marshalField(members)
+	default:
+		return doc, nil
+	}
+}
+
+// marshalField encodes v like json.Marshal, but without escaping HTML characters, so
+// plaintext values keep the bytes they were written with.
+func marshalField(v any) (json.RawMessage, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, fmt.Errorf("%w: %w", ValidationError("field encryption: failed to encode document"), err)
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
@@ -0,0 +1,85 @@
+package libstore_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestFieldCryptStore(t *testing.T) {
+	ctx := context.Background()
+	backend := libstore.NewInMemoryOps()
+	ops, err := libstore.NewFieldCryptStoreGCM(backend, []string{"ssn", "pin", "cards.number", "address.zip"}, bytes.Repeat([]byte{0x42}, 32), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ops.Create(ctx, "customer"); err != nil {
+		t.Fatalf("Error creating key: %v", err)
+	}
+
+	doc := `{"name": "Ada <Lovelace>", "ssn": "123-45-6789", "pin": [4,7,1,1],
+		"cards": [{"number": "4111111111111111", "brand": "visa"}, {"number": {"pan": "5500005555555559"}, "brand": "mc"}]}`
+	if err := ops.Put(ctx, "customer", []byte(doc)); err != nil {
+		t.Fatalf("Error putting entry: %v", err)
+	}
+
+	stored, err := backend.Read(ctx, "customer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"123-45-6789", "[4,7,1,1]", "4111111111111111", "5500005555555559"} {
+		if strings.Contains(string(stored), secret) {
+			t.Errorf("Expected %q to be encrypted, Got: %s", secret, stored)
+		}
+	}
+	for _, plain := range []string{`"Ada <Lovelace>"`, `"brand":"visa"`, `"brand":"mc"`} {
+		if !strings.Contains(string(stored), plain) {
+			t.Errorf("Expected %s in plaintext, Got: %s", plain, stored)
+		}
+	}
+
+	value, err := ops.Read(ctx, "customer")
+	if err != nil {
+		t.Fatalf("Error reading entry: %v", err)
+	}
+	var want, got any
+	if err := json.Unmarshal([]byte(doc), &want); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(value, &got); err != nil {
+		t.Fatalf("Expected a JSON document, Got: %s: %v", value, err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Expected %v, Got: %v", want, got)
+	}
+
+	// A vault moved to another field does not decrypt.
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(stored, &fields); err != nil {
+		t.Fatal(err)
+	}
+	fields["pin"] = fields["ssn"]
+	swapped, err := json.Marshal(fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.Put(ctx, "customer", swapped); err != nil {
+		t.Fatal(err)
+	}
+	var decryptionErr libstore.DecryptionError
+	if _, err := ops.Read(ctx, "customer"); !errors.As(err, &decryptionErr) {
+		t.Errorf("Expected a DecryptionError for a moved field, Got: %v", err)
+	}
+
+	var validationErr libstore.ValidationError
+	if err := ops.Put(ctx, "customer", []byte("not json")); !errors.As(err, &validationErr) {
+		t.Errorf("Expected a ValidationError for an entry that is not JSON, Got: %v", err)
+	}
+}
@@ -2,23 +2,73 @@ package libstore
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
+	"sync"
+	"time"
 )
 
 // fileOps implements the Ops interface for file operations.
+// Operations on the same key are serialized within the process by the RWMutex of
+// its stripe of locks.
 type fileOps struct {
-	location string
+	location      string
+	locks         *keyLocks
+	mmapThreshold int64
+	logKey        KeyRedactor
+}
+
+// FileOption configures the Ops returned by NewFileOps.
+type FileOption func(*fileOps)
+
+// WithMmapThreshold makes Read and ReadAll memory-map files of at least threshold
+// bytes and split their entries directly from the mapped region, instead of
+// scanning them through a buffered reader. Smaller files, and platforms without
+// mmap, use the buffered path. It is disabled by default.
+func WithMmapThreshold(threshold int64) FileOption {
+	return func(fops *fileOps) {
+		fops.mmapThreshold = threshold
+	}
+}
+
+// WithFileLogKeys makes the logs of the Ops emit keys as redact returns them, for
+// instance HashKeys, rather than in full. Keys are also left out of the file names
+// logged, which are derived from them. A nil redact logs full keys.
+func WithFileLogKeys(redact KeyRedactor) FileOption {
+	return func(fops *fileOps) {
+		fops.logKey = redact
+		if redact == nil {
+			fops.logKey = FullKeys
+		}
+	}
 }
 
 // NewFileOps initializes a new Ops instance with an OS filesystem-based implementation.
 // It returns an error if the provided location is invalid.
-func NewFileOps(location string) (Ops, error) {
+//
+// Every key is stored in a file of location named after it, so any key string can
+// be stored: bytes other than ASCII letters, digits and "-_.~" are percent-encoded,
+// as are a trailing dot and the first letter of names Windows reserves, and names
+// longer than 200 bytes are split into nested directories. Keys made of those
+// characters alone are stored under their own name, so existing directories keep
+// working unless their keys hold other characters. NewFileOps returns a
+// LocationError for a location holding a file whose name is not the escaped form of
+// a key, naming the file to rename, rather than losing the key it stores. Earlier
+// names that read as escaped ones, such as "%41" for "A", cannot be told apart and
+// change keys. On case-insensitive file systems, keys differing only in case share a
+// file.
+//
+// Operations return a LocationError rather than a KeyNotFoundError once location
+// no longer exists or is not a directory.
+func NewFileOps(location string, opts ...FileOption) (Ops, error) {
 	fileInfo, err := os.Stat(location)
 	if os.IsNotExist(err) {
 		// Directory doesn't exist, create it
@@ -32,21 +82,127 @@ func NewFileOps(location string) (Ops, error) {
 		return fileOps{}, fmt.Errorf("file: %s is not a directory", location)
 	}
 
-	return fileOps{location: location}, nil
+	fops := fileOps{location: location, locks: &keyLocks{}, logKey: FullKeys}
+	for _, opt := range opts {
+		opt(&fops)
+	}
+	if err := fops.checkLegacyNames(); err != nil {
+		return fileOps{}, err
+	}
+	return fops, nil
+}
+
+// mapFile memory-maps files for readMapped. Tests replace it to make mapping fail.
+var mapFile = mmapFile
+
+// readMapped memory-maps file, storing key, when mmap is enabled and the file is large
+// enough, and calls fn with its content. It reports false, without calling fn, when
+// the caller should use the buffered path instead.
+func (fops fileOps) readMapped(key string, file *os.File, fn func(data []byte)) (bool, error) {
+	if fops.mmapThreshold <= 0 {
+		return false, nil
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", KeyError(fmt.Sprintf("file: getting file info %s", file.Name())), err)
+	}
+	if stat.Size() < fops.mmapThreshold || stat.Size() == 0 || int64(int(stat.Size())) != stat.Size() {
+		return false, nil
+	}
+	data, unmap, err := mapFile(file, int(stat.Size()))
+	if err != nil {
+		slog.Debug("mapping file, falling back to buffered read", "key", fops.logKey(key), "error", err)
+		return false, nil
+	}
+	defer func() {
+		if uerr := unmap(); uerr != nil {
+			slog.Debug("unmapping file", "error", uerr)
+		}
+	}()
+	fn(data)
+	return true, nil
+}
+
+// dropCR drops a trailing carriage return, as bufio.ScanLines does.
+func dropCR(line []byte) []byte {
+	if len(line) > 0 && line[len(line)-1] == '\r' {
+		return line[:len(line)-1]
+	}
+	return line
+}
+
+// splitLines splits data into lines the way bufio.ScanLines does. data is copied once
+// and the lines share the copy, each capped so appending to one cannot overwrite the next.
+func splitLines(data []byte) [][]byte {
+	data = bytes.Clone(data)
+	lines := make([][]byte, 0, bytes.Count(data, []byte("\n"))+1)
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			i = len(data)
+		}
+		line := dropCR(data[:i])
+		lines = append(lines, line[:len(line):len(line)])
+		data = data[min(i+1, len(data)):]
+	}
+	return lines
+}
+
+// lastLine returns a copy of the last line of data, as scanning it with bufio.ScanLines would.
+func lastLine(data []byte) []byte {
+	data = bytes.TrimSuffix(data, []byte("\n"))
+	return bytes.Clone(dropCR(data[bytes.LastIndexByte(data, '\n')+1:]))
+}
+
+// notFound returns the error for the missing file of key: a KeyNotFoundError, or a
+// LocationError if the location itself is gone.
+func (fops fileOps) notFound(key string) error {
+	if err := fops.checkLocation(); err != nil {
+		return err
+	}
+	return KeyNotFoundError(fmt.Sprintf("file: key not found %s", key))
+}
+
+// checkLocation returns a LocationError unless the location is a directory, for
+// instance if it was removed after NewFileOps.
+func (fops fileOps) checkLocation() error {
+	info, err := os.Stat(fops.location)
+	if err != nil {
+		return fmt.Errorf("%w: %w", LocationError(fmt.Sprintf("file: location %s is not accessible", fops.location)), err)
+	}
+	if !info.IsDir() {
+		return LocationError(fmt.Sprintf("file: location %s is not a directory", fops.location))
+	}
+	return nil
+}
+
+// keyLock returns the mutex guarding the given key.
+func (fops fileOps) keyLock(key string) *sync.RWMutex {
+	return fops.locks.get(key)
 }
 
 // Create creates a new file with the given key.
 // It returns an error if the file already exists or if there is an issue creating the file.
 func (fops fileOps) Create(ctx context.Context, key string) error {
-	path := filepath.Join(fops.location, key)
+	mu := fops.keyLock(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	path := fops.path(key)
 	if _, err := os.Stat(path); err == nil {
 		return KeyError(fmt.Sprintf("file: file %s already exists", key))
 	} else if !os.IsNotExist(err) {
 		return fmt.Errorf("%w: %w", KeyError("file: checking if file exists"), err)
 	}
+	if err := fops.makeChunkDirs(path); err != nil {
+		return fmt.Errorf("%w: %w", KeyError(fmt.Sprintf("file: creating file %s", key)), err)
+	}
 
 	file, err := os.Create(path)
 	if err != nil {
+		if lerr := fops.checkLocation(); lerr != nil {
+			return lerr
+		}
 		return fmt.Errorf("%w: %w", KeyError(fmt.Sprintf("file: creating file %s", key)), err)
 	}
 	defer func() {
@@ -60,20 +216,30 @@ func (fops fileOps) Create(ctx context.Context, key string) error {
 // ReadAll reads the entire content of the file with the given key.
 // It returns the content as a byte slice or an error if the file cannot be read.
 func (fops fileOps) ReadAll(ctx context.Context, key string) ([][]byte, error) {
-	path := filepath.Join(fops.location, key)
+	mu := fops.keyLock(key)
+	mu.RLock()
+	defer mu.RUnlock()
+
+	path := fops.path(key)
 	file, err := os.Open(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, KeyNotFoundError(fmt.Sprintf("file: key not found %s", key))
+			return nil, fops.notFound(key)
 		}
 		return nil, fmt.Errorf("%w: %w", KeyError(fmt.Sprintf("file: reading file %s", key)), err)
 	}
 	defer file.Close()
 
 	var lines [][]byte
+	if mapped, err := fops.readMapped(key, file, func(data []byte) { lines = splitLines(data) }); err != nil {
+		return nil, err
+	} else if mapped {
+		return lines, nil
+	}
+
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
-		lines = append(lines, scanner.Bytes())
+		lines = append(lines, bytes.Clone(scanner.Bytes()))
 	}
 	if err := scanner.Err(); err != nil && err != io.EOF {
 		return nil, fmt.Errorf("%w: %w", KeyError(fmt.Sprintf("file: reading file %s lines", key)), err)
@@ -85,11 +251,51 @@ func (fops fileOps) ReadAll(ctx context.Context, key string) ([][]byte, error) {
 // Read reads the last line of the file with the given key.
 // It returns the last line as a byte slice or an error if the file cannot be read.
 func (fops fileOps) Read(ctx context.Context, key string) ([]byte, error) {
-	path := filepath.Join(fops.location, key)
+	mu := fops.keyLock(key)
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return fops.read(key)
+}
+
+// ReadPrefix implements PrefixReader. Lines are not indexed, so the file is still
+// scanned for the last line, but only its first n bytes are returned.
+func (fops fileOps) ReadPrefix(ctx context.Context, key string, n int) ([]byte, error) {
+	if err := checkPrefixLength(n); err != nil {
+		return nil, err
+	}
+	mu := fops.keyLock(key)
+	mu.RLock()
+	defer mu.RUnlock()
+
+	last, err := fops.read(key)
+	if err != nil {
+		return nil, err
+	}
+	return last[:min(n, len(last))], nil
+}
+
+// CreatedAt implements CreatedAtReader with the birth time of the file where the
+// platform and file system record it, and its inode change time otherwise. PutCapped
+// replaces the file, so it counts as a new creation.
+func (fops fileOps) CreatedAt(ctx context.Context, key string) (time.Time, error) {
+	createdAt, err := birthTime(fops.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return time.Time{}, fops.notFound(key)
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %w", KeyError(fmt.Sprintf("file: reading creation time of %s", key)), err)
+	}
+	return createdAt, nil
+}
+
+// read returns the last line of the file with the given key. The caller must hold the key lock.
+func (fops fileOps) read(key string) ([]byte, error) {
+	path := fops.path(key)
 	file, err := os.Open(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, KeyNotFoundError(fmt.Sprintf("file: key not found %s", key))
+			return nil, fops.notFound(key)
 		}
 		return nil, fmt.Errorf("file: opening file %s: %w", key, err)
 	}
@@ -99,28 +305,41 @@ func (fops fileOps) Read(ctx context.Context, key string) ([]byte, error) {
 		}
 	}()
 
-	scanner := bufio.NewScanner(file)
-	var lastLine []byte
-
-	for scanner.Scan() {
-		lastLine = scanner.Bytes()
+	var last []byte
+	mapped, err := fops.readMapped(key, file, func(data []byte) { last = lastLine(data) })
+	if err != nil {
+		return nil, err
 	}
-
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("%w: %w", KeyError(fmt.Sprintf("file: reading file %s", key)), err)
+	if !mapped {
+		if last, err = readLastLine(file); err != nil {
+			return nil, fmt.Errorf("%w: %w", KeyError(fmt.Sprintf("file: reading file %s", key)), err)
+		}
 	}
-	if len(lastLine) == 0 {
+	if len(last) == 0 {
 		return nil, EntryError(fmt.Sprintf("file: file is empty for name %s", path))
 	}
-	return lastLine, nil
+	return last, nil
 }
 
 // Put appends an entry to the file with the given key.
 // It returns an error if the file cannot be opened or written to.
 func (fops fileOps) Put(ctx context.Context, key string, entry []byte) error {
-	path := filepath.Join(fops.location, key)
+	mu := fops.keyLock(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	return fops.put(ctx, key, entry)
+}
+
+// put appends entry to the file with the given key, syncing it if ctx asks for a
+// Durable write. The caller must hold the key lock for writing.
+func (fops fileOps) put(ctx context.Context, key string, entry []byte) error {
+	path := fops.path(key)
 	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
 	if err != nil {
+		if os.IsNotExist(err) {
+			return fops.notFound(key)
+		}
 		return fmt.Errorf("%w: %w", KeyError(fmt.Sprintf("file: opening file %s", key)), err)
 	}
 	defer func() {
@@ -141,37 +360,379 @@ func (fops fileOps) Put(ctx context.Context, key string, entry []byte) error {
 	if _, err = file.Write(entry); err != nil {
 		return fmt.Errorf("%w: %w", KeyError(fmt.Sprintf("file: writing to file %s", key)), err)
 	}
+	if opOptionsFrom(ctx).durable {
+		if err := file.Sync(); err != nil {
+			return fmt.Errorf("%w: %w", KeyError(fmt.Sprintf("file: syncing file %s", key)), err)
+		}
+	}
 	return nil
 }
 
 // Delete deletes the file with the given key.
 // It returns an error if the file cannot be deleted.
 func (fops fileOps) Delete(ctx context.Context, key string) error {
-	path := filepath.Join(fops.location, key)
+	mu := fops.keyLock(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	path := fops.path(key)
 	if err := os.Remove(path); err != nil {
 		if os.IsNotExist(err) {
-			return KeyNotFoundError(fmt.Sprintf("file: key not found %s", key))
+			return fops.notFound(key)
 		}
 		return fmt.Errorf("%w: %w", LocationError(fmt.Sprintf("file: deleting file %s", key)), err)
 	}
+	fops.removeChunkDirs(path)
 	return nil
 }
 
-// List lists all regular files in the directory.
-// It returns a slice of file names or an error if the directory cannot be read.
+// List lists the keys of all files in the directory, sorted by bytes. Files whose
+// names fileOps did not write are left out.
 func (fops fileOps) List(ctx context.Context) ([]string, error) {
 	var res []string
-	err := filepath.WalkDir(fops.location, func(path string, d fs.DirEntry, err error) error {
+	err := fops.walkKeys(func(path string, d fs.DirEntry, key string) error {
+		res = append(res, key)
+		return nil
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(res)
+	return res, nil
+}
+
+// ListModifiedSince lists the keys of all files modified after since.
+// It returns an empty slice when nothing changed.
+func (fops fileOps) ListModifiedSince(ctx context.Context, since time.Time) ([]string, error) {
+	res := []string{}
+	err := fops.walkKeys(func(path string, d fs.DirEntry, key string) error {
+		info, err := d.Info()
 		if err != nil {
-			return fmt.Errorf("%w: %w", LocationError(fmt.Sprintf("file: walking directory %s", path)), err)
+			return fmt.Errorf("%w: %w", LocationError(fmt.Sprintf("file: getting file info %s", path)), err)
 		}
-		if d.Type().IsRegular() {
-			res = append(res, d.Name())
+		if info.ModTime().After(since) {
+			res = append(res, key)
 		}
 		return nil
-	})
+	}, nil)
 	if err != nil {
 		return nil, err
 	}
 	return res, nil
 }
+
+// ListPartial lists the keys of all files in the directory, skipping entries that
+// cannot be read. Each unreadable path is reported in the result instead of
+// aborting the walk.
+func (fops fileOps) ListPartial(ctx context.Context) (ListResult, error) {
+	var res ListResult
+	err := fops.walkKeys(func(path string, d fs.DirEntry, key string) error {
+		res.Keys = append(res.Keys, key)
+		return nil
+	}, func(path string, err error) {
+		res.Errors = append(res.Errors, ListSourceError{Source: path, Err: err})
+	})
+	if err != nil {
+		return ListResult{}, err
+	}
+	return res, nil
+}
+
+// CreateIfNotExists creates a new file with the given key unless it already exists.
+// The file is created with O_EXCL, so concurrent processes cannot both create it.
+func (fops fileOps) CreateIfNotExists(ctx context.Context, key string) (bool, error) {
+	mu := fops.keyLock(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	path := fops.path(key)
+	if err := fops.makeChunkDirs(path); err != nil {
+		return false, fmt.Errorf("%w: %w", KeyError(fmt.Sprintf("file: creating file %s", key)), err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if os.IsExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		if lerr := fops.checkLocation(); lerr != nil {
+			return false, lerr
+		}
+		return false, fmt.Errorf("%w: %w", KeyError(fmt.Sprintf("file: creating file %s", key)), err)
+	}
+	if cerr := file.Close(); cerr != nil {
+		slog.Debug("closing file", "error", cerr)
+	}
+	return true, nil
+}
+
+// PutFrom appends the content of r as a new entry of the file with the given key.
+// Like Put, the content must not contain newlines, which separate entries.
+func (fops fileOps) PutFrom(ctx context.Context, key string, r io.Reader) error {
+	mu := fops.keyLock(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	path := fops.path(key)
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fops.notFound(key)
+		}
+		return fmt.Errorf("%w: %w", KeyError(fmt.Sprintf("file: opening file %s", key)), err)
+	}
+	defer func() {
+		if cerr := file.Close(); cerr != nil {
+			slog.Debug("closing file", "error", cerr)
+		}
+	}()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("%w: %w", KeyError(fmt.Sprintf("file: getting file info %s", key)), err)
+	}
+	if stat.Size() > 0 {
+		if _, err := file.Write([]byte("\n")); err != nil {
+			return fmt.Errorf("%w: %w", KeyError(fmt.Sprintf("file: writing to file %s", key)), err)
+		}
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		return fmt.Errorf("%w: %w", KeyError(fmt.Sprintf("file: writing to file %s", key)), err)
+	}
+	return nil
+}
+
+// ReadTo implements StreamReader. The file is scanned backwards from its end for the
+// start of the last line, which is then copied to w, so neither the file nor the
+// entry is held in memory.
+func (fops fileOps) ReadTo(ctx context.Context, key string, w io.Writer) error {
+	mu := fops.keyLock(key)
+	mu.RLock()
+	defer mu.RUnlock()
+
+	path := fops.path(key)
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fops.notFound(key)
+		}
+		return fmt.Errorf("%w: %w", KeyError(fmt.Sprintf("file: opening file %s", key)), err)
+	}
+	defer func() {
+		if cerr := file.Close(); cerr != nil {
+			slog.Debug("closing file", "error", cerr)
+		}
+	}()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("%w: %w", KeyError(fmt.Sprintf("file: getting file info %s", key)), err)
+	}
+	start, end, err := lastLineBounds(file, stat.Size())
+	if err != nil {
+		return fmt.Errorf("%w: %w", KeyError(fmt.Sprintf("file: reading file %s", key)), err)
+	}
+	if start == end {
+		return EntryError(fmt.Sprintf("file: file is empty for name %s", path))
+	}
+	if _, err := io.Copy(w, io.NewSectionReader(file, start, end-start)); err != nil {
+		return fmt.Errorf("%w: %w", EntryError("failed to copy content"), err)
+	}
+	return nil
+}
+
+// readLastLine returns the last line of file without reading the lines before it.
+// Unlike a bufio.Scanner it has no limit on the length of the line.
+func readLastLine(file *os.File) ([]byte, error) {
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	start, end, err := lastLineBounds(file, stat.Size())
+	if err != nil {
+		return nil, err
+	}
+	last := make([]byte, end-start)
+	if _, err := file.ReadAt(last, start); err != nil {
+		return nil, err
+	}
+	return last, nil
+}
+
+// lastLineBounds returns the offsets of the start and end of the last line of the
+// size bytes of r, without its line ending, as lastLine would find it.
+func lastLineBounds(r io.ReaderAt, size int64) (int64, int64, error) {
+	end := size
+	last := make([]byte, 1)
+	if end > 0 {
+		if _, err := r.ReadAt(last, end-1); err != nil {
+			return 0, 0, err
+		}
+		if last[0] == '\n' {
+			end--
+		}
+	}
+	var start int64
+	buf := make([]byte, 32*1024)
+	for pos := end; pos > 0; {
+		n := min(int64(len(buf)), pos)
+		if _, err := r.ReadAt(buf[:n], pos-n); err != nil {
+			return 0, 0, err
+		}
+		if i := bytes.LastIndexByte(buf[:n], '\n'); i >= 0 {
+			start = pos - n + int64(i) + 1
+			break
+		}
+		pos -= n
+	}
+	if end > start {
+		if _, err := r.ReadAt(last, end-1); err != nil {
+			return 0, 0, err
+		}
+		if last[0] == '\r' {
+			end--
+		}
+	}
+	return start, end, nil
+}
+
+// ReadConcat implements ConcatReader. The file is read in one go; when sep is a
+// newline and the file has no carriage returns it already is the joined blob.
+func (fops fileOps) ReadConcat(ctx context.Context, key string, sep []byte) ([]byte, error) {
+	mu := fops.keyLock(key)
+	mu.RLock()
+	defer mu.RUnlock()
+
+	data, err := os.ReadFile(fops.path(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fops.notFound(key)
+		}
+		return nil, fmt.Errorf("%w: %w", KeyError(fmt.Sprintf("file: reading file %s", key)), err)
+	}
+	if bytes.Equal(sep, []byte("\n")) && bytes.IndexByte(data, '\r') < 0 {
+		return bytes.TrimSuffix(data, []byte("\n")), nil
+	}
+	return bytes.Join(splitLines(data), sep), nil
+}
+
+// PutAndGetPrevious implements PreviousPutter. It is atomic with respect to the other
+// operations of this process on the key, not to other processes sharing the directory.
+func (fops fileOps) PutAndGetPrevious(ctx context.Context, key string, entry []byte) ([]byte, error) {
+	mu := fops.keyLock(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	previous, err := fops.read(key)
+	var entryErr EntryError
+	if errors.As(err, &entryErr) {
+		previous, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := fops.put(ctx, key, entry); err != nil {
+		return nil, err
+	}
+	return previous, nil
+}
+
+// Increment implements Incrementer, appending the new value. Like
+// PutAndGetPrevious, it is atomic with respect to the other operations of this
+// process on the key, not to other processes sharing the directory.
+func (fops fileOps) Increment(ctx context.Context, key string, delta int64) (int64, error) {
+	mu := fops.keyLock(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	latest, err := fops.read(key)
+	var entryErr EntryError
+	if errors.As(err, &entryErr) {
+		latest, err = nil, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	value, err := addCounter(key, latest, delta)
+	if err != nil {
+		return 0, err
+	}
+	if err := fops.put(ctx, key, formatCounter(value)); err != nil {
+		return 0, err
+	}
+	return value, nil
+}
+
+// PutCapped implements CappedPutter. The file is rewritten with the retained entries
+// and the new one into a temporary file, which is then renamed over the original, so
+// readers see either the old or the new content. The temporary file is named after
+// fileTempPattern, which no key maps to, so it never shows up in List.
+func (fops fileOps) PutCapped(ctx context.Context, key string, entry []byte, maxEntries int) error {
+	if err := checkMaxEntries(maxEntries); err != nil {
+		return err
+	}
+	mu := fops.keyLock(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	path := fops.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fops.notFound(key)
+		}
+		return fmt.Errorf("%w: %w", KeyError(fmt.Sprintf("file: reading file %s", key)), err)
+	}
+	lines := append(splitLines(data), entry)
+	lines = lines[max(len(lines)-maxEntries, 0):]
+
+	if err := writeReplacing(path, bytes.Join(lines, []byte("\n")), opOptionsFrom(ctx).durable); err != nil {
+		return fmt.Errorf("%w: %w", KeyError(fmt.Sprintf("file: rewriting file %s", key)), err)
+	}
+	return nil
+}
+
+// writeReplacing writes data to a temporary file next to path and renames it over
+// path, syncing it first if durable is set.
+func writeReplacing(path string, data []byte, durable bool) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), fileTempPattern)
+	if err != nil {
+		return err
+	}
+	err = func() error {
+		if err := tmp.Chmod(0644); err != nil {
+			return err
+		}
+		if _, err := tmp.Write(data); err != nil {
+			return err
+		}
+		if durable {
+			if err := tmp.Sync(); err != nil {
+				return err
+			}
+		}
+		return tmp.Close()
+	}()
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
+
+var (
+	_ Ops                 = fileOps{}
+	_ PreviousPutter      = fileOps{}
+	_ Incrementer         = fileOps{}
+	_ StreamPutter        = fileOps{}
+	_ StreamReader        = fileOps{}
+	_ IdempotentCreator   = fileOps{}
+	_ ModifiedSinceLister = fileOps{}
+	_ PartialLister       = fileOps{}
+	_ ConcatReader        = fileOps{}
+	_ CappedPutter        = fileOps{}
+	_ PrefixReader        = fileOps{}
+	_ CreatedAtReader     = fileOps{}
+)
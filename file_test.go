@@ -2,8 +2,14 @@ package libstore_test
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/cecmp/libstore"
@@ -125,3 +131,243 @@ func TestWalkDir(t *testing.T) {
 		t.Errorf("Unexpected files found. Expected: %v, Got: %v", expectedFiles, foundFiles)
 	}
 }
+
+func TestConcurrentPut(t *testing.T) {
+	fileOps, err := libstore.NewFileOps(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileName := "concurrent.txt"
+	if err := fileOps.Create(context.TODO(), fileName); err != nil {
+		t.Fatalf("Error creating file: %v", err)
+	}
+
+	const writers, perWriter = 20, 50
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				if err := fileOps.Put(context.TODO(), fileName, []byte(fmt.Sprintf("writer %02d entry %02d", w, i))); err != nil {
+					t.Errorf("Error appending to file: %v", err)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	readContent, err := fileOps.ReadAll(context.TODO(), fileName)
+	if err != nil {
+		t.Fatalf("Error reading file: %v", err)
+	}
+	var got, expected []string
+	for _, c := range readContent {
+		got = append(got, string(c))
+	}
+	for w := 0; w < writers; w++ {
+		for i := 0; i < perWriter; i++ {
+			expected = append(expected, fmt.Sprintf("writer %02d entry %02d", w, i))
+		}
+	}
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Unexpected entries. Expected %d entries, Got %d", len(expected), len(got))
+	}
+}
+
+func TestMmapRead(t *testing.T) {
+	buffered, err := libstore.NewFileOps(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapped, err := libstore.NewFileOps(t.TempDir(), libstore.WithMmapThreshold(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := [][]byte{[]byte("first"), []byte("with carriage return\r"), {}, []byte("last")}
+	for _, ops := range []libstore.Ops{buffered, mapped} {
+		if err := ops.Create(context.TODO(), "key"); err != nil {
+			t.Fatal(err)
+		}
+		for _, entry := range entries {
+			if err := ops.Put(context.TODO(), "key", entry); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	wantAll, err := buffered.ReadAll(context.TODO(), "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotAll, err := mapped.ReadAll(context.TODO(), "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotAll, wantAll) {
+		t.Errorf("ReadAll mismatch. Expected: %q Got: %q", wantAll, gotAll)
+	}
+	want, err := buffered.Read(context.TODO(), "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := mapped.Read(context.TODO(), "key")
+	if err != nil || string(got) != string(want) {
+		t.Errorf("Read mismatch. Expected: %q Got: %q, %v", want, got, err)
+	}
+}
+
+func BenchmarkFileReadAll(b *testing.B) {
+	for _, bench := range []struct {
+		name string
+		opts []libstore.FileOption
+	}{
+		{"buffered", nil},
+		{"mmap", []libstore.FileOption{libstore.WithMmapThreshold(1 << 20)}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			ops, err := libstore.NewFileOps(b.TempDir(), bench.opts...)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if err := ops.Create(context.TODO(), "key"); err != nil {
+				b.Fatal(err)
+			}
+			// 8 MiB of 1 KiB entries.
+			entry := make([]byte, 1024)
+			for i := range entry {
+				entry[i] = 'a'
+			}
+			for range 8 * 1024 {
+				if err := ops.Put(context.TODO(), "key", entry); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for range b.N {
+				if _, err := ops.ReadAll(context.TODO(), "key"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestFilePutDurable(t *testing.T) {
+	ctx := context.Background()
+	ops, err := libstore.NewFileOps(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ops.Create(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ops.Put(libstore.WithOpOptions(ctx, libstore.Durable()), "key", []byte("critical")); err != nil {
+		t.Fatalf("Error putting durable entry: %v", err)
+	}
+	if err := ops.Put(ctx, "key", []byte("routine")); err != nil {
+		t.Fatalf("Error putting entry: %v", err)
+	}
+	entries, err := ops.ReadAll(ctx, "key")
+	if err != nil || len(entries) != 2 || string(entries[0]) != "critical" {
+		t.Errorf("Unexpected entries: %q, %v", entries, err)
+	}
+}
+
+func TestFileLocationGone(t *testing.T) {
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "store")
+	ops, err := libstore.NewFileOps(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ops.Create(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	var notFoundErr libstore.KeyNotFoundError
+	if _, err := ops.ReadAll(ctx, "missing"); !errors.As(err, &notFoundErr) {
+		t.Fatalf("Expected a KeyNotFoundError while the location exists, Got: %v", err)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := map[string]func() error{
+		"Create":  func() error { return ops.Create(ctx, "new") },
+		"Read":    func() error { _, err := ops.Read(ctx, "key"); return err },
+		"ReadAll": func() error { _, err := ops.ReadAll(ctx, "key"); return err },
+		"Put":     func() error { return ops.Put(ctx, "key", []byte("entry")) },
+		"Delete":  func() error { return ops.Delete(ctx, "key") },
+		"List":    func() error { _, err := ops.List(ctx); return err },
+	}
+	for name, call := range calls {
+		err := call()
+		var locationErr libstore.LocationError
+		if !errors.As(err, &locationErr) || errors.As(err, &notFoundErr) {
+			t.Errorf("Expected a LocationError from %s once the location is gone, Got: %v", name, err)
+		}
+	}
+}
+
+func TestFileListPartial(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	ops, err := libstore.NewFileOps(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	long := strings.Repeat("l", 300)
+	for _, key := range []string{"a", "b", long} {
+		if err := ops.Create(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lister := ops.(libstore.PartialLister)
+	res, err := lister.ListPartial(ctx)
+	if err != nil || len(res.Errors) != 0 {
+		t.Fatalf("Unexpected listing errors: %v, %v", res.Errors, err)
+	}
+	sort.Strings(res.Keys)
+	if want := []string{"a", "b", long}; !reflect.DeepEqual(res.Keys, want) {
+		t.Errorf("Expected %d keys, Got: %q", len(want), res.Keys)
+	}
+
+	// The leading chunk of the long key's name is a directory.
+	chunks, err := filepath.Glob(filepath.Join(dir, "*%"))
+	if err != nil || len(chunks) != 1 {
+		t.Fatalf("Expected one chunk directory, Got: %v, %v", chunks, err)
+	}
+	if err := os.Chmod(chunks[0], 0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(chunks[0], 0755) })
+	if _, err := os.ReadDir(chunks[0]); err == nil {
+		t.Log("Skipping unreadable directory checks: permissions are not enforced for this user")
+	} else {
+		res, err = lister.ListPartial(ctx)
+		if err != nil {
+			t.Fatalf("Expected a partial listing, Got: %v", err)
+		}
+		sort.Strings(res.Keys)
+		if !reflect.DeepEqual(res.Keys, []string{"a", "b"}) || len(res.Errors) != 1 || res.Errors[0].Source != chunks[0] {
+			t.Errorf("Expected the readable keys and the unreadable directory, Got: %q, %v", res.Keys, res.Errors)
+		}
+		var locationErr libstore.LocationError
+		if _, err := ops.List(ctx); !errors.As(err, &locationErr) {
+			t.Errorf("Expected List to fail on the unreadable directory, Got: %v", err)
+		}
+	}
+
+	if err := os.Chmod(chunks[0], 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	var locationErr libstore.LocationError
+	if _, err := lister.ListPartial(ctx); !errors.As(err, &locationErr) {
+		t.Errorf("Expected a LocationError once nothing can be listed, Got: %v", err)
+	}
+}
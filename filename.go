@@ -0,0 +1,201 @@
+package libstore
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// fileNameChunk is the longest file or directory name, less the suffixes fileOps
+// adds, that fileOps writes. File systems commonly limit names to 255 bytes.
+const fileNameChunk = 200
+
+// fileNameChunkSuffix ends the directories holding the leading chunks of an over-long
+// file name. Encoded names never end with it, so these directories cannot clash with
+// the files of shorter keys.
+const fileNameChunkSuffix = "%"
+
+// fileTempPattern names the temporary files of fileOps, for os.CreateTemp. A "%"
+// not followed by two hex digits is never produced by escapeFileName, so no key maps
+// to these files and walkKeys skips them.
+const fileTempPattern = "%tmp-*"
+
+// windowsDeviceNames are the file names Windows reserves, with or without extension.
+var windowsDeviceNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// escapeFileName percent-encodes every byte of s that is not an ASCII letter, digit
+// or one of "-_.~", and also a trailing dot, which Windows drops. The empty key
+// becomes a lone "%", which no other key encodes to.
+func escapeFileName(s string) string {
+	if s == "" {
+		return "%"
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isFileNameByte(c) && !(c == '.' && i == len(s)-1) {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// isFileNameByte reports whether c is stored unescaped in file names.
+func isFileNameByte(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '_' || c == '.' || c == '~'
+}
+
+// unescapeFileName reverses escapeFileName. It reports false for names
+// escapeFileName does not produce.
+func unescapeFileName(name string) (string, bool) {
+	if name == "%" {
+		return "", true
+	}
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c != '%' {
+			if !isFileNameByte(c) {
+				return "", false
+			}
+			b.WriteByte(c)
+			continue
+		}
+		if i+2 >= len(name) {
+			return "", false
+		}
+		decoded, err := strconv.ParseUint(name[i+1:i+3], 16, 8)
+		if err != nil {
+			return "", false
+		}
+		b.WriteByte(byte(decoded))
+		i += 2
+	}
+	return b.String(), true
+}
+
+// path returns the path of the file storing key. Its name is the escaped key, split
+// into a chain of directories if it is too long for one name, with the first byte
+// of a name Windows reserves for a device escaped too.
+func (fops fileOps) path(key string) string {
+	name := escapeFileName(key)
+	parts := []string{fops.location}
+	for len(name) > fileNameChunk {
+		parts = append(parts, name[:fileNameChunk]+fileNameChunkSuffix)
+		name = name[fileNameChunk:]
+	}
+	if base, _, _ := strings.Cut(name, "."); windowsDeviceNames[strings.ToUpper(base)] {
+		name = fmt.Sprintf("%%%02X", name[0]) + name[1:]
+	}
+	return filepath.Join(append(parts, name)...)
+}
+
+// key returns the key stored in the file at path, a path under fops.location. It
+// reports false for files that fileOps did not name, including names escaped
+// differently than path would.
+func (fops fileOps) key(path string) (string, bool) {
+	rel, err := filepath.Rel(fops.location, path)
+	if err != nil {
+		return "", false
+	}
+	parts := strings.Split(rel, string(filepath.Separator))
+	for i, part := range parts[:len(parts)-1] {
+		chunk, ok := strings.CutSuffix(part, fileNameChunkSuffix)
+		if !ok || len(chunk) != fileNameChunk {
+			return "", false
+		}
+		parts[i] = chunk
+	}
+	key, ok := unescapeFileName(strings.Join(parts, ""))
+	if !ok {
+		return "", false
+	}
+	if canonical, err := filepath.Rel(fops.location, fops.path(key)); err != nil || canonical != rel {
+		return "", false
+	}
+	return key, true
+}
+
+// checkLegacyNames returns a LocationError if fops.location holds a regular file
+// whose name fileOps no longer writes, such as a file of an earlier version of
+// fileOps stored its key under unescaped. Such a key would be silently lost: reads
+// would miss its file and writes would create a second one. The error names the file
+// and the name to rename it to.
+func (fops fileOps) checkLegacyNames() error {
+	entries, err := os.ReadDir(fops.location)
+	if err != nil {
+		return fmt.Errorf("%w: %w", LocationError(fmt.Sprintf("file: reading directory %s", fops.location)), err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || strings.HasPrefix(name, strings.TrimSuffix(fileTempPattern, "*")) {
+			continue
+		}
+		if _, ok := fops.key(filepath.Join(fops.location, name)); !ok {
+			return LocationError(fmt.Sprintf("file: %s holds %q, a file not named by its escaped key; rename it to %q to keep its key",
+				fops.location, name, filepath.Base(fops.path(name))))
+		}
+	}
+	return nil
+}
+
+// walkKeys calls fn with the path, entry and key of every file under fops.location
+// that stores a key, skipping the directories that do not hold name chunks. Errors
+// reading a directory other than fops.location are passed to onErr, and abort the
+// walk if onErr is nil.
+func (fops fileOps) walkKeys(fn func(path string, d fs.DirEntry, key string) error, onErr func(path string, err error)) error {
+	return filepath.WalkDir(fops.location, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == fops.location || onErr == nil {
+				return fmt.Errorf("%w: %w", LocationError(fmt.Sprintf("file: walking directory %s", path)), err)
+			}
+			onErr(path, err)
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if path != fops.location && !strings.HasSuffix(d.Name(), fileNameChunkSuffix) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if key, ok := fops.key(path); ok {
+			return fn(path, d, key)
+		}
+		return nil
+	})
+}
+
+// makeChunkDirs creates the directories holding the name chunks of the file at path.
+func (fops fileOps) makeChunkDirs(path string) error {
+	if dir := filepath.Dir(path); strings.HasSuffix(dir, fileNameChunkSuffix) {
+		return os.MkdirAll(dir, 0755)
+	}
+	return nil
+}
+
+// removeChunkDirs removes the directories holding the name chunks of the file at
+// path, from the innermost, as long as they are empty.
+func (fops fileOps) removeChunkDirs(path string) {
+	root := filepath.Clean(fops.location)
+	for dir := filepath.Dir(path); dir != root && strings.HasSuffix(dir, fileNameChunkSuffix); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestFileOpsKeyEncoding(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	ops, err := libstore.NewFileOps(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := []string{
+		"plain.txt",
+		"C:\\windows:stream",
+		"nested/key/with/slashes",
+		"key with spaces ",
+		"ünïcødé/日本語",
+		"100%",
+		"%41",
+		"nul\x00byte",
+		".",
+		"..",
+		"trailing.",
+		"CON",
+		"aux.txt",
+		"",
+		strings.Repeat("long/", 120),
+		strings.Repeat("x", 200),
+		strings.Repeat("x", 200) + "y",
+	}
+	for _, key := range keys {
+		if err := ops.Create(ctx, key); err != nil {
+			t.Fatalf("Error creating key %q: %v", key, err)
+		}
+		if err := ops.Put(ctx, key, []byte("entry of "+key)); err != nil {
+			t.Fatalf("Error putting entry of %q: %v", key, err)
+		}
+	}
+	for _, key := range keys {
+		if entry, err := ops.Read(ctx, key); err != nil || string(entry) != "entry of "+key {
+			t.Errorf("Read(%q) = %q, %v", key, entry, err)
+		}
+	}
+	listed, err := ops.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := slices.Sorted(slices.Values(keys))
+	if !slices.Equal(listed, want) {
+		t.Errorf("List() = %q, want %q", listed, want)
+	}
+
+	// Files the store did not name are not listed.
+	if err := os.WriteFile(filepath.Join(dir, "not escaped"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if listed, err := ops.List(ctx); err != nil || len(listed) != len(keys) {
+		t.Errorf("List() with a foreign file = %q, %v", listed, err)
+	}
+
+	for _, key := range keys {
+		if err := ops.Delete(ctx, key); err != nil {
+			t.Errorf("Error deleting key %q: %v", key, err)
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Errorf("Expected Delete to remove the directories of long names, Got: %v, %v", entries, err)
+	}
+}
+
+func TestFileOpsLegacyNames(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	// A file an earlier fileOps named after its unescaped key.
+	if err := os.WriteFile(filepath.Join(dir, "legacy key"), []byte("entry\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var locationErr libstore.LocationError
+	if _, err := libstore.NewFileOps(dir); !errors.As(err, &locationErr) || !strings.Contains(err.Error(), "legacy%20key") {
+		t.Fatalf("Expected a LocationError naming the escaped file name, Got: %v", err)
+	}
+
+	if err := os.Rename(filepath.Join(dir, "legacy key"), filepath.Join(dir, "legacy%20key")); err != nil {
+		t.Fatal(err)
+	}
+	// Temporary files are not taken for legacy ones.
+	if err := os.WriteFile(filepath.Join(dir, "%tmp-123"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	ops, err := libstore.NewFileOps(dir)
+	if err != nil {
+		t.Fatalf("Error opening the renamed store: %v", err)
+	}
+	if entry, err := ops.Read(ctx, "legacy key"); err != nil || string(entry) != "entry" {
+		t.Errorf("Read() = %q, %v, want the legacy entry", entry, err)
+	}
+}
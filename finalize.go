@@ -0,0 +1,23 @@
+package libstore
+
+import (
+	"context"
+)
+
+// Finalizer is implemented by backends that can make a single key immutable.
+type Finalizer interface {
+	// Finalize makes key immutable: later Puts and Deletes of it fail with an
+	// ImmutableError, while reads keep working. Finalizing a key twice is not an
+	// error. It returns a KeyNotFoundError if the key does not exist.
+	Finalize(ctx context.Context, key string) error
+}
+
+// Finalize makes key immutable once it reaches its final state, leaving every other
+// key writable. There is no way back through this package. It returns an
+// UnsupportedError if ops does not implement Finalizer.
+func Finalize(ctx context.Context, ops Ops, key string) error {
+	if finalizer, ok := ops.(Finalizer); ok {
+		return finalizer.Finalize(ctx, key)
+	}
+	return UnsupportedError("Finalize is not supported by this backend")
+}
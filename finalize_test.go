@@ -0,0 +1,56 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestFinalize(t *testing.T) {
+	ctx := context.Background()
+	ops := libstore.NewInMemoryOps()
+	for _, key := range []string{"final", "open"} {
+		if err := ops.Create(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+		if err := ops.Put(ctx, key, []byte("v1")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := libstore.Finalize(ctx, ops, "final"); err != nil {
+		t.Fatalf("Finalize() error = %v", err)
+	}
+	if err := libstore.Finalize(ctx, ops, "final"); err != nil {
+		t.Errorf("Finalize() of a finalized key error = %v", err)
+	}
+
+	var immutable libstore.ImmutableError
+	if err := ops.Put(ctx, "final", []byte("v2")); !errors.As(err, &immutable) {
+		t.Errorf("Put() after Finalize error = %v, want ImmutableError", err)
+	}
+	if err := libstore.PutCapped(ctx, ops, "final", []byte("v2"), 2); !errors.As(err, &immutable) {
+		t.Errorf("PutCapped() after Finalize error = %v, want ImmutableError", err)
+	}
+	if err := ops.Delete(ctx, "final"); !errors.As(err, &immutable) {
+		t.Errorf("Delete() after Finalize error = %v, want ImmutableError", err)
+	}
+	if entry, err := ops.Read(ctx, "final"); err != nil || string(entry) != "v1" {
+		t.Errorf("Read() after Finalize = %q, %v, want v1", entry, err)
+	}
+
+	if err := ops.Put(ctx, "open", []byte("v2")); err != nil {
+		t.Errorf("Put() of another key error = %v", err)
+	}
+
+	var notFound libstore.KeyNotFoundError
+	if err := libstore.Finalize(ctx, ops, "missing"); !errors.As(err, &notFound) {
+		t.Errorf("Finalize() of a missing key error = %v, want KeyNotFoundError", err)
+	}
+	var unsupported libstore.UnsupportedError
+	if err := libstore.Finalize(ctx, newRecordingOps(ops), "open"); !errors.As(err, &unsupported) {
+		t.Errorf("Finalize() without Finalizer error = %v, want UnsupportedError", err)
+	}
+}
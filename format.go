@@ -0,0 +1,67 @@
+package libstore
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// FormatVersion is the version of the entry header written by this package.
+// Readers reject entries carrying a newer version.
+const FormatVersion uint8 = 1
+
+// formatMagic marks entries carrying a format header.
+// The leading NUL keeps it from colliding with text payloads.
+var formatMagic = []byte{0x00, 'L', 'S', 'F'}
+
+// formatHeaderLen is the length of the magic, version and flags bytes.
+var formatHeaderLen = len(formatMagic) + 2
+
+// FormatInfo describes the header of a stored entry.
+type FormatInfo struct {
+	// Version is the format version, or 0 for entries written without a header.
+	Version uint8
+	// Flags holds the encoding flags recorded in the header.
+	Flags uint8
+	// HeaderLen is the number of bytes preceding the payload.
+	HeaderLen int
+}
+
+// EncodeFormat prepends a header with the current FormatVersion and the given flags to payload.
+func EncodeFormat(flags uint8, payload []byte) []byte {
+	res := make([]byte, 0, formatHeaderLen+len(payload))
+	res = append(res, formatMagic...)
+	res = append(res, FormatVersion, flags)
+	return append(res, payload...)
+}
+
+// DetectFormat inspects the header of a stored entry.
+//
+// Entries without a header are reported as version 0 so that data written before
+// headers were introduced stays readable. It returns an EntryError if the header is
+// truncated or was written by a newer, incompatible version.
+func DetectFormat(entry []byte) (FormatInfo, error) {
+	if !bytes.HasPrefix(entry, formatMagic) {
+		return FormatInfo{}, nil
+	}
+	if len(entry) < formatHeaderLen {
+		return FormatInfo{}, EntryError("format: truncated header")
+	}
+	info := FormatInfo{
+		Version:   entry[len(formatMagic)],
+		Flags:     entry[len(formatMagic)+1],
+		HeaderLen: formatHeaderLen,
+	}
+	if info.Version == 0 || info.Version > FormatVersion {
+		return FormatInfo{}, EntryError(fmt.Sprintf("format: unsupported version %d, this reader supports up to %d", info.Version, FormatVersion))
+	}
+	return info, nil
+}
+
+// DecodeFormat detects the header of entry and returns it along with the payload.
+func DecodeFormat(entry []byte) ([]byte, FormatInfo, error) {
+	info, err := DetectFormat(entry)
+	if err != nil {
+		return nil, FormatInfo{}, err
+	}
+	return entry[info.HeaderLen:], info, nil
+}
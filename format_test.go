@@ -0,0 +1,50 @@
+package libstore_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestFormatRoundTrip(t *testing.T) {
+	entry := libstore.EncodeFormat(0x01, []byte("payload"))
+	payload, info, err := libstore.DecodeFormat(entry)
+	if err != nil {
+		t.Fatalf("Error decoding format: %v", err)
+	}
+	if info.Version != libstore.FormatVersion || info.Flags != 0x01 {
+		t.Errorf("Unexpected format info: %+v", info)
+	}
+	if string(payload) != "payload" {
+		t.Error("Payload mismatch. Expected: payload Got:", string(payload))
+	}
+}
+
+func TestFormatLegacyEntry(t *testing.T) {
+	payload, info, err := libstore.DecodeFormat([]byte("plain entry"))
+	if err != nil {
+		t.Fatalf("Error decoding legacy entry: %v", err)
+	}
+	if info.Version != 0 || string(payload) != "plain entry" {
+		t.Errorf("Unexpected legacy decoding: %+v %q", info, payload)
+	}
+}
+
+func TestFormatRejectsUnknownVersions(t *testing.T) {
+	header := libstore.EncodeFormat(0, nil)
+	for _, version := range []byte{0, libstore.FormatVersion + 1, 0xff} {
+		entry := append([]byte{}, header...)
+		entry[len(entry)-2] = version
+		_, err := libstore.DetectFormat(append(entry, "payload"...))
+		var entryErr libstore.EntryError
+		if !errors.As(err, &entryErr) {
+			t.Errorf("Expected an EntryError for version %d, Got: %v", version, err)
+		}
+	}
+
+	_, err := libstore.DetectFormat(header[:len(header)-1])
+	if err == nil {
+		t.Error("Expected an error for a truncated header")
+	}
+}
@@ -0,0 +1,219 @@
+package libstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// storeFS presents an Ops as a read-only file system.
+type storeFS struct {
+	ops Ops
+}
+
+// AsFS adapts ops into a read-only fs.FS, for instance to serve a store with
+// http.FileServer(http.FS(AsFS(ops))) or to parse templates with template.ParseFS.
+//
+// Every key is a file holding its latest entry, so opening a file maps to Read, and
+// a key that exists but holds no entries yet is an empty file. Keys are split at "/"
+// into a tree of directories, which are listed with List. Keys that are not valid
+// fs paths, such as keys with a leading "/", are left out of the tree. A key that is
+// also the prefix of other keys is presented as a file, hiding the keys below it
+// from directory listings. A missing key results in fs.ErrNotExist.
+func AsFS(ops Ops) fs.FS {
+	return storeFS{ops: ops}
+}
+
+// Open implements fs.FS.
+func (s storeFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if name != "." {
+		entry, err := s.read(name)
+		if err == nil {
+			return &storeFile{info: storeFileInfo{name: path.Base(name), size: int64(len(entry))}, Reader: bytes.NewReader(entry)}, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+	}
+	entries, err := s.readDir(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &storeDir{info: storeFileInfo{name: path.Base(name), dir: true}, entries: entries}, nil
+}
+
+// ReadFile implements fs.ReadFileFS.
+func (s storeFS) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: fs.ErrInvalid}
+	}
+	entry, err := s.read(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: err}
+	}
+	return entry, nil
+}
+
+// ReadDir implements fs.ReadDirFS.
+func (s storeFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	entries, err := s.readDir(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	return entries, nil
+}
+
+// read returns the latest entry of key, translating a missing key to fs.ErrNotExist.
+func (s storeFS) read(key string) ([]byte, error) {
+	entry, err := s.ops.Read(context.Background(), key)
+	var notFound KeyNotFoundError
+	var entryErr EntryError
+	switch {
+	case errors.As(err, &notFound):
+		return nil, fs.ErrNotExist
+	case errors.As(err, &entryErr):
+		return []byte{}, nil
+	case err != nil:
+		return nil, err
+	}
+	// Backends may return their own buffers, which fs callers are free to modify.
+	return bytes.Clone(entry), nil
+}
+
+// readDir lists the directory dir, sorted by name. It returns fs.ErrNotExist if no
+// key lies below dir.
+func (s storeFS) readDir(dir string) ([]fs.DirEntry, error) {
+	keys, err := s.ops.List(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	prefix := ""
+	if dir != "." {
+		prefix = dir + "/"
+	}
+
+	children := map[string]bool{}
+	for _, key := range keys {
+		rest, ok := strings.CutPrefix(key, prefix)
+		if !ok || !fs.ValidPath(key) {
+			continue
+		}
+		child, _, nested := strings.Cut(rest, "/")
+		// A key both listed as a file and prefixing others is presented as a file.
+		children[child] = children[child] || !nested
+	}
+	if len(children) == 0 && dir != "." {
+		return nil, fs.ErrNotExist
+	}
+
+	entries := make([]fs.DirEntry, 0, len(children))
+	for name, file := range children {
+		entries = append(entries, storeDirEntry{fsys: s, key: prefix + name, info: storeFileInfo{name: name, dir: !file}})
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return entries, nil
+}
+
+// storeFileInfo describes a key or directory of a storeFS.
+type storeFileInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (i storeFileInfo) Name() string       { return i.name }
+func (i storeFileInfo) Size() int64        { return i.size }
+func (i storeFileInfo) ModTime() time.Time { return time.Time{} }
+func (i storeFileInfo) IsDir() bool        { return i.dir }
+func (i storeFileInfo) Sys() any           { return nil }
+
+func (i storeFileInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0o555
+	}
+	return 0o444
+}
+
+// storeDirEntry is a listed key or directory. The size of a key is only known once
+// it is read, so Info reads it.
+type storeDirEntry struct {
+	fsys storeFS
+	key  string
+	info storeFileInfo
+}
+
+func (e storeDirEntry) Name() string      { return e.info.name }
+func (e storeDirEntry) IsDir() bool       { return e.info.dir }
+func (e storeDirEntry) Type() fs.FileMode { return e.info.Mode().Type() }
+
+func (e storeDirEntry) Info() (fs.FileInfo, error) {
+	if e.info.dir {
+		return e.info, nil
+	}
+	entry, err := e.fsys.read(e.key)
+	if err != nil {
+		return nil, err
+	}
+	info := e.info
+	info.size = int64(len(entry))
+	return info, nil
+}
+
+// storeFile is an opened key. Embedding bytes.Reader lets http.FileServer seek in it.
+type storeFile struct {
+	*bytes.Reader
+	info storeFileInfo
+}
+
+func (f *storeFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *storeFile) Close() error               { return nil }
+
+// storeDir is an opened directory.
+type storeDir struct {
+	info    storeFileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *storeDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *storeDir) Close() error               { return nil }
+
+func (d *storeDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: errors.New("is a directory")}
+}
+
+// ReadDir implements fs.ReadDirFile.
+func (d *storeDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	rest = rest[:min(n, len(rest))]
+	d.offset += len(rest)
+	return rest, nil
+}
+
+var (
+	_ fs.ReadDirFS   = storeFS{}
+	_ fs.ReadFileFS  = storeFS{}
+	_ fs.ReadDirFile = (*storeDir)(nil)
+	_ io.Seeker      = (*storeFile)(nil)
+)
@@ -0,0 +1,78 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/cecmp/libstore"
+)
+
+func newFSStore(t *testing.T) libstore.Ops {
+	ops := libstore.NewInMemoryOps()
+	for key, entry := range map[string]string{
+		"index.html":        "<h1>index</h1>",
+		"docs/guide.txt":    "guide",
+		"docs/api/ops.txt":  "ops",
+		"docs/api/fs.txt":   "fs",
+		"empty":             "",
+		"/not/a/valid/path": "skipped",
+	} {
+		if err := ops.Create(context.TODO(), key); err != nil {
+			t.Fatal(err)
+		}
+		if entry != "" {
+			if err := ops.Put(context.TODO(), key, []byte(entry)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return ops
+}
+
+func TestAsFS(t *testing.T) {
+	fsys := libstore.AsFS(newFSStore(t))
+	if err := fstest.TestFS(fsys, "index.html", "empty", "docs/guide.txt", "docs/api/ops.txt", "docs/api/fs.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	if entry, err := fs.ReadFile(fsys, "docs/api/ops.txt"); err != nil || string(entry) != "ops" {
+		t.Errorf("Unexpected file content: %q, %v", entry, err)
+	}
+	entries, err := fs.ReadDir(fsys, "docs")
+	if err != nil || len(entries) != 2 || entries[0].Name() != "api" || !entries[0].IsDir() || entries[1].Name() != "guide.txt" {
+		t.Errorf("Unexpected directory entries: %v, %v", entries, err)
+	}
+	if _, err := fsys.Open("missing.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected fs.ErrNotExist, Got: %v", err)
+	}
+}
+
+func TestAsFSFileServer(t *testing.T) {
+	server := httptest.NewServer(http.FileServer(http.FS(libstore.AsFS(newFSStore(t)))))
+	defer server.Close()
+
+	res, err := http.Get(server.URL + "/docs/guide.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil || res.StatusCode != http.StatusOK || string(body) != "guide" {
+		t.Errorf("Unexpected response: %d %q, %v", res.StatusCode, body, err)
+	}
+
+	res, err = http.Get(server.URL + "/missing.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing key, Got: %d", res.StatusCode)
+	}
+}
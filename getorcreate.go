@@ -0,0 +1,37 @@
+package libstore
+
+import (
+	"context"
+)
+
+// GetOrCreator is implemented by backends that can atomically set a key if it is
+// absent and otherwise return its current value.
+type GetOrCreator interface {
+	// GetOrCreate creates key with entry as its only entry and returns entry with
+	// created set, or, if key already exists, returns its latest entry.
+	GetOrCreate(ctx context.Context, key string, entry []byte) (value []byte, created bool, err error)
+}
+
+// GetOrCreate creates key holding entry unless it already exists, and returns the
+// value of key along with whether this call created it.
+//
+// Backends implementing GetOrCreator do so atomically. For the others the key is
+// created with CreateIfNotExists and entry is put afterwards, so a concurrent caller
+// may find the key still empty and receive an EntryError.
+func GetOrCreate(ctx context.Context, ops Ops, key string, entry []byte) ([]byte, bool, error) {
+	if creator, ok := ops.(GetOrCreator); ok {
+		return creator.GetOrCreate(ctx, key, entry)
+	}
+	created, err := CreateIfNotExists(ctx, ops, key)
+	if err != nil {
+		return nil, false, err
+	}
+	if !created {
+		value, err := ops.Read(ctx, key)
+		return value, false, err
+	}
+	if err := ops.Put(ctx, key, entry); err != nil {
+		return nil, false, err
+	}
+	return entry, true, nil
+}
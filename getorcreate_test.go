@@ -0,0 +1,57 @@
+package libstore_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestGetOrCreateConcurrent(t *testing.T) {
+	ops := libstore.NewInMemoryOps()
+
+	const callers = 16
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	created := 0
+	values := map[string]bool{}
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, ok, err := libstore.GetOrCreate(context.TODO(), ops, "leader", []byte(fmt.Sprint(i)))
+			if err != nil {
+				t.Errorf("Error in GetOrCreate: %v", err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if ok {
+				created++
+			}
+			values[string(value)] = true
+		}()
+	}
+	wg.Wait()
+
+	if created != 1 || len(values) != 1 {
+		t.Errorf("Expected exactly one creator and one value, Got: %d creators, values %v", created, values)
+	}
+}
+
+func TestGetOrCreateFallback(t *testing.T) {
+	ops, err := libstore.NewFileOps(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, created, err := libstore.GetOrCreate(context.TODO(), ops, "key", []byte("first"))
+	if err != nil || !created || string(value) != "first" {
+		t.Fatalf("Expected key to be created, Got: %q, %v, %v", value, created, err)
+	}
+	value, created, err = libstore.GetOrCreate(context.TODO(), ops, "key", []byte("second"))
+	if err != nil || created || string(value) != "first" {
+		t.Errorf("Expected the existing value, Got: %q, %v, %v", value, created, err)
+	}
+}
@@ -0,0 +1,24 @@
+package libstore
+
+import (
+	"context"
+)
+
+// PreviousPutter is implemented by backends that can write an entry and return the
+// entry it superseded in one atomic step.
+type PreviousPutter interface {
+	// PutAndGetPrevious writes entry like Put and returns the latest entry before the
+	// write, or nil if the key held no entries. It returns a KeyNotFoundError if the
+	// key does not exist, in which case nothing is written.
+	PutAndGetPrevious(ctx context.Context, key string, entry []byte) (previous []byte, err error)
+}
+
+// PutAndGetPrevious writes entry to key and returns the entry it superseded, as one
+// atomic step. It returns an UnsupportedError if ops does not implement
+// PreviousPutter, as a Read followed by a Put cannot give that guarantee.
+func PutAndGetPrevious(ctx context.Context, ops Ops, key string, entry []byte) ([]byte, error) {
+	if putter, ok := ops.(PreviousPutter); ok {
+		return putter.PutAndGetPrevious(ctx, key, entry)
+	}
+	return nil, UnsupportedError("PutAndGetPrevious is not supported by this backend")
+}
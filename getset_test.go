@@ -0,0 +1,75 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestPutAndGetPrevious(t *testing.T) {
+	fileOps, err := libstore.NewFileOps(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, ops := range map[string]libstore.Ops{
+		"memory": libstore.NewInMemoryOps(),
+		"file":   fileOps,
+	} {
+		t.Run(name, func(t *testing.T) {
+			var notFound libstore.KeyNotFoundError
+			if _, err := libstore.PutAndGetPrevious(context.TODO(), ops, "missing", []byte("x")); !errors.As(err, &notFound) {
+				t.Errorf("Expected a KeyNotFoundError, Got: %v", err)
+			}
+			if err := ops.Create(context.TODO(), "key"); err != nil {
+				t.Fatal(err)
+			}
+
+			// Every swap must see the entry written by exactly one other swap, or none
+			// for the first one, so the previous entries and the final one are all distinct.
+			const swaps = 32
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			seen := map[string]int{}
+			for i := range swaps {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					previous, err := libstore.PutAndGetPrevious(context.TODO(), ops, "key", []byte(fmt.Sprint(i)))
+					if err != nil {
+						t.Errorf("Error swapping: %v", err)
+						return
+					}
+					mu.Lock()
+					seen[string(previous)]++
+					mu.Unlock()
+				}()
+			}
+			wg.Wait()
+
+			last, err := ops.Read(context.TODO(), "key")
+			if err != nil {
+				t.Fatal(err)
+			}
+			seen[string(last)]++
+			if len(seen) != swaps+1 {
+				t.Errorf("Expected %d distinct entries, Got: %v", swaps+1, seen)
+			}
+			if seen[""] != 1 {
+				t.Errorf("Expected exactly one swap to find no previous entry, Got: %d", seen[""])
+			}
+		})
+	}
+}
+
+func TestPutAndGetPreviousUnsupported(t *testing.T) {
+	ops := newRecordingOps(libstore.NewInMemoryOps())
+	_, err := libstore.PutAndGetPrevious(context.TODO(), ops, "key", []byte("x"))
+	var unsupported libstore.UnsupportedError
+	if !errors.As(err, &unsupported) {
+		t.Errorf("Expected an UnsupportedError, Got: %v", err)
+	}
+}
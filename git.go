@@ -0,0 +1,275 @@
+package libstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// gitOps implements the Ops interface on top of a Git repository.
+// Every Put is a commit, so the history of a key is the Git log of its file.
+type gitOps struct {
+	mu   *sync.Mutex
+	path string
+	repo *git.Repository
+}
+
+// NewGitOps initializes a new Ops instance backed by the Git repository at repoPath.
+//
+// Parameters:
+//   - repoPath: Path to the working tree of the repository. It is initialized if it is not a repository yet.
+//
+// Returns:
+//   - An initialized gitOps instance that implements the Ops interface.
+//   - An error if the repository cannot be opened or initialized.
+//
+// Create adds an empty file, Put commits a new version of the file, Delete removes
+// the file and commits. Read returns the working-tree content and ReadAll replays
+// the versions committed since the key was last created. Putting the content the
+// key already holds records a commit but no new version, as Git sees no change to the file.
+// Keys are paths relative to the working tree; a key that is absolute, contains a ".."
+// segment or names something inside the .git directory is rejected with a KeyError.
+func NewGitOps(repoPath string) (Ops, error) {
+	repo, err := git.PlainOpen(repoPath)
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		repo, err = git.PlainInit(repoPath, false)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", LocationError("git: failed to open repository"), err)
+	}
+	return gitOps{mu: &sync.Mutex{}, path: repoPath, repo: repo}, nil
+}
+
+// commit writes content to key, or removes it when content is nil, and commits the change.
+func (g gitOps) commit(key string, content []byte, message string) error {
+	if err := checkGitKey(key); err != nil {
+		return err
+	}
+	wt, err := g.repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("%w: %w", OpsInternalError("git: failed to open worktree"), err)
+	}
+	path := filepath.Join(g.path, key)
+	if content == nil {
+		if _, err := wt.Remove(filepath.ToSlash(key)); err != nil {
+			return fmt.Errorf("%w: %w", OpsInternalError("git: failed to remove file"), err)
+		}
+	} else {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("%w: %w", LocationError("git: failed to create directory"), err)
+		}
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			return fmt.Errorf("%w: %w", OpsInternalError("git: failed to write file"), err)
+		}
+		if _, err := wt.Add(filepath.ToSlash(key)); err != nil {
+			return fmt.Errorf("%w: %w", OpsInternalError("git: failed to stage file"), err)
+		}
+	}
+	_, err = wt.Commit(message, &git.CommitOptions{
+		AllowEmptyCommits: true,
+		Author: &object.Signature{
+			Name:  "libstore",
+			Email: "libstore@localhost",
+			When:  time.Now(),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %w", OpsInternalError("git: failed to commit"), err)
+	}
+	return nil
+}
+
+// checkGitKey rejects keys that do not name a file of the working tree: absolute
+// paths, paths leaving the repository and paths into the .git directory.
+func checkGitKey(key string) error {
+	if !filepath.IsLocal(key) {
+		return KeyError("git: key is not a path inside the repository: " + key)
+	}
+	for _, segment := range strings.Split(filepath.ToSlash(key), "/") {
+		if segment == ".." || strings.EqualFold(segment, ".git") {
+			return KeyError("git: key is not a path inside the repository: " + key)
+		}
+	}
+	return nil
+}
+
+func (g gitOps) exists(key string) (bool, error) {
+	if err := checkGitKey(key); err != nil {
+		return false, err
+	}
+	_, err := os.Stat(filepath.Join(g.path, key))
+	if err == nil {
+		return true, nil
+	}
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return false, fmt.Errorf("%w: %w", KeyError("git: checking if file exists"), err)
+}
+
+// Create implements Ops.
+func (g gitOps) Create(ctx context.Context, key string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	exists, err := g.exists(key)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return KeyError("git: key already exists: " + key)
+	}
+	return g.commit(key, []byte{}, "libstore: create "+key)
+}
+
+// ReadAll implements Ops.
+func (g gitOps) ReadAll(ctx context.Context, key string) ([][]byte, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	exists, err := g.exists(key)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, KeyNotFoundError("git: key not found: " + key)
+	}
+
+	name := filepath.ToSlash(key)
+	commits, err := g.repo.Log(&git.LogOptions{FileName: &name})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", OpsInternalError("git: failed to read log"), err)
+	}
+	defer commits.Close()
+
+	// The log is newest first; walk back until the commit preceding the latest creation.
+	var versions [][]byte
+	for {
+		c, err := commits.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", OpsInternalError("git: failed to walk log"), err)
+		}
+		file, err := c.File(name)
+		if errors.Is(err, object.ErrFileNotFound) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", OpsInternalError("git: failed to read file at commit"), err)
+		}
+		content, err := file.Contents()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", OpsInternalError("git: failed to read file contents"), err)
+		}
+		versions = append(versions, []byte(content))
+	}
+	if len(versions) == 0 {
+		return nil, nil
+	}
+	// Drop the empty version committed by Create.
+	versions = versions[:len(versions)-1]
+	slices.Reverse(versions)
+	return versions, nil
+}
+
+// Read implements Ops.
+func (g gitOps) Read(ctx context.Context, key string) ([]byte, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if err := checkGitKey(key); err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(filepath.Join(g.path, key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, KeyNotFoundError("git: key not found: " + key)
+		}
+		return nil, fmt.Errorf("%w: %w", KeyError("git: reading file "+key), err)
+	}
+	if len(content) == 0 {
+		return nil, EntryError("git: no entries found for key: " + key)
+	}
+	return content, nil
+}
+
+// Put implements Ops.
+func (g gitOps) Put(ctx context.Context, key string, entry []byte) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	exists, err := g.exists(key)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return KeyNotFoundError("git: key not found: " + key)
+	}
+	if entry == nil {
+		entry = []byte{}
+	}
+	return g.commit(key, entry, "libstore: put "+key)
+}
+
+// Delete implements Ops.
+func (g gitOps) Delete(ctx context.Context, key string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	exists, err := g.exists(key)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return KeyNotFoundError("git: key not found: " + key)
+	}
+	return g.commit(key, nil, "libstore: delete "+key)
+}
+
+// List implements Ops.
+func (g gitOps) List(ctx context.Context) ([]string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	head, err := g.repo.Head()
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", OpsInternalError("git: failed to resolve HEAD"), err)
+	}
+	c, err := g.repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", OpsInternalError("git: failed to read HEAD commit"), err)
+	}
+	files, err := c.Files()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", OpsInternalError("git: failed to read tree"), err)
+	}
+	var keys []string
+	err = files.ForEach(func(f *object.File) error {
+		keys = append(keys, filepath.FromSlash(f.Name))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", OpsInternalError("git: failed to walk tree"), err)
+	}
+	// Git orders the tree with directories as if their names ended in a slash.
+	slices.Sort(keys)
+	return keys, nil
+}
+
+var _ Ops = gitOps{}
@@ -0,0 +1,106 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestGitOps(t *testing.T) {
+	gitOps, err := libstore.NewGitOps(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := "config.yaml"
+	if err := gitOps.Create(context.TODO(), key); err != nil {
+		t.Fatalf("Error creating key: %v", err)
+	}
+	if err := gitOps.Create(context.TODO(), key); err == nil {
+		t.Error("Expected an error, key should already exist")
+	}
+	if _, err := gitOps.Read(context.TODO(), key); err == nil {
+		t.Error("Expected an error when reading an empty key")
+	}
+
+	versions := []string{"a: 1", "a: 2", "a: 3"}
+	for _, v := range versions {
+		if err := gitOps.Put(context.TODO(), key, []byte(v)); err != nil {
+			t.Fatalf("Error putting entry: %v", err)
+		}
+	}
+
+	readContent, err := gitOps.ReadAll(context.TODO(), key)
+	if err != nil {
+		t.Fatalf("Error reading history: %v", err)
+	}
+	var got []string
+	for _, c := range readContent {
+		got = append(got, string(c))
+	}
+	if !reflect.DeepEqual(got, versions) {
+		t.Errorf("History mismatch. Expected: %v, Got: %v", versions, got)
+	}
+
+	last, err := gitOps.Read(context.TODO(), key)
+	if err != nil {
+		t.Fatalf("Error reading key: %v", err)
+	}
+	if string(last) != "a: 3" {
+		t.Error("Last entry mismatch. Expected: a: 3 Got:", string(last))
+	}
+
+	keys, err := gitOps.List(context.TODO())
+	if err != nil {
+		t.Fatalf("Error listing keys: %v", err)
+	}
+	if !reflect.DeepEqual(keys, []string{key}) {
+		t.Errorf("Unexpected keys. Expected: %v, Got: %v", []string{key}, keys)
+	}
+
+	if err := gitOps.Delete(context.TODO(), key); err != nil {
+		t.Fatalf("Error deleting key: %v", err)
+	}
+	if _, err := gitOps.Read(context.TODO(), key); err == nil {
+		t.Error("Expected an error when reading a deleted key")
+	}
+
+	if err := gitOps.Create(context.TODO(), key); err != nil {
+		t.Fatalf("Error recreating key: %v", err)
+	}
+	readContent, err = gitOps.ReadAll(context.TODO(), key)
+	if err != nil {
+		t.Fatalf("Error reading history: %v", err)
+	}
+	if len(readContent) != 0 {
+		t.Errorf("Expected a recreated key to have no history, Got: %q", readContent)
+	}
+}
+
+func TestGitOpsRejectsKeysOutsideWorkTree(t *testing.T) {
+	parent := t.TempDir()
+	repo := filepath.Join(parent, "repo")
+	gitOps, err := libstore.NewGitOps(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"", "../escape", "a/../../escape", "/abs", ".git/x", ".git/HEAD", "sub/.git/config", "a/../b"} {
+		var keyErr libstore.KeyError
+		if err := gitOps.Create(context.TODO(), key); !errors.As(err, &keyErr) {
+			t.Errorf("Create(%q): expected a KeyError, got %v", key, err)
+		}
+		if err := gitOps.Put(context.TODO(), key, []byte("x")); !errors.As(err, &keyErr) {
+			t.Errorf("Put(%q): expected a KeyError, got %v", key, err)
+		}
+		if _, err := gitOps.Read(context.TODO(), key); !errors.As(err, &keyErr) {
+			t.Errorf("Read(%q): expected a KeyError, got %v", key, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(parent, "escape")); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing to be written outside the repository, got %v", err)
+	}
+}
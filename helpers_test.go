@@ -0,0 +1,117 @@
+package libstore_test
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+// recordingOps wraps an Ops and counts the calls made to each of its methods.
+type recordingOps struct {
+	libstore.Ops
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func newRecordingOps(ops libstore.Ops) *recordingOps {
+	return &recordingOps{Ops: ops, calls: map[string]int{}}
+}
+
+func (r *recordingOps) record(method string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls[method]++
+}
+
+// count returns the number of calls made to method.
+func (r *recordingOps) count(method string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls[method]
+}
+
+func (r *recordingOps) Create(ctx context.Context, key string) error {
+	r.record("Create")
+	return r.Ops.Create(ctx, key)
+}
+
+func (r *recordingOps) ReadAll(ctx context.Context, key string) ([][]byte, error) {
+	r.record("ReadAll")
+	return r.Ops.ReadAll(ctx, key)
+}
+
+func (r *recordingOps) Read(ctx context.Context, key string) ([]byte, error) {
+	r.record("Read")
+	return r.Ops.Read(ctx, key)
+}
+
+func (r *recordingOps) Put(ctx context.Context, key string, entry []byte) error {
+	r.record("Put")
+	return r.Ops.Put(ctx, key, entry)
+}
+
+func (r *recordingOps) Delete(ctx context.Context, key string) error {
+	r.record("Delete")
+	return r.Ops.Delete(ctx, key)
+}
+
+func (r *recordingOps) List(ctx context.Context) ([]string, error) {
+	r.record("List")
+	return r.Ops.List(ctx)
+}
+
+// testBackendConstructors open a fresh Ops of each backend shared by the tests that
+// run against several of them, by name. DB skips the test unless
+// LIBSTORE_TEST_POSTGRES is set.
+var testBackendConstructors = map[string]func(t *testing.T) libstore.Ops{
+	"InMemory": func(t *testing.T) libstore.Ops { return libstore.NewInMemoryOps() },
+	// recordingOps hides the capabilities of the in-memory store, exercising the
+	// fallbacks of the package helpers.
+	"Fallback": func(t *testing.T) libstore.Ops { return newRecordingOps(libstore.NewInMemoryOps()) },
+	"File":     newTestFileOps,
+	"Git": func(t *testing.T) libstore.Ops {
+		ops, err := libstore.NewGitOps(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ops
+	},
+	"LogFile": func(t *testing.T) libstore.Ops {
+		ops, err := libstore.NewLogFileOps(filepath.Join(t.TempDir(), "segment"), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { ops.(*libstore.LogFileOps).Close() })
+		return ops
+	},
+	"S3": func(t *testing.T) libstore.Ops { return newFakeS3Ops(t) },
+	"DB": newTestDBOps,
+}
+
+// testBackends returns the constructors of the named backends of
+// testBackendConstructors, for a test to range over after adding its own.
+func testBackends(t *testing.T, names ...string) map[string]func(t *testing.T) libstore.Ops {
+	t.Helper()
+	backends := make(map[string]func(t *testing.T) libstore.Ops, len(names))
+	for _, name := range names {
+		newOps, ok := testBackendConstructors[name]
+		if !ok {
+			t.Fatalf("unknown test backend %q", name)
+		}
+		backends[name] = newOps
+	}
+	return backends
+}
+
+// newTestFileOps opens a fileOps in a temporary directory.
+func newTestFileOps(t *testing.T) libstore.Ops {
+	t.Helper()
+	ops, err := libstore.NewFileOps(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ops
+}
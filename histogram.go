@@ -0,0 +1,80 @@
+package libstore
+
+import (
+	"context"
+	"math"
+	"slices"
+)
+
+// SizeOverflowBucket is the SizeHistogram bucket counting entries larger than every bound.
+const SizeOverflowBucket int64 = math.MaxInt64
+
+// SizeHistogrammer is implemented by backends that can compute the size distribution
+// of their entries without reading them.
+type SizeHistogrammer interface {
+	// SizeHistogram counts the entries of every key by size. See the SizeHistogram function.
+	SizeHistogram(ctx context.Context, buckets []int64) (map[int64]int, error)
+}
+
+// SizeHistogram counts the entries of all keys of ops by size in bytes.
+//
+// buckets are inclusive upper bounds, in any order. An entry is counted under the
+// smallest bound it does not exceed, and under SizeOverflowBucket if it exceeds them
+// all. Every bound is present in the result, with a count of zero if no entry falls
+// into it.
+//
+// Backends implementing SizeHistogrammer compute it without reading entries; for the
+// others every key is read in full.
+func SizeHistogram(ctx context.Context, ops Ops, buckets []int64) (map[int64]int, error) {
+	if histogrammer, ok := ops.(SizeHistogrammer); ok {
+		return histogrammer.SizeHistogram(ctx, buckets)
+	}
+	h := newSizeHistogram(buckets)
+	for key, err := range ListSeq(ctx, ops) {
+		if err != nil {
+			return nil, err
+		}
+		entries, err := ops.ReadAll(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			h.add(int64(len(entry)), 1)
+		}
+	}
+	return h.counts, nil
+}
+
+// sizeHistogram bins sizes into sorted, inclusive upper bounds.
+type sizeHistogram struct {
+	bounds []int64
+	counts map[int64]int
+}
+
+func newSizeHistogram(buckets []int64) *sizeHistogram {
+	bounds := slices.Compact(slices.Sorted(slices.Values(buckets)))
+	counts := make(map[int64]int, len(bounds)+1)
+	for _, bound := range bounds {
+		counts[bound] = 0
+	}
+	counts[SizeOverflowBucket] = 0
+	return &sizeHistogram{bounds: bounds, counts: counts}
+}
+
+// bucket returns the bound size is counted under.
+func (h *sizeHistogram) bucket(size int64) int64 {
+	i, _ := slices.BinarySearch(h.bounds, size)
+	return h.boundAt(i)
+}
+
+// boundAt returns the i-th bound, or SizeOverflowBucket past the last one.
+func (h *sizeHistogram) boundAt(i int) int64 {
+	if i >= len(h.bounds) {
+		return SizeOverflowBucket
+	}
+	return h.bounds[i]
+}
+
+func (h *sizeHistogram) add(size int64, n int) {
+	h.counts[h.bucket(size)] += n
+}
@@ -0,0 +1,38 @@
+package libstore_test
+
+import (
+	"context"
+	"maps"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestSizeHistogram(t *testing.T) {
+	for name, ops := range map[string]libstore.Ops{
+		"memory": libstore.NewInMemoryOps(),
+		// recordingOps hides the SizeHistogrammer of the in-memory store, exercising the fallback.
+		"fallback": newRecordingOps(libstore.NewInMemoryOps()),
+	} {
+		t.Run(name, func(t *testing.T) {
+			for _, size := range []int{0, 10, 11, 100, 1000} {
+				key := string(rune('a' + size%26))
+				if _, err := libstore.CreateIfNotExists(context.TODO(), ops, key); err != nil {
+					t.Fatal(err)
+				}
+				if err := ops.Put(context.TODO(), key, make([]byte, size)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := libstore.SizeHistogram(context.TODO(), ops, []int64{100, 10})
+			if err != nil {
+				t.Fatalf("Error computing histogram: %v", err)
+			}
+			want := map[int64]int{10: 2, 100: 2, libstore.SizeOverflowBucket: 1}
+			if !maps.Equal(got, want) {
+				t.Errorf("Histogram mismatch. Expected: %v Got: %v", want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package libstore
+
+import (
+	"context"
+	"time"
+)
+
+// VersionInfo is one version of a key, as returned by History.
+type VersionInfo struct {
+	// Version numbers the entries of a key, starting at 1 for the oldest.
+	Version int64
+	// Value is the entry written as this version.
+	Value []byte
+	// CreatedAt is when the version was written, or the zero time if the backend
+	// does not track it.
+	CreatedAt time.Time
+}
+
+// HistoryReader is implemented by backends that record when each version of a key
+// was written.
+type HistoryReader interface {
+	// History returns every version of key, oldest first.
+	// It returns a KeyNotFoundError if the key does not exist.
+	History(ctx context.Context, key string) ([]VersionInfo, error)
+}
+
+// History returns every version of key, oldest first, along with when it was
+// written. A missing key yields a KeyNotFoundError.
+//
+// Backends implementing HistoryReader report the versions and timestamps they
+// store. For the others the entries are read with ReadAll, numbered from 1 and
+// returned with zero timestamps.
+func History(ctx context.Context, ops Ops, key string) ([]VersionInfo, error) {
+	if reader, ok := ops.(HistoryReader); ok {
+		return reader.History(ctx, key)
+	}
+	entries, err := ops.ReadAll(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	history := make([]VersionInfo, len(entries))
+	for i, entry := range entries {
+		history[i] = VersionInfo{Version: int64(i + 1), Value: entry}
+	}
+	return history, nil
+}
+
+// HistoryPager is implemented by backends that can read a page of the history of a
+// key without reading all of it.
+type HistoryPager interface {
+	// HistoryPage returns up to limit versions of key older than beforeVersion, or
+	// the latest ones if beforeVersion is not positive, newest first, along with the
+	// beforeVersion of the next page, or 0 after the last page. It returns a
+	// KeyNotFoundError if the key does not exist.
+	HistoryPage(ctx context.Context, key string, beforeVersion int64, limit int) ([]VersionInfo, int64, error)
+}
+
+// HistoryPage returns one page of the history of key, newest first, such as for an
+// activity feed: up to limit versions older than beforeVersion, and the beforeVersion
+// of the next page. The first page is read with a beforeVersion of 0, meaning the
+// latest version, and each following one with the nextBefore of the page before it,
+// until nextBefore is 0. It returns an EntryError if limit is not positive.
+//
+// Backends implementing HistoryPager, such as the database backend, read the page
+// alone. For the others the whole history is read with History and cut.
+func HistoryPage(ctx context.Context, ops Ops, key string, beforeVersion int64, limit int) ([]VersionInfo, int64, error) {
+	if err := checkPageLimit(limit); err != nil {
+		return nil, 0, err
+	}
+	if pager, ok := ops.(HistoryPager); ok {
+		return pager.HistoryPage(ctx, key, beforeVersion, limit)
+	}
+	history, err := History(ctx, ops, key)
+	if err != nil {
+		return nil, 0, err
+	}
+	page := []VersionInfo{}
+	for i := len(history) - 1; i >= 0; i-- {
+		if beforeVersion > 0 && history[i].Version >= beforeVersion {
+			continue
+		}
+		if len(page) == limit {
+			return page, page[limit-1].Version, nil
+		}
+		page = append(page, history[i])
+	}
+	return page, 0, nil
+}
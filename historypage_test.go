@@ -0,0 +1,75 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestHistoryPage(t *testing.T) {
+	// Put appends to the file and database backends, so their keys have a history.
+	backends := testBackends(t, "File", "DB")
+	for name, newOps := range backends {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			ops := newOps(t)
+			key := filepath.Base(testKey(t))
+			if err := ops.Create(ctx, key); err != nil {
+				t.Fatal(err)
+			}
+			if page, next, err := libstore.HistoryPage(ctx, ops, key, 0, 10); err != nil || len(page) != 0 || next != 0 {
+				t.Errorf("Expected an empty last page for a key without entries, Got: %v, %d, %v", page, next, err)
+			}
+			const versions = 25
+			for i := 1; i <= versions; i++ {
+				if err := ops.Put(ctx, key, []byte(fmt.Sprint(i))); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var pages int
+			want := int64(versions)
+			before := int64(0)
+			for {
+				page, next, err := libstore.HistoryPage(ctx, ops, key, before, 10)
+				if err != nil {
+					t.Fatalf("HistoryPage(%d) error = %v", before, err)
+				}
+				pages++
+				for _, info := range page {
+					if info.Version != want || string(info.Value) != fmt.Sprint(want) {
+						t.Fatalf("Expected version %d, Got: %d with %q", want, info.Version, info.Value)
+					}
+					want--
+				}
+				if next == 0 || pages > versions {
+					break
+				}
+				before = next
+			}
+			if pages != 3 || want != 0 {
+				t.Errorf("Expected 3 pages down to version 1, Got: %d pages down to version %d", pages, want+1)
+			}
+
+			var notFound libstore.KeyNotFoundError
+			if _, _, err := libstore.HistoryPage(ctx, ops, key+"-missing", 5, 10); !errors.As(err, &notFound) {
+				t.Errorf("Expected a KeyNotFoundError for a missing key, Got: %v", err)
+			}
+			var entryErr libstore.EntryError
+			if _, _, err := libstore.HistoryPage(ctx, ops, key, 0, 0); !errors.As(err, &entryErr) {
+				t.Errorf("Expected an EntryError for a zero limit, Got: %v", err)
+			}
+			if pager, ok := ops.(libstore.HistoryPager); ok {
+				for _, limit := range []int{0, -1} {
+					if _, _, err := pager.HistoryPage(ctx, key, 0, limit); !errors.As(err, &entryErr) {
+						t.Errorf("Expected an EntryError from the backend for a limit of %d, Got: %v", limit, err)
+					}
+				}
+			}
+		})
+	}
+}
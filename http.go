@@ -0,0 +1,608 @@
+package libstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+)
+
+// The HTTP API served by NewHTTPHandler and used by NewHTTPClientOps:
+//
+//	GET    /keys             List, as a JSON array of keys
+//	POST   /keys/{key}       Create
+//	GET    /keys/{key}       Read, the latest entry, tagged by ReadWithToken
+//	GET    /keys/{key}?all   ReadAll, every entry
+//	PUT    /keys/{key}       Put, with the entry as the body, of at most HTTPHandlerMaxBodySize
+//	DELETE /keys/{key}       Delete
+//
+// Entries are exchanged in one of two encodings, chosen by the Accept header of
+// reads and the Content-Type header of writes:
+//
+//   - Raw: Read returns the entry itself, with the content type recorded by the
+//     backend if it implements ContentTypeReader and application/octet-stream
+//     otherwise; ReadAll returns a multipart/mixed body with one part per entry;
+//     Put stores the body as is, recording its Content-Type with the ContentType
+//     option. This is the default of Read and Put.
+//   - JSON (application/json): entries travel base64-encoded in JSON, as an
+//     httpEntry object for Read and Put and as an array of strings for ReadAll.
+//     This is the default of ReadAll. A JSON document meant to be stored as is must
+//     therefore be sent with another Content-Type: a Put of application/json whose
+//     body is not an httpEntry object, with an entry field and no other fields than
+//     those of httpEntry, is rejected with 400 Bad Request.
+//
+// Read sends the token of the entry as its ETag, with a "+json" suffix in the JSON
+// encoding so that the two representations of an entry do not share an ETag, and
+// answers 304 Not Modified to requests whose If-None-Match lists the ETag of the
+// representation asked for.
+//
+// Keys are path-escaped. Failures are reported with an Error as the JSON body.
+const httpKeysPath = "/keys"
+
+// httpDefaultMaxBodySize is the size above which NewHTTPHandler rejects the body of
+// a Put, unless HTTPHandlerMaxBodySize sets another.
+const httpDefaultMaxBodySize = 32 << 20
+
+// Media types of the HTTP API.
+const (
+	httpRawType       = "application/octet-stream"
+	httpJSONType      = "application/json"
+	httpMultipartType = "multipart/mixed"
+)
+
+// httpJSONETagSuffix ends the ETag of an entry sent in the JSON encoding.
+const httpJSONETagSuffix = "+json"
+
+// httpEntry is the JSON encoding of an entry.
+type httpEntry struct {
+	// Entry is the entry, base64-encoded.
+	Entry []byte `json:"entry"`
+	// ContentType is the content type recorded with the entry, if any.
+	ContentType string `json:"content_type,omitempty"`
+}
+
+// negotiate returns the first of offers accepted by the Accept header of r, ignoring
+// quality values, or offers[0] if the header is missing or accepts none of them.
+func negotiate(r *http.Request, offers ...string) string {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		accepted, _, _ = strings.Cut(accepted, ";")
+		accepted = strings.TrimSpace(accepted)
+		for _, offer := range offers {
+			if strings.EqualFold(accepted, offer) {
+				return offer
+			}
+		}
+	}
+	return offers[0]
+}
+
+// noneMatch reports whether the If-None-Match header of r lists the entity tag
+// etag, weakly or not, or is "*".
+func noneMatch(r *http.Request, etag string) bool {
+	for _, listed := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		listed = strings.TrimPrefix(strings.TrimSpace(listed), "W/")
+		if listed == "*" || listed == `"`+etag+`"` {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeHTTPEntry decodes the httpEntry object of a Put in the JSON encoding. It
+// returns an EntryError if body is not such an object, has unknown fields or has no
+// entry field, so a JSON document sent for storage as is is not silently stored as
+// an empty entry.
+func decodeHTTPEntry(body []byte) (httpEntry, error) {
+	var e struct {
+		Entry       *[]byte `json:"entry"`
+		ContentType string  `json:"content_type"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&e); err != nil {
+		return httpEntry{}, fmt.Errorf("%w: %w", EntryError("http: invalid JSON entry"), err)
+	}
+	if dec.More() {
+		return httpEntry{}, EntryError("http: invalid JSON entry: data after the object")
+	}
+	if e.Entry == nil {
+		return httpEntry{}, EntryError(`http: invalid JSON entry: missing "entry" field`)
+	}
+	return httpEntry{Entry: *e.Entry, ContentType: e.ContentType}, nil
+}
+
+// mediaType returns the media type of the Content-Type in h, without parameters.
+func mediaType(h http.Header) (string, map[string]string) {
+	t, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		return "", nil
+	}
+	return t, params
+}
+
+// HTTPHandlerOption configures NewHTTPHandler.
+type HTTPHandlerOption func(*httpHandler)
+
+// HTTPHandlerMaxBodySize sets the largest body, in bytes, accepted by a Put,
+// 32 MiB by default. Larger bodies are answered with 413 Request Entity Too Large
+// and an EntryError, without reading them past the limit.
+func HTTPHandlerMaxBodySize(n int64) HTTPHandlerOption {
+	return func(h *httpHandler) {
+		h.maxBodySize = n
+	}
+}
+
+// httpHandler holds the settings of NewHTTPHandler.
+type httpHandler struct {
+	maxBodySize int64
+}
+
+// NewHTTPHandler returns an http.Handler exposing ops over HTTP, for clients such
+// as NewHTTPClientOps. The request context is passed on to ops.
+func NewHTTPHandler(ops Ops, opts ...HTTPHandlerOption) http.Handler {
+	h := httpHandler{maxBodySize: httpDefaultMaxBodySize}
+	for _, opt := range opts {
+		opt(&h)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+httpKeysPath, func(w http.ResponseWriter, r *http.Request) {
+		keys, err := ops.List(r.Context())
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(keys)
+	})
+	mux.HandleFunc("POST "+httpKeysPath+"/{key...}", func(w http.ResponseWriter, r *http.Request) {
+		if err := ops.Create(r.Context(), r.PathValue("key")); err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+	mux.HandleFunc("GET "+httpKeysPath+"/{key...}", func(w http.ResponseWriter, r *http.Request) {
+		key := r.PathValue("key")
+		if !r.URL.Query().Has("all") {
+			entry, token, err := ReadWithToken(r.Context(), ops, key)
+			if err != nil {
+				writeHTTPError(w, err)
+				return
+			}
+			encoding, etag := negotiate(r, httpRawType, httpJSONType), token
+			if encoding == httpJSONType {
+				etag += httpJSONETagSuffix
+			}
+			w.Header().Set("ETag", `"`+etag+`"`)
+			w.Header().Set("Vary", "Accept")
+			if noneMatch(r, etag) {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			contentType := ""
+			if reader, ok := ops.(ContentTypeReader); ok {
+				if contentType, err = reader.ReadContentType(r.Context(), key); err != nil {
+					writeHTTPError(w, err)
+					return
+				}
+			}
+			if encoding == httpJSONType {
+				w.Header().Set("Content-Type", httpJSONType)
+				_ = json.NewEncoder(w).Encode(httpEntry{Entry: entry, ContentType: contentType})
+				return
+			}
+			if contentType == "" {
+				contentType = httpRawType
+			}
+			w.Header().Set("Content-Type", contentType)
+			_, _ = w.Write(entry)
+			return
+		}
+		entries, err := ops.ReadAll(r.Context(), key)
+		if err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		if negotiate(r, httpJSONType, httpMultipartType) == httpMultipartType {
+			mw := multipart.NewWriter(w)
+			w.Header().Set("Content-Type", httpMultipartType+"; boundary="+mw.Boundary())
+			for _, entry := range entries {
+				part, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {httpRawType}})
+				if err != nil {
+					return
+				}
+				if _, err := part.Write(entry); err != nil {
+					return
+				}
+			}
+			_ = mw.Close()
+			return
+		}
+		// Encode entry by entry so large histories are not buffered twice.
+		w.Header().Set("Content-Type", httpJSONType)
+		enc := json.NewEncoder(w)
+		_, _ = io.WriteString(w, "[")
+		for i, entry := range entries {
+			if i > 0 {
+				_, _ = io.WriteString(w, ",")
+			}
+			if err := enc.Encode(entry); err != nil {
+				return
+			}
+		}
+		_, _ = io.WriteString(w, "]")
+	})
+	mux.HandleFunc("PUT "+httpKeysPath+"/{key...}", func(w http.ResponseWriter, r *http.Request) {
+		entry, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.maxBodySize))
+		if tooLarge := (*http.MaxBytesError)(nil); errors.As(err, &tooLarge) {
+			writeHTTPErrorStatus(w, http.StatusRequestEntityTooLarge,
+				EntryError(fmt.Sprintf("http: request body larger than %d bytes", tooLarge.Limit)))
+			return
+		}
+		if err != nil {
+			writeHTTPError(w, fmt.Errorf("%w: %w", EntryError("http: failed to read request body"), err))
+			return
+		}
+		ctx := r.Context()
+		switch contentType, _ := mediaType(r.Header); contentType {
+		case "":
+		case httpJSONType:
+			e, err := decodeHTTPEntry(entry)
+			if err != nil {
+				writeHTTPError(w, err)
+				return
+			}
+			entry = e.Entry
+			if e.ContentType != "" {
+				ctx = WithOpOptions(ctx, ContentType(e.ContentType))
+			}
+		default:
+			ctx = WithOpOptions(ctx, ContentType(r.Header.Get("Content-Type")))
+		}
+		if err := ops.Put(ctx, r.PathValue("key"), entry); err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("DELETE "+httpKeysPath+"/{key...}", func(w http.ResponseWriter, r *http.Request) {
+		if err := ops.Delete(r.Context(), r.PathValue("key")); err != nil {
+			writeHTTPError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux
+}
+
+// httpError finds the first libstore error in the chain of err, so wrapped errors
+// keep their code, and reports it with the message of err.
+func httpError(err error) *Error {
+	var find func(err error) ErrorCode
+	find = func(err error) ErrorCode {
+		if err == nil {
+			return ErrUnknown
+		}
+		if code := NewError(err).Code; code != ErrUnknown {
+			return code
+		}
+		switch u := err.(type) {
+		case interface{ Unwrap() error }:
+			return find(u.Unwrap())
+		case interface{ Unwrap() []error }:
+			for _, err := range u.Unwrap() {
+				if code := find(err); code != ErrUnknown {
+					return code
+				}
+			}
+		}
+		return ErrUnknown
+	}
+	return &Error{Code: find(err), Message: strings.TrimPrefix(err.Error(), "libstore: ")}
+}
+
+func writeHTTPError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch httpError(err).Code {
+	case ErrKey, ErrEntry:
+		status = http.StatusBadRequest
+	case ErrKeyNotFound:
+		status = http.StatusNotFound
+	case ErrConflict, ErrImmutable, ErrVersionLimit, ErrFenced:
+		status = http.StatusConflict
+	case ErrPermission:
+		status = http.StatusForbidden
+	case ErrUnsupported:
+		status = http.StatusNotImplemented
+	case ErrClosed:
+		status = http.StatusServiceUnavailable
+	case ErrTimeout:
+		status = http.StatusGatewayTimeout
+	case ErrPrecondition:
+		status = http.StatusPreconditionFailed
+	}
+	writeHTTPErrorStatus(w, status, err)
+}
+
+// writeHTTPErrorStatus reports err with the given status rather than the one of its
+// code.
+func writeHTTPErrorStatus(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(httpError(err))
+}
+
+// HTTPEncoding selects how NewHTTPClientOps exchanges entries with the server.
+type HTTPEncoding int
+
+const (
+	// HTTPEncodingRaw sends and receives entries as raw bodies, and histories as
+	// multipart/mixed bodies.
+	HTTPEncodingRaw HTTPEncoding = iota
+	// HTTPEncodingJSON sends and receives entries base64-encoded in JSON, for
+	// intermediaries that only pass JSON through.
+	HTTPEncodingJSON
+)
+
+// HTTPClientOption configures NewHTTPClientOps.
+type HTTPClientOption func(*httpClientOps)
+
+// HTTPClientEncoding sets the encoding of the entries exchanged with the server,
+// HTTPEncodingRaw by default. Responses are decoded by their Content-Type, so a
+// server answering in the other encoding is understood too.
+func HTTPClientEncoding(encoding HTTPEncoding) HTTPClientOption {
+	return func(h *httpClientOps) {
+		h.encoding = encoding
+	}
+}
+
+// httpClientOps implements Ops by calling a remote NewHTTPHandler.
+type httpClientOps struct {
+	baseURL  string
+	client   *http.Client
+	encoding HTTPEncoding
+}
+
+// NewHTTPClientOps returns an Ops backed by the store served by NewHTTPHandler at
+// baseURL, using client or, if it is nil, http.DefaultClient. Failures reported by
+// the server are returned as the libstore error types they were raised as, and the
+// context of every call is attached to its request.
+func NewHTTPClientOps(baseURL string, client *http.Client, opts ...HTTPClientOption) Ops {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	h := httpClientOps{baseURL: strings.TrimSuffix(baseURL, "/"), client: client}
+	for _, opt := range opts {
+		opt(&h)
+	}
+	return h
+}
+
+// httpKeyPath returns the path of the resource of key. It rejects with a KeyError the
+// keys the server cannot route to their resource: the empty key, which would name
+// the key listing, and keys with a "." or ".." segment, which the server cleans
+// away.
+func httpKeyPath(key string) (string, error) {
+	if key == "" {
+		return "", KeyError("http: empty key")
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "." || segment == ".." {
+			return "", KeyError("http: key has a dot segment: " + key)
+		}
+	}
+	return httpKeysPath + "/" + url.PathEscape(key), nil
+}
+
+// doKey sends a request for key to do.
+func (h httpClientOps) doKey(ctx context.Context, method, key, query string, header http.Header, body []byte) (*http.Response, error) {
+	path, err := httpKeyPath(key)
+	if err != nil {
+		return nil, err
+	}
+	return h.do(ctx, method, path, query, header, body)
+}
+
+// do sends a request for path and returns the response if it succeeded or was
+// 304 Not Modified. The caller must close its body.
+func (h httpClientOps) do(ctx context.Context, method, path, query string, header http.Header, body []byte) (*http.Response, error) {
+	u := h.baseURL + path
+	if query != "" {
+		u += "?" + query
+	}
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", LocationError("http: invalid request"), err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	res, err := h.client.Do(req)
+	if err != nil {
+		return nil, annotateTimeout("http: "+method, fmt.Errorf("%w: %w", OpsInternalError("http: request failed"), err))
+	}
+	if res.StatusCode < 300 || res.StatusCode == http.StatusNotModified {
+		return res, nil
+	}
+	defer res.Body.Close()
+
+	var e Error
+	if err := json.NewDecoder(res.Body).Decode(&e); err != nil {
+		return nil, OpsInternalError(fmt.Sprintf("http: unexpected status %s", res.Status))
+	}
+	return nil, TranslateToError(int(e.Code), e.Message)
+}
+
+// Create implements Ops.
+func (h httpClientOps) Create(ctx context.Context, key string) error {
+	res, err := h.doKey(ctx, http.MethodPost, key, "", nil, nil)
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+// ReadAll implements Ops. The entries are decoded as they arrive.
+func (h httpClientOps) ReadAll(ctx context.Context, key string) ([][]byte, error) {
+	accept := httpMultipartType
+	if h.encoding == HTTPEncodingJSON {
+		accept = httpJSONType
+	}
+	res, err := h.doKey(ctx, http.MethodGet, key, "all", http.Header{"Accept": {accept}}, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	entries := [][]byte{}
+	if contentType, params := mediaType(res.Header); contentType == httpMultipartType {
+		mr := multipart.NewReader(res.Body, params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				return entries, nil
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", EntryError("http: failed to decode entries"), err)
+			}
+			entry, err := io.ReadAll(part)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", EntryError("http: failed to decode entries"), err)
+			}
+			entries = append(entries, entry)
+		}
+	}
+	dec := json.NewDecoder(res.Body)
+	if _, err := dec.Token(); err != nil {
+		return nil, fmt.Errorf("%w: %w", EntryError("http: failed to decode entries"), err)
+	}
+	for dec.More() {
+		var entry []byte
+		if err := dec.Decode(&entry); err != nil {
+			return nil, fmt.Errorf("%w: %w", EntryError("http: failed to decode entries"), err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Read implements Ops.
+func (h httpClientOps) Read(ctx context.Context, key string) ([]byte, error) {
+	entry, _, _, err := h.read(ctx, key, "")
+	return entry, err
+}
+
+// ReadWithToken implements TokenReader with the ETag sent by the server.
+func (h httpClientOps) ReadWithToken(ctx context.Context, key string) ([]byte, string, error) {
+	entry, token, _, err := h.read(ctx, key, "")
+	return entry, token, err
+}
+
+// ReadIfNoneMatch implements ConditionalReader with a conditional GET, so the server
+// does not send an unchanged entry.
+func (h httpClientOps) ReadIfNoneMatch(ctx context.Context, key string, token string) ([]byte, bool, error) {
+	entry, _, unchanged, err := h.read(ctx, key, token)
+	return entry, unchanged, err
+}
+
+// read reads key and its token, unless the token is token.
+func (h httpClientOps) read(ctx context.Context, key string, token string) ([]byte, string, bool, error) {
+	accept, suffix := httpRawType, ""
+	if h.encoding == HTTPEncodingJSON {
+		accept, suffix = httpJSONType, httpJSONETagSuffix
+	}
+	header := http.Header{"Accept": {accept}}
+	if token != "" {
+		header.Set("If-None-Match", `"`+token+suffix+`"`)
+	}
+	res, err := h.doKey(ctx, http.MethodGet, key, "", header, nil)
+	if err != nil {
+		return nil, "", false, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotModified {
+		return nil, token, true, nil
+	}
+	current := strings.Trim(strings.TrimPrefix(res.Header.Get("ETag"), "W/"), `"`)
+	if contentType, _ := mediaType(res.Header); contentType == httpJSONType && h.encoding == HTTPEncodingJSON {
+		var e httpEntry
+		if err := json.NewDecoder(res.Body).Decode(&e); err != nil {
+			return nil, "", false, fmt.Errorf("%w: %w", EntryError("http: failed to decode entry"), err)
+		}
+		return e.Entry, strings.TrimSuffix(current, httpJSONETagSuffix), false, nil
+	}
+	entry, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("%w: %w", EntryError("http: failed to read entry"), err)
+	}
+	return entry, current, false, nil
+}
+
+// Put implements Ops. The ContentType option is sent along with the entry, in the
+// JSON encoding if it is application/json, which the server would take for the
+// encoding of the raw body.
+func (h httpClientOps) Put(ctx context.Context, key string, entry []byte) error {
+	if entry == nil {
+		entry = []byte{}
+	}
+	header := http.Header{}
+	contentType := opOptionsFrom(ctx).contentType
+	if t, _, _ := mime.ParseMediaType(contentType); h.encoding == HTTPEncodingJSON || t == httpJSONType {
+		body, err := json.Marshal(httpEntry{Entry: entry, ContentType: contentType})
+		if err != nil {
+			return fmt.Errorf("%w: %w", OpsInternalError("http: failed to encode entry"), err)
+		}
+		entry = body
+		header.Set("Content-Type", httpJSONType)
+	} else if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	res, err := h.doKey(ctx, http.MethodPut, key, "", header, entry)
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+// Delete implements Ops.
+func (h httpClientOps) Delete(ctx context.Context, key string) error {
+	res, err := h.doKey(ctx, http.MethodDelete, key, "", nil, nil)
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+// List implements Ops.
+func (h httpClientOps) List(ctx context.Context) ([]string, error) {
+	res, err := h.do(ctx, http.MethodGet, httpKeysPath, "", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	var keys []string
+	if err := json.NewDecoder(res.Body).Decode(&keys); err != nil {
+		return nil, fmt.Errorf("%w: %w", OpsInternalError("http: failed to decode keys"), err)
+	}
+	return keys, nil
+}
+
+var (
+	_ Ops               = httpClientOps{}
+	_ TokenReader       = httpClientOps{}
+	_ ConditionalReader = httpClientOps{}
+)
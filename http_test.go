@@ -0,0 +1,238 @@
+package libstore_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cecmp/libstore"
+	"github.com/cecmp/libstore/libstoretest"
+)
+
+func newHTTPClientOps(t *testing.T, backend libstore.Ops) libstore.Ops {
+	server := httptest.NewServer(libstore.NewHTTPHandler(backend))
+	t.Cleanup(server.Close)
+	return libstore.NewHTTPClientOps(server.URL, server.Client())
+}
+
+func TestHTTPClientOpsConformance(t *testing.T) {
+	libstoretest.RunConformance(t, func() libstore.Ops {
+		backend, err := libstore.NewFileOps(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return newHTTPClientOps(t, backend)
+	})
+}
+
+func TestHTTPClientOps(t *testing.T) {
+	ctx := context.Background()
+	ops := newHTTPClientOps(t, libstore.NewInMemoryOps())
+
+	key := "nested/key with spaces?"
+	if err := ops.Create(ctx, key); err != nil {
+		t.Fatalf("Error creating key: %v", err)
+	}
+	binary := []byte{0, 1, '\n', 0xff}
+	for _, entry := range [][]byte{[]byte("text"), binary} {
+		if err := ops.Put(ctx, key, entry); err != nil {
+			t.Fatalf("Error putting entry: %v", err)
+		}
+	}
+	if entry, err := ops.Read(ctx, key); err != nil || !slices.Equal(entry, binary) {
+		t.Errorf("Unexpected entry: %q, %v", entry, err)
+	}
+	if entries, err := ops.ReadAll(ctx, key); err != nil || len(entries) == 0 || !slices.Equal(entries[len(entries)-1], binary) {
+		t.Errorf("Unexpected entries: %q, %v", entries, err)
+	}
+	if keys, err := ops.List(ctx); err != nil || !slices.Equal(keys, []string{key}) {
+		t.Errorf("Unexpected keys: %v, %v", keys, err)
+	}
+
+	var keyErr libstore.KeyError
+	if err := ops.Create(ctx, key); !errors.As(err, &keyErr) || err.Error() != "libstore: key "+key+" already exists" {
+		t.Errorf("Expected the KeyError raised by the backend, Got: %T %v", err, err)
+	}
+	var notFound libstore.KeyNotFoundError
+	if _, err := ops.Read(ctx, "missing"); !errors.As(err, &notFound) {
+		t.Errorf("Expected a KeyNotFoundError, Got: %T %v", err, err)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := ops.Read(canceled, key); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the context to be propagated, Got: %v", err)
+	}
+}
+
+func TestHTTPEncodings(t *testing.T) {
+	ctx := context.Background()
+	backend := libstore.NewInMemoryOps()
+	server := httptest.NewServer(libstore.NewHTTPHandler(backend))
+	t.Cleanup(server.Close)
+
+	binary := []byte{0, 0xff, '\n', '"', 0xc3, 0x28, '\r', '\n', '-', '-'}
+	history := [][]byte{binary, {}, []byte(`{"entry":"not an envelope"}`)}
+	if err := backend.Create(ctx, "history"); err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range history {
+		if err := libstore.PutCapped(ctx, backend, "history", entry, len(history)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, encoding := range map[string]libstore.HTTPEncoding{"raw": libstore.HTTPEncodingRaw, "json": libstore.HTTPEncodingJSON} {
+		ops := libstore.NewHTTPClientOps(server.URL, server.Client(), libstore.HTTPClientEncoding(encoding))
+		key := "binary/" + name
+		if err := ops.Create(ctx, key); err != nil {
+			t.Fatalf("%s: Error creating key: %v", name, err)
+		}
+		for _, entry := range history {
+			if err := ops.Put(ctx, key, entry); err != nil {
+				t.Fatalf("%s: Error putting entry: %v", name, err)
+			}
+			if got, err := ops.Read(ctx, key); err != nil || !bytes.Equal(got, entry) {
+				t.Errorf("%s: Read() = %q, %v, want %q", name, got, err, entry)
+			}
+		}
+		entries, err := ops.ReadAll(ctx, "history")
+		if err != nil || len(entries) != len(history) {
+			t.Fatalf("%s: ReadAll() = %q, %v, want %q", name, entries, err, history)
+		}
+		for i := range history {
+			if !bytes.Equal(entries[i], history[i]) {
+				t.Errorf("%s: ReadAll()[%d] = %q, want %q", name, i, entries[i], history[i])
+			}
+		}
+	}
+
+	// The server answers in the encoding asked for, each with its own ETag.
+	etags := map[string]string{}
+	for accept, want := range map[string]string{
+		"":                                  "application/octet-stream",
+		"application/octet-stream":          "application/octet-stream",
+		"text/html, application/json;q=0.9": "application/json",
+	} {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/keys/history", nil)
+		req.Header.Set("Accept", accept)
+		res, err := server.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if got := res.Header.Get("Content-Type"); got != want {
+			t.Errorf("Accept %q: Content-Type = %q, want %q", accept, got, want)
+		}
+		etags[want] = res.Header.Get("ETag")
+	}
+	if raw, json := etags["application/octet-stream"], etags["application/json"]; raw == "" || raw == json {
+		t.Errorf("Expected distinct ETags for the raw and JSON encodings, Got: %q and %q", raw, json)
+	}
+}
+
+func TestHTTPPutJSONEntry(t *testing.T) {
+	backend, err := libstore.NewFileOps(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(libstore.NewHTTPHandler(backend))
+	t.Cleanup(server.Close)
+	if err := backend.Create(context.Background(), "key"); err != nil {
+		t.Fatal(err)
+	}
+
+	for body, want := range map[string]int{
+		`{"entry":"ZW50cnk="}`:                          http.StatusNoContent,
+		`{"entry":"ZW50cnk=","content_type":"a/b"}`:     http.StatusNoContent,
+		`{"name":"a JSON document"}`:                    http.StatusBadRequest,
+		`{"entry":"ZW50cnk=","name":"a JSON document"}`: http.StatusBadRequest,
+		`{"entry":null}`:                                http.StatusBadRequest,
+		`{"entry":"ZW50cnk="} {}`:                       http.StatusBadRequest,
+		`[1, 2]`:                                        http.StatusBadRequest,
+	} {
+		req, _ := http.NewRequest(http.MethodPut, server.URL+"/keys/key", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := server.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != want {
+			t.Errorf("Put %s: status = %d, want %d", body, res.StatusCode, want)
+		}
+	}
+	if entries, err := backend.ReadAll(context.Background(), "key"); err != nil || len(entries) != 2 {
+		t.Errorf("Expected only the two valid entries to be stored, Got: %q, %v", entries, err)
+	}
+}
+
+func TestHTTPReadWithToken(t *testing.T) {
+	testReadWithToken(t, newHTTPClientOps(t, libstore.NewInMemoryOps()), "key")
+	t.Run("JSON", func(t *testing.T) {
+		server := httptest.NewServer(libstore.NewHTTPHandler(libstore.NewInMemoryOps()))
+		t.Cleanup(server.Close)
+		ops := libstore.NewHTTPClientOps(server.URL, server.Client(), libstore.HTTPClientEncoding(libstore.HTTPEncodingJSON))
+		testReadWithToken(t, ops, "key")
+	})
+}
+
+func TestHTTPClientOpsInvalidKeys(t *testing.T) {
+	ctx := context.Background()
+	backend := libstore.NewInMemoryOps()
+	if err := backend.Create(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	ops := newHTTPClientOps(t, backend)
+	for _, key := range []string{"", ".", "..", "a/..", "../a", "a/./b"} {
+		var keyErr libstore.KeyError
+		if _, err := ops.Read(ctx, key); !errors.As(err, &keyErr) {
+			t.Errorf("Read(%q): expected a KeyError, got %v", key, err)
+		}
+		if err := ops.Create(ctx, key); !errors.As(err, &keyErr) {
+			t.Errorf("Create(%q): expected a KeyError, got %v", key, err)
+		}
+		if err := ops.Put(ctx, key, []byte("x")); !errors.As(err, &keyErr) {
+			t.Errorf("Put(%q): expected a KeyError, got %v", key, err)
+		}
+		if err := ops.Delete(ctx, key); !errors.As(err, &keyErr) {
+			t.Errorf("Delete(%q): expected a KeyError, got %v", key, err)
+		}
+	}
+}
+
+func TestHTTPHandlerMaxBodySize(t *testing.T) {
+	ctx := context.Background()
+	backend := libstore.NewInMemoryOps()
+	server := httptest.NewServer(libstore.NewHTTPHandler(backend, libstore.HTTPHandlerMaxBodySize(4)))
+	t.Cleanup(server.Close)
+	ops := libstore.NewHTTPClientOps(server.URL, server.Client())
+	if err := ops.Create(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ops.Put(ctx, "key", []byte("four")); err != nil {
+		t.Fatalf("Put at the limit: %v", err)
+	}
+	var entryErr libstore.EntryError
+	if err := ops.Put(ctx, "key", []byte("fives")); !errors.As(err, &entryErr) {
+		t.Errorf("Put over the limit: expected an EntryError, got %v", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodPut, server.URL+"/keys/key", strings.NewReader("fives"))
+	res, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want %d", res.StatusCode, http.StatusRequestEntityTooLarge)
+	}
+	if entry, err := backend.Read(ctx, "key"); err != nil || string(entry) != "four" {
+		t.Errorf("Expected the entry at the limit to be kept, Got: %q, %v", entry, err)
+	}
+}
@@ -0,0 +1,369 @@
+package libstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// txRunner is implemented by backends that can run several operations as one
+// atomic step, such as InMemoryOps.
+type txRunner interface {
+	WithTx(fn func(tx TxOps) error) error
+}
+
+// txContextRunner is implemented by backends whose transactions run under a
+// context, such as the database backend. fn may run more than once if the
+// transaction is retried.
+type txContextRunner interface {
+	withTx(ctx context.Context, fn func(tx TxOps) error) error
+}
+
+// conditionalWriter is implemented by backends that can replace the entry of a key
+// only if the key did not change since it was read, such as S3Ops.
+type conditionalWriter interface {
+	// readForUpdate returns the latest entry of key, nil if it holds none, and a
+	// token naming the state of key. It returns a KeyNotFoundError if key does not
+	// exist.
+	readForUpdate(ctx context.Context, key string) ([]byte, string, error)
+	// putIfUnchanged writes entry as the latest entry of key if key is still in the
+	// state token names, or if key does not exist when token is empty. It returns a
+	// ConflictError otherwise.
+	putIfUnchanged(ctx context.Context, key string, entry []byte, token string) error
+}
+
+// indexUpdateAttempts bounds how often an update of the index through a
+// conditionalWriter is retried after another writer changed the index.
+const indexUpdateAttempts = 10
+
+// IndexedOps keeps the list of its keys in an index key of the underlying Ops, so
+// that List reads one entry instead of listing the backend.
+type IndexedOps struct {
+	ops      Ops
+	indexKey string
+	// mu serializes the updates of the index.
+	mu *sync.Mutex
+}
+
+// NewIndexedOps wraps ops so that the keys created and deleted through it are
+// recorded in indexKey, whose latest entry holds them as a sorted JSON array, and
+// List returns them from there. This makes List a single Read, at the cost of
+// writing the index on every Create and Delete. indexKey itself is not listed, and
+// the operations on it return a KeyError.
+//
+// On backends with transactions, InMemoryOps and the database backend, the key and
+// the index are written in one transaction. On the others the index is written
+// after the key on Create, which is undone if the index cannot be written, and
+// before the key on Delete, whose index entry is restored if the key cannot be
+// deleted. On S3Ops the index is replaced with a write conditional on its ETag,
+// retried if another writer changed it in between. The index is written with
+// PutCapped to keep only its latest entry where the backend implements it.
+//
+// Updates are serialized in process. On backends without transactions or
+// conditional writes, several IndexedOps sharing an index, including from other
+// processes, can lose each other's updates. Keys written to ops directly are
+// missing from the index on every backend; Rebuild then recreates the index from
+// the keys ops lists. List calls Rebuild if the index does not exist yet.
+func NewIndexedOps(ops Ops, indexKey string) *IndexedOps {
+	return &IndexedOps{ops: ops, indexKey: indexKey, mu: &sync.Mutex{}}
+}
+
+// Unwrap implements Unwrapper.
+func (i *IndexedOps) Unwrap() Ops {
+	return i.ops
+}
+
+// reserved returns a KeyError for the index key.
+func (i *IndexedOps) reserved(key string) error {
+	if key == i.indexKey {
+		return KeyError("index: key " + key + " is reserved for the index")
+	}
+	return nil
+}
+
+// Create implements libstore.Ops, adding key to the index.
+func (i *IndexedOps) Create(ctx context.Context, key string) error {
+	if err := i.reserved(key); err != nil {
+		return err
+	}
+	return i.update(ctx, false, func(ops Ops) error {
+		return ops.Create(ctx, key)
+	}, func(ops Ops) error {
+		return ops.Delete(ctx, key)
+	}, func(keys []string) []string {
+		return insertKey(keys, key)
+	})
+}
+
+// Delete implements libstore.Ops, removing key from the index.
+func (i *IndexedOps) Delete(ctx context.Context, key string) error {
+	if err := i.reserved(key); err != nil {
+		return err
+	}
+	return i.update(ctx, true, func(ops Ops) error {
+		return ops.Delete(ctx, key)
+	}, func(ops Ops) error {
+		return i.changeIndex(ctx, ops, func(keys []string) []string {
+			return insertKey(keys, key)
+		})
+	}, func(keys []string) []string {
+		if n, found := slices.BinarySearch(keys, key); found {
+			keys = slices.Delete(keys, n, n+1)
+		}
+		return keys
+	})
+}
+
+// insertKey inserts key into the sorted keys unless they hold it.
+func insertKey(keys []string, key string) []string {
+	if n, found := slices.BinarySearch(keys, key); !found {
+		keys = slices.Insert(keys, n, key)
+	}
+	return keys
+}
+
+// Put implements libstore.Ops.
+func (i *IndexedOps) Put(ctx context.Context, key string, entry []byte) error {
+	if err := i.reserved(key); err != nil {
+		return err
+	}
+	return i.ops.Put(ctx, key, entry)
+}
+
+// Read implements libstore.Ops.
+func (i *IndexedOps) Read(ctx context.Context, key string) ([]byte, error) {
+	if err := i.reserved(key); err != nil {
+		return nil, err
+	}
+	return i.ops.Read(ctx, key)
+}
+
+// ReadAll implements libstore.Ops.
+func (i *IndexedOps) ReadAll(ctx context.Context, key string) ([][]byte, error) {
+	if err := i.reserved(key); err != nil {
+		return nil, err
+	}
+	return i.ops.ReadAll(ctx, key)
+}
+
+// List implements libstore.Ops, returning the keys recorded in the index.
+func (i *IndexedOps) List(ctx context.Context) ([]string, error) {
+	keys, err := i.readIndex(ctx, i.ops)
+	var notFoundErr KeyNotFoundError
+	if errors.As(err, &notFoundErr) {
+		if err := i.Rebuild(ctx); err != nil {
+			return nil, err
+		}
+		keys, err = i.readIndex(ctx, i.ops)
+	}
+	return keys, err
+}
+
+// Rebuild replaces the index with the keys listed by the underlying Ops, to repair
+// an index that drifted from the backend.
+func (i *IndexedOps) Rebuild(ctx context.Context) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return i.atomically(ctx, func(ops Ops) error {
+		keys, err := i.listBackend(ctx, ops)
+		if err != nil {
+			return err
+		}
+		return i.writeIndex(ctx, ops, keys)
+	})
+}
+
+// update applies write to the backend and change to the keys of the index, in one
+// transaction where the backend has them. Otherwise the index is changed after
+// write, or before it if indexFirst is set, and undo reverts whichever step went
+// first if the second fails. A write failing with a KeyNotFoundError is not undone
+// after the index: the key is gone either way.
+func (i *IndexedOps) update(ctx context.Context, indexFirst bool, write, undo func(ops Ops) error, change func(keys []string) []string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.transactional() {
+		return i.atomically(ctx, func(ops Ops) error {
+			if err := write(ops); err != nil {
+				return err
+			}
+			return i.changeIndex(ctx, ops, change)
+		})
+	}
+	if indexFirst {
+		if err := i.changeIndex(ctx, i.ops, change); err != nil {
+			return err
+		}
+		err := write(i.ops)
+		var notFoundErr KeyNotFoundError
+		if err != nil && !errors.As(err, &notFoundErr) {
+			return errors.Join(err, undo(i.ops))
+		}
+		return err
+	}
+	if err := write(i.ops); err != nil {
+		return err
+	}
+	if err := i.changeIndex(ctx, i.ops, change); err != nil {
+		return errors.Join(err, undo(i.ops))
+	}
+	return nil
+}
+
+// transactional reports whether the backend runs transactions for atomically.
+func (i *IndexedOps) transactional() bool {
+	switch i.ops.(type) {
+	case txRunner, txContextRunner:
+		return true
+	}
+	return false
+}
+
+// atomically runs fn in a transaction of the backend if it supports them, and on the
+// backend itself otherwise.
+func (i *IndexedOps) atomically(ctx context.Context, fn func(ops Ops) error) error {
+	switch runner := i.ops.(type) {
+	case txRunner:
+		return runner.WithTx(func(tx TxOps) error {
+			return fn(tx)
+		})
+	case txContextRunner:
+		return runner.withTx(ctx, func(tx TxOps) error {
+			return fn(tx)
+		})
+	}
+	return fn(i.ops)
+}
+
+// changeIndex applies change to the keys of the index of ops. On a
+// conditionalWriter the index is replaced only if no other writer changed it since
+// it was read, retrying otherwise.
+func (i *IndexedOps) changeIndex(ctx context.Context, ops Ops, change func(keys []string) []string) error {
+	writer, ok := ops.(conditionalWriter)
+	if !ok {
+		keys, err := i.readOrListIndex(ctx, ops)
+		if err != nil {
+			return err
+		}
+		return i.writeIndex(ctx, ops, change(keys))
+	}
+	var err error
+	for range indexUpdateAttempts {
+		var keys []string
+		var token string
+		if keys, token, err = i.readIndexForUpdate(ctx, ops, writer); err != nil {
+			return err
+		}
+		var entry []byte
+		if entry, err = encodeIndex(change(keys)); err != nil {
+			return err
+		}
+		err = writer.putIfUnchanged(ctx, i.indexKey, entry, token)
+		var conflictErr ConflictError
+		if !errors.As(err, &conflictErr) {
+			return err
+		}
+	}
+	return fmt.Errorf("%w: %w", ConflictError("index: key "+i.indexKey+" kept changing"), err)
+}
+
+// readIndexForUpdate returns the keys recorded in the index of ops and the token to
+// replace it with, or the keys ops lists if there is no index yet.
+func (i *IndexedOps) readIndexForUpdate(ctx context.Context, ops Ops, writer conditionalWriter) ([]string, string, error) {
+	entry, token, err := writer.readForUpdate(ctx, i.indexKey)
+	var notFoundErr KeyNotFoundError
+	if errors.As(err, &notFoundErr) {
+		keys, err := i.listBackend(ctx, ops)
+		return keys, "", err
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if entry == nil {
+		// Created but never written: an interrupted first update.
+		keys, err := i.listBackend(ctx, ops)
+		return keys, token, err
+	}
+	keys, err := i.decodeIndex(entry)
+	return keys, token, err
+}
+
+// readOrListIndex returns the keys recorded in the index of ops, or the keys ops
+// lists if there is no index yet.
+func (i *IndexedOps) readOrListIndex(ctx context.Context, ops Ops) ([]string, error) {
+	keys, err := i.readIndex(ctx, ops)
+	var notFoundErr KeyNotFoundError
+	if errors.As(err, &notFoundErr) {
+		return i.listBackend(ctx, ops)
+	}
+	return keys, err
+}
+
+// readIndex returns the keys recorded in the index of ops. It returns a
+// KeyNotFoundError if the index does not exist yet.
+func (i *IndexedOps) readIndex(ctx context.Context, ops Ops) ([]string, error) {
+	entry, err := ops.Read(ctx, i.indexKey)
+	var entryErr EntryError
+	if errors.As(err, &entryErr) {
+		// Created but never written: an interrupted first update.
+		return nil, KeyNotFoundError("index: key " + i.indexKey + " holds no index")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return i.decodeIndex(entry)
+}
+
+// decodeIndex returns the keys recorded in entry, an entry of the index.
+func (i *IndexedOps) decodeIndex(entry []byte) ([]string, error) {
+	keys := []string{}
+	if err := json.Unmarshal(entry, &keys); err != nil {
+		return nil, fmt.Errorf("%w: %w", EntryError("index: key "+i.indexKey+" does not hold an index"), err)
+	}
+	return keys, nil
+}
+
+// encodeIndex returns keys as an entry of the index.
+func encodeIndex(keys []string) ([]byte, error) {
+	if keys == nil {
+		keys = []string{}
+	}
+	entry, err := json.Marshal(keys)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", EntryError("index: failed to encode index"), err)
+	}
+	return entry, nil
+}
+
+// listBackend returns the sorted keys ops lists, without the index key.
+func (i *IndexedOps) listBackend(ctx context.Context, ops Ops) ([]string, error) {
+	keys, err := ops.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	keys = slices.DeleteFunc(slices.Clone(keys), func(key string) bool {
+		return key == i.indexKey
+	})
+	slices.Sort(keys)
+	return keys, nil
+}
+
+// writeIndex records keys as the latest entry of the index of ops, creating the
+// index if needed.
+func (i *IndexedOps) writeIndex(ctx context.Context, ops Ops, keys []string) error {
+	entry, err := encodeIndex(keys)
+	if err != nil {
+		return err
+	}
+	if _, err := CreateIfNotExists(ctx, ops, i.indexKey); err != nil {
+		return err
+	}
+	if _, ok := ops.(CappedPutter); ok {
+		return PutCapped(ctx, ops, i.indexKey, entry, 1)
+	}
+	return ops.Put(ctx, i.indexKey, entry)
+}
@@ -0,0 +1,149 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestIndexedOps(t *testing.T) {
+	backends := testBackends(t, "InMemory", "File")
+	for name, newOps := range backends {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			backend := newOps(t)
+			if err := backend.Create(ctx, "existing"); err != nil {
+				t.Fatal(err)
+			}
+			ops := libstore.NewIndexedOps(backend, "_index")
+
+			if keys, err := ops.List(ctx); err != nil || !slices.Equal(keys, []string{"existing"}) {
+				t.Errorf("Expected the index to be built from the backend, Got: %v, %v", keys, err)
+			}
+			for _, key := range []string{"b", "a"} {
+				if err := ops.Create(ctx, key); err != nil {
+					t.Fatalf("Error creating key: %v", err)
+				}
+			}
+			if err := ops.Delete(ctx, "existing"); err != nil {
+				t.Fatalf("Error deleting key: %v", err)
+			}
+			if keys, err := ops.List(ctx); err != nil || !slices.Equal(keys, []string{"a", "b"}) {
+				t.Errorf("Expected the index to follow Create and Delete, Got: %v, %v", keys, err)
+			}
+			if err := ops.Create(ctx, "a"); err == nil {
+				t.Errorf("Expected creating an existing key to fail")
+			}
+			var keyErr libstore.KeyError
+			if _, err := ops.Read(ctx, "_index"); !errors.As(err, &keyErr) {
+				t.Errorf("Expected a KeyError reading the index key, Got: %v", err)
+			}
+
+			// Keys written past the index drift from it until Rebuild.
+			if err := backend.Create(ctx, "c"); err != nil {
+				t.Fatal(err)
+			}
+			if keys, err := ops.List(ctx); err != nil || !slices.Equal(keys, []string{"a", "b"}) {
+				t.Errorf("Expected List to be served from the index, Got: %v, %v", keys, err)
+			}
+			if err := ops.Rebuild(ctx); err != nil {
+				t.Fatalf("Error rebuilding index: %v", err)
+			}
+			if keys, err := ops.List(ctx); err != nil || !slices.Equal(keys, []string{"a", "b", "c"}) {
+				t.Errorf("Expected the rebuilt index to hold the backend's keys, Got: %v, %v", keys, err)
+			}
+			if entries, err := backend.ReadAll(ctx, "_index"); err != nil || len(entries) != 1 {
+				t.Errorf("Expected the index to keep its latest entry only, Got: %d entries, %v", len(entries), err)
+			}
+		})
+	}
+}
+
+func TestIndexedOpsConcurrentCreates(t *testing.T) {
+	ctx := context.Background()
+	ops := libstore.NewIndexedOps(libstore.NewInMemoryOps(), "_index")
+	var wg sync.WaitGroup
+	for n := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := ops.Create(ctx, fmt.Sprintf("key%02d", n)); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if keys, err := ops.List(ctx); err != nil || len(keys) != 20 {
+		t.Errorf("Expected 20 keys in the index, Got: %v, %v", keys, err)
+	}
+}
+
+func TestIndexedOpsSharedIndex(t *testing.T) {
+	// Each IndexedOps stands for a process of its own, so only the backend keeps
+	// their updates of the index from overwriting each other.
+	backends := testBackends(t, "S3", "DB")
+	for name, newOps := range backends {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			backend := newOps(t)
+			prefix := testKey(t)
+			indexes := []*libstore.IndexedOps{
+				libstore.NewIndexedOps(backend, prefix+"-index"),
+				libstore.NewIndexedOps(backend, prefix+"-index"),
+			}
+			var wg sync.WaitGroup
+			for n := range 20 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if err := indexes[n%2].Create(ctx, fmt.Sprintf("%s-key%02d", prefix, n)); err != nil {
+						t.Error(err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			keys, err := indexes[0].List(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			created := slices.DeleteFunc(keys, func(key string) bool {
+				return !strings.HasPrefix(key, prefix+"-key")
+			})
+			if len(created) != 20 {
+				t.Errorf("Expected the 20 keys in the shared index, Got: %v", created)
+			}
+		})
+	}
+}
+
+// failingDeleteOps fails every Delete.
+type failingDeleteOps struct {
+	libstore.Ops
+}
+
+func (failingDeleteOps) Delete(ctx context.Context, key string) error {
+	return libstore.OpsInternalError("injected failure")
+}
+
+func TestIndexedOpsDeleteFailure(t *testing.T) {
+	ctx := context.Background()
+	backend := newTestFileOps(t)
+	ops := libstore.NewIndexedOps(failingDeleteOps{backend}, "_index")
+	if err := ops.Create(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	var internalErr libstore.OpsInternalError
+	if err := ops.Delete(ctx, "key"); !errors.As(err, &internalErr) {
+		t.Fatalf("Expected the injected failure, Got: %v", err)
+	}
+	if keys, err := ops.List(ctx); err != nil || !slices.Equal(keys, []string{"key"}) {
+		t.Errorf("Expected the index to keep the key that could not be deleted, Got: %v, %v", keys, err)
+	}
+}
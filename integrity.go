@@ -0,0 +1,78 @@
+package libstore
+
+import (
+	"context"
+)
+
+// VersionRange is an inclusive range of version numbers.
+type VersionRange struct {
+	From, To int64
+}
+
+// IntegrityReport describes how the stored versions of a key are numbered.
+type IntegrityReport struct {
+	// Versions is the number of entries stored for the key.
+	Versions int
+	// MaxVersion is the highest version number stored, or 0 if the key holds no entries.
+	MaxVersion int64
+	// Gaps lists the ranges of versions between 1 and MaxVersion that are missing.
+	Gaps []VersionRange
+	// Duplicates lists the versions stored more than once.
+	Duplicates []int64
+}
+
+// Contiguous reports whether the versions form the sequence 1..MaxVersion, each
+// stored exactly once.
+func (r IntegrityReport) Contiguous() bool {
+	return len(r.Gaps) == 0 && len(r.Duplicates) == 0
+}
+
+// IntegrityVerifier is implemented by backends that number versions explicitly and
+// can check that numbering.
+type IntegrityVerifier interface {
+	// VerifyIntegrity reports the gaps and duplicates among the versions of key.
+	// It returns a KeyNotFoundError if the key does not exist.
+	VerifyIntegrity(ctx context.Context, key string) (IntegrityReport, error)
+}
+
+// VerifyIntegrity checks that the versions of key are numbered 1..N without gaps or
+// duplicates, which failed inserts, partial deletes or manual edits can break before
+// they show up as confusing reads.
+//
+// Backends implementing IntegrityVerifier check their stored version numbers. The
+// others number entries by their position, so their report is built from ReadAll
+// and is always contiguous.
+func VerifyIntegrity(ctx context.Context, ops Ops, key string) (IntegrityReport, error) {
+	if verifier, ok := ops.(IntegrityVerifier); ok {
+		return verifier.VerifyIntegrity(ctx, key)
+	}
+	entries, err := ops.ReadAll(ctx, key)
+	if err != nil {
+		return IntegrityReport{}, err
+	}
+	return IntegrityReport{Versions: len(entries), MaxVersion: int64(len(entries))}, nil
+}
+
+// versionCount is a version number and how many times it is stored.
+type versionCount struct {
+	version int64
+	count   int
+}
+
+// integrityReport builds the report for counts, sorted by version.
+func integrityReport(counts []versionCount) IntegrityReport {
+	var r IntegrityReport
+	next := int64(1)
+	for _, c := range counts {
+		if c.version > next {
+			r.Gaps = append(r.Gaps, VersionRange{From: next, To: c.version - 1})
+		}
+		if c.count > 1 {
+			r.Duplicates = append(r.Duplicates, c.version)
+		}
+		r.Versions += c.count
+		r.MaxVersion = c.version
+		next = c.version + 1
+	}
+	return r
+}
@@ -0,0 +1,33 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestVerifyIntegrityFallback(t *testing.T) {
+	ctx := context.Background()
+	ops := newRecordingOps(libstore.NewInMemoryOps())
+	if err := ops.Create(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ops.Put(ctx, "key", []byte("v1")); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := libstore.VerifyIntegrity(ctx, ops, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Contiguous() || report.Versions != 1 || report.MaxVersion != 1 {
+		t.Errorf("Expected one contiguous version, Got: %+v", report)
+	}
+
+	var notFound libstore.KeyNotFoundError
+	if _, err := libstore.VerifyIntegrity(ctx, ops, "missing"); !errors.As(err, &notFound) {
+		t.Errorf("Expected a KeyNotFoundError, Got: %v", err)
+	}
+}
@@ -0,0 +1,307 @@
+package libstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// JetStream values are framed with one leading byte telling the empty value written
+// by Create apart from entries.
+const (
+	jetStreamMarker byte = 0
+	jetStreamEntry  byte = 1
+)
+
+// maxJetStreamPutAttempts bounds how often Put retries after another writer changed
+// the key between its read and its conditional update.
+const maxJetStreamPutAttempts = 10
+
+// jetStreamOps stores keys in a NATS JetStream key-value bucket.
+type jetStreamOps struct {
+	kv nats.KeyValue
+}
+
+// NewJetStreamOps initializes an Ops backed by an existing NATS JetStream key-value
+// bucket.
+//
+// Parameters:
+//   - js: A JetStream context of an open NATS connection.
+//   - bucket: The name of the key-value bucket.
+//
+// Returns:
+//   - An Ops storing every entry as a revision of its key.
+//   - A LocationError if the bucket does not exist or cannot be bound.
+//
+// ReadAll returns the revisions written since the key was last created, so the
+// bucket must keep enough history: JetStream retains at most the bucket's History
+// setting, itself at most 64, revisions per key and drops older ones. Keys are
+// limited to the characters JetStream allows, letters, digits and "-/_=.", and
+// other keys fail with a KeyError.
+//
+// Note:
+// Every value is stored with one leading framing byte, so other JetStream clients
+// see that byte before the entry. An entry is limited to the bucket's MaxValueSize
+// and the server's max_payload, 1 MiB by default, less the framing byte and the
+// message headers.
+func NewJetStreamOps(js nats.JetStreamContext, bucket string) (Ops, error) {
+	kv, err := js.KeyValue(bucket)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", LocationError("jetstream: failed to bind bucket "+bucket), err)
+	}
+	return jetStreamOps{kv: kv}, nil
+}
+
+// jetStreamError wraps an error returned by JetStream in a BackendError carrying its
+// API error code.
+func jetStreamError(op string, err error) error {
+	e := &BackendError{Backend: "jetstream", Op: op, Err: err}
+	var apiErr *nats.APIError
+	if errors.As(err, &apiErr) {
+		e.Code = fmt.Sprint(apiErr.ErrorCode)
+	}
+	return annotateTimeout(op, e)
+}
+
+// keyError maps the errors JetStream reports about key itself, and wraps the others.
+func (j jetStreamOps) keyError(op, key string, err error) error {
+	switch {
+	case errors.Is(err, nats.ErrKeyNotFound):
+		return KeyNotFoundError("jetstream: key not found: " + key)
+	case errors.Is(err, nats.ErrInvalidKey):
+		return fmt.Errorf("%w: %w", KeyError("jetstream: invalid key: "+key), err)
+	}
+	return jetStreamError(op, err)
+}
+
+// Create implements Ops. It writes an empty marker revision, which JetStream accepts
+// only if the key does not exist or was deleted.
+func (j jetStreamOps) Create(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return annotateTimeout("jetstream: create", err)
+	}
+	_, err := j.kv.Create(key, []byte{jetStreamMarker})
+	if errors.Is(err, nats.ErrKeyExists) {
+		return KeyError("jetstream: key already exists: " + key)
+	}
+	if err != nil {
+		return j.keyError("failed to create key", key, err)
+	}
+	return nil
+}
+
+// ReadAll implements Ops with the revision history of the key.
+func (j jetStreamOps) ReadAll(ctx context.Context, key string) ([][]byte, error) {
+	revisions, err := j.live(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	entries := [][]byte{}
+	for _, rev := range revisions {
+		if value := rev.Value(); len(value) > 0 && value[0] == jetStreamEntry {
+			entries = append(entries, value[1:])
+		}
+	}
+	return entries, nil
+}
+
+// History implements HistoryReader, dating every entry with its revision's time.
+func (j jetStreamOps) History(ctx context.Context, key string) ([]VersionInfo, error) {
+	revisions, err := j.live(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	history := []VersionInfo{}
+	for _, rev := range revisions {
+		if value := rev.Value(); len(value) > 0 && value[0] == jetStreamEntry {
+			history = append(history, VersionInfo{
+				Version:   int64(len(history) + 1),
+				Value:     value[1:],
+				CreatedAt: rev.Created(),
+			})
+		}
+	}
+	return history, nil
+}
+
+// live returns the revisions of key written since it was last created, oldest first.
+func (j jetStreamOps) live(ctx context.Context, key string) ([]nats.KeyValueEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, annotateTimeout("jetstream: read history", err)
+	}
+	revisions, err := j.kv.History(key, nats.Context(ctx))
+	if err != nil {
+		return nil, j.keyError("failed to read history", key, err)
+	}
+	start := 0
+	for i, rev := range revisions {
+		if rev.Operation() != nats.KeyValuePut {
+			start = i + 1
+		}
+	}
+	if start == len(revisions) {
+		return nil, KeyNotFoundError("jetstream: key not found: " + key)
+	}
+	return revisions[start:], nil
+}
+
+// Read implements Ops with the latest revision of the key.
+func (j jetStreamOps) Read(ctx context.Context, key string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, annotateTimeout("jetstream: read", err)
+	}
+	rev, err := j.kv.Get(key)
+	if err != nil {
+		return nil, j.keyError("failed to read key", key, err)
+	}
+	value := rev.Value()
+	if len(value) == 0 || value[0] != jetStreamEntry {
+		return nil, EntryError("jetstream: no entries found for key: " + key)
+	}
+	return value[1:], nil
+}
+
+// Put implements Ops. It appends a revision conditioned on the latest one, so a key
+// deleted concurrently is not brought back.
+func (j jetStreamOps) Put(ctx context.Context, key string, entry []byte) error {
+	value := append([]byte{jetStreamEntry}, entry...)
+	var err error
+	for range maxJetStreamPutAttempts {
+		if err := ctx.Err(); err != nil {
+			return annotateTimeout("jetstream: put", err)
+		}
+		var rev nats.KeyValueEntry
+		rev, err = j.kv.Get(key)
+		if err != nil {
+			return j.keyError("failed to read key", key, err)
+		}
+		_, err = j.kv.Update(key, value, rev.Revision())
+		if !errors.Is(err, nats.ErrKeyExists) {
+			break
+		}
+	}
+	if errors.Is(err, nats.ErrKeyExists) {
+		return fmt.Errorf("%w: %w", ConflictError("jetstream: key kept changing: "+key), err)
+	}
+	if err != nil {
+		return j.keyError("failed to put entry", key, err)
+	}
+	return nil
+}
+
+// Increment implements Incrementer. JetStream has no atomic add, so the new value
+// is appended with an update conditioned on the revision it was computed from, and
+// computed again if another writer got in between. Counters are contended by
+// nature, so it keeps retrying, after a short random pause, until the update
+// succeeds or ctx is done.
+func (j jetStreamOps) Increment(ctx context.Context, key string, delta int64) (int64, error) {
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return 0, annotateTimeout("jetstream: increment", err)
+		}
+		rev, err := j.kv.Get(key)
+		if err != nil {
+			return 0, j.keyError("failed to read key", key, err)
+		}
+		var latest []byte
+		if value := rev.Value(); len(value) > 0 && value[0] == jetStreamEntry {
+			latest = value[1:]
+		}
+		value, err := addCounter(key, latest, delta)
+		if err != nil {
+			return 0, err
+		}
+		_, err = j.kv.Update(key, append([]byte{jetStreamEntry}, formatCounter(value)...), rev.Revision())
+		if err == nil {
+			return value, nil
+		}
+		if !errors.Is(err, nats.ErrKeyExists) {
+			return 0, j.keyError("failed to put entry", key, err)
+		}
+		// Spread the retries of the writers that lost, so they do not collide again.
+		pause := time.Duration(rand.Int64N(int64(min(attempt, 10)) * int64(time.Millisecond)))
+		select {
+		case <-ctx.Done():
+		case <-time.After(pause):
+		}
+	}
+}
+
+// Delete implements Ops. It writes a delete marker; earlier revisions remain until
+// the bucket's history limit drops them.
+func (j jetStreamOps) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return annotateTimeout("jetstream: delete", err)
+	}
+	if _, err := j.kv.Get(key); err != nil {
+		return j.keyError("failed to read key", key, err)
+	}
+	if err := j.kv.Delete(key); err != nil {
+		return j.keyError("failed to delete key", key, err)
+	}
+	return nil
+}
+
+// Watch implements Watcher with a JetStream key watcher that skips the current value.
+func (j jetStreamOps) Watch(ctx context.Context, key string) (<-chan []byte, error) {
+	w, err := j.kv.Watch(key, nats.UpdatesOnly(), nats.Context(ctx))
+	if err != nil {
+		return nil, j.keyError("failed to watch key", key, err)
+	}
+	entries := make(chan []byte)
+	go func() {
+		defer close(entries)
+		defer func() { _ = w.Stop() }()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case rev, ok := <-w.Updates():
+				if !ok {
+					return
+				}
+				if rev == nil || rev.Operation() != nats.KeyValuePut {
+					continue
+				}
+				value := rev.Value()
+				if len(value) == 0 || value[0] != jetStreamEntry {
+					continue
+				}
+				select {
+				case entries <- value[1:]:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return entries, nil
+}
+
+// List implements Ops with the keys of the bucket.
+func (j jetStreamOps) List(ctx context.Context) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, annotateTimeout("jetstream: list", err)
+	}
+	keys, err := j.kv.Keys(nats.Context(ctx))
+	if errors.Is(err, nats.ErrNoKeysFound) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, jetStreamError("failed to list keys", err)
+	}
+	slices.Sort(keys)
+	return keys, nil
+}
+
+var (
+	_ Ops           = jetStreamOps{}
+	_ HistoryReader = jetStreamOps{}
+	_ Watcher       = jetStreamOps{}
+	_ Incrementer   = jetStreamOps{}
+)
@@ -0,0 +1,71 @@
+package libstore_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cecmp/libstore"
+	"github.com/cecmp/libstore/libstoretest"
+	"github.com/nats-io/nats.go"
+)
+
+// newTestJetStreamOps connects to the NATS server at the URL in LIBSTORE_TEST_NATS,
+// skipping the test when it is not set. Every call uses a fresh key-value bucket.
+func newTestJetStreamOps(t *testing.T) libstore.Ops {
+	t.Helper()
+	url := os.Getenv("LIBSTORE_TEST_NATS")
+	if url == "" {
+		t.Skip("LIBSTORE_TEST_NATS not set")
+	}
+	nc, err := nats.Connect(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(nc.Close)
+	js, err := nc.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bucket := fmt.Sprintf("libstore_test_%d", time.Now().UnixNano())
+	if _, err := js.CreateKeyValue(&nats.KeyValueConfig{Bucket: bucket, History: 64}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = js.DeleteKeyValue(bucket) })
+	ops, err := libstore.NewJetStreamOps(js, bucket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ops
+}
+
+func TestJetStreamOpsConformance(t *testing.T) {
+	ops := newTestJetStreamOps(t)
+	libstoretest.RunConformance(t, func() libstore.Ops { return ops })
+}
+
+func TestJetStreamWaitForKey(t *testing.T) {
+	ops := newTestJetStreamOps(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := ops.Create(ctx, "lock"); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		if err := ops.Put(ctx, "lock", []byte("owner")); err != nil {
+			t.Error(err)
+		}
+	}()
+	// Polling every hour, only the watch can deliver the entry in time.
+	entry, err := libstore.WaitForKey(ctx, ops, "lock", time.Hour)
+	if err != nil || string(entry) != "owner" {
+		t.Errorf("WaitForKey() = %q, %v, want owner", entry, err)
+	}
+}
+
+func TestJetStreamIncrement(t *testing.T) {
+	testIncrement(t, newTestJetStreamOps(t), "counter")
+}
@@ -0,0 +1,128 @@
+package libstore
+
+import (
+	"context"
+	"encoding/base32"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// KeyCodec translates between the keys seen by callers and the names stored in a backend.
+type KeyCodec interface {
+	// Encode returns the stored form of key.
+	Encode(key string) string
+	// Decode returns the original key for a stored name.
+	// It returns an error if the stored name was not produced by Encode.
+	Decode(stored string) (string, error)
+}
+
+// Base32KeyCodec encodes keys using unpadded base32 (A-Z, 2-7), which is safe as
+// a file name, an S3 object key and a database value alike.
+type Base32KeyCodec struct{}
+
+var base32KeyEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Encode implements KeyCodec.
+func (Base32KeyCodec) Encode(key string) string {
+	return base32KeyEncoding.EncodeToString([]byte(key))
+}
+
+// Decode implements KeyCodec.
+func (Base32KeyCodec) Decode(stored string) (string, error) {
+	key, err := base32KeyEncoding.DecodeString(stored)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", KeyError(fmt.Sprintf("key codec: decoding key %s", stored)), err)
+	}
+	return string(key), nil
+}
+
+// CaseFoldKeyCodec stores keys case-folded, so keys differing only in case, such as
+// "User@example.com" and "user@example.com", name the same stored key. Folding maps
+// every rune to the lower case of its upper case, which also unifies variants like
+// the Greek final sigma and the Kelvin sign. It is applied rune by rune, so
+// multi-rune foldings such as "ß" to "ss" are not performed.
+//
+// Folding cannot be undone, so Decode returns the stored, folded form.
+type CaseFoldKeyCodec struct{}
+
+// Encode implements KeyCodec.
+func (CaseFoldKeyCodec) Encode(key string) string {
+	return strings.Map(func(r rune) rune {
+		return unicode.ToLower(unicode.ToUpper(r))
+	}, key)
+}
+
+// Decode implements KeyCodec.
+func (CaseFoldKeyCodec) Decode(stored string) (string, error) {
+	return stored, nil
+}
+
+// keyCodecOps applies a KeyCodec to every key passed to the underlying Ops.
+type keyCodecOps struct {
+	ops   Ops
+	codec KeyCodec
+}
+
+// NewKeyCodecOps wraps ops so that every key is encoded with codec before it reaches
+// the backend, and every listed name is decoded back to the original key.
+func NewKeyCodecOps(ops Ops, codec KeyCodec) Ops {
+	return keyCodecOps{ops: ops, codec: codec}
+}
+
+// NewCaseFoldOps wraps ops so that keys are matched case-insensitively, by applying
+// CaseFoldKeyCodec to every key. List returns the folded keys.
+func NewCaseFoldOps(ops Ops) Ops {
+	return NewKeyCodecOps(ops, CaseFoldKeyCodec{})
+}
+
+// Unwrap implements Unwrapper.
+func (k keyCodecOps) Unwrap() Ops {
+	return k.ops
+}
+
+// Create implements Ops.
+func (k keyCodecOps) Create(ctx context.Context, key string) error {
+	return k.ops.Create(ctx, k.codec.Encode(key))
+}
+
+// ReadAll implements Ops.
+func (k keyCodecOps) ReadAll(ctx context.Context, key string) ([][]byte, error) {
+	return k.ops.ReadAll(ctx, k.codec.Encode(key))
+}
+
+// Read implements Ops.
+func (k keyCodecOps) Read(ctx context.Context, key string) ([]byte, error) {
+	return k.ops.Read(ctx, k.codec.Encode(key))
+}
+
+// Put implements Ops.
+func (k keyCodecOps) Put(ctx context.Context, key string, entry []byte) error {
+	return k.ops.Put(ctx, k.codec.Encode(key), entry)
+}
+
+// Delete implements Ops.
+func (k keyCodecOps) Delete(ctx context.Context, key string) error {
+	return k.ops.Delete(ctx, k.codec.Encode(key))
+}
+
+// List implements Ops.
+func (k keyCodecOps) List(ctx context.Context) ([]string, error) {
+	stored, err := k.ops.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, len(stored))
+	for i, name := range stored {
+		keys[i], err = k.codec.Decode(name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// Decoding does not preserve the order of the stored names.
+	slices.Sort(keys)
+	return keys, nil
+}
+
+var _ Ops = keyCodecOps{}
@@ -0,0 +1,108 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestCaseFoldOps(t *testing.T) {
+	ctx := context.Background()
+	ops := libstore.NewCaseFoldOps(libstore.NewInMemoryOps())
+
+	cases := []struct {
+		write, read string
+	}{
+		{"User@Example.com", "user@example.COM"},
+		{"ÄRGER", "ärger"},
+		// The final sigma folds like any other sigma.
+		{"ΟΔΟΣ", "οδος"},
+		// The Kelvin sign folds to the Latin letter k.
+		{"\u212Aelvin", "kelvin"},
+	}
+	for _, c := range cases {
+		if err := ops.Create(ctx, c.write); err != nil {
+			t.Fatalf("Error creating %s: %v", c.write, err)
+		}
+		if err := ops.Put(ctx, c.read, []byte(c.write)); err != nil {
+			t.Fatalf("Error putting %s: %v", c.read, err)
+		}
+		if entry, err := ops.Read(ctx, c.write); err != nil || string(entry) != c.write {
+			t.Errorf("Expected %s and %s to name the same key, Got: %q, %v", c.write, c.read, entry, err)
+		}
+	}
+
+	keys, err := ops.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(keys)
+	want := []string{"kelvin", "user@example.com", "ärger", "οδοσ"}
+	if !slices.Equal(keys, want) {
+		t.Errorf("Expected the folded keys %q, Got: %q", want, keys)
+	}
+
+	var keyErr libstore.KeyError
+	if err := ops.Create(ctx, "USER@EXAMPLE.COM"); !errors.As(err, &keyErr) {
+		t.Errorf("Expected creating a differently-cased key to collide, Got: %v", err)
+	}
+	if err := ops.Delete(ctx, "User@EXAMPLE.com"); err != nil {
+		t.Fatalf("Error deleting key: %v", err)
+	}
+	var notFound libstore.KeyNotFoundError
+	if _, err := ops.Read(ctx, "user@example.com"); !errors.As(err, &notFound) {
+		t.Errorf("Expected the key to be deleted, Got: %v", err)
+	}
+}
+
+func TestBase32KeyCodecOps(t *testing.T) {
+	ctx := context.Background()
+	backend, err := libstore.NewFileOps(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops := libstore.NewKeyCodecOps(backend, libstore.Base32KeyCodec{})
+
+	keys := []string{"a/b/../c", "CON", "with space", "ünïcode", ".hidden"}
+	for _, key := range keys {
+		if err := ops.Create(ctx, key); err != nil {
+			t.Fatalf("Error creating %q: %v", key, err)
+		}
+		if err := ops.Put(ctx, key, []byte(key)); err != nil {
+			t.Fatalf("Error putting %q: %v", key, err)
+		}
+		if entry, err := ops.Read(ctx, key); err != nil || string(entry) != key {
+			t.Errorf("Expected %q to read back, Got: %q, %v", key, entry, err)
+		}
+	}
+
+	stored, err := backend.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range stored {
+		if strings.Trim(name, "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567") != "" {
+			t.Errorf("Expected the stored name %q to be unpadded base32", name)
+		}
+	}
+	listed, err := ops.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := slices.Sorted(slices.Values(keys))
+	if !slices.Equal(listed, want) {
+		t.Errorf("Expected List to decode the keys %q, Got: %q", want, listed)
+	}
+
+	if err := backend.Create(ctx, "not-base32"); err != nil {
+		t.Fatal(err)
+	}
+	var keyErr libstore.KeyError
+	if _, err := ops.List(ctx); !errors.As(err, &keyErr) {
+		t.Errorf("Expected a KeyError for a stored name Encode did not produce, Got: %v", err)
+	}
+}
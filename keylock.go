@@ -0,0 +1,22 @@
+package libstore
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+// keyLockStripes is the number of mutexes of a keyLocks.
+const keyLockStripes = 256
+
+// keyLocks serializes operations on keys with a fixed set of mutexes, each guarding
+// the keys hashing to it, so that no mutex is kept for every key ever used. Distinct
+// keys may share a mutex, so a caller must not hold the lock of one key while
+// taking that of another.
+type keyLocks [keyLockStripes]sync.RWMutex
+
+// get returns the mutex guarding key.
+func (l *keyLocks) get(key string) *sync.RWMutex {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &l[h.Sum32()%keyLockStripes]
+}
@@ -0,0 +1,94 @@
+package libstore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// lazyConnectRetry is how long a failed lazy connection check is reported again
+// before it is retried, so an unavailable store is not probed on every call.
+const lazyConnectRetry = 5 * time.Second
+
+// lazyInit runs a connection check on first use rather than in a constructor. The
+// check runs until it succeeds once; a failure is cached for lazyConnectRetry.
+type lazyInit struct {
+	backend string
+	check   func(ctx context.Context) error
+	now     func() time.Time
+
+	mu       sync.Mutex
+	done     bool
+	err      error
+	failedAt time.Time
+	// running is closed when the check in flight, if any, returns.
+	running chan struct{}
+}
+
+// newLazyInit returns a lazyInit running check, which connects to backend.
+func newLazyInit(backend string, check func(ctx context.Context) error) *lazyInit {
+	return &lazyInit{backend: backend, check: check, now: time.Now}
+}
+
+// wait runs the check unless it already succeeded, or returns the error of a check
+// that failed less than lazyConnectRetry ago. Failures are reported as a
+// LocationError carrying the message of the check error but not the error itself,
+// so the provider errors of the check are not taken for those of the operation that
+// ran it. The check runs without the mutex held: callers arriving while it is in
+// flight wait for its outcome or for their own ctx, whichever comes first. A nil
+// lazyInit always succeeds.
+func (l *lazyInit) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	for {
+		l.mu.Lock()
+		if l.done {
+			l.mu.Unlock()
+			return nil
+		}
+		if l.err != nil && l.now().Sub(l.failedAt) < lazyConnectRetry {
+			err := l.err
+			l.mu.Unlock()
+			return err
+		}
+		if running := l.running; running != nil {
+			l.mu.Unlock()
+			select {
+			case <-running:
+				// A check cut short by its caller leaves nothing cached: the loop
+				// then runs one for this caller.
+				continue
+			case <-ctx.Done():
+				return annotateTimeout("waiting for connection check", ctx.Err())
+			}
+		}
+		running := make(chan struct{})
+		l.running = running
+		l.mu.Unlock()
+		return l.run(ctx, running)
+	}
+}
+
+// run runs the check for wait and records its outcome before closing running.
+func (l *lazyInit) run(ctx context.Context, running chan struct{}) error {
+	err := l.check(ctx)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	defer close(running)
+	l.running = nil
+	if err != nil {
+		// A check cut short by its caller says nothing about the store.
+		if ctx.Err() != nil {
+			return err
+		}
+		msg := strings.TrimPrefix(err.Error(), "libstore: ")
+		l.err = fmt.Errorf("%w: %s", LocationError(l.backend+": connection check failed"), msg)
+		l.failedAt = l.now()
+		return l.err
+	}
+	l.done, l.err = true, nil
+	return nil
+}
@@ -0,0 +1,41 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestLazyInitWaitHonoursContext(t *testing.T) {
+	release := make(chan struct{})
+	checks := 0
+	wait := libstore.LazyInitWait("test", func(ctx context.Context) error {
+		checks++
+		<-release
+		return nil
+	})
+
+	first := make(chan error, 1)
+	go func() { first <- wait(context.Background()) }()
+	// Let the first caller start the check.
+	time.Sleep(10 * time.Millisecond)
+
+	// A caller arriving while the check is in flight gives up with its ctx.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	var timeoutErr libstore.TimeoutError
+	if err := wait(ctx); !errors.As(err, &timeoutErr) {
+		t.Errorf("Expected a TimeoutError while the check is in flight, Got: %v", err)
+	}
+
+	close(release)
+	if err := <-first; err != nil {
+		t.Fatalf("Error from the first caller: %v", err)
+	}
+	if err := wait(context.Background()); err != nil || checks != 1 {
+		t.Errorf("Expected the succeeded check to be kept, Got: %v after %d checks", err, checks)
+	}
+}
@@ -0,0 +1,230 @@
+// Package libstoretest provides a conformance suite for implementations of libstore.Ops.
+package libstoretest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/cecmp/libstore"
+)
+
+// Option declares a documented divergence of a store from the contract of
+// RunConformance.
+type Option func(*contract)
+
+// contract holds the divergences declared by the options of RunConformance.
+type contract struct {
+	putReplaces           bool
+	putCreates            bool
+	deleteMissingSucceeds bool
+}
+
+// PutReplaces declares that Put replaces the entries of a key rather than appending:
+// Read returns the latest entry and ReadAll that entry alone.
+func PutReplaces() Option {
+	return func(c *contract) {
+		c.putReplaces = true
+	}
+}
+
+// PutCreates declares that Put of a missing key creates it with the entry rather
+// than failing with a KeyNotFoundError.
+func PutCreates() Option {
+	return func(c *contract) {
+		c.putCreates = true
+	}
+}
+
+// DeleteMissingSucceeds declares that Delete of a missing key succeeds rather than
+// failing with a KeyNotFoundError.
+func DeleteMissingSucceeds() Option {
+	return func(c *contract) {
+		c.deleteMissingSucceeds = true
+	}
+}
+
+// RunConformance runs the libstore.Ops contract against the stores returned by factory.
+//
+// factory is called once per subtest. It may return the same shared store every time,
+// as each subtest writes only to keys of its own.
+//
+// The contract checked is:
+//   - Create fails with a KeyError for a key that already exists.
+//   - Read and ReadAll fail with a KeyNotFoundError for a missing key.
+//   - Read fails with an EntryError for a key without entries; ReadAll returns none.
+//   - Put appends: Read returns the latest entry and ReadAll all entries, oldest first.
+//   - Put and Delete fail with a KeyNotFoundError for a missing key.
+//   - List returns every key exactly once, and no deleted key, sorted by bytes.
+//
+// Backends documented to diverge from part of the contract declare it with options,
+// which replace the diverging clause with the behaviour documented instead, still
+// checked: PutReplaces, PutCreates and DeleteMissingSucceeds.
+func RunConformance(t *testing.T, factory func() libstore.Ops, opts ...Option) {
+	var c contract
+	for _, opt := range opts {
+		opt(&c)
+	}
+	run := t.Run
+
+	run("Create", func(t *testing.T) {
+		ops, key := factory(), testKey(t, "a")
+		if err := ops.Create(context.Background(), key); err != nil {
+			t.Fatalf("Error creating key: %v", err)
+		}
+		err := ops.Create(context.Background(), key)
+		var keyErr libstore.KeyError
+		if !errors.As(err, &keyErr) {
+			t.Errorf("Expected a KeyError creating an existing key, Got: %v", err)
+		}
+	})
+
+	run("ReadMissing", func(t *testing.T) {
+		ops, key := factory(), testKey(t, "missing")
+		_, err := ops.Read(context.Background(), key)
+		expectNotFound(t, "Read", err)
+		_, err = ops.ReadAll(context.Background(), key)
+		expectNotFound(t, "ReadAll", err)
+	})
+
+	run("ReadEmpty", func(t *testing.T) {
+		ops, key := factory(), testKey(t, "a")
+		if err := ops.Create(context.Background(), key); err != nil {
+			t.Fatalf("Error creating key: %v", err)
+		}
+		value, err := ops.Read(context.Background(), key)
+		var entryErr libstore.EntryError
+		if !errors.As(err, &entryErr) || value != nil {
+			t.Errorf("Expected an EntryError reading an empty key, Got: %q, %v", value, err)
+		}
+		entries, err := ops.ReadAll(context.Background(), key)
+		if err != nil || len(entries) != 0 {
+			t.Errorf("Expected no entries for an empty key, Got: %q, %v", entries, err)
+		}
+	})
+
+	run("Put", func(t *testing.T) {
+		ops, key := factory(), testKey(t, "a")
+		if err := ops.Create(context.Background(), key); err != nil {
+			t.Fatalf("Error creating key: %v", err)
+		}
+		put := []string{"first", "second", "third"}
+		for _, entry := range put {
+			if err := ops.Put(context.Background(), key, []byte(entry)); err != nil {
+				t.Fatalf("Error putting entry: %v", err)
+			}
+		}
+		value, err := ops.Read(context.Background(), key)
+		if err != nil || string(value) != "third" {
+			t.Errorf("Expected the latest entry, Got: %q, %v", value, err)
+		}
+		entries, err := ops.ReadAll(context.Background(), key)
+		if err != nil {
+			t.Fatalf("Error reading all entries: %v", err)
+		}
+		got := make([]string, len(entries))
+		for i, entry := range entries {
+			got[i] = string(entry)
+		}
+		if want := put; c.putReplaces {
+			if want = put[len(put)-1:]; !slices.Equal(got, want) {
+				t.Errorf("Expected only the latest entry: %q, Got: %q", want, got)
+			}
+		} else if !slices.Equal(got, want) {
+			t.Errorf("Expected all entries oldest first: %q, Got: %q", want, got)
+		}
+	})
+
+	run("PutMissing", func(t *testing.T) {
+		ops, key := factory(), testKey(t, "missing")
+		err := ops.Put(context.Background(), key, []byte("entry"))
+		if !c.putCreates {
+			expectNotFound(t, "Put", err)
+			return
+		}
+		if err != nil {
+			t.Fatalf("Expected Put to create a missing key, Got: %v", err)
+		}
+		value, err := ops.Read(context.Background(), key)
+		if err != nil || string(value) != "entry" {
+			t.Errorf("Expected Put to create a missing key with the entry, Got: %q, %v", value, err)
+		}
+	})
+
+	run("Delete", func(t *testing.T) {
+		ops, key := factory(), testKey(t, "a")
+		if err := ops.Create(context.Background(), key); err != nil {
+			t.Fatalf("Error creating key: %v", err)
+		}
+		if err := ops.Put(context.Background(), key, []byte("entry")); err != nil {
+			t.Fatalf("Error putting entry: %v", err)
+		}
+		if err := ops.Delete(context.Background(), key); err != nil {
+			t.Fatalf("Error deleting key: %v", err)
+		}
+		_, err := ops.Read(context.Background(), key)
+		expectNotFound(t, "Read after Delete", err)
+		err = ops.Delete(context.Background(), key)
+		if !c.deleteMissingSucceeds {
+			expectNotFound(t, "Delete", err)
+		} else if err != nil {
+			t.Errorf("Expected Delete of a missing key to succeed, Got: %v", err)
+		}
+	})
+
+	run("List", func(t *testing.T) {
+		ops := factory()
+		keys := []string{testKey(t, "a"), testKey(t, "b"), testKey(t, "c")}
+		for _, key := range keys {
+			if err := ops.Create(context.Background(), key); err != nil {
+				t.Fatalf("Error creating key: %v", err)
+			}
+		}
+		if err := ops.Put(context.Background(), keys[0], []byte("one")); err != nil {
+			t.Fatalf("Error putting entry: %v", err)
+		}
+		if err := ops.Put(context.Background(), keys[0], []byte("two")); err != nil {
+			t.Fatalf("Error putting entry: %v", err)
+		}
+		if err := ops.Delete(context.Background(), keys[2]); err != nil {
+			t.Fatalf("Error deleting key: %v", err)
+		}
+
+		listed, err := ops.List(context.Background())
+		if err != nil {
+			t.Fatalf("Error listing keys: %v", err)
+		}
+		counts := map[string]int{}
+		for _, key := range listed {
+			counts[key]++
+		}
+		for _, key := range keys[:2] {
+			if counts[key] != 1 {
+				t.Errorf("Expected %s to be listed once, Got: %d times", key, counts[key])
+			}
+		}
+		if counts[keys[2]] != 0 {
+			t.Errorf("Expected deleted key %s not to be listed", keys[2])
+		}
+		if !slices.IsSorted(listed) {
+			t.Errorf("Expected keys to be listed in sorted order, Got: %v", listed)
+		}
+	})
+}
+
+// testKey returns a key unique to the running subtest, so stores can be shared.
+func testKey(t *testing.T, name string) string {
+	return fmt.Sprintf("conformance-%s-%d-%s", path.Base(t.Name()), time.Now().UnixNano(), name)
+}
+
+func expectNotFound(t *testing.T, op string, err error) {
+	t.Helper()
+	var notFoundErr libstore.KeyNotFoundError
+	if !errors.As(err, &notFoundErr) {
+		t.Errorf("Expected %s of a missing key to fail with a KeyNotFoundError, Got: %v", op, err)
+	}
+}
@@ -0,0 +1,100 @@
+package libstore
+
+import (
+	"context"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// concurrencyLimitOps bounds the number of calls running against the underlying Ops.
+type concurrencyLimitOps struct {
+	ops Ops
+	sem *semaphore.Weighted
+}
+
+// NewConcurrencyLimitOps wraps ops so that at most limit calls run against it at
+// once, protecting the connection pool or request quota of a backend whatever the
+// number of goroutines using it. A call arriving while limit calls are in flight
+// waits for one to finish; if its context ends first it returns a TimeoutError
+// without reaching ops. A limit below 1 is treated as 1.
+func NewConcurrencyLimitOps(ops Ops, limit int) Ops {
+	return concurrencyLimitOps{ops: ops, sem: semaphore.NewWeighted(int64(max(limit, 1)))}
+}
+
+// acquire waits for a slot, which the returned function releases.
+func (c concurrencyLimitOps) acquire(ctx context.Context) (func(), error) {
+	if err := c.sem.Acquire(ctx, 1); err != nil {
+		return nil, annotateTimeout("concurrency limit: waiting for a slot", err)
+	}
+	return func() { c.sem.Release(1) }, nil
+}
+
+// Unwrap implements Unwrapper.
+func (c concurrencyLimitOps) Unwrap() Ops {
+	return c.ops
+}
+
+// Create implements Ops.
+func (c concurrencyLimitOps) Create(ctx context.Context, key string) error {
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return c.ops.Create(ctx, key)
+}
+
+// ReadAll implements Ops.
+func (c concurrencyLimitOps) ReadAll(ctx context.Context, key string) ([][]byte, error) {
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return c.ops.ReadAll(ctx, key)
+}
+
+// Read implements Ops.
+func (c concurrencyLimitOps) Read(ctx context.Context, key string) ([]byte, error) {
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return c.ops.Read(ctx, key)
+}
+
+// Put implements Ops.
+func (c concurrencyLimitOps) Put(ctx context.Context, key string, entry []byte) error {
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return c.ops.Put(ctx, key, entry)
+}
+
+// Delete implements Ops.
+func (c concurrencyLimitOps) Delete(ctx context.Context, key string) error {
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return c.ops.Delete(ctx, key)
+}
+
+// List implements Ops.
+func (c concurrencyLimitOps) List(ctx context.Context) ([]string, error) {
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return c.ops.List(ctx)
+}
+
+var (
+	_ Ops       = concurrencyLimitOps{}
+	_ Unwrapper = concurrencyLimitOps{}
+)
@@ -0,0 +1,68 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cecmp/libstore"
+)
+
+// gatedReadOps holds every Read until release is closed, tracking the most reads
+// in flight at once.
+type gatedReadOps struct {
+	libstore.Ops
+	release chan struct{}
+
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+}
+
+func (b *gatedReadOps) Read(ctx context.Context, key string) ([]byte, error) {
+	b.mu.Lock()
+	b.inFlight++
+	b.maxInFlight = max(b.maxInFlight, b.inFlight)
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		b.inFlight--
+		b.mu.Unlock()
+	}()
+	<-b.release
+	return nil, nil
+}
+
+func TestConcurrencyLimitOps(t *testing.T) {
+	const limit = 3
+	backend := &gatedReadOps{Ops: libstore.NewInMemoryOps(), release: make(chan struct{})}
+	ops := libstore.NewConcurrencyLimitOps(backend, limit)
+
+	var wg sync.WaitGroup
+	for range 4 * limit {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := ops.Read(context.Background(), "key"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	// A call arriving while the limit is reached gives up with its context.
+	time.Sleep(20 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	var timeout libstore.TimeoutError
+	if _, err := ops.Read(ctx, "key"); !errors.As(err, &timeout) {
+		t.Errorf("Expected a TimeoutError while the limit is reached, Got: %v", err)
+	}
+
+	close(backend.release)
+	wg.Wait()
+	if backend.maxInFlight != limit {
+		t.Errorf("Expected at most %d reads in flight, Got: %d", limit, backend.maxInFlight)
+	}
+}
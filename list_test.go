@@ -0,0 +1,107 @@
+package libstore_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestListSorted(t *testing.T) {
+	backends := testBackends(t, "InMemory", "S3", "File", "Git", "LogFile")
+	backends["DB"] = func(t *testing.T) libstore.Ops {
+		return libstore.NewPrefixOps(newTestDBOps(t), testKey(t)+"/")
+	}
+	backends["KeyCodec"] = func(t *testing.T) libstore.Ops {
+		return libstore.NewKeyCodecOps(libstore.NewInMemoryOps(), libstore.Base32KeyCodec{})
+	}
+	// Byte order differs from case-insensitive and locale-aware orders.
+	keys := []string{"b", "é", "a1", "B", "a-1", "a"}
+	want := []string{"B", "a", "a-1", "a1", "b", "é"}
+
+	for name, newOps := range backends {
+		t.Run(name, func(t *testing.T) {
+			ops := newOps(t)
+			for _, key := range keys {
+				if err := ops.Create(context.Background(), key); err != nil {
+					t.Fatalf("Create(%q) error = %v", key, err)
+				}
+			}
+			for range 2 {
+				got, err := ops.List(context.Background())
+				if err != nil {
+					t.Fatalf("List() error = %v", err)
+				}
+				if !slices.Equal(got, want) {
+					t.Errorf("List() = %v, want %v", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestListModifiedSince(t *testing.T) {
+	backends := testBackends(t, "InMemory", "File", "S3", "DB")
+	backends["CryptStore"] = func(t *testing.T) libstore.Ops {
+		ops, err := libstore.NewCryptStoreGCM(newTestFileOps(t), bytes.Repeat([]byte{0x42}, 32), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ops
+	}
+	for name, newOps := range backends {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			ops := newOps(t)
+			lister, ok := ops.(libstore.ModifiedSinceLister)
+			if !ok {
+				t.Fatalf("Expected %T to implement ModifiedSinceLister", ops)
+			}
+			base := filepath.Base(testKey(t))
+			unchanged, written, created := base+"-unchanged", base+"-written", base+"-created"
+			for _, key := range []string{unchanged, written} {
+				if err := ops.Create(ctx, key); err != nil {
+					t.Fatal(err)
+				}
+				if err := ops.Put(ctx, key, []byte("v1")); err != nil {
+					t.Fatal(err)
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			since := time.Now()
+			time.Sleep(20 * time.Millisecond)
+			if err := ops.Put(ctx, written, []byte("v2")); err != nil {
+				t.Fatal(err)
+			}
+			if err := ops.Create(ctx, created); err != nil {
+				t.Fatal(err)
+			}
+
+			keys, err := lister.ListModifiedSince(ctx, since)
+			if err != nil {
+				t.Fatalf("Error listing modified keys: %v", err)
+			}
+			if !slices.Contains(keys, written) || !slices.Contains(keys, created) || slices.Contains(keys, unchanged) {
+				t.Errorf("Expected %s and %s but not %s, Got: %v", written, created, unchanged, keys)
+			}
+			if keys, err := lister.ListModifiedSince(ctx, time.Now().Add(time.Hour)); err != nil || slices.Contains(keys, written) {
+				t.Errorf("Expected no key modified in the future, Got: %v, %v", keys, err)
+			}
+		})
+	}
+
+	ops, err := libstore.NewCryptStoreGCM(newRecordingOps(libstore.NewInMemoryOps()), bytes.Repeat([]byte{0x42}, 32), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var unsupportedErr libstore.UnsupportedError
+	if _, err := ops.(libstore.ModifiedSinceLister).ListModifiedSince(context.Background(), time.Time{}); !errors.As(err, &unsupportedErr) {
+		t.Errorf("Expected an UnsupportedError over a store without ListModifiedSince, Got: %v", err)
+	}
+}
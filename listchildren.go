@@ -0,0 +1,60 @@
+package libstore
+
+import (
+	"context"
+	"strings"
+)
+
+// ChildLister is implemented by backends that can list one level of a key hierarchy
+// without listing every key below it.
+type ChildLister interface {
+	// ListChildren lists the keys under prefix that contain no delimiter after the
+	// prefix, and, collapsed into common prefixes, the others up to and including
+	// the first delimiter after the prefix. Both are sorted by bytes.
+	ListChildren(ctx context.Context, prefix, delimiter string) (keys []string, commonPrefixes []string, err error)
+}
+
+// ListChildren lists the immediate children of prefix in ops, like a directory
+// listing: with prefix "a/" and delimiter "/", the keys "a/b/c", "a/b/d" and "a/e"
+// yield the key "a/e" and the common prefix "a/b/". An empty delimiter lists every
+// key under prefix and no common prefixes.
+//
+// Backends implementing ChildLister group the keys themselves; for the others every
+// key is listed and grouped client-side.
+func ListChildren(ctx context.Context, ops Ops, prefix, delimiter string) ([]string, []string, error) {
+	if lister, ok := ops.(ChildLister); ok {
+		return lister.ListChildren(ctx, prefix, delimiter)
+	}
+	keys, err := ops.List(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	children, commonPrefixes := groupChildren(keys, prefix, delimiter)
+	return children, commonPrefixes, nil
+}
+
+// groupChildren splits the sorted keys under prefix into immediate children and
+// common prefixes.
+func groupChildren(keys []string, prefix, delimiter string) ([]string, []string) {
+	children, commonPrefixes := []string{}, []string{}
+	for _, key := range keys {
+		rest, ok := strings.CutPrefix(key, prefix)
+		if !ok {
+			continue
+		}
+		i := -1
+		if delimiter != "" {
+			i = strings.Index(rest, delimiter)
+		}
+		if i < 0 {
+			children = append(children, key)
+			continue
+		}
+		// Sorted keys sharing a common prefix are adjacent.
+		commonPrefix := prefix + rest[:i+len(delimiter)]
+		if n := len(commonPrefixes); n == 0 || commonPrefixes[n-1] != commonPrefix {
+			commonPrefixes = append(commonPrefixes, commonPrefix)
+		}
+	}
+	return children, commonPrefixes
+}
@@ -0,0 +1,66 @@
+package libstore_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestListChildren(t *testing.T) {
+	backends := map[string]func(t *testing.T) (libstore.Ops, string){
+		"InMemory": func(t *testing.T) (libstore.Ops, string) { return libstore.NewInMemoryOps(), "" },
+		"Fallback": func(t *testing.T) (libstore.Ops, string) {
+			return newRecordingOps(libstore.NewInMemoryOps()), ""
+		},
+		"S3": func(t *testing.T) (libstore.Ops, string) {
+			return newFakeS3Ops(t, libstore.WithListPageSize(1)), ""
+		},
+		// Other tests share the database, so keys are nested under a unique root.
+		"DB": func(t *testing.T) (libstore.Ops, string) { return newTestDBOps(t), testKey(t) + "/" },
+	}
+
+	for name, newOps := range backends {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			ops, root := newOps(t)
+			for _, key := range []string{"a/b/c", "a/b/e", "a/b-c", "a/d", "x"} {
+				if err := ops.Create(ctx, root+key); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			tests := []struct {
+				prefix, delimiter    string
+				keys, commonPrefixes []string
+			}{
+				{"a/", "/", []string{"a/b-c", "a/d"}, []string{"a/b/"}},
+				{"", "/", []string{"x"}, []string{"a/"}},
+				{"a/b", "/", []string{"a/b-c"}, []string{"a/b/"}},
+				{"a/", "", []string{"a/b-c", "a/b/c", "a/b/e", "a/d"}, []string{}},
+				{"z/", "/", []string{}, []string{}},
+			}
+			for _, tt := range tests {
+				keys, commonPrefixes, err := libstore.ListChildren(ctx, ops, root+tt.prefix, tt.delimiter)
+				if err != nil {
+					t.Fatalf("ListChildren(%q, %q) error = %v", tt.prefix, tt.delimiter, err)
+				}
+				wantKeys, wantPrefixes := rooted(root, tt.keys), rooted(root, tt.commonPrefixes)
+				if !slices.Equal(keys, wantKeys) || !slices.Equal(commonPrefixes, wantPrefixes) {
+					t.Errorf("ListChildren(%q, %q) = %q, %q, want %q, %q",
+						tt.prefix, tt.delimiter, keys, commonPrefixes, wantKeys, wantPrefixes)
+				}
+			}
+		})
+	}
+}
+
+// rooted prefixes every name with root.
+func rooted(root string, names []string) []string {
+	rooted := make([]string, len(names))
+	for i, name := range names {
+		rooted[i] = root + name
+	}
+	return rooted
+}
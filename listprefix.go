@@ -0,0 +1,65 @@
+package libstore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// PrefixPageLister is implemented by backends that can page through the keys under a
+// prefix without listing every key under it.
+type PrefixPageLister interface {
+	// ListPrefixPage lists up to limit keys under prefix, sorted by bytes, starting
+	// after cursor. next is the cursor of the following page, empty after the last.
+	ListPrefixPage(ctx context.Context, prefix, cursor string, limit int) (keys []string, next string, err error)
+}
+
+// ListPrefixPage lists one page of at most limit keys under prefix, sorted by bytes.
+// The first page is listed with an empty cursor, and each following one with the
+// next cursor returned by the page before it, until next is empty. Cursors are opaque
+// and only valid for the ops and prefix that returned them. It returns an EntryError
+// if limit is not positive.
+//
+// Backends implementing PrefixPageLister list a single page, such as S3Ops with a
+// prefix and continuation token or the database backend with a keyset query; for
+// the others every key is listed and the page cut client-side.
+func ListPrefixPage(ctx context.Context, ops Ops, prefix, cursor string, limit int) ([]string, string, error) {
+	if err := checkPageLimit(limit); err != nil {
+		return nil, "", err
+	}
+	if lister, ok := ops.(PrefixPageLister); ok {
+		return lister.ListPrefixPage(ctx, prefix, cursor, limit)
+	}
+	keys, err := ops.List(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	page, next := prefixPage(keys, prefix, cursor, limit)
+	return page, next, nil
+}
+
+// checkPageLimit returns an EntryError if the page limit is not positive. Paging
+// helpers check it, and so do the backends implementing their interfaces, as they
+// can be called directly.
+func checkPageLimit(limit int) error {
+	if limit <= 0 {
+		return EntryError(fmt.Sprintf("page limit must be positive, got %d", limit))
+	}
+	return nil
+}
+
+// prefixPage cuts the page of at most limit keys under prefix sorting after cursor
+// out of the sorted keys. Its cursors are the last key of a page.
+func prefixPage(keys []string, prefix, cursor string, limit int) ([]string, string) {
+	page := []string{}
+	for _, key := range keys {
+		if key <= cursor || !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if len(page) == limit {
+			return page, page[limit-1]
+		}
+		page = append(page, key)
+	}
+	return page, ""
+}
@@ -0,0 +1,81 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestListPrefixPage(t *testing.T) {
+	backends := map[string]func(t *testing.T) (libstore.Ops, string){
+		"InMemory": func(t *testing.T) (libstore.Ops, string) { return libstore.NewInMemoryOps(), "" },
+		"Fallback": func(t *testing.T) (libstore.Ops, string) {
+			return newRecordingOps(libstore.NewInMemoryOps()), ""
+		},
+		"S3": func(t *testing.T) (libstore.Ops, string) { return newFakeS3Ops(t), "" },
+		// Other tests share the database, so keys are nested under a unique root.
+		"DB": func(t *testing.T) (libstore.Ops, string) { return newTestDBOps(t), testKey(t) + "/" },
+	}
+
+	for name, newOps := range backends {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			ops, root := newOps(t)
+			// "a%x" and "a_x" would match the prefix "a%" or "a_" as LIKE patterns.
+			for _, key := range []string{"a/1", "a/2", "a/3", "a/4", "a/5", "a%x", "a_x", "b/1", "0"} {
+				if err := ops.Create(ctx, root+key); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			tests := []struct {
+				prefix string
+				limit  int
+				pages  [][]string
+			}{
+				{"a/", 2, [][]string{{"a/1", "a/2"}, {"a/3", "a/4"}, {"a/5"}}},
+				{"a/", 5, [][]string{{"a/1", "a/2", "a/3", "a/4", "a/5"}}},
+				{"a%", 1, [][]string{{"a%x"}}},
+				{"b/", 10, [][]string{{"b/1"}}},
+				{"z/", 3, [][]string{{}}},
+			}
+			for _, tt := range tests {
+				var pages [][]string
+				cursor := ""
+				for {
+					keys, next, err := libstore.ListPrefixPage(ctx, ops, root+tt.prefix, cursor, tt.limit)
+					if err != nil {
+						t.Fatalf("ListPrefixPage(%q, %q, %d) error = %v", tt.prefix, cursor, tt.limit, err)
+					}
+					pages = append(pages, keys)
+					if next == "" || len(pages) > len(tt.pages) {
+						break
+					}
+					cursor = next
+				}
+				want := make([][]string, len(tt.pages))
+				for i, page := range tt.pages {
+					want[i] = rooted(root, page)
+				}
+				if !slices.EqualFunc(pages, want, slices.Equal) {
+					t.Errorf("ListPrefixPage(%q, %d) pages = %q, want %q", tt.prefix, tt.limit, pages, want)
+				}
+			}
+
+			var entryErr libstore.EntryError
+			if _, _, err := libstore.ListPrefixPage(ctx, ops, root, "", 0); !errors.As(err, &entryErr) {
+				t.Errorf("Expected an EntryError for a zero limit, Got: %v", err)
+			}
+			if lister, ok := ops.(libstore.PrefixPageLister); ok {
+				for _, limit := range []int{0, -1} {
+					if _, _, err := lister.ListPrefixPage(ctx, root, "", limit); !errors.As(err, &entryErr) {
+						t.Errorf("Expected an EntryError from the backend for a limit of %d, Got: %v", limit, err)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package libstore
+
+import (
+	"context"
+	"iter"
+)
+
+// listPageSize is the number of keys fetched per page by paginated listings.
+const listPageSize = 1000
+
+// SeqLister is implemented by backends that can page through their keys lazily.
+type SeqLister interface {
+	// ListSeq yields every key one at a time, fetching pages from the backend as needed.
+	// An error ends the sequence after being yielded.
+	ListSeq(ctx context.Context) iter.Seq2[string, error]
+}
+
+// ListSeq returns an iterator over all keys of ops.
+//
+// Backends implementing SeqLister page through their keys with bounded memory; for
+// the others the keys are listed up front and yielded one by one. Iteration stops
+// after yielding an error, including ctx.Err() once ctx is cancelled.
+func ListSeq(ctx context.Context, ops Ops) iter.Seq2[string, error] {
+	if lister, ok := ops.(SeqLister); ok {
+		return lister.ListSeq(ctx)
+	}
+	return func(yield func(string, error) bool) {
+		keys, err := ops.List(ctx)
+		if err != nil {
+			yield("", err)
+			return
+		}
+		for _, key := range keys {
+			if err := ctx.Err(); err != nil {
+				yield("", annotateTimeout("listing keys", err))
+				return
+			}
+			if !yield(key, nil) {
+				return
+			}
+		}
+	}
+}
+
+// ListChan streams the keys of ops onto the returned key channel, which holds up to
+// buffer keys not yet received, as ListSeq pages through them, so consumers can
+// start on the first page while later ones are fetched. The key channel is closed
+// once every key was sent or listing failed; the error channel then yields the
+// error, if any, and is closed too.
+//
+// Once ctx is cancelled the producing goroutine stops, even if nobody receives from
+// either channel, and ctx.Err() is sent as the error.
+func ListChan(ctx context.Context, ops Ops, buffer int) (<-chan string, <-chan error) {
+	keys := make(chan string, max(buffer, 0))
+	// The error channel holds the one error sent, so the producer never blocks on it.
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		defer close(keys)
+		for key, err := range ListSeq(ctx, ops) {
+			if err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case keys <- key:
+			case <-ctx.Done():
+				errs <- annotateTimeout("listing keys", ctx.Err())
+				return
+			}
+		}
+	}()
+	return keys, errs
+}
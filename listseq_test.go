@@ -0,0 +1,107 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"testing"
+	"time"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestListSeq(t *testing.T) {
+	ops := libstore.NewInMemoryOps()
+	for _, key := range []string{"a", "b", "c"} {
+		if err := ops.Create(context.TODO(), key); err != nil {
+			t.Fatalf("Error creating key: %v", err)
+		}
+	}
+
+	seen := map[string]bool{}
+	for key, err := range libstore.ListSeq(context.TODO(), ops) {
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		seen[key] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("Expected 3 keys, Got: %v", seen)
+	}
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	var keys int
+	var errs []error
+	for _, err := range libstore.ListSeq(ctx, ops) {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		keys++
+		cancel()
+	}
+	if keys != 1 {
+		t.Errorf("Expected iteration to stop after cancellation, Got %d keys", keys)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], context.Canceled) {
+		t.Errorf("Expected a single context.Canceled error, Got: %v", errs)
+	}
+}
+
+// gatedLister yields the key "first" and then, once gate is closed, the key "second".
+// done is closed when the iteration returns.
+type gatedLister struct {
+	*libstore.InMemoryOps
+	gate chan struct{}
+	done chan struct{}
+}
+
+func (g gatedLister) ListSeq(ctx context.Context) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		defer close(g.done)
+		if !yield("first", nil) {
+			return
+		}
+		select {
+		case <-g.gate:
+		case <-ctx.Done():
+			yield("", ctx.Err())
+			return
+		}
+		yield("second", nil)
+	}
+}
+
+func TestListChan(t *testing.T) {
+	ctx := context.Background()
+	lister := gatedLister{InMemoryOps: libstore.NewInMemoryOps(), gate: make(chan struct{}), done: make(chan struct{})}
+	keys, errs := libstore.ListChan(ctx, lister, 0)
+	if key := <-keys; key != "first" {
+		t.Fatalf("Expected the first key before the listing completes, Got: %q", key)
+	}
+	close(lister.gate)
+	if key := <-keys; key != "second" {
+		t.Errorf("Expected the second key, Got: %q", key)
+	}
+	if key, ok := <-keys; ok {
+		t.Errorf("Expected the key channel to be closed, Got: %q", key)
+	}
+	if err := <-errs; err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	// Nobody receives after cancellation; the producer must stop on its own.
+	canceled, cancel := context.WithCancel(ctx)
+	lister = gatedLister{InMemoryOps: libstore.NewInMemoryOps(), gate: make(chan struct{}), done: make(chan struct{})}
+	_, errs = libstore.ListChan(canceled, lister, 0)
+	cancel()
+	select {
+	case <-lister.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the producer to stop after cancellation")
+	}
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, Got: %v", err)
+	}
+}
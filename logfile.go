@@ -0,0 +1,374 @@
+package libstore
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Record kinds of the log file. A zero kind marks the unused, preallocated tail.
+const (
+	logRecordEnd byte = iota
+	logRecordCreate
+	logRecordPut
+	logRecordDelete
+)
+
+// logRecordHeaderLen is the length of the kind, key length and entry length fields.
+const logRecordHeaderLen = 1 + 4 + 4
+
+// LogFileOps stores all keys in a single preallocated, append-only segment file.
+//
+// Every Create, Put and Delete appends a record to the segment, and an in-memory index
+// maps each key to the offsets of its entries. The index is persisted next to the
+// segment, with an ".index" suffix, by Close and Compact. On open, records appended
+// after the last persisted index are replayed, so the index survives a crash.
+// Space held by deleted keys is only reclaimed by Compact.
+type LogFileOps struct {
+	mu    sync.RWMutex
+	path  string
+	file  *os.File
+	size  int64
+	index logIndex
+}
+
+// logIndex is the persisted form of the index.
+type logIndex struct {
+	// End is the offset at which the next record is written.
+	End int64
+	// Keys maps each key to the offsets of its entries, oldest first.
+	Keys map[string][]int64
+}
+
+// NewLogFileOps opens or creates the segment file at path, preallocated to size bytes.
+//
+// Parameters:
+//   - path: Path of the segment file. The index is stored at path + ".index".
+//   - size: Number of bytes to preallocate. The segment grows beyond it when full.
+//
+// Returns:
+//   - An initialized *LogFileOps instance that implements the Ops interface. Assert it
+//     to *LogFileOps to call Compact and Close.
+//   - An error if the segment or its index cannot be read.
+func NewLogFileOps(path string, size int64) (Ops, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", LocationError("logfile: opening segment"), err)
+	}
+	l := &LogFileOps{path: path, file: file, index: logIndex{Keys: map[string][]int64{}}}
+	if err := l.open(size); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *LogFileOps) open(size int64) error {
+	stat, err := l.file.Stat()
+	if err != nil {
+		return fmt.Errorf("%w: %w", LocationError("logfile: getting segment info"), err)
+	}
+	l.size = stat.Size()
+	if l.size < size {
+		if err := l.file.Truncate(size); err != nil {
+			return fmt.Errorf("%w: %w", LocationError("logfile: preallocating segment"), err)
+		}
+		l.size = size
+	}
+
+	indexFile, err := os.Open(l.path + ".index")
+	if err == nil {
+		defer indexFile.Close()
+		if err := gob.NewDecoder(indexFile).Decode(&l.index); err != nil {
+			return fmt.Errorf("%w: %w", LocationError("logfile: decoding index"), err)
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("%w: %w", LocationError("logfile: opening index"), err)
+	}
+	return l.replay()
+}
+
+// replay applies the records written after the persisted index.
+func (l *LogFileOps) replay() error {
+	for l.index.End+logRecordHeaderLen <= l.size {
+		kind, key, _, n, err := l.readRecord(l.index.End)
+		if err != nil {
+			return err
+		}
+		if kind == logRecordEnd {
+			return nil
+		}
+		l.apply(kind, key, l.index.End)
+		l.index.End += n
+	}
+	return nil
+}
+
+// apply updates the index for a record of the given kind written at off.
+func (l *LogFileOps) apply(kind byte, key string, off int64) {
+	switch kind {
+	case logRecordCreate:
+		l.index.Keys[key] = []int64{}
+	case logRecordPut:
+		l.index.Keys[key] = append(l.index.Keys[key], off)
+	case logRecordDelete:
+		delete(l.index.Keys, key)
+	}
+}
+
+// readRecord reads the record at off and returns its kind, key, entry and total length.
+func (l *LogFileOps) readRecord(off int64) (byte, string, []byte, int64, error) {
+	header := make([]byte, logRecordHeaderLen)
+	if _, err := l.file.ReadAt(header, off); err != nil {
+		return 0, "", nil, 0, fmt.Errorf("%w: %w", EntryError("logfile: reading record header"), err)
+	}
+	kind := header[0]
+	if kind == logRecordEnd {
+		return kind, "", nil, 0, nil
+	}
+	if kind > logRecordDelete {
+		return 0, "", nil, 0, EntryError(fmt.Sprintf("logfile: corrupt record at offset %d", off))
+	}
+	keyLen := int64(binary.BigEndian.Uint32(header[1:5]))
+	entryLen := int64(binary.BigEndian.Uint32(header[5:9]))
+	body := make([]byte, keyLen+entryLen)
+	if _, err := l.file.ReadAt(body, off+logRecordHeaderLen); err != nil {
+		if errors.Is(err, io.EOF) {
+			return 0, "", nil, 0, EntryError(fmt.Sprintf("logfile: truncated record at offset %d", off))
+		}
+		return 0, "", nil, 0, fmt.Errorf("%w: %w", EntryError("logfile: reading record"), err)
+	}
+	return kind, string(body[:keyLen]), body[keyLen:], logRecordHeaderLen + keyLen + entryLen, nil
+}
+
+// append writes a record at the end of the segment, growing it if needed, and
+// applies it to the index. The caller must hold mu for writing.
+func (l *LogFileOps) append(kind byte, key string, entry []byte) error {
+	record := make([]byte, logRecordHeaderLen, logRecordHeaderLen+len(key)+len(entry))
+	record[0] = kind
+	binary.BigEndian.PutUint32(record[1:5], uint32(len(key)))
+	binary.BigEndian.PutUint32(record[5:9], uint32(len(entry)))
+	record = append(append(record, key...), entry...)
+
+	// Keep room for the header of the end marker that follows the record.
+	needed := l.index.End + int64(len(record)) + logRecordHeaderLen
+	if needed > l.size {
+		size := max(2*l.size, needed)
+		if err := l.file.Truncate(size); err != nil {
+			return fmt.Errorf("%w: %w", LocationError("logfile: growing segment"), err)
+		}
+		l.size = size
+	}
+	if _, err := l.file.WriteAt(record, l.index.End); err != nil {
+		return fmt.Errorf("%w: %w", EntryError("logfile: writing record"), err)
+	}
+	l.apply(kind, key, l.index.End)
+	l.index.End += int64(len(record))
+	return nil
+}
+
+// readEntry reads the entry of the put record at off.
+func (l *LogFileOps) readEntry(off int64) ([]byte, error) {
+	_, _, entry, _, err := l.readRecord(off)
+	return entry, err
+}
+
+// Create implements Ops.
+func (l *LogFileOps) Create(ctx context.Context, key string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if key == "" {
+		return KeyError("logfile: key is empty")
+	}
+	if _, exists := l.index.Keys[key]; exists {
+		return KeyError(fmt.Sprintf("logfile: key %s already exists", key))
+	}
+	return l.append(logRecordCreate, key, nil)
+}
+
+// ReadAll implements Ops.
+func (l *LogFileOps) ReadAll(ctx context.Context, key string) ([][]byte, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	offsets, exists := l.index.Keys[key]
+	if !exists {
+		return nil, KeyNotFoundError(fmt.Sprintf("logfile: key %s not found", key))
+	}
+	entries := make([][]byte, len(offsets))
+	for i, off := range offsets {
+		entry, err := l.readEntry(off)
+		if err != nil {
+			return nil, err
+		}
+		entries[i] = entry
+	}
+	return entries, nil
+}
+
+// Read implements Ops.
+func (l *LogFileOps) Read(ctx context.Context, key string) ([]byte, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	offsets, exists := l.index.Keys[key]
+	if !exists {
+		return nil, KeyNotFoundError(fmt.Sprintf("logfile: key %s not found", key))
+	}
+	if len(offsets) == 0 {
+		return nil, EntryError(fmt.Sprintf("logfile: no entries found for key %s", key))
+	}
+	return l.readEntry(offsets[len(offsets)-1])
+}
+
+// Put implements Ops. It appends a new entry to the key, and syncs the segment if
+// ctx asks for a Durable write.
+func (l *LogFileOps) Put(ctx context.Context, key string, entry []byte) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, exists := l.index.Keys[key]; !exists {
+		return KeyNotFoundError(fmt.Sprintf("logfile: key %s not found", key))
+	}
+	if err := l.append(logRecordPut, key, entry); err != nil {
+		return err
+	}
+	if opOptionsFrom(ctx).durable {
+		if err := l.file.Sync(); err != nil {
+			return fmt.Errorf("%w: %w", LocationError("logfile: syncing segment"), err)
+		}
+	}
+	return nil
+}
+
+// Delete implements Ops.
+func (l *LogFileOps) Delete(ctx context.Context, key string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, exists := l.index.Keys[key]; !exists {
+		return KeyNotFoundError(fmt.Sprintf("logfile: key %s not found", key))
+	}
+	return l.append(logRecordDelete, key, nil)
+}
+
+// List implements Ops.
+func (l *LogFileOps) List(ctx context.Context) ([]string, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	keys := make([]string, 0, len(l.index.Keys))
+	for key := range l.index.Keys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Compact rewrites the segment with only the live keys and their entries, reclaiming
+// the space of deleted keys, and persists the new index.
+func (l *LogFileOps) Compact(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	tmpPath := l.path + ".compact"
+	file, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("%w: %w", LocationError("logfile: creating compacted segment"), err)
+	}
+	// The compacted segment grows as records are copied and is not preallocated,
+	// so it only holds the live entries.
+	compacted := &LogFileOps{path: l.path, file: file, index: logIndex{Keys: map[string][]int64{}}}
+
+	keys := make([]string, 0, len(l.index.Keys))
+	for key := range l.index.Keys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := compacted.append(logRecordCreate, key, nil); err != nil {
+			_ = file.Close()
+			return err
+		}
+		for _, off := range l.index.Keys[key] {
+			entry, err := l.readEntry(off)
+			if err == nil {
+				err = compacted.append(logRecordPut, key, entry)
+			}
+			if err != nil {
+				_ = file.Close()
+				return err
+			}
+		}
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("%w: %w", LocationError("logfile: syncing compacted segment"), err)
+	}
+	// Persist the index before swapping segments, so the index on disk never
+	// describes offsets of the wrong segment for longer than the rename below.
+	if err := compacted.writeIndex(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := os.Rename(tmpPath, l.path); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("%w: %w", LocationError("logfile: replacing segment"), err)
+	}
+	if cerr := l.file.Close(); cerr != nil {
+		_ = file.Close()
+		return fmt.Errorf("%w: %w", LocationError("logfile: closing old segment"), cerr)
+	}
+	l.file, l.size, l.index = compacted.file, compacted.size, compacted.index
+	return nil
+}
+
+// writeIndex persists the index atomically. The caller must hold mu.
+func (l *LogFileOps) writeIndex() error {
+	tmpPath := l.path + ".index.tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("%w: %w", LocationError("logfile: creating index"), err)
+	}
+	if err := gob.NewEncoder(file).Encode(l.index); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("%w: %w", LocationError("logfile: encoding index"), err)
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("%w: %w", LocationError("logfile: syncing index"), err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("%w: %w", LocationError("logfile: closing index"), err)
+	}
+	if err := os.Rename(tmpPath, l.path+".index"); err != nil {
+		return fmt.Errorf("%w: %w", LocationError("logfile: replacing index"), err)
+	}
+	return nil
+}
+
+// Close syncs the segment, flushes the index and closes the segment file.
+func (l *LogFileOps) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.file.Sync(); err != nil {
+		return fmt.Errorf("%w: %w", LocationError("logfile: syncing segment"), err)
+	}
+	if err := l.writeIndex(); err != nil {
+		return err
+	}
+	if err := l.file.Close(); err != nil {
+		return fmt.Errorf("%w: %w", LocationError("logfile: closing segment"), err)
+	}
+	return nil
+}
+
+var _ Ops = (*LogFileOps)(nil)
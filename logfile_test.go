@@ -0,0 +1,162 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestLogFileOps(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "segment")
+	ops, err := libstore.NewLogFileOps(path, 64)
+	if err != nil {
+		t.Fatalf("Error opening log file: %v", err)
+	}
+
+	if err := ops.Create(ctx, "a"); err != nil {
+		t.Fatalf("Error creating key: %v", err)
+	}
+	var entryErr libstore.EntryError
+	if _, err := ops.Read(ctx, "a"); !errors.As(err, &entryErr) {
+		t.Errorf("Expected an EntryError reading an empty key, Got: %v", err)
+	}
+	// The entries outgrow the preallocated size, so the segment has to grow.
+	for _, entry := range []string{"first entry", "second entry", "third entry, which is longer"} {
+		if err := ops.Put(ctx, "a", []byte(entry)); err != nil {
+			t.Fatalf("Error putting entry: %v", err)
+		}
+	}
+	last, err := ops.Read(ctx, "a")
+	if err != nil || string(last) != "third entry, which is longer" {
+		t.Errorf("Unexpected last entry: %q, %v", last, err)
+	}
+	entries, err := ops.ReadAll(ctx, "a")
+	if err != nil || len(entries) != 3 || string(entries[0]) != "first entry" {
+		t.Errorf("Unexpected entries: %q, %v", entries, err)
+	}
+
+	if err := ops.Create(ctx, "a"); err == nil {
+		t.Error("Expected an error creating an existing key")
+	}
+	var notFound libstore.KeyNotFoundError
+	if err := ops.Put(ctx, "missing", []byte("x")); !errors.As(err, &notFound) {
+		t.Errorf("Expected a KeyNotFoundError, Got: %v", err)
+	}
+	if err := ops.Delete(ctx, "a"); err != nil {
+		t.Fatalf("Error deleting key: %v", err)
+	}
+	if _, err := ops.Read(ctx, "a"); !errors.As(err, &notFound) {
+		t.Errorf("Expected a KeyNotFoundError after Delete, Got: %v", err)
+	}
+	if err := ops.(*libstore.LogFileOps).Close(); err != nil {
+		t.Fatalf("Error closing log file: %v", err)
+	}
+}
+
+func TestLogFileOpsReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "segment")
+	ops, err := libstore.NewLogFileOps(path, 1024)
+	if err != nil {
+		t.Fatalf("Error opening log file: %v", err)
+	}
+	for _, key := range []string{"kept", "deleted"} {
+		if err := ops.Create(ctx, key); err != nil {
+			t.Fatalf("Error creating key: %v", err)
+		}
+		if err := ops.Put(ctx, key, []byte("value of "+key)); err != nil {
+			t.Fatalf("Error putting entry: %v", err)
+		}
+	}
+	if err := ops.(*libstore.LogFileOps).Close(); err != nil {
+		t.Fatalf("Error closing log file: %v", err)
+	}
+
+	// Reopen and write more without closing, so the records after the persisted
+	// index have to be replayed on the next open.
+	ops, err = libstore.NewLogFileOps(path, 1024)
+	if err != nil {
+		t.Fatalf("Error reopening log file: %v", err)
+	}
+	if err := ops.Delete(ctx, "deleted"); err != nil {
+		t.Fatalf("Error deleting key: %v", err)
+	}
+	if err := ops.Put(ctx, "kept", []byte("latest")); err != nil {
+		t.Fatalf("Error putting entry: %v", err)
+	}
+
+	reopened, err := libstore.NewLogFileOps(path, 1024)
+	if err != nil {
+		t.Fatalf("Error reopening log file: %v", err)
+	}
+	keys, err := reopened.List(ctx)
+	if err != nil || len(keys) != 1 || keys[0] != "kept" {
+		t.Errorf("Unexpected keys after replay: %v, %v", keys, err)
+	}
+	if entry, err := reopened.Read(ctx, "kept"); err != nil || string(entry) != "latest" {
+		t.Errorf("Unexpected entry after replay: %q, %v", entry, err)
+	}
+	if err := ops.(*libstore.LogFileOps).Close(); err != nil {
+		t.Fatalf("Error closing log file: %v", err)
+	}
+	if err := reopened.(*libstore.LogFileOps).Close(); err != nil {
+		t.Fatalf("Error closing log file: %v", err)
+	}
+}
+
+func TestLogFileOpsCompact(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "segment")
+	ops, err := libstore.NewLogFileOps(path, 0)
+	if err != nil {
+		t.Fatalf("Error opening log file: %v", err)
+	}
+	logOps := ops.(*libstore.LogFileOps)
+	defer logOps.Close()
+
+	big := make([]byte, 1<<16)
+	for _, key := range []string{"kept", "deleted"} {
+		if err := ops.Create(ctx, key); err != nil {
+			t.Fatalf("Error creating key: %v", err)
+		}
+	}
+	if err := ops.Put(ctx, "kept", []byte("small")); err != nil {
+		t.Fatalf("Error putting entry: %v", err)
+	}
+	if err := ops.Put(ctx, "deleted", big); err != nil {
+		t.Fatalf("Error putting entry: %v", err)
+	}
+	if err := ops.Delete(ctx, "deleted"); err != nil {
+		t.Fatalf("Error deleting key: %v", err)
+	}
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := logOps.Compact(ctx); err != nil {
+		t.Fatalf("Error compacting: %v", err)
+	}
+	if entry, err := ops.Read(ctx, "kept"); err != nil || string(entry) != "small" {
+		t.Errorf("Unexpected entry after compaction: %q, %v", entry, err)
+	}
+	after, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Size() >= before.Size() {
+		t.Errorf("Expected compaction to shrink the segment from %d bytes, Got: %d", before.Size(), after.Size())
+	}
+	if err := ops.Put(ctx, "kept", []byte("after")); err != nil {
+		t.Fatalf("Error putting entry after compaction: %v", err)
+	}
+	entries, err := ops.ReadAll(ctx, "kept")
+	if err != nil || len(entries) != 2 || string(entries[1]) != "after" {
+		t.Errorf("Unexpected entries after compaction: %q, %v", entries, err)
+	}
+}
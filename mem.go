@@ -1,34 +1,167 @@
 package libstore
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 )
 
 // InMemoryOps is an in-memory implementation of the Ops interface.
 type InMemoryOps struct {
-	mu    sync.RWMutex
-	store map[string][][]byte
+	mu       sync.RWMutex
+	store    map[string][][]byte
+	modified map[string]time.Time
+	created  map[string]time.Time
+	expires  map[string]time.Time
+	metadata map[string]Metadata
+	// finalized holds the keys made immutable by Finalize.
+	finalized map[string]bool
+	// fenceTokens holds the highest fence token applied to each key by PutFenced.
+	fenceTokens map[string]int64
+	now         func() time.Time
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
 // NewInMemoryOps creates a new InMemoryOps instance.
+// Keys written with PutWithTTL or PutWithExpiry expire lazily: they are hidden once expired and
+// reclaimed by the next write to the key.
 func NewInMemoryOps() *InMemoryOps {
 	return &InMemoryOps{
-		store: make(map[string][][]byte),
+		store:       make(map[string][][]byte),
+		modified:    make(map[string]time.Time),
+		created:     make(map[string]time.Time),
+		expires:     make(map[string]time.Time),
+		metadata:    make(map[string]Metadata),
+		finalized:   make(map[string]bool),
+		fenceTokens: make(map[string]int64),
+		now:         time.Now,
+	}
+}
+
+// NewInMemoryOpsWithSweeper creates a new InMemoryOps instance that additionally
+// removes expired keys every interval, so keys that are never accessed again do not
+// leak memory. Close stops the sweeper.
+func NewInMemoryOpsWithSweeper(interval time.Duration) *InMemoryOps {
+	ops := NewInMemoryOps()
+	ops.stop = make(chan struct{})
+	ops.done = make(chan struct{})
+	go ops.sweepEvery(interval)
+	return ops
+}
+
+// Close stops the background sweeper, if any, and waits for it to exit.
+// It is safe to call Close more than once.
+func (ops *InMemoryOps) Close() error {
+	ops.closeOnce.Do(func() {
+		if ops.stop != nil {
+			close(ops.stop)
+			<-ops.done
+		}
+	})
+	return nil
+}
+
+func (ops *InMemoryOps) sweepEvery(interval time.Duration) {
+	defer close(ops.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ops.stop:
+			return
+		case <-ticker.C:
+			ops.sweep()
+		}
+	}
+}
+
+// sweep removes expired keys. Expired keys are collected under the read lock and
+// removed one at a time, so the write lock is only ever held briefly.
+func (ops *InMemoryOps) sweep() {
+	ops.mu.RLock()
+	var expired []string
+	now := ops.now()
+	for key, expiresAt := range ops.expires {
+		if !now.Before(expiresAt) {
+			expired = append(expired, key)
+		}
+	}
+	ops.mu.RUnlock()
+
+	for _, key := range expired {
+		ops.mu.Lock()
+		if ops.expired(key) {
+			ops.remove(key)
+		}
+		ops.mu.Unlock()
+	}
+}
+
+// expired reports whether key has a TTL that has elapsed. The caller must hold mu.
+func (ops *InMemoryOps) expired(key string) bool {
+	expiresAt, ok := ops.expires[key]
+	return ok && !ops.now().Before(expiresAt)
+}
+
+// lookup returns the entries of a key that exists and has not expired. The caller must hold mu.
+func (ops *InMemoryOps) lookup(key string) ([][]byte, bool) {
+	data, exists := ops.store[key]
+	if !exists || ops.expired(key) {
+		return nil, false
+	}
+	return data, true
+}
+
+// remove deletes key and its bookkeeping. The caller must hold mu for writing.
+func (ops *InMemoryOps) remove(key string) {
+	delete(ops.store, key)
+	delete(ops.modified, key)
+	delete(ops.created, key)
+	delete(ops.expires, key)
+	delete(ops.metadata, key)
+	delete(ops.finalized, key)
+	delete(ops.fenceTokens, key)
+}
+
+// writable returns the entries of a key that exists and is not finalized. The caller
+// must hold mu for writing.
+func (ops *InMemoryOps) writable(key string) ([][]byte, error) {
+	data, exists := ops.lookup(key)
+	if !exists {
+		ops.remove(key)
+		return nil, KeyNotFoundError(fmt.Sprintf("key %s not found", key))
 	}
+	if ops.finalized[key] {
+		return nil, ImmutableError(fmt.Sprintf("key %s is finalized", key))
+	}
+	return data, nil
 }
 
 // Create creates a new key in the store.
 func (ops *InMemoryOps) Create(ctx context.Context, key string) error {
 	ops.mu.Lock()
 	defer ops.mu.Unlock()
+	return ops.create(key)
+}
 
-	if _, exists := ops.store[key]; exists {
+// create implements Create. The caller must hold mu for writing.
+func (ops *InMemoryOps) create(key string) error {
+	if _, exists := ops.lookup(key); exists {
 		return KeyError(fmt.Sprintf("key %s already exists", key))
 	}
 
+	ops.remove(key)
 	ops.store[key] = [][]byte{}
+	ops.modified[key] = ops.now()
+	ops.created[key] = ops.modified[key]
 	return nil
 }
 
@@ -36,8 +169,12 @@ func (ops *InMemoryOps) Create(ctx context.Context, key string) error {
 func (ops *InMemoryOps) ReadAll(ctx context.Context, key string) ([][]byte, error) {
 	ops.mu.RLock()
 	defer ops.mu.RUnlock()
+	return ops.readAll(key)
+}
 
-	data, exists := ops.store[key]
+// readAll implements ReadAll. The caller must hold mu.
+func (ops *InMemoryOps) readAll(key string) ([][]byte, error) {
+	data, exists := ops.lookup(key)
 	if !exists {
 		return nil, KeyNotFoundError(fmt.Sprintf("key %s not found", key))
 	}
@@ -45,12 +182,47 @@ func (ops *InMemoryOps) ReadAll(ctx context.Context, key string) ([][]byte, erro
 	return data, nil
 }
 
+// History implements HistoryReader. Put replaces the entries of a key, so only the
+// latest version is kept, dated with the time it was written.
+func (ops *InMemoryOps) History(ctx context.Context, key string) ([]VersionInfo, error) {
+	ops.mu.RLock()
+	defer ops.mu.RUnlock()
+
+	data, exists := ops.lookup(key)
+	if !exists {
+		return nil, KeyNotFoundError(fmt.Sprintf("key %s not found", key))
+	}
+	history := make([]VersionInfo, len(data))
+	for i, entry := range data {
+		history[i] = VersionInfo{Version: int64(i + 1), Value: entry}
+	}
+	if len(history) > 0 {
+		history[len(history)-1].CreatedAt = ops.modified[key]
+	}
+	return history, nil
+}
+
+// CreatedAt implements CreatedAtReader.
+func (ops *InMemoryOps) CreatedAt(ctx context.Context, key string) (time.Time, error) {
+	ops.mu.RLock()
+	defer ops.mu.RUnlock()
+
+	if _, exists := ops.lookup(key); !exists {
+		return time.Time{}, KeyNotFoundError(fmt.Sprintf("key %s not found", key))
+	}
+	return ops.created[key], nil
+}
+
 // ReadLast reads the last entry associated with the key.
 func (ops *InMemoryOps) Read(ctx context.Context, key string) ([]byte, error) {
 	ops.mu.RLock()
 	defer ops.mu.RUnlock()
+	return ops.read(key)
+}
 
-	data, exists := ops.store[key]
+// read implements Read. The caller must hold mu.
+func (ops *InMemoryOps) read(key string) ([]byte, error) {
+	data, exists := ops.lookup(key)
 	if !exists {
 		return nil, KeyNotFoundError(fmt.Sprintf("key %s not found", key))
 	}
@@ -62,41 +234,403 @@ func (ops *InMemoryOps) Read(ctx context.Context, key string) ([]byte, error) {
 	return data[len(data)-1], nil
 }
 
+// ReadPrefix implements PrefixReader by slicing the latest entry.
+func (ops *InMemoryOps) ReadPrefix(ctx context.Context, key string, n int) ([]byte, error) {
+	if err := checkPrefixLength(n); err != nil {
+		return nil, err
+	}
+	ops.mu.RLock()
+	defer ops.mu.RUnlock()
+
+	data, exists := ops.lookup(key)
+	if !exists {
+		return nil, KeyNotFoundError(fmt.Sprintf("key %s not found", key))
+	}
+	if len(data) == 0 {
+		return nil, EntryError(fmt.Sprintf("no entries found for key %s", key))
+	}
+	last := data[len(data)-1]
+	return bytes.Clone(last[:min(n, len(last))]), nil
+}
+
 // Put replaces all entries associated with the key with a single entry.
 func (ops *InMemoryOps) Put(ctx context.Context, key string, entry []byte) error {
 	ops.mu.Lock()
 	defer ops.mu.Unlock()
+	return ops.put(key, entry)
+}
 
-	if _, exists := ops.store[key]; !exists {
-		return KeyNotFoundError(fmt.Sprintf("key %s not found", key))
+// put implements Put. The caller must hold mu for writing.
+func (ops *InMemoryOps) put(key string, entry []byte) error {
+	if _, err := ops.writable(key); err != nil {
+		return err
 	}
 
 	ops.store[key] = [][]byte{entry}
+	ops.modified[key] = ops.now()
+	delete(ops.expires, key)
+	return nil
+}
+
+// PutWithTTL replaces all entries associated with the key with a single entry that
+// expires after ttl. Once expired, the key reads as not found.
+func (ops *InMemoryOps) PutWithTTL(ctx context.Context, key string, entry []byte, ttl time.Duration) error {
+	ops.mu.Lock()
+	defer ops.mu.Unlock()
+
+	if _, err := ops.writable(key); err != nil {
+		return err
+	}
+
+	now := ops.now()
+	ops.putExpiring(key, entry, now, now.Add(ttl))
+	return nil
+}
+
+// PutWithExpiry implements ExpiryPutter. It replaces all entries associated with the
+// key with a single entry that expires at expiresAt, like PutWithTTL.
+func (ops *InMemoryOps) PutWithExpiry(ctx context.Context, key string, entry []byte, expiresAt time.Time) error {
+	ops.mu.Lock()
+	defer ops.mu.Unlock()
+
+	now := ops.now()
+	if !expiresAt.After(now) {
+		return EntryError(fmt.Sprintf("expiry %s of key %s is not in the future", expiresAt.Format(time.RFC3339), key))
+	}
+	if _, err := ops.writable(key); err != nil {
+		return err
+	}
+
+	ops.putExpiring(key, entry, now, expiresAt)
 	return nil
 }
 
+// putExpiring replaces the entries of key with entry, written at now and expiring at
+// expiresAt. The caller must hold mu for writing.
+func (ops *InMemoryOps) putExpiring(key string, entry []byte, now, expiresAt time.Time) {
+	ops.store[key] = [][]byte{entry}
+	ops.modified[key] = now
+	ops.expires[key] = expiresAt
+}
+
 // Delete deletes the key and all its associated entries.
 func (ops *InMemoryOps) Delete(ctx context.Context, key string) error {
 	ops.mu.Lock()
 	defer ops.mu.Unlock()
+	return ops.delete(key)
+}
 
-	if _, exists := ops.store[key]; !exists {
-		return KeyNotFoundError(fmt.Sprintf("key %s not found", key))
+// delete implements Delete. The caller must hold mu for writing.
+func (ops *InMemoryOps) delete(key string) error {
+	if _, err := ops.writable(key); err != nil {
+		return err
 	}
 
-	delete(ops.store, key)
+	ops.remove(key)
 	return nil
 }
 
-// List lists all keys in the store.
+// List lists all keys in the store, sorted.
 func (ops *InMemoryOps) List(ctx context.Context) ([]string, error) {
 	ops.mu.RLock()
 	defer ops.mu.RUnlock()
+	return ops.list(), nil
+}
 
+// list implements List. The caller must hold mu.
+func (ops *InMemoryOps) list() []string {
 	var keys []string
 	for key := range ops.store {
-		keys = append(keys, key)
+		if !ops.expired(key) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	return keys
+}
+
+// ListModifiedSince lists the keys created or written after since.
+func (ops *InMemoryOps) ListModifiedSince(ctx context.Context, since time.Time) ([]string, error) {
+	ops.mu.RLock()
+	defer ops.mu.RUnlock()
+
+	keys := []string{}
+	for key, modified := range ops.modified {
+		if modified.After(since) && !ops.expired(key) {
+			keys = append(keys, key)
+		}
 	}
 
 	return keys, nil
 }
+
+// CreateIfNotExists creates the key unless it already exists.
+func (ops *InMemoryOps) CreateIfNotExists(ctx context.Context, key string) (bool, error) {
+	ops.mu.Lock()
+	defer ops.mu.Unlock()
+
+	if _, exists := ops.lookup(key); exists {
+		return false, nil
+	}
+
+	ops.remove(key)
+	ops.store[key] = [][]byte{}
+	ops.modified[key] = ops.now()
+	ops.created[key] = ops.modified[key]
+	return true, nil
+}
+
+// CreateBatch implements BatchCreator, creating the keys under one lock.
+func (ops *InMemoryOps) CreateBatch(ctx context.Context, keys []string) error {
+	ops.mu.Lock()
+	defer ops.mu.Unlock()
+
+	keys = uniqueKeys(keys)
+	var existing []string
+	for _, key := range keys {
+		if _, exists := ops.lookup(key); exists {
+			existing = append(existing, key)
+		}
+	}
+	if len(existing) > 0 && opOptionsFrom(ctx).failOnExisting {
+		return existingKeysError(existing)
+	}
+	for _, key := range keys {
+		if _, exists := ops.lookup(key); !exists {
+			ops.remove(key)
+			ops.store[key] = [][]byte{}
+			ops.modified[key] = ops.now()
+			ops.created[key] = ops.modified[key]
+		}
+	}
+	return existingKeysError(existing)
+}
+
+// ReadAllKeys implements BulkReader, collecting the last entry of every key.
+func (ops *InMemoryOps) ReadAllKeys(ctx context.Context) (map[string][]byte, error) {
+	ops.mu.RLock()
+	defer ops.mu.RUnlock()
+
+	values := make(map[string][]byte, len(ops.store))
+	for key, data := range ops.store {
+		if len(data) > 0 && !ops.expired(key) {
+			values[key] = data[len(data)-1]
+		}
+	}
+	return values, nil
+}
+
+// GetOrCreate implements GetOrCreator.
+func (ops *InMemoryOps) GetOrCreate(ctx context.Context, key string, entry []byte) ([]byte, bool, error) {
+	ops.mu.Lock()
+	defer ops.mu.Unlock()
+
+	if data, exists := ops.lookup(key); exists {
+		if len(data) == 0 {
+			return nil, false, EntryError(fmt.Sprintf("no entries found for key %s", key))
+		}
+		return data[len(data)-1], false, nil
+	}
+
+	ops.remove(key)
+	ops.store[key] = [][]byte{entry}
+	ops.modified[key] = ops.now()
+	ops.created[key] = ops.modified[key]
+	return entry, true, nil
+}
+
+// SizeHistogram implements SizeHistogrammer.
+func (ops *InMemoryOps) SizeHistogram(ctx context.Context, buckets []int64) (map[int64]int, error) {
+	ops.mu.RLock()
+	defer ops.mu.RUnlock()
+
+	h := newSizeHistogram(buckets)
+	for key, data := range ops.store {
+		if ops.expired(key) {
+			continue
+		}
+		for _, entry := range data {
+			h.add(int64(len(entry)), 1)
+		}
+	}
+	return h.counts, nil
+}
+
+// PutAndGetPrevious implements PreviousPutter. Like Put, it replaces all entries.
+func (ops *InMemoryOps) PutAndGetPrevious(ctx context.Context, key string, entry []byte) ([]byte, error) {
+	ops.mu.Lock()
+	defer ops.mu.Unlock()
+
+	data, err := ops.writable(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var previous []byte
+	if len(data) > 0 {
+		previous = data[len(data)-1]
+	}
+	ops.store[key] = [][]byte{entry}
+	ops.modified[key] = ops.now()
+	delete(ops.expires, key)
+	return previous, nil
+}
+
+// Increment implements Incrementer under the write lock. Like Put, it replaces all
+// entries.
+func (ops *InMemoryOps) Increment(ctx context.Context, key string, delta int64) (int64, error) {
+	ops.mu.Lock()
+	defer ops.mu.Unlock()
+
+	data, err := ops.writable(key)
+	if err != nil {
+		return 0, err
+	}
+	var latest []byte
+	if len(data) > 0 {
+		latest = data[len(data)-1]
+	}
+	value, err := addCounter(key, latest, delta)
+	if err != nil {
+		return 0, err
+	}
+	ops.store[key] = [][]byte{formatCounter(value)}
+	ops.modified[key] = ops.now()
+	delete(ops.expires, key)
+	return value, nil
+}
+
+// PutCapped implements CappedPutter. Unlike Put it appends, keeping the newest
+// maxEntries entries.
+func (ops *InMemoryOps) PutCapped(ctx context.Context, key string, entry []byte, maxEntries int) error {
+	if err := checkMaxEntries(maxEntries); err != nil {
+		return err
+	}
+	ops.mu.Lock()
+	defer ops.mu.Unlock()
+
+	data, err := ops.writable(key)
+	if err != nil {
+		return err
+	}
+
+	// Copy the retained tail so the dropped entries are not kept alive by it.
+	data = append(data, entry)
+	ops.store[key] = append([][]byte(nil), data[max(len(data)-maxEntries, 0):]...)
+	ops.modified[key] = ops.now()
+	delete(ops.expires, key)
+	return nil
+}
+
+// PutFenced implements FencedPutter. The token is kept apart from the metadata of
+// the key, until the key is deleted.
+func (ops *InMemoryOps) PutFenced(ctx context.Context, key string, entry []byte, fenceToken int64) error {
+	ops.mu.Lock()
+	defer ops.mu.Unlock()
+
+	if _, err := ops.writable(key); err != nil {
+		return err
+	}
+	if highest, ok := ops.fenceTokens[key]; ok {
+		if err := checkFence(key, highest, fenceToken); err != nil {
+			return err
+		}
+	}
+	if err := ops.put(key, entry); err != nil {
+		return err
+	}
+	ops.fenceTokens[key] = fenceToken
+	return nil
+}
+
+// PutMetadata implements MetadataStore.
+func (ops *InMemoryOps) PutMetadata(ctx context.Context, key string, meta Metadata) error {
+	ops.mu.Lock()
+	defer ops.mu.Unlock()
+
+	if _, exists := ops.lookup(key); !exists {
+		ops.remove(key)
+		return KeyNotFoundError(fmt.Sprintf("key %s not found", key))
+	}
+	ops.metadata[key] = maps.Clone(meta)
+	return nil
+}
+
+// ReadMetadata implements MetadataStore.
+func (ops *InMemoryOps) ReadMetadata(ctx context.Context, key string) (Metadata, error) {
+	ops.mu.RLock()
+	defer ops.mu.RUnlock()
+
+	if _, exists := ops.lookup(key); !exists {
+		return nil, KeyNotFoundError(fmt.Sprintf("key %s not found", key))
+	}
+	meta := maps.Clone(ops.metadata[key])
+	if meta == nil {
+		meta = Metadata{}
+	}
+	return meta, nil
+}
+
+// ListWhere implements WhereLister, matching the metadata of every key in memory.
+func (ops *InMemoryOps) ListWhere(ctx context.Context, predicate MetaPredicate) ([]string, error) {
+	ops.mu.RLock()
+	defer ops.mu.RUnlock()
+
+	keys := []string{}
+	for key := range ops.store {
+		if !ops.expired(key) && predicate.Match(ops.metadata[key]) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// ListChildren implements ChildLister, sorting only the keys under prefix.
+func (ops *InMemoryOps) ListChildren(ctx context.Context, prefix, delimiter string) ([]string, []string, error) {
+	ops.mu.RLock()
+	var keys []string
+	for key := range ops.store {
+		if strings.HasPrefix(key, prefix) && !ops.expired(key) {
+			keys = append(keys, key)
+		}
+	}
+	ops.mu.RUnlock()
+
+	slices.Sort(keys)
+	children, commonPrefixes := groupChildren(keys, prefix, delimiter)
+	return children, commonPrefixes, nil
+}
+
+// ListPrefixPage implements PrefixPageLister, sorting only the keys under prefix
+// after cursor.
+func (ops *InMemoryOps) ListPrefixPage(ctx context.Context, prefix, cursor string, limit int) ([]string, string, error) {
+	if err := checkPageLimit(limit); err != nil {
+		return nil, "", err
+	}
+	ops.mu.RLock()
+	var keys []string
+	for key := range ops.store {
+		if key > cursor && strings.HasPrefix(key, prefix) && !ops.expired(key) {
+			keys = append(keys, key)
+		}
+	}
+	ops.mu.RUnlock()
+
+	slices.Sort(keys)
+	page, next := prefixPage(keys, prefix, cursor, limit)
+	return page, next, nil
+}
+
+// Finalize implements Finalizer.
+func (ops *InMemoryOps) Finalize(ctx context.Context, key string) error {
+	ops.mu.Lock()
+	defer ops.mu.Unlock()
+
+	if _, exists := ops.lookup(key); !exists {
+		ops.remove(key)
+		return KeyNotFoundError(fmt.Sprintf("key %s not found", key))
+	}
+	ops.finalized[key] = true
+	return nil
+}
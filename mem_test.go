@@ -0,0 +1,137 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cecmp/libstore"
+)
+
+// fakeClock is a manually advanced clock.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestInMemoryTTLSweeper(t *testing.T) {
+	ops := libstore.NewInMemoryOpsWithSweeper(time.Millisecond)
+	defer ops.Close()
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	libstore.SetInMemoryClock(ops, clock.Now)
+
+	for _, key := range []string{"ephemeral", "durable"} {
+		if err := ops.Create(context.TODO(), key); err != nil {
+			t.Fatalf("Error creating key: %v", err)
+		}
+	}
+	if err := ops.PutWithTTL(context.TODO(), "ephemeral", []byte("value"), time.Minute); err != nil {
+		t.Fatalf("Error putting entry: %v", err)
+	}
+	if err := ops.Put(context.TODO(), "durable", []byte("value")); err != nil {
+		t.Fatalf("Error putting entry: %v", err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if n := libstore.InMemoryStoredKeys(ops); n != 2 {
+		t.Fatalf("Expected unexpired keys to be kept, Got %d keys", n)
+	}
+
+	clock.Advance(2 * time.Minute)
+	deadline := time.Now().Add(time.Second)
+	for libstore.InMemoryStoredKeys(ops) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the sweeper to reclaim the expired key")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	_, err := ops.Read(context.TODO(), "ephemeral")
+	var notFoundErr libstore.KeyNotFoundError
+	if !errors.As(err, &notFoundErr) {
+		t.Errorf("Expected a KeyNotFoundError, Got: %v", err)
+	}
+	if _, err := ops.Read(context.TODO(), "durable"); err != nil {
+		t.Errorf("Error reading key: %v", err)
+	}
+}
+
+func TestInMemoryPutWithExpiry(t *testing.T) {
+	ops := libstore.NewInMemoryOpsWithSweeper(time.Millisecond)
+	defer ops.Close()
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)}
+	libstore.SetInMemoryClock(ops, clock.Now)
+	endOfDay := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	if err := ops.Create(context.TODO(), "daily"); err != nil {
+		t.Fatalf("Error creating key: %v", err)
+	}
+	var entryErr libstore.EntryError
+	if err := libstore.PutWithExpiry(context.TODO(), ops, "daily", []byte("value"), clock.Now()); !errors.As(err, &entryErr) {
+		t.Errorf("Expected an EntryError for an expiry that is not in the future, Got: %v", err)
+	}
+	if err := libstore.PutWithExpiry(context.TODO(), ops, "daily", []byte("value"), endOfDay); err != nil {
+		t.Fatalf("Error putting entry: %v", err)
+	}
+
+	clock.Advance(14*time.Hour + 59*time.Minute)
+	if entry, err := ops.Read(context.TODO(), "daily"); err != nil || string(entry) != "value" {
+		t.Errorf("Expected the key to live until the end of the day, Got: %q, %v", entry, err)
+	}
+
+	clock.Advance(time.Minute)
+	var notFoundErr libstore.KeyNotFoundError
+	if _, err := ops.ReadAll(context.TODO(), "daily"); !errors.As(err, &notFoundErr) {
+		t.Errorf("Expected a KeyNotFoundError once expired, Got: %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for libstore.InMemoryStoredKeys(ops) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the sweeper to reclaim the expired key")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestInMemoryCloseStopsSweeper(t *testing.T) {
+	ops := libstore.NewInMemoryOpsWithSweeper(time.Millisecond)
+	if err := ops.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ops.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInMemoryHistory(t *testing.T) {
+	ops := libstore.NewInMemoryOps()
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	libstore.SetInMemoryClock(ops, func() time.Time { return now })
+	if err := ops.Create(context.TODO(), "key"); err != nil {
+		t.Fatal(err)
+	}
+	if history, err := libstore.History(context.TODO(), ops, "key"); err != nil || len(history) != 0 {
+		t.Errorf("Expected an empty history, Got: %+v, %v", history, err)
+	}
+	if err := ops.Put(context.TODO(), "key", []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	history, err := libstore.History(context.TODO(), ops, "key")
+	if err != nil || len(history) != 1 || history[0].Version != 1 || string(history[0].Value) != "value" || !history[0].CreatedAt.Equal(now) {
+		t.Errorf("Unexpected history: %+v, %v", history, err)
+	}
+}
@@ -0,0 +1,100 @@
+package libstore_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestInMemorySaveAndLoad(t *testing.T) {
+	ctx := context.Background()
+	ops := libstore.NewInMemoryOps()
+	if err := ops.Create(ctx, "binary"); err != nil {
+		t.Fatalf("Error creating key: %v", err)
+	}
+	for _, entry := range [][]byte{{0x00, 0xff, '\n', 0x01}, {}, []byte("text\nwith newline")} {
+		if err := libstore.PutCapped(ctx, ops, "binary", entry, 10); err != nil {
+			t.Fatalf("Error putting entry: %v", err)
+		}
+	}
+	if err := ops.Create(ctx, "empty"); err != nil {
+		t.Fatalf("Error creating key: %v", err)
+	}
+	if err := ops.Create(ctx, "fenced"); err != nil {
+		t.Fatalf("Error creating key: %v", err)
+	}
+	if err := ops.PutFenced(ctx, "fenced", []byte("leader"), 5); err != nil {
+		t.Fatalf("Error putting fenced entry: %v", err)
+	}
+	if err := ops.Create(ctx, "final"); err != nil {
+		t.Fatalf("Error creating key: %v", err)
+	}
+	if err := ops.Put(ctx, "final", []byte("done")); err != nil {
+		t.Fatalf("Error putting entry: %v", err)
+	}
+	if err := ops.PutMetadata(ctx, "final", libstore.Metadata{"owner": "alice"}); err != nil {
+		t.Fatalf("Error putting metadata: %v", err)
+	}
+	if err := ops.Finalize(ctx, "final"); err != nil {
+		t.Fatalf("Error finalizing key: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := ops.SaveTo(&buf); err != nil {
+		t.Fatalf("Error saving store: %v", err)
+	}
+	loaded, err := libstore.LoadInMemoryOps(&buf)
+	if err != nil {
+		t.Fatalf("Error loading store: %v", err)
+	}
+
+	keys, err := ops.List(ctx)
+	if err != nil {
+		t.Fatalf("Error listing keys: %v", err)
+	}
+	loadedKeys, err := loaded.List(ctx)
+	if err != nil {
+		t.Fatalf("Error listing loaded keys: %v", err)
+	}
+	slices.Sort(keys)
+	slices.Sort(loadedKeys)
+	if !reflect.DeepEqual(keys, loadedKeys) {
+		t.Fatalf("Expected keys %v, Got: %v", keys, loadedKeys)
+	}
+	for _, key := range keys {
+		want, err := ops.ReadAll(ctx, key)
+		if err != nil {
+			t.Fatalf("Error reading key %s: %v", key, err)
+		}
+		got, err := loaded.ReadAll(ctx, key)
+		if err != nil {
+			t.Fatalf("Error reading loaded key %s: %v", key, err)
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("Expected entries %q for key %s, Got: %q", want, key, got)
+		}
+	}
+
+	if meta, err := loaded.ReadMetadata(ctx, "final"); err != nil || meta["owner"] != "alice" {
+		t.Errorf("Expected the metadata to be loaded, Got: %v, %v", meta, err)
+	}
+	var fencedErr libstore.FencedError
+	if err := loaded.PutFenced(ctx, "fenced", []byte("deposed"), 4); !errors.As(err, &fencedErr) {
+		t.Errorf("Expected the fence token to be loaded, Got: %v", err)
+	}
+	var immutable libstore.ImmutableError
+	if err := loaded.Put(ctx, "final", []byte("again")); !errors.As(err, &immutable) {
+		t.Errorf("Expected an ImmutableError for a finalized key, Got: %v", err)
+	}
+
+	var entryErr libstore.EntryError
+	if _, err := libstore.LoadInMemoryOps(strings.NewReader("not a store")); !errors.As(err, &entryErr) {
+		t.Errorf("Expected an EntryError for garbage input, Got: %v", err)
+	}
+}
@@ -0,0 +1,170 @@
+package libstore
+
+import (
+	"context"
+	"time"
+)
+
+// TxOps is the view of a store inside a transaction. Its operations act on the state
+// of the store as the transaction sees it.
+type TxOps interface {
+	Ops
+}
+
+// inMemoryTx is the TxOps of a transaction of InMemoryOps, which holds its write lock.
+type inMemoryTx struct {
+	ops *InMemoryOps
+	// saved holds the state of the keys the transaction wrote before it first did,
+	// to roll them back.
+	saved map[string]inMemoryKeyState
+	done  bool
+}
+
+// inMemoryKeyState is the state of one key of InMemoryOps.
+type inMemoryKeyState struct {
+	exists    bool
+	data      [][]byte
+	modified  time.Time
+	created   time.Time
+	expires   time.Time
+	expiring  bool
+	metadata  Metadata
+	finalized bool
+	// fenceToken is the highest fence token of the key, if fenced is set.
+	fenceToken int64
+	fenced     bool
+}
+
+// WithTx runs fn with the write lock of ops held, so the operations of fn on any
+// number of keys form one atomic step for the other users of ops: they see the
+// store either before or after fn, and calls they make meanwhile wait for fn to
+// return. If fn returns an error or panics, every write of fn is rolled back and
+// the error returned.
+//
+// tx is only valid during fn; its operations return a ClosedError afterwards. fn
+// must not use ops itself, which would deadlock.
+func (ops *InMemoryOps) WithTx(fn func(tx TxOps) error) (err error) {
+	ops.mu.Lock()
+	defer ops.mu.Unlock()
+
+	tx := &inMemoryTx{ops: ops, saved: map[string]inMemoryKeyState{}}
+	defer func() {
+		tx.done = true
+		if r := recover(); r != nil {
+			tx.rollback()
+			panic(r)
+		}
+		if err != nil {
+			tx.rollback()
+		}
+	}()
+	return fn(tx)
+}
+
+// save records the state of key before the transaction first writes it.
+func (tx *inMemoryTx) save(key string) {
+	if _, ok := tx.saved[key]; ok {
+		return
+	}
+	ops := tx.ops
+	data, exists := ops.store[key]
+	expires, expiring := ops.expires[key]
+	fenceToken, fenced := ops.fenceTokens[key]
+	tx.saved[key] = inMemoryKeyState{
+		exists:     exists,
+		data:       data,
+		modified:   ops.modified[key],
+		created:    ops.created[key],
+		expires:    expires,
+		expiring:   expiring,
+		metadata:   ops.metadata[key],
+		finalized:  ops.finalized[key],
+		fenceToken: fenceToken,
+		fenced:     fenced,
+	}
+}
+
+// rollback restores the keys the transaction wrote.
+func (tx *inMemoryTx) rollback() {
+	ops := tx.ops
+	for key, state := range tx.saved {
+		ops.remove(key)
+		if !state.exists {
+			continue
+		}
+		ops.store[key] = state.data
+		ops.modified[key] = state.modified
+		ops.created[key] = state.created
+		if state.expiring {
+			ops.expires[key] = state.expires
+		}
+		if state.metadata != nil {
+			ops.metadata[key] = state.metadata
+		}
+		if state.finalized {
+			ops.finalized[key] = true
+		}
+		if state.fenced {
+			ops.fenceTokens[key] = state.fenceToken
+		}
+	}
+}
+
+// closed returns a ClosedError once the transaction is over.
+func (tx *inMemoryTx) closed() error {
+	if tx.done {
+		return ClosedError("memory: transaction is over")
+	}
+	return nil
+}
+
+// Create implements libstore.Ops.
+func (tx *inMemoryTx) Create(ctx context.Context, key string) error {
+	if err := tx.closed(); err != nil {
+		return err
+	}
+	tx.save(key)
+	return tx.ops.create(key)
+}
+
+// ReadAll implements libstore.Ops.
+func (tx *inMemoryTx) ReadAll(ctx context.Context, key string) ([][]byte, error) {
+	if err := tx.closed(); err != nil {
+		return nil, err
+	}
+	return tx.ops.readAll(key)
+}
+
+// Read implements libstore.Ops.
+func (tx *inMemoryTx) Read(ctx context.Context, key string) ([]byte, error) {
+	if err := tx.closed(); err != nil {
+		return nil, err
+	}
+	return tx.ops.read(key)
+}
+
+// Put implements libstore.Ops.
+func (tx *inMemoryTx) Put(ctx context.Context, key string, entry []byte) error {
+	if err := tx.closed(); err != nil {
+		return err
+	}
+	tx.save(key)
+	return tx.ops.put(key, entry)
+}
+
+// Delete implements libstore.Ops.
+func (tx *inMemoryTx) Delete(ctx context.Context, key string) error {
+	if err := tx.closed(); err != nil {
+		return err
+	}
+	tx.save(key)
+	return tx.ops.delete(key)
+}
+
+// List implements libstore.Ops.
+func (tx *inMemoryTx) List(ctx context.Context) ([]string, error) {
+	if err := tx.closed(); err != nil {
+		return nil, err
+	}
+	return tx.ops.list(), nil
+}
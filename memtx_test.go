@@ -0,0 +1,107 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+// readInt reads the integer stored in key.
+func readInt(t *testing.T, ops libstore.Ops, key string) int {
+	t.Helper()
+	entry, err := ops.Read(context.Background(), key)
+	if err != nil {
+		t.Errorf("Error reading key %s: %v", key, err)
+		return 0
+	}
+	n, err := strconv.Atoi(string(entry))
+	if err != nil {
+		t.Errorf("Error parsing key %s: %v", key, err)
+	}
+	return n
+}
+
+func TestInMemoryWithTx(t *testing.T) {
+	ctx := context.Background()
+	ops := libstore.NewInMemoryOps()
+	for key, value := range map[string]string{"from": "100", "to": "0"} {
+		if err := ops.Create(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+		if err := ops.Put(ctx, key, []byte(value)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Transfers move one unit at a time while auditors check that none is ever seen
+	// in flight.
+	const transfers, audits = 50, 50
+	var wg sync.WaitGroup
+	for range transfers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := ops.WithTx(func(tx libstore.TxOps) error {
+				from, to := readInt(t, tx, "from"), readInt(t, tx, "to")
+				if err := tx.Put(ctx, "from", []byte(strconv.Itoa(from-1))); err != nil {
+					return err
+				}
+				return tx.Put(ctx, "to", []byte(strconv.Itoa(to+1)))
+			})
+			if err != nil {
+				t.Errorf("Error transferring: %v", err)
+			}
+		}()
+	}
+	for range audits {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = ops.WithTx(func(tx libstore.TxOps) error {
+				if sum := readInt(t, tx, "from") + readInt(t, tx, "to"); sum != 100 {
+					t.Errorf("Expected a total of 100, Got: %d", sum)
+				}
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+	if from, to := readInt(t, ops, "from"), readInt(t, ops, "to"); from != 100-transfers || to != transfers {
+		t.Errorf("Expected %d and %d after the transfers, Got: %d and %d", 100-transfers, transfers, from, to)
+	}
+
+	// A failed transaction leaves no trace.
+	errAbort := errors.New("abort")
+	var leaked libstore.TxOps
+	err := ops.WithTx(func(tx libstore.TxOps) error {
+		leaked = tx
+		if err := tx.Put(ctx, "from", []byte("0")); err != nil {
+			return err
+		}
+		if err := tx.Delete(ctx, "to"); err != nil {
+			return err
+		}
+		if err := tx.Create(ctx, "new"); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Errorf("Expected the error of fn, Got: %v", err)
+	}
+	if keys, err := ops.List(ctx); err != nil || len(keys) != 2 {
+		t.Errorf("Expected the keys from and to only, Got: %v, %v", keys, err)
+	}
+	if from, to := readInt(t, ops, "from"), readInt(t, ops, "to"); from != 100-transfers || to != transfers {
+		t.Errorf("Expected the rollback to restore %d and %d, Got: %d and %d", 100-transfers, transfers, from, to)
+	}
+
+	var closed libstore.ClosedError
+	if _, err := leaked.Read(ctx, "from"); !errors.As(err, &closed) {
+		t.Errorf("Expected a ClosedError after the transaction, Got: %v", err)
+	}
+}
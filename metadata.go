@@ -0,0 +1,129 @@
+package libstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Metadata holds string attributes attached to a key, such as its owner or status.
+type Metadata map[string]string
+
+// MetadataStore is implemented by backends that can attach Metadata to keys.
+type MetadataStore interface {
+	// PutMetadata replaces the metadata of key.
+	// It returns a KeyNotFoundError if the key does not exist.
+	PutMetadata(ctx context.Context, key string, meta Metadata) error
+	// ReadMetadata returns the metadata of key, which is empty if none was put.
+	// It returns a KeyNotFoundError if the key does not exist.
+	ReadMetadata(ctx context.Context, key string) (Metadata, error)
+}
+
+// MetaOp is the comparison made by a MetaCondition.
+type MetaOp int
+
+const (
+	// MetaEquals holds if the field is set to Value.
+	MetaEquals MetaOp = iota
+	// MetaNotEquals holds if the field is unset or set to something other than Value.
+	MetaNotEquals
+	// MetaExists holds if the field is set, to any value.
+	MetaExists
+)
+
+// MetaCondition compares one metadata field.
+type MetaCondition struct {
+	Field string
+	Op    MetaOp
+	Value string
+}
+
+// MetaPredicate selects keys whose metadata satisfies all of its conditions. An
+// empty predicate selects every key. Its conditions are plain data, so backends can
+// translate them into queries with bound parameters.
+type MetaPredicate []MetaCondition
+
+// Match reports whether meta satisfies the predicate.
+func (p MetaPredicate) Match(meta Metadata) bool {
+	for _, c := range p {
+		value, ok := meta[c.Field]
+		switch c.Op {
+		case MetaEquals:
+			if !ok || value != c.Value {
+				return false
+			}
+		case MetaNotEquals:
+			if ok && value == c.Value {
+				return false
+			}
+		case MetaExists:
+			if !ok {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// validate returns a KeyError if the predicate uses an unknown operator.
+func (p MetaPredicate) validate() error {
+	for _, c := range p {
+		if c.Op < MetaEquals || c.Op > MetaExists {
+			return KeyError(fmt.Sprintf("metadata: unknown operator %d on field %s", c.Op, c.Field))
+		}
+	}
+	return nil
+}
+
+// WhereLister is implemented by backends that can filter keys by metadata themselves.
+type WhereLister interface {
+	// ListWhere lists the keys whose metadata satisfies predicate.
+	ListWhere(ctx context.Context, predicate MetaPredicate) ([]string, error)
+}
+
+// ListWhere lists the keys of ops whose metadata satisfies predicate, for instance
+// all keys whose owner is X and whose status is active.
+//
+// Backends implementing WhereLister filter the keys themselves. For other backends
+// implementing MetadataStore the metadata of every key is read and matched here. It
+// returns an UnsupportedError for backends without metadata.
+func ListWhere(ctx context.Context, ops Ops, predicate MetaPredicate) ([]string, error) {
+	if err := predicate.validate(); err != nil {
+		return nil, err
+	}
+	if lister, ok := ops.(WhereLister); ok {
+		return lister.ListWhere(ctx, predicate)
+	}
+	store, ok := ops.(MetadataStore)
+	if !ok {
+		return nil, UnsupportedError("metadata is not supported by this backend")
+	}
+	return listWhere(ctx, ops, store, predicate)
+}
+
+// listWhere lists the keys of ops and keeps those whose metadata, read from store,
+// satisfies predicate.
+func listWhere(ctx context.Context, ops Ops, store MetadataStore, predicate MetaPredicate) ([]string, error) {
+	keys, err := ops.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	matched := []string{}
+	for _, key := range keys {
+		meta, err := store.ReadMetadata(ctx, key)
+		var notFound KeyNotFoundError
+		if errors.As(err, &notFound) {
+			// Deleted since it was listed.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if predicate.Match(meta) {
+			matched = append(matched, key)
+		}
+	}
+	return matched, nil
+}
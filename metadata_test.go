@@ -0,0 +1,113 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+// testListWhere attaches metadata to a few keys of ops and checks which keys
+// ListWhere selects.
+func testListWhere(t *testing.T, ops libstore.Ops, prefix string) {
+	t.Helper()
+	ctx := context.Background()
+	store, ok := ops.(libstore.MetadataStore)
+	if !ok {
+		t.Fatalf("%T does not implement MetadataStore", ops)
+	}
+
+	metas := map[string]libstore.Metadata{
+		prefix + "a": {"owner": "alice", "status": "active"},
+		prefix + "b": {"owner": "alice", "status": "archived"},
+		prefix + "c": {"owner": "bob", "status": "active"},
+		prefix + "d": nil,
+	}
+	for key, meta := range metas {
+		if err := ops.Create(ctx, key); err != nil {
+			t.Fatalf("Create(%q) error = %v", key, err)
+		}
+		if meta != nil {
+			if err := store.PutMetadata(ctx, key, meta); err != nil {
+				t.Fatalf("PutMetadata(%q) error = %v", key, err)
+			}
+		}
+	}
+
+	got, err := store.ReadMetadata(ctx, prefix+"a")
+	if err != nil || got["owner"] != "alice" || got["status"] != "active" || len(got) != 2 {
+		t.Fatalf("ReadMetadata() = %v, %v, want owner=alice status=active", got, err)
+	}
+
+	tests := []struct {
+		name      string
+		predicate libstore.MetaPredicate
+		want      []string
+	}{
+		{"equals", libstore.MetaPredicate{
+			{Field: "owner", Op: libstore.MetaEquals, Value: "alice"},
+			{Field: "status", Op: libstore.MetaEquals, Value: "active"},
+		}, []string{"a"}},
+		{"not equals", libstore.MetaPredicate{
+			{Field: "status", Op: libstore.MetaNotEquals, Value: "active"},
+		}, []string{"b", "d"}},
+		{"exists", libstore.MetaPredicate{
+			{Field: "owner", Op: libstore.MetaExists},
+		}, []string{"a", "b", "c"}},
+	}
+	for _, tt := range tests {
+		keys, err := libstore.ListWhere(ctx, ops, tt.predicate)
+		if err != nil {
+			t.Fatalf("%s: ListWhere() error = %v", tt.name, err)
+		}
+		var matched []string
+		for _, key := range keys {
+			if len(key) > len(prefix) && key[:len(prefix)] == prefix {
+				matched = append(matched, key[len(prefix):])
+			}
+		}
+		slices.Sort(matched)
+		if !slices.Equal(matched, tt.want) {
+			t.Errorf("%s: ListWhere() = %v, want %v", tt.name, matched, tt.want)
+		}
+	}
+
+	var notFound libstore.KeyNotFoundError
+	if err := store.PutMetadata(ctx, prefix+"missing", libstore.Metadata{"owner": "alice"}); !errors.As(err, &notFound) {
+		t.Errorf("PutMetadata() on missing key error = %v, want KeyNotFoundError", err)
+	}
+	if _, err := store.ReadMetadata(ctx, prefix+"missing"); !errors.As(err, &notFound) {
+		t.Errorf("ReadMetadata() on missing key error = %v, want KeyNotFoundError", err)
+	}
+}
+
+func TestInMemoryListWhere(t *testing.T) {
+	testListWhere(t, libstore.NewInMemoryOps(), "")
+}
+
+func TestS3ListWhere(t *testing.T) {
+	testListWhere(t, newFakeS3Ops(t), "")
+}
+
+func TestDBListWhere(t *testing.T) {
+	testListWhere(t, newTestDBOps(t), testKey(t)+"/")
+}
+
+func TestListWhereUnsupported(t *testing.T) {
+	ops := newRecordingOps(libstore.NewInMemoryOps())
+	_, err := libstore.ListWhere(context.Background(), ops, nil)
+	var unsupported libstore.UnsupportedError
+	if !errors.As(err, &unsupported) {
+		t.Errorf("ListWhere() error = %v, want UnsupportedError", err)
+	}
+}
+
+func TestListWhereUnknownOperator(t *testing.T) {
+	_, err := libstore.ListWhere(context.Background(), libstore.NewInMemoryOps(), libstore.MetaPredicate{{Field: "owner", Op: libstore.MetaOp(42)}})
+	var keyErr libstore.KeyError
+	if !errors.As(err, &keyErr) {
+		t.Errorf("ListWhere() error = %v, want KeyError", err)
+	}
+}
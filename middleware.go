@@ -0,0 +1,119 @@
+package libstore
+
+import (
+	"time"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// Middleware wraps an Ops with additional behavior.
+type Middleware func(Ops) Ops
+
+// Chain wraps base with the given middlewares. The first middleware is the outermost:
+// Chain(base, a, b) is a(b(base)), so a call passes through a, then b, then base.
+//
+// Order matters. The recommended order, from outermost to innermost, is:
+//   - observability (metrics, tracing, logging), so it sees every call as issued;
+//   - key validation, so invalid keys are rejected before any further work;
+//   - caching, so cache hits skip everything below;
+//   - retries and rate limiting, so each attempt is limited individually;
+//   - transformations (deduplication, key encoding, encryption), closest to the
+//     backend, so retries repeat the transformed operation.
+func Chain(base Ops, mw ...Middleware) Ops {
+	ops := base
+	for i := len(mw) - 1; i >= 0; i-- {
+		ops = mw[i](ops)
+	}
+	return ops
+}
+
+// WithKeyCodec returns a Middleware applying NewKeyCodecOps with codec.
+func WithKeyCodec(codec KeyCodec) Middleware {
+	return func(ops Ops) Ops {
+		return NewKeyCodecOps(ops, codec)
+	}
+}
+
+// WithDedup returns a Middleware applying NewDedupOps.
+func WithDedup() Middleware {
+	return NewDedupOps
+}
+
+// WithKeyValidation returns a Middleware applying NewValidatedKeyOps with rules.
+func WithKeyValidation(rules KeyRules) Middleware {
+	return func(ops Ops) Ops {
+		return NewValidatedKeyOps(ops, rules)
+	}
+}
+
+// WithReadReplicas returns a Middleware applying NewReadReplicaOps, using the wrapped
+// Ops as the primary.
+func WithReadReplicas(replicas ...Ops) Middleware {
+	return func(ops Ops) Ops {
+		return NewReadReplicaOps(ops, replicas...)
+	}
+}
+
+// WithDrain returns a Middleware applying NewDrainableOps. Use it as the outermost
+// middleware so Drain sees every call.
+func WithDrain() Middleware {
+	return func(ops Ops) Ops {
+		return NewDrainableOps(ops)
+	}
+}
+
+// WithPrefix returns a Middleware applying NewPrefixOps with prefix.
+func WithPrefix(prefix string) Middleware {
+	return func(ops Ops) Ops {
+		return NewPrefixOps(ops, prefix)
+	}
+}
+
+// WithCaseFold returns a Middleware applying NewCaseFoldOps.
+func WithCaseFold() Middleware {
+	return func(ops Ops) Ops {
+		return NewCaseFoldOps(ops)
+	}
+}
+
+// WithCache returns a Middleware applying NewCacheOps with ttl.
+func WithCache(ttl time.Duration) Middleware {
+	return func(ops Ops) Ops {
+		return NewCacheOps(ops, ttl)
+	}
+}
+
+// WithSerialization returns a Middleware applying NewSerializedOps. Use it as the
+// innermost middleware, so only calls to the backend itself are serialized.
+func WithSerialization() Middleware {
+	return NewSerializedOps
+}
+
+// WithSchema returns a Middleware applying NewSchemaOps with schema and opts.
+func WithSchema(schema *jsonschema.Schema, opts ...SchemaOption) Middleware {
+	return func(ops Ops) Ops {
+		return NewSchemaOps(ops, schema, opts...)
+	}
+}
+
+// WithChunking returns a Middleware applying NewChunkingOps with chunkSize. Use it
+// closest to the backend, so the chunks are what reaches it.
+func WithChunking(chunkSize int) Middleware {
+	return func(ops Ops) Ops {
+		return NewChunkingOps(ops, chunkSize)
+	}
+}
+
+// WithConcurrencyLimit returns a Middleware applying NewConcurrencyLimitOps with limit.
+func WithConcurrencyLimit(limit int) Middleware {
+	return func(ops Ops) Ops {
+		return NewConcurrencyLimitOps(ops, limit)
+	}
+}
+
+// WithVersionCap returns a Middleware applying NewVersionCapOps with maxVersions.
+func WithVersionCap(maxVersions int, opts ...VersionCapOption) Middleware {
+	return func(ops Ops) Ops {
+		return NewVersionCapOps(ops, maxVersions, opts...)
+	}
+}
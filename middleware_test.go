@@ -0,0 +1,39 @@
+package libstore_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+// tracingOps records the name of each wrapper a Create passes through.
+type tracingOps struct {
+	libstore.Ops
+	name  string
+	trace *[]string
+}
+
+func (o tracingOps) Create(ctx context.Context, key string) error {
+	*o.trace = append(*o.trace, o.name)
+	return o.Ops.Create(ctx, key)
+}
+
+func TestChainOrder(t *testing.T) {
+	var trace []string
+	traced := func(name string) libstore.Middleware {
+		return func(ops libstore.Ops) libstore.Ops {
+			return tracingOps{Ops: ops, name: name, trace: &trace}
+		}
+	}
+
+	ops := libstore.Chain(libstore.NewInMemoryOps(), traced("outer"), traced("middle"), traced("inner"))
+	if err := ops.Create(context.TODO(), "key"); err != nil {
+		t.Fatalf("Error creating key: %v", err)
+	}
+	expected := []string{"outer", "middle", "inner"}
+	if !reflect.DeepEqual(trace, expected) {
+		t.Errorf("Unexpected order. Expected: %v, Got: %v", expected, trace)
+	}
+}
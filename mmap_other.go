@@ -0,0 +1,13 @@
+//go:build !unix
+
+package libstore
+
+import (
+	"errors"
+	"os"
+)
+
+// mmapFile is not available on this platform; callers fall back to buffered reads.
+func mmapFile(file *os.File, size int) ([]byte, func() error, error) {
+	return nil, nil, errors.ErrUnsupported
+}
@@ -0,0 +1,50 @@
+package libstore
+
+import (
+	"context"
+	"fmt"
+)
+
+// nullOps discards everything written to it.
+type nullOps struct{}
+
+// NewNullOps returns an Ops that stores nothing. Create, Put and Delete succeed
+// without keeping their data, Read and ReadAll return a KeyNotFoundError for every
+// key, and List returns no keys. It can disable persistence behind a flag without
+// touching the call sites, and gives benchmarks a baseline for the overhead of
+// wrappers.
+func NewNullOps() Ops {
+	return nullOps{}
+}
+
+// Create implements libstore.Ops without creating anything.
+func (nullOps) Create(ctx context.Context, key string) error {
+	return nil
+}
+
+// ReadAll implements libstore.Ops.
+func (nullOps) ReadAll(ctx context.Context, key string) ([][]byte, error) {
+	return nil, KeyNotFoundError(fmt.Sprintf("key %s not found", key))
+}
+
+// Read implements libstore.Ops.
+func (nullOps) Read(ctx context.Context, key string) ([]byte, error) {
+	return nil, KeyNotFoundError(fmt.Sprintf("key %s not found", key))
+}
+
+// Put implements libstore.Ops, discarding entry.
+func (nullOps) Put(ctx context.Context, key string, entry []byte) error {
+	return nil
+}
+
+// Delete implements libstore.Ops.
+func (nullOps) Delete(ctx context.Context, key string) error {
+	return nil
+}
+
+// List implements libstore.Ops.
+func (nullOps) List(ctx context.Context) ([]string, error) {
+	return []string{}, nil
+}
+
+var _ Ops = nullOps{}
@@ -0,0 +1,34 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestNullOps(t *testing.T) {
+	ctx := context.Background()
+	ops := libstore.NewNullOps()
+	if err := ops.Create(ctx, "key"); err != nil {
+		t.Errorf("Expected Create to succeed, Got: %v", err)
+	}
+	if err := ops.Put(ctx, "key", []byte("discarded")); err != nil {
+		t.Errorf("Expected Put to succeed, Got: %v", err)
+	}
+
+	var notFound libstore.KeyNotFoundError
+	if _, err := ops.Read(ctx, "key"); !errors.As(err, &notFound) {
+		t.Errorf("Expected a KeyNotFoundError from Read, Got: %v", err)
+	}
+	if _, err := ops.ReadAll(ctx, "key"); !errors.As(err, &notFound) {
+		t.Errorf("Expected a KeyNotFoundError from ReadAll, Got: %v", err)
+	}
+	if keys, err := ops.List(ctx); err != nil || len(keys) != 0 {
+		t.Errorf("Expected no keys, Got: %v, %v", keys, err)
+	}
+	if err := ops.Delete(ctx, "key"); err != nil {
+		t.Errorf("Expected Delete to succeed, Got: %v", err)
+	}
+}
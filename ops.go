@@ -2,6 +2,7 @@ package libstore
 
 import (
 	"context"
+	"time"
 )
 
 // Ops defines the interface for data operations.
@@ -14,6 +15,8 @@ type Ops interface {
 	ReadAll(ctx context.Context, key string) ([][]byte, error)
 	// Read reads the last entry of the given key.
 	// It returns the last entry or an error if the file cannot be read.
+	// A key that exists but holds no entries yet returns an EntryError, and a key
+	// that does not exist returns a KeyNotFoundError, on every backend.
 	Read(ctx context.Context, key string) ([]byte, error)
 	// Put replaces an entry to the file with the given key.
 	// It returns an error if the file cannot be opened or written to.
@@ -21,17 +24,114 @@ type Ops interface {
 	// Delete deletes the given key and associated content.
 	// It returns an error if the key or associated content cannot be deleted.
 	Delete(ctx context.Context, key string) error
-	// List lists all keys in the bucket-scope.
+	// List lists all keys in the bucket-scope, sorted lexicographically by their bytes.
 	// It returns a slice of key names or an error if the bucket-scope cannot be read.
 	List(ctx context.Context) ([]string, error)
 }
 
+// ModifiedSinceLister is implemented by backends that can list the keys
+// changed after a point in time without diffing full listings.
+type ModifiedSinceLister interface {
+	// ListModifiedSince lists the keys whose latest entry was written after since.
+	// It returns an empty slice when nothing changed.
+	ListModifiedSince(ctx context.Context, since time.Time) ([]string, error)
+}
+
+// ListResult holds the outcome of a listing that tolerates partial failures.
+type ListResult struct {
+	// Keys are the keys that were listed successfully.
+	Keys []string
+	// Errors holds one entry per source that could not be listed.
+	Errors []ListSourceError
+}
+
+// ListSourceError reports a source, such as a subdirectory or a backend, that could not be listed.
+type ListSourceError struct {
+	Source string
+	Err    error
+}
+
+func (e ListSourceError) Error() string {
+	return "libstore: listing " + e.Source + ": " + e.Err.Error()
+}
+
+func (e ListSourceError) Unwrap() error {
+	return e.Err
+}
+
+// PartialLister is implemented by backends that can return a usable listing
+// even when some of their sources fail.
+type PartialLister interface {
+	// ListPartial lists all keys that can be listed and reports the sources that failed.
+	// It returns an error only if nothing could be listed.
+	ListPartial(ctx context.Context) (ListResult, error)
+}
+
+// VersionedDeleter is implemented by backends that number the versions of a key
+// and can delete it only if it has not changed.
+type VersionedDeleter interface {
+	// DeleteIfVersion deletes the given key only if its latest version is expectedVersion.
+	// It returns a KeyNotFoundError if the key does not exist and a ConflictError if
+	// the latest version differs.
+	DeleteIfVersion(ctx context.Context, key string, expectedVersion int64) error
+}
+
+// ETagDeleter is implemented by backends that identify content by an ETag
+// and can delete a key only if it has not changed.
+type ETagDeleter interface {
+	// DeleteIfUnchanged deletes the given key only if its ETag still equals etag.
+	// It returns a KeyNotFoundError if the key does not exist and a ConflictError if
+	// the ETag differs.
+	DeleteIfUnchanged(ctx context.Context, key string, etag string) error
+}
+
+// TTLPutter is implemented by backends that can expire keys.
+type TTLPutter interface {
+	// PutWithTTL replaces an entry like Put and makes the key expire after ttl.
+	// An expired key reads as not found.
+	PutWithTTL(ctx context.Context, key string, entry []byte, ttl time.Duration) error
+}
+
+// ExpiryPutter is implemented by backends that can expire keys at an absolute time.
+type ExpiryPutter interface {
+	// PutWithExpiry replaces an entry like Put and makes the key expire at expiresAt.
+	// An expired key reads as not found. It returns an EntryError if expiresAt is
+	// not in the future.
+	PutWithExpiry(ctx context.Context, key string, entry []byte, expiresAt time.Time) error
+}
+
+// TimestampPutter is implemented by backends that record when an entry was written
+// and can be told that time explicitly, for instance when importing historical data.
+type TimestampPutter interface {
+	// PutAt writes an entry like Put, recording ts as its write time instead of now.
+	PutAt(ctx context.Context, key string, entry []byte, ts time.Time) error
+}
+
 type (
 	LocationError    string
 	KeyError         string
 	EntryError       string
 	OpsInternalError string
 	KeyNotFoundError string
+	UnsupportedError string
+	ConflictError    string
+	ClosedError      string
+	PermissionError  string
+	// TimeoutError reports an operation abandoned because its context was cancelled
+	// or its deadline expired. It wraps the context's error, so errors.Is matches
+	// context.DeadlineExceeded or context.Canceled as well.
+	TimeoutError string
+	// PreconditionError reports a write refused because a condition it was made
+	// on did not hold.
+	PreconditionError string
+	// ImmutableError reports a write to a key made immutable by Finalize.
+	ImmutableError string
+	// VersionLimitError reports a Put refused because the key already holds as
+	// many entries as NewVersionCapOps allows.
+	VersionLimitError string
+	// FencedError reports a PutFenced refused because a write with a higher fence
+	// token was already applied to the key.
+	FencedError string
 )
 
 func (e LocationError) Error() string {
@@ -49,3 +149,30 @@ func (e OpsInternalError) Error() string {
 func (e KeyNotFoundError) Error() string {
 	return "libstore: " + string(e)
 }
+func (e UnsupportedError) Error() string {
+	return "libstore: " + string(e)
+}
+func (e ConflictError) Error() string {
+	return "libstore: " + string(e)
+}
+func (e ClosedError) Error() string {
+	return "libstore: " + string(e)
+}
+func (e PermissionError) Error() string {
+	return "libstore: " + string(e)
+}
+func (e TimeoutError) Error() string {
+	return "libstore: " + string(e)
+}
+func (e PreconditionError) Error() string {
+	return "libstore: " + string(e)
+}
+func (e ImmutableError) Error() string {
+	return "libstore: " + string(e)
+}
+func (e VersionLimitError) Error() string {
+	return "libstore: " + string(e)
+}
+func (e FencedError) Error() string {
+	return "libstore: " + string(e)
+}
@@ -0,0 +1,61 @@
+package libstore
+
+import (
+	"context"
+)
+
+// OpOption configures a single operation.
+//
+// Options travel in the context, so they pass unchanged through wrappers that only
+// know the Ops interface. Backends that do not support an option ignore it.
+type OpOption func(*opOptions)
+
+type opOptions struct {
+	contentType    string
+	durable        bool
+	consistency    Consistency
+	failOnExisting bool
+	deterministic  bool
+	listLimit      int
+}
+
+type opOptionsKey struct{}
+
+// WithOpOptions returns a copy of ctx carrying the given options for the operations
+// performed with it. Options already present in ctx are kept unless overridden.
+func WithOpOptions(ctx context.Context, opts ...OpOption) context.Context {
+	o := opOptionsFrom(ctx)
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return context.WithValue(ctx, opOptionsKey{}, o)
+}
+
+func opOptionsFrom(ctx context.Context) opOptions {
+	o, _ := ctx.Value(opOptionsKey{}).(opOptions)
+	return o
+}
+
+// ContentType sets the MIME type recorded with the entry written by Put.
+// It is a no-op for backends that do not track content types.
+func ContentType(contentType string) OpOption {
+	return func(o *opOptions) {
+		o.contentType = contentType
+	}
+}
+
+// Durable asks Put to make the entry durable before returning, at the cost of
+// latency. The file and log file backends sync the file after the write, and the
+// database backend commits the transaction with synchronous_commit on. Other
+// backends ignore it.
+func Durable() OpOption {
+	return func(o *opOptions) {
+		o.durable = true
+	}
+}
+
+// ContentTypeReader is implemented by backends that record the content type of entries.
+type ContentTypeReader interface {
+	// ReadContentType returns the content type of the latest entry of the given key.
+	ReadContentType(ctx context.Context, key string) (string, error)
+}
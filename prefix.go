@@ -0,0 +1,69 @@
+package libstore
+
+import (
+	"context"
+	"strings"
+)
+
+// prefixOps scopes the underlying Ops to the keys under a prefix.
+type prefixOps struct {
+	ops    Ops
+	prefix string
+}
+
+// NewPrefixOps wraps ops so that every key is stored under prefix. Callers see keys
+// without the prefix, and List only returns the keys stored under it, so the returned
+// Ops cannot reach keys outside its namespace.
+//
+// The prefix is prepended as is; include a separator such as "/" to keep namespaces
+// like "a" and "ab" apart.
+func NewPrefixOps(ops Ops, prefix string) Ops {
+	return prefixOps{ops: ops, prefix: prefix}
+}
+
+// Unwrap implements Unwrapper.
+func (p prefixOps) Unwrap() Ops {
+	return p.ops
+}
+
+// Create implements Ops.
+func (p prefixOps) Create(ctx context.Context, key string) error {
+	return p.ops.Create(ctx, p.prefix+key)
+}
+
+// ReadAll implements Ops.
+func (p prefixOps) ReadAll(ctx context.Context, key string) ([][]byte, error) {
+	return p.ops.ReadAll(ctx, p.prefix+key)
+}
+
+// Read implements Ops.
+func (p prefixOps) Read(ctx context.Context, key string) ([]byte, error) {
+	return p.ops.Read(ctx, p.prefix+key)
+}
+
+// Put implements Ops.
+func (p prefixOps) Put(ctx context.Context, key string, entry []byte) error {
+	return p.ops.Put(ctx, p.prefix+key, entry)
+}
+
+// Delete implements Ops.
+func (p prefixOps) Delete(ctx context.Context, key string) error {
+	return p.ops.Delete(ctx, p.prefix+key)
+}
+
+// List implements Ops.
+func (p prefixOps) List(ctx context.Context) ([]string, error) {
+	stored, err := p.ops.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, name := range stored {
+		if key, ok := strings.CutPrefix(name, p.prefix); ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+var _ Ops = prefixOps{}
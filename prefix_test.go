@@ -0,0 +1,50 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestPrefixOps(t *testing.T) {
+	backend := libstore.NewInMemoryOps()
+	if err := backend.Create(context.TODO(), "outside"); err != nil {
+		t.Fatal(err)
+	}
+	ops := libstore.NewPrefixOps(backend, "tenant/")
+
+	if err := ops.Create(context.TODO(), "key"); err != nil {
+		t.Fatalf("Error creating key: %v", err)
+	}
+	if err := ops.Put(context.TODO(), "key", []byte("value")); err != nil {
+		t.Fatalf("Error putting entry: %v", err)
+	}
+	if value, err := backend.Read(context.TODO(), "tenant/key"); err != nil || string(value) != "value" {
+		t.Errorf("Expected the entry under the prefix, Got: %q, %v", value, err)
+	}
+	if value, err := ops.Read(context.TODO(), "key"); err != nil || string(value) != "value" {
+		t.Errorf("Unexpected Read: %q, %v", value, err)
+	}
+	if entries, err := ops.ReadAll(context.TODO(), "key"); err != nil || len(entries) != 1 {
+		t.Errorf("Unexpected ReadAll: %q, %v", entries, err)
+	}
+
+	keys, err := ops.List(context.TODO())
+	if err != nil || !slices.Equal(keys, []string{"key"}) {
+		t.Errorf("Expected only the keys under the prefix, Got: %v, %v", keys, err)
+	}
+
+	var notFound libstore.KeyNotFoundError
+	if _, err := ops.Read(context.TODO(), "outside"); !errors.As(err, &notFound) {
+		t.Errorf("Expected keys outside the prefix to be unreachable, Got: %v", err)
+	}
+	if err := ops.Delete(context.TODO(), "key"); err != nil {
+		t.Fatalf("Error deleting key: %v", err)
+	}
+	if _, err := backend.Read(context.TODO(), "tenant/key"); !errors.As(err, &notFound) {
+		t.Errorf("Expected Delete to remove the prefixed key, Got: %v", err)
+	}
+}
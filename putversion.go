@@ -0,0 +1,31 @@
+package libstore
+
+import (
+	"context"
+	"fmt"
+)
+
+// VersionPutter is implemented by backends that number the entries of a key and can
+// be told the number of a new entry.
+type VersionPutter interface {
+	// PutAtVersion writes entry to key as the given version instead of the next one.
+	// It returns a KeyNotFoundError if the key does not exist and a ConflictError if
+	// the key already has an entry at that version.
+	PutAtVersion(ctx context.Context, key string, version int64, entry []byte) error
+}
+
+// PutAtVersion writes entry to key as the given version, for instance to import the
+// history of another system with its version numbers intact. Versions may be written
+// in any order and with gaps; ReadAll returns the entries in version order.
+//
+// It returns an EntryError if version is not positive and an UnsupportedError if ops
+// does not implement VersionPutter.
+func PutAtVersion(ctx context.Context, ops Ops, key string, version int64, entry []byte) error {
+	if version < 1 {
+		return EntryError(fmt.Sprintf("version must be positive, got %d", version))
+	}
+	if putter, ok := ops.(VersionPutter); ok {
+		return putter.PutAtVersion(ctx, key, version, entry)
+	}
+	return UnsupportedError("PutAtVersion is not supported by this backend")
+}
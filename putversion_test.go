@@ -0,0 +1,25 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestPutAtVersionErrors(t *testing.T) {
+	ops := libstore.NewInMemoryOps()
+	if err := ops.Create(context.TODO(), "key"); err != nil {
+		t.Fatal(err)
+	}
+
+	var unsupported libstore.UnsupportedError
+	if err := libstore.PutAtVersion(context.TODO(), ops, "key", 1, []byte("entry")); !errors.As(err, &unsupported) {
+		t.Errorf("Expected an UnsupportedError, Got: %v", err)
+	}
+	var entryErr libstore.EntryError
+	if err := libstore.PutAtVersion(context.TODO(), ops, "key", 0, []byte("entry")); !errors.As(err, &entryErr) {
+		t.Errorf("Expected an EntryError for version 0, Got: %v", err)
+	}
+}
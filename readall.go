@@ -0,0 +1,64 @@
+package libstore
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// BulkReader is implemented by backends that can read the latest entry of every key
+// more efficiently than one Read per key.
+type BulkReader interface {
+	// ReadAllKeys returns the latest entry of every key. Keys without entries are omitted.
+	ReadAllKeys(ctx context.Context) (map[string][]byte, error)
+}
+
+// ReadAllKeys returns the latest entry of every key of ops. Keys without entries are omitted.
+//
+// Backends implementing BulkReader answer in one pass; for the others the keys are
+// listed and read one at a time.
+func ReadAllKeys(ctx context.Context, ops Ops) (map[string][]byte, error) {
+	if reader, ok := ops.(BulkReader); ok {
+		return reader.ReadAllKeys(ctx)
+	}
+	return readKeys(ctx, ops, 1)
+}
+
+// readKeys lists the keys of ops and reads them with up to concurrency reads in flight.
+// Keys that have no entries, or are deleted while listing, are omitted.
+func readKeys(ctx context.Context, ops Ops, concurrency int) (map[string][]byte, error) {
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(concurrency, 1))
+
+	var mu sync.Mutex
+	values := map[string][]byte{}
+	for key, err := range ListSeq(ctx, ops) {
+		if err != nil {
+			if werr := g.Wait(); werr != nil {
+				return nil, werr
+			}
+			return nil, err
+		}
+		g.Go(func() error {
+			value, err := ops.Read(ctx, key)
+			var entryErr EntryError
+			var notFoundErr KeyNotFoundError
+			if errors.As(err, &entryErr) || errors.As(err, &notFoundErr) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			values[key] = value
+			mu.Unlock()
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
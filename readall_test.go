@@ -0,0 +1,44 @@
+package libstore_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestReadAllKeys(t *testing.T) {
+	ctx := context.Background()
+	fileOps, err := libstore.NewFileOps(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	backends := map[string]libstore.Ops{
+		"memory": libstore.NewInMemoryOps(),
+		// recordingOps hides the BulkReader of the in-memory store, exercising the fallback.
+		"fallback": newRecordingOps(libstore.NewInMemoryOps()),
+		"file":     fileOps,
+	}
+	for name, ops := range backends {
+		t.Run(name, func(t *testing.T) {
+			for _, key := range []string{"a", "b", "empty"} {
+				if err := ops.Create(ctx, key); err != nil {
+					t.Fatalf("Error creating key: %v", err)
+				}
+			}
+			for _, put := range [][2]string{{"a", "a1"}, {"a", "a2"}, {"b", "b1"}} {
+				if err := ops.Put(ctx, put[0], []byte(put[1])); err != nil {
+					t.Fatalf("Error putting entry: %v", err)
+				}
+			}
+
+			values, err := libstore.ReadAllKeys(ctx, ops)
+			if err != nil {
+				t.Fatalf("Error reading all keys: %v", err)
+			}
+			if len(values) != 2 || string(values["a"]) != "a2" || string(values["b"]) != "b1" {
+				t.Errorf("Unexpected values: %q", values)
+			}
+		})
+	}
+}
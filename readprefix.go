@@ -0,0 +1,41 @@
+package libstore
+
+import "context"
+
+// PrefixReader is implemented by backends that can read the start of the latest entry
+// of a key without transferring the rest of it.
+type PrefixReader interface {
+	// ReadPrefix returns the first n bytes of the latest entry of key, or the whole
+	// entry if it is shorter. It fails like Read for a missing key or a key without
+	// entries.
+	ReadPrefix(ctx context.Context, key string, n int) ([]byte, error)
+}
+
+// ReadPrefix returns the first n bytes of the latest entry of key, or the whole entry
+// if it is shorter, for instance to peek at a header or a leading JSON field of a
+// large value. It returns an EntryError if n is not positive.
+//
+// Backends implementing PrefixReader transfer only the prefix. For the others the
+// entry is read in full with Read and cut.
+func ReadPrefix(ctx context.Context, ops Ops, key string, n int) ([]byte, error) {
+	if err := checkPrefixLength(n); err != nil {
+		return nil, err
+	}
+	if reader, ok := ops.(PrefixReader); ok {
+		return reader.ReadPrefix(ctx, key, n)
+	}
+	entry, err := ops.Read(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return entry[:min(n, len(entry))], nil
+}
+
+// checkPrefixLength returns the EntryError of ReadPrefix if n is not positive.
+// Implementations of PrefixReader check it too, as they can be called directly.
+func checkPrefixLength(n int) error {
+	if n < 1 {
+		return EntryError("prefix length must be positive")
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestReadPrefix(t *testing.T) {
+	backends := testBackends(t, "InMemory", "S3", "File", "Fallback")
+	backends["DB"] = func(t *testing.T) libstore.Ops {
+		return libstore.NewPrefixOps(newTestDBOps(t), testKey(t)+"/")
+	}
+
+	for name, newOps := range backends {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			ops := newOps(t)
+			var notFound libstore.KeyNotFoundError
+			if _, err := libstore.ReadPrefix(ctx, ops, "missing", 4); !errors.As(err, &notFound) {
+				t.Errorf("ReadPrefix() on missing key error = %v, want KeyNotFoundError", err)
+			}
+
+			if err := ops.Create(ctx, "key"); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			var entryErr libstore.EntryError
+			if _, err := libstore.ReadPrefix(ctx, ops, "key", 4); !errors.As(err, &entryErr) {
+				t.Errorf("ReadPrefix() on empty key error = %v, want EntryError", err)
+			}
+
+			if err := ops.Put(ctx, "key", []byte(`{"kind":"old"}`)); err != nil {
+				t.Fatalf("Put() error = %v", err)
+			}
+			if err := ops.Put(ctx, "key", []byte(`{"kind":"report","body":"..."}`)); err != nil {
+				t.Fatalf("Put() error = %v", err)
+			}
+			got, err := libstore.ReadPrefix(ctx, ops, "key", 16)
+			if err != nil || string(got) != `{"kind":"report"` {
+				t.Errorf("ReadPrefix() = %q, %v, want %q", got, err, `{"kind":"report"`)
+			}
+			got, err = libstore.ReadPrefix(ctx, ops, "key", 1000)
+			if err != nil || string(got) != `{"kind":"report","body":"..."}` {
+				t.Errorf("ReadPrefix() beyond the entry = %q, %v, want the whole entry", got, err)
+			}
+			if _, err := libstore.ReadPrefix(ctx, ops, "key", 0); !errors.As(err, &entryErr) {
+				t.Errorf("ReadPrefix() with n = 0 error = %v, want EntryError", err)
+			}
+			if reader, ok := ops.(libstore.PrefixReader); ok {
+				for _, n := range []int{0, -1} {
+					if _, err := reader.ReadPrefix(ctx, "key", n); !errors.As(err, &entryErr) {
+						t.Errorf("PrefixReader.ReadPrefix() with n = %d error = %v, want EntryError", n, err)
+					}
+				}
+			}
+		})
+	}
+}
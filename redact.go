@@ -0,0 +1,36 @@
+package libstore
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// keyHashSize is the number of bytes of the HMAC that HashKeys keeps.
+const keyHashSize = 16
+
+// KeyRedactor turns a key into the form the logs, metrics and traces of libstore
+// emit for it, so telemetry can leave out keys that hold personal data such as
+// e-mail addresses or user IDs. Telemetry options take a KeyRedactor and default to
+// FullKeys.
+type KeyRedactor func(key string) string
+
+// FullKeys is the KeyRedactor emitting keys as they are, which is convenient during
+// development.
+func FullKeys(key string) string {
+	return key
+}
+
+// HashKeys returns a KeyRedactor emitting the HMAC-SHA256 of a key under salt,
+// truncated to 16 bytes and hex-encoded. A key always hashes to the same value, so
+// the telemetry of a key can be correlated, but without salt it cannot be traced
+// back to the key, even for keys guessable enough to defeat an unsalted hash. Keep
+// salt as secret as the keys.
+func HashKeys(salt []byte) KeyRedactor {
+	salt = append([]byte(nil), salt...)
+	return func(key string) string {
+		mac := hmac.New(sha256.New, salt)
+		mac.Write([]byte(key))
+		return hex.EncodeToString(mac.Sum(nil)[:keyHashSize])
+	}
+}
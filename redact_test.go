@@ -0,0 +1,72 @@
+package libstore_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestHashKeys(t *testing.T) {
+	const key = "alice@example.com"
+	redact := libstore.HashKeys([]byte("salt"))
+
+	hashed := redact(key)
+	if strings.Contains(hashed, "alice") || strings.Contains(hashed, "example") {
+		t.Errorf("Expected the key to be hidden, Got: %s", hashed)
+	}
+	if again := redact(key); again != hashed {
+		t.Errorf("Expected the same hash for the same key, Got: %s and %s", hashed, again)
+	}
+	if other := redact("bob@example.com"); other == hashed {
+		t.Errorf("Expected different keys to hash differently, Got: %s for both", hashed)
+	}
+	if salted := libstore.HashKeys([]byte("pepper"))(key); salted == hashed {
+		t.Errorf("Expected the hash to depend on the salt, Got: %s for both", hashed)
+	}
+	// HMAC-SHA256("salt", "alice@example.com"), truncated.
+	if want := "771672a85fbac3a09ea3559eb6dcbca6"; hashed != want {
+		t.Errorf("Expected %s, Got: %s", want, hashed)
+	}
+
+	if full := libstore.FullKeys(key); full != key {
+		t.Errorf("Expected FullKeys to keep the key, Got: %s", full)
+	}
+}
+
+func TestFileLogKeys(t *testing.T) {
+	ctx := context.Background()
+	const key = "alice@example.com"
+	var logs bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	// The mmap fallback is the log line naming a key.
+	libstore.FailMapFile(t, errors.New("mapping disabled"))
+
+	redact := libstore.HashKeys([]byte("salt"))
+	ops, err := libstore.NewFileOps(t.TempDir(), libstore.WithMmapThreshold(1), libstore.WithFileLogKeys(redact))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ops.Create(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if err := ops.Put(ctx, key, []byte("entry")); err != nil {
+		t.Fatal(err)
+	}
+	if entry, err := ops.Read(ctx, key); err != nil || string(entry) != "entry" {
+		t.Fatalf("Read() = %q, %v", entry, err)
+	}
+
+	if !strings.Contains(logs.String(), "key="+redact(key)) {
+		t.Errorf("Expected the log to name the redacted key, Got: %s", logs.String())
+	}
+	if strings.Contains(logs.String(), "alice") {
+		t.Errorf("Expected the log not to hold the key, Got: %s", logs.String())
+	}
+}
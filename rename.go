@@ -0,0 +1,23 @@
+package libstore
+
+import (
+	"context"
+)
+
+// Renamer is implemented by backends that can rename a key in place.
+type Renamer interface {
+	// Rename moves every entry of oldKey, with its version and write time, to newKey.
+	// It returns a KeyNotFoundError if oldKey does not exist and a KeyError if newKey
+	// already exists.
+	Rename(ctx context.Context, oldKey, newKey string) error
+}
+
+// Rename renames oldKey to newKey, keeping its whole history, which is far cheaper
+// than copying a long history and deleting the original. It returns an
+// UnsupportedError if ops does not implement Renamer.
+func Rename(ctx context.Context, ops Ops, oldKey, newKey string) error {
+	if renamer, ok := ops.(Renamer); ok {
+		return renamer.Rename(ctx, oldKey, newKey)
+	}
+	return UnsupportedError("Rename is not supported by this backend")
+}
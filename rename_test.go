@@ -0,0 +1,20 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestRenameUnsupported(t *testing.T) {
+	ops := libstore.NewInMemoryOps()
+	if err := ops.Create(context.TODO(), "old"); err != nil {
+		t.Fatal(err)
+	}
+	var unsupported libstore.UnsupportedError
+	if err := libstore.Rename(context.TODO(), ops, "old", "new"); !errors.As(err, &unsupported) {
+		t.Errorf("Expected an UnsupportedError, Got: %v", err)
+	}
+}
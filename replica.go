@@ -0,0 +1,102 @@
+package libstore
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+// readReplicaOps sends writes to a primary and balances reads across replicas.
+type readReplicaOps struct {
+	primary  Ops
+	replicas []Ops
+	next     *atomic.Uint64
+}
+
+// NewReadReplicaOps returns an Ops that routes Create, Put and Delete to primary and
+// distributes Read, ReadAll and List round-robin across replicas. A read that fails
+// on a replica is retried on primary, unless its context is done or it failed with a
+// KeyNotFoundError or a TimeoutError. Without replicas every operation goes to
+// primary.
+//
+// Replicas are expected to lag behind primary. A read issued right after a write may
+// be served by a replica that has not applied it yet and return the previous value,
+// or a KeyNotFoundError for a key just created; callers needing read-after-write
+// consistency should read from primary directly.
+func NewReadReplicaOps(primary Ops, replicas ...Ops) Ops {
+	return readReplicaOps{primary: primary, replicas: replicas, next: &atomic.Uint64{}}
+}
+
+// Drain implements Drainer, draining primary and every replica.
+func (r readReplicaOps) Drain(ctx context.Context) error {
+	errs := []error{Drain(ctx, r.primary)}
+	for _, replica := range r.replicas {
+		errs = append(errs, Drain(ctx, replica))
+	}
+	return errors.Join(errs...)
+}
+
+// replica returns the replica serving the next read, or primary if there are none.
+// It reports whether the returned Ops is a replica.
+func (r readReplicaOps) replica() (Ops, bool) {
+	if len(r.replicas) == 0 {
+		return r.primary, false
+	}
+	return r.replicas[(r.next.Add(1)-1)%uint64(len(r.replicas))], true
+}
+
+// fallsBack reports whether a read that failed on a replica with err is retried on
+// primary. Reads whose caller gave up are not, nor reads of keys the replica does not
+// hold, which would send every miss to primary.
+func fallsBack(ctx context.Context, err error) bool {
+	var notFound KeyNotFoundError
+	var timeout TimeoutError
+	return err != nil && ctx.Err() == nil && !errors.As(err, &notFound) && !errors.As(err, &timeout)
+}
+
+// Create implements Ops.
+func (r readReplicaOps) Create(ctx context.Context, key string) error {
+	return r.primary.Create(ctx, key)
+}
+
+// ReadAll implements Ops.
+func (r readReplicaOps) ReadAll(ctx context.Context, key string) ([][]byte, error) {
+	replica, isReplica := r.replica()
+	res, err := replica.ReadAll(ctx, key)
+	if isReplica && fallsBack(ctx, err) {
+		return r.primary.ReadAll(ctx, key)
+	}
+	return res, err
+}
+
+// Read implements Ops.
+func (r readReplicaOps) Read(ctx context.Context, key string) ([]byte, error) {
+	replica, isReplica := r.replica()
+	res, err := replica.Read(ctx, key)
+	if isReplica && fallsBack(ctx, err) {
+		return r.primary.Read(ctx, key)
+	}
+	return res, err
+}
+
+// Put implements Ops.
+func (r readReplicaOps) Put(ctx context.Context, key string, entry []byte) error {
+	return r.primary.Put(ctx, key, entry)
+}
+
+// Delete implements Ops.
+func (r readReplicaOps) Delete(ctx context.Context, key string) error {
+	return r.primary.Delete(ctx, key)
+}
+
+// List implements Ops.
+func (r readReplicaOps) List(ctx context.Context) ([]string, error) {
+	replica, isReplica := r.replica()
+	res, err := replica.List(ctx)
+	if isReplica && fallsBack(ctx, err) {
+		return r.primary.List(ctx)
+	}
+	return res, err
+}
+
+var _ Ops = readReplicaOps{}
@@ -0,0 +1,88 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestReadReplicaOps(t *testing.T) {
+	primary := newRecordingOps(libstore.NewInMemoryOps())
+	replica1 := newRecordingOps(libstore.NewInMemoryOps())
+	replica2 := newRecordingOps(libstore.NewInMemoryOps())
+	ops := libstore.NewReadReplicaOps(primary, replica1, replica2)
+
+	// Simulate replication of the key to both replicas.
+	for _, o := range []libstore.Ops{ops, replica1.Ops, replica2.Ops} {
+		if err := o.Create(context.TODO(), "key"); err != nil {
+			t.Fatalf("Error creating key: %v", err)
+		}
+		if err := o.Put(context.TODO(), "key", []byte("value")); err != nil {
+			t.Fatalf("Error putting entry: %v", err)
+		}
+	}
+	if primary.count("Create") != 1 || primary.count("Put") != 1 {
+		t.Errorf("Expected writes to go to primary, Got: %v", primary.calls)
+	}
+	if replica1.count("Create")+replica1.count("Put")+replica2.count("Create")+replica2.count("Put") != 0 {
+		t.Error("Expected no writes to be routed to replicas")
+	}
+
+	for i := 0; i < 4; i++ {
+		if _, err := ops.Read(context.TODO(), "key"); err != nil {
+			t.Fatalf("Error reading key: %v", err)
+		}
+	}
+	if replica1.count("Read") != 2 || replica2.count("Read") != 2 || primary.count("Read") != 0 {
+		t.Errorf("Expected reads to alternate between replicas, Got: %d, %d, primary %d",
+			replica1.count("Read"), replica2.count("Read"), primary.count("Read"))
+	}
+
+	// A key missing on a lagging replica is not looked up on primary.
+	if err := ops.Create(context.TODO(), "fresh"); err != nil {
+		t.Fatalf("Error creating key: %v", err)
+	}
+	var notFound libstore.KeyNotFoundError
+	if _, err := ops.Read(context.TODO(), "fresh"); !errors.As(err, &notFound) || primary.count("Read") != 0 {
+		t.Errorf("Expected the replica's KeyNotFoundError, Got: %v after %d primary reads", err, primary.count("Read"))
+	}
+}
+
+// failingReadOps fails every read with err.
+type failingReadOps struct {
+	libstore.Ops
+	err error
+}
+
+func (f failingReadOps) Read(ctx context.Context, key string) ([]byte, error) {
+	return nil, f.err
+}
+
+func TestReadReplicaOpsFallback(t *testing.T) {
+	primary := newRecordingOps(libstore.NewInMemoryOps())
+	if err := primary.Create(context.TODO(), "key"); err != nil {
+		t.Fatal(err)
+	}
+	if err := primary.Put(context.TODO(), "key", []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+
+	unavailable := libstore.NewReadReplicaOps(primary, failingReadOps{libstore.NewInMemoryOps(), libstore.OpsInternalError("replica unavailable")})
+	if value, err := unavailable.Read(context.TODO(), "key"); err != nil || string(value) != "value" || primary.count("Read") != 1 {
+		t.Errorf("Expected a failed replica read to fall back to primary, Got: %q, %v", value, err)
+	}
+
+	timedOut := libstore.NewReadReplicaOps(primary, failingReadOps{libstore.NewInMemoryOps(), libstore.TimeoutError("replica timed out")})
+	var timeoutErr libstore.TimeoutError
+	if _, err := timedOut.Read(context.TODO(), "key"); !errors.As(err, &timeoutErr) || primary.count("Read") != 1 {
+		t.Errorf("Expected a timed out replica read not to reach primary, Got: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := unavailable.Read(ctx, "key"); err == nil || primary.count("Read") != 1 {
+		t.Errorf("Expected a cancelled read not to reach primary, Got: %v", err)
+	}
+}
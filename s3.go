@@ -1,22 +1,303 @@
 package libstore
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"golang.org/x/sync/errgroup"
 )
 
+// defaultMultipartThreshold is the entry size above which Put uses a multipart upload.
+const defaultMultipartThreshold = 64 * 1024 * 1024
+
+// readAllKeysConcurrency is the number of GetObject calls ReadAllKeys keeps in flight.
+const readAllKeysConcurrency = 16
+
+// createBatchConcurrency is the number of PutObject calls CreateBatch keeps in flight.
+const createBatchConcurrency = 16
+
 // S3Ops provides operations for AWS S3 bucket interactions.
+//
+// By default a key is a single object and Put replaces it, so the object holds only
+// the latest entry: Read and ReadAll cost one GET, Put one PUT of the entry alone,
+// and earlier entries are not kept. With WithNativeVersioning every Put adds an
+// object version instead, and ReadAll stitches the versions back together. No Put
+// ever rewrites earlier entries, at the cost of a ListObjectVersions call and one GET
+// per version on ReadAll, and of storage for every version until the key is purged.
+// Neither layout appends to an object, so objects never need compacting.
 type S3Ops struct {
 	s3Client *s3.Client
 	bucket   string
+
+	multipartThreshold   int64
+	multipartPartSize    int64
+	multipartConcurrency int
+
+	nativeVersioning bool
+	purgeOnDelete    bool
+
+	listPageSize int32
+
+	clientOptions []func(*s3.Options)
+	stats         *s3Counters
+	lazy          bool
+	contextTags   []S3TagKey
+	preserveTags  bool
+
+	readConsistency Consistency
+}
+
+// s3MaxListKeys is the most keys S3 returns in one ListObjectsV2 page.
+const s3MaxListKeys = 1000
+
+// S3Option configures an S3Ops instance.
+type S3Option func(*S3Ops)
+
+// WithMultipartThreshold sets the entry size in bytes above which Put switches from a
+// single PutObject to a multipart upload. It defaults to 64 MiB.
+func WithMultipartThreshold(threshold int64) S3Option {
+	return func(s *S3Ops) {
+		s.multipartThreshold = threshold
+	}
+}
+
+// WithMultipartPartSize sets the size in bytes of each part of a multipart upload.
+// It defaults to the SDK's manager.DefaultUploadPartSize.
+func WithMultipartPartSize(partSize int64) S3Option {
+	return func(s *S3Ops) {
+		s.multipartPartSize = partSize
+	}
+}
+
+// WithMultipartConcurrency sets the number of parts of a multipart upload sent in parallel.
+// It defaults to the SDK's manager.DefaultUploadConcurrency.
+func WithMultipartConcurrency(concurrency int) S3Option {
+	return func(s *S3Ops) {
+		s.multipartConcurrency = concurrency
+	}
+}
+
+// WithNativeVersioning makes S3Ops use the bucket's object versions as the entries of
+// a key: ReadAll returns the versions written since the key was last created, oldest
+// first, and Delete leaves a delete marker. NewS3Ops fails if versioning is not enabled
+// on the bucket.
+func WithNativeVersioning() S3Option {
+	return func(s *S3Ops) {
+		s.nativeVersioning = true
+	}
+}
+
+// WithPurgeOnDelete makes Delete remove every version and delete marker of a key
+// instead of adding a delete marker.
+func WithPurgeOnDelete() S3Option {
+	return func(s *S3Ops) {
+		s.purgeOnDelete = true
+	}
+}
+
+// WithListPageSize sets the number of keys requested per ListObjectsV2 page by List,
+// ListSeq and ListModifiedSince. It defaults to the S3 maximum of 1000; smaller pages
+// bound the memory held per request.
+func WithListPageSize(size int32) S3Option {
+	return func(s *S3Ops) {
+		s.listPageSize = size
+	}
+}
+
+// ListLimit caps the number of keys returned by the List it is passed to: the S3
+// backend stops requesting pages once it holds limit keys, returning the first keys
+// in order. A limit of 0 lists every key. Other backends ignore it and list every
+// key, so a caller passing it must not take a short listing for a complete one.
+func ListLimit(limit int) OpOption {
+	return func(o *opOptions) {
+		o.listLimit = limit
+	}
+}
+
+// WithS3ClientOptions applies fn to the options of the S3 client, for instance to set
+// BaseEndpoint and UsePathStyle for an S3-compatible service.
+func WithS3ClientOptions(fn func(*s3.Options)) S3Option {
+	return func(s *S3Ops) {
+		s.clientOptions = append(s.clientOptions, fn)
+	}
+}
+
+// WithS3LazyConnect makes NewS3Ops return without contacting S3. The bucket is then
+// checked before the first request, and the operation sending it returns the error
+// NewS3Ops would have returned. A failed check is reported again by the operations
+// of the next few seconds before it is retried, so an unavailable service is not
+// probed on every call.
+func WithS3LazyConnect() S3Option {
+	return func(s *S3Ops) {
+		s.lazy = true
+	}
+}
+
+// WithRequestStats makes S3Ops count the S3 requests it sends, by the request classes
+// S3 bills for, so that they can be read with Stats. Wrapping one S3Ops per tenant
+// attributes request charges to tenants.
+func WithRequestStats() S3Option {
+	return func(s *S3Ops) {
+		s.stats = &s3Counters{}
+	}
+}
+
+// s3EventualReadAttempts bounds the GetObject calls of a read that finds no object
+// with WithS3ReadConsistency(Eventual), and s3EventualReadBackoff is the pause before
+// the first retry, doubled before each next one.
+const (
+	s3EventualReadAttempts = 5
+	s3EventualReadBackoff  = 50 * time.Millisecond
+)
+
+// WithS3ReadConsistency sets the consistency S3Ops expects of the store. It defaults
+// to Strong, as S3 reads its own writes, and reads are not retried. With Eventual,
+// for S3-compatible stores that may not show an object right after it is written,
+// such as older MinIO or Ceph releases, Read and ReadAll retry a read finding no
+// object up to 4 times, pausing 50ms before the first retry and twice as long before
+// each next one. A missing key then costs 750ms before its KeyNotFoundError; reads
+// made with ReadConsistency(Strong) are not retried.
+func WithS3ReadConsistency(consistency Consistency) S3Option {
+	return func(s *S3Ops) {
+		s.readConsistency = consistency
+	}
+}
+
+// readEventually calls read, retrying it while it finds no object if s expects an
+// eventually consistent store.
+func readEventually[T any](ctx context.Context, s *S3Ops, read func(ctx context.Context) (T, error)) (T, error) {
+	value, err := read(ctx)
+	if s.readConsistency != Eventual || opOptionsFrom(ctx).consistency == Strong {
+		return value, err
+	}
+	backoff := s3EventualReadBackoff
+	for attempt := 1; attempt < s3EventualReadAttempts && isS3NotFound(err); attempt++ {
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return value, annotateTimeout("s3: waiting to retry read", ctx.Err())
+		case <-timer.C:
+		}
+		backoff *= 2
+		value, err = read(ctx)
+	}
+	return value, err
+}
+
+// isS3NotFound reports whether err reports a missing object.
+func isS3NotFound(err error) bool {
+	var notFound KeyNotFoundError
+	return errors.As(err, &notFound) || isS3ErrorCode(err, "NoSuchKey", "NotFound")
+}
+
+// S3TagKey is a context key whose value, a string, S3Ops writes as the object tag
+// named by the key when it is registered with WithS3ContextTags. Store values with
+// context.WithValue:
+//
+//	ctx = context.WithValue(ctx, libstore.S3TagKey("trace-id"), traceID)
+type S3TagKey string
+
+// s3ReservedTagPrefix starts the names of the object tags S3Ops writes for itself,
+// which are kept apart from the metadata of PutMetadata and ReadMetadata.
+const s3ReservedTagPrefix = "libstore-"
+
+// WithS3ContextTags makes every write of an object tag it with the values the
+// context of the write holds for keys, such as a trace ID or the source system,
+// recording their provenance without changing the entries. Each value is written
+// as the tag named by its key prefixed with "libstore-", beside the tags the object
+// already holds, such as those put with PutMetadata. ReadMetadata and ListWhere do
+// not see these tags and PutMetadata keeps them. Keys the context holds no string
+// value for, or an empty one, are left out. As S3 drops the tags of an object it
+// replaces, a write whose context holds none leaves the object untagged unless
+// WithS3PreserveTags is set too, in which case the tags of an earlier write stay in
+// place. The tags count towards the S3 limit of 10 tags per object.
+func WithS3ContextTags(keys ...S3TagKey) S3Option {
+	return func(s *S3Ops) {
+		s.contextTags = append(s.contextTags, keys...)
+	}
+}
+
+// WithS3PreserveTags makes the writes replacing an object, such as Put, PutFrom,
+// PutAndGetPrevious and PutFenced, carry its tags over to the new object, so that
+// the metadata of PutMetadata and the tags of WithS3ContextTags survive them. S3
+// drops the tags of a replaced object otherwise. Each such write then costs a
+// GetObjectTagging request more, and tags put by another client between that
+// request and the write are lost.
+func WithS3PreserveTags() S3Option {
+	return func(s *S3Ops) {
+		s.preserveTags = true
+	}
+}
+
+// contextTagging returns the Tagging of a PutObject request for the tags registered
+// with WithS3ContextTags found in ctx, or nil if there are none.
+func (s *S3Ops) contextTagging(ctx context.Context) *string {
+	tags := url.Values{}
+	s.setContextTags(ctx, tags)
+	return encodeTagging(tags)
+}
+
+// setContextTags sets the tags registered with WithS3ContextTags found in ctx in
+// tags, under s3ReservedTagPrefix.
+func (s *S3Ops) setContextTags(ctx context.Context, tags url.Values) {
+	for _, key := range s.contextTags {
+		if value, ok := ctx.Value(key).(string); ok && value != "" {
+			tags.Set(s3ReservedTagPrefix+string(key), value)
+		}
+	}
+}
+
+// replaceTagging returns the Tagging of a PutObject request replacing the object of
+// key: with WithS3PreserveTags, the tags the object holds, which the new object would
+// otherwise lose, with those of contextTagging set over them, and contextTagging
+// alone otherwise. A missing object holds no tags.
+func (s *S3Ops) replaceTagging(ctx context.Context, key string) (*string, error) {
+	if !s.preserveTags {
+		return s.contextTagging(ctx), nil
+	}
+	tags := url.Values{}
+	output, err := s.s3Client.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil && !isS3ErrorCode(err, "NoSuchKey", "NotFound") {
+		return nil, s3Error("failed to read tags", err)
+	}
+	if err == nil {
+		for _, tag := range output.TagSet {
+			tags.Set(aws.ToString(tag.Key), aws.ToString(tag.Value))
+		}
+	}
+	s.setContextTags(ctx, tags)
+	return encodeTagging(tags), nil
+}
+
+// encodeTagging returns tags as the Tagging of a PutObject request, or nil if there
+// are none.
+func encodeTagging(tags url.Values) *string {
+	if len(tags) == 0 {
+		return nil
+	}
+	return aws.String(tags.Encode())
 }
 
 // NewS3Ops initializes an S3Ops instance with AWS S3 client authorization.
@@ -24,10 +305,12 @@ type S3Ops struct {
 // Parameters:
 //   - ctx: Context for managing request lifecycles.
 //   - bucket: The name of the AWS S3 bucket to interact with.
+//   - opts: Options such as WithMultipartThreshold.
 //
 // Returns:
 //   - A pointer to an initialized S3Ops instance.
 //   - An error if the AWS configuration cannot be loaded or if the specified bucket cannot be accessed.
+//     With WithS3LazyConnect, the bucket is only checked by the first operation.
 //
 // Environment Variables:
 //   - AWS_ACCESS_KEY_ID: AWS access key ID.
@@ -36,28 +319,79 @@ type S3Ops struct {
 //
 // Note:
 // These environment variables are required for the AWS SDK to authenticate and perform operations on the S3 bucket.
-func NewS3Ops(ctx context.Context, bucket string) (*S3Ops, error) {
+func NewS3Ops(ctx context.Context, bucket string, opts ...S3Option) (*S3Ops, error) {
 	// Load the default configuration.
 	cfg, err := config.LoadDefaultConfig(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("%w: %w", LocationError("failed to load AWS configuration"), err)
 	}
 
+	s := &S3Ops{
+		bucket:             bucket,
+		multipartThreshold: defaultMultipartThreshold,
+		readConsistency:    Strong,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
 	// Create an S3 client using the loaded configuration
-	s3Client := s3.NewFromConfig(cfg)
+	clientOptions := s.clientOptions
+	if s.stats != nil {
+		clientOptions = append(clientOptions, func(o *s3.Options) {
+			o.APIOptions = append(o.APIOptions, s.stats.register)
+		})
+	}
+	if !s.lazy {
+		s.s3Client = s3.NewFromConfig(cfg, clientOptions...)
+		if err := s.checkBucket(ctx, s.s3Client); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
 
-	// Check if the bucket exists and is accessible
-	_, err = s3Client.HeadBucket(ctx, &s3.HeadBucketInput{
-		Bucket: aws.String(bucket),
+	// The bucket is checked with a client of its own, so the check does not wait
+	// for itself.
+	probe := s3.NewFromConfig(cfg, clientOptions...)
+	init := newLazyInit("s3", func(ctx context.Context) error {
+		return s.checkBucket(ctx, probe)
+	})
+	s.s3Client = s3.NewFromConfig(cfg, append(clientOptions, func(o *s3.Options) {
+		o.APIOptions = append(o.APIOptions, func(stack *middleware.Stack) error {
+			return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("libstoreLazyConnect",
+				func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+					if err := init.wait(ctx); err != nil {
+						return middleware.InitializeOutput{}, middleware.Metadata{}, err
+					}
+					return next.HandleInitialize(ctx, in)
+				}), middleware.Before)
+		})
+	})...)
+	return s, nil
+}
+
+// checkBucket checks that the bucket exists and is accessible with client, and
+// that versioning is enabled on it if WithNativeVersioning is set.
+func (s *S3Ops) checkBucket(ctx context.Context, client *s3.Client) error {
+	_, err := client.HeadBucket(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(s.bucket),
 	})
 	if err != nil {
-		return nil, fmt.Errorf("%w: %w", LocationError("failed to access S3 bucket"), err)
+		return fmt.Errorf("%w: %w", LocationError("failed to access S3 bucket"), err)
 	}
 
-	return &S3Ops{
-		s3Client: s3Client,
-		bucket:   bucket,
-	}, nil
+	if s.nativeVersioning {
+		output, err := client.GetBucketVersioning(ctx, &s3.GetBucketVersioningInput{
+			Bucket: aws.String(s.bucket),
+		})
+		if err != nil {
+			return fmt.Errorf("%w: %w", LocationError("failed to get bucket versioning"), err)
+		}
+		if output.Status != types.BucketVersioningStatusEnabled {
+			return LocationError("versioning is not enabled on bucket " + s.bucket)
+		}
+	}
+	return nil
 }
 
 // Create creates a new key in S3.
@@ -75,34 +409,45 @@ func (s *S3Ops) Create(ctx context.Context, key string) error {
 	// If the error is not a "Not Found" error, return an OpsInternalError
 	var nfe *types.NotFound
 	if !errors.As(err, &nfe) {
-		return fmt.Errorf("%w: %w", OpsInternalError("failed to check if key exists"), err)
+		return s3Error("failed to check if key exists", err)
 	}
 
 	// Create an empty object
 	_, err = s.s3Client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket: aws.String(s.bucket),
-		Key:    aws.String(key),
-		Body:   strings.NewReader(""),
+		Bucket:  aws.String(s.bucket),
+		Key:     aws.String(key),
+		Body:    strings.NewReader(""),
+		Tagging: s.contextTagging(ctx),
 	})
 	if err != nil {
-		return fmt.Errorf("%w: %w", OpsInternalError("failed to create key"), err)
+		return s3Error("failed to create key", err)
 	}
 
 	return nil
 }
 
 // ReadAll reads the entire content of the given key.
+// With WithNativeVersioning it reads every version of the key instead.
 func (s *S3Ops) ReadAll(ctx context.Context, key string) ([][]byte, error) {
+	return readEventually(ctx, s, func(ctx context.Context) ([][]byte, error) {
+		return s.readAll(ctx, key)
+	})
+}
+
+// readAll implements ReadAll without retries.
+func (s *S3Ops) readAll(ctx context.Context, key string) ([][]byte, error) {
+	if s.nativeVersioning {
+		return s.readAllVersions(ctx, key)
+	}
 	output, err := s.s3Client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(s.bucket),
 		Key:    aws.String(key),
 	})
 	if err != nil {
-		var nfe *types.NotFound
-		if errors.As(err, &nfe) {
-			return nil, KeyNotFoundError("key not found: " + key)
+		if isS3ErrorCode(err, "NoSuchKey", "NotFound") {
+			return nil, s.keyNotFound(ctx, key, err)
 		}
-		return nil, fmt.Errorf("%w: %w", OpsInternalError("failed to read key"), err)
+		return nil, s3Error("failed to read key", err)
 	}
 	defer output.Body.Close()
 
@@ -110,38 +455,182 @@ func (s *S3Ops) ReadAll(ctx context.Context, key string) ([][]byte, error) {
 	if err != nil {
 		return nil, fmt.Errorf("%w: %w", EntryError("failed to read content"), err)
 	}
+	// An empty object, as left by Create, holds no entry.
+	if len(content) == 0 {
+		return [][]byte{}, nil
+	}
 
 	// Assume entries are separated by newlines
 	return [][]byte{content}, nil
 }
 
-// ReadLast reads the last entry of the given key.
+// Read reads the last entry of the given key.
+//
+// Put replaces the object, so an object holds exactly one unframed entry and the
+// latest entry is the whole object body. There is no framing to seek into, so the
+// body is read in full without going through ReadAll.
+// An empty object, as left by Create, holds no entry.
 func (s *S3Ops) Read(ctx context.Context, key string) ([]byte, error) {
-	entries, err := s.ReadAll(ctx, key)
+	return readEventually(ctx, s, func(ctx context.Context) ([]byte, error) {
+		return s.read(ctx, key)
+	})
+}
+
+// read implements Read without retries.
+func (s *S3Ops) read(ctx context.Context, key string) ([]byte, error) {
+	output, err := s.s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
 	if err != nil {
-		return nil, err
+		if isS3ErrorCode(err, "NoSuchKey", "NotFound") {
+			return nil, s.keyNotFound(ctx, key, err)
+		}
+		return nil, s3Error("failed to read key", err)
 	}
-	if len(entries) == 0 {
+	defer output.Body.Close()
+
+	if output.ContentLength != nil && *output.ContentLength == 0 {
 		return nil, EntryError("no entries found for key: " + key)
 	}
-	return entries[len(entries)-1], nil
+	content := make([]byte, 0, aws.ToInt64(output.ContentLength))
+	buf := bytes.NewBuffer(content)
+	if _, err := buf.ReadFrom(output.Body); err != nil {
+		return nil, fmt.Errorf("%w: %w", EntryError("failed to read content"), err)
+	}
+	return buf.Bytes(), nil
 }
 
-// Put replaces an entry to the file with the given key.
-func (s *S3Ops) Put(ctx context.Context, key string, entry []byte) error {
-	_, err := s.s3Client.PutObject(ctx, &s3.PutObjectInput{
+// ReadWithToken implements TokenReader. The token is the ETag of the object,
+// without its quotes.
+func (s *S3Ops) ReadWithToken(ctx context.Context, key string) ([]byte, string, error) {
+	entry, token, _, err := s.readIfNoneMatch(ctx, key, "")
+	return entry, token, err
+}
+
+// ReadIfNoneMatch implements ConditionalReader with a GET conditional on the ETag,
+// so an unchanged object is not downloaded.
+func (s *S3Ops) ReadIfNoneMatch(ctx context.Context, key string, token string) ([]byte, bool, error) {
+	entry, _, unchanged, err := s.readIfNoneMatch(ctx, key, token)
+	return entry, unchanged, err
+}
+
+// readIfNoneMatch reads key and its ETag, unless the ETag is token.
+func (s *S3Ops) readIfNoneMatch(ctx context.Context, key string, token string) ([]byte, string, bool, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}
+	if token != "" {
+		input.IfNoneMatch = aws.String(`"` + token + `"`)
+	}
+	output, err := s.s3Client.GetObject(ctx, input)
+	if err != nil {
+		var responseErr *smithyhttp.ResponseError
+		if errors.As(err, &responseErr) && responseErr.HTTPStatusCode() == http.StatusNotModified {
+			return nil, token, true, nil
+		}
+		var nfe *types.NotFound
+		if errors.As(err, &nfe) || isS3ErrorCode(err, "NoSuchKey") {
+			return nil, "", false, s.keyNotFound(ctx, key, err)
+		}
+		return nil, "", false, s3Error("failed to read key", err)
+	}
+	defer output.Body.Close()
+
+	if output.ContentLength != nil && *output.ContentLength == 0 {
+		return nil, "", false, EntryError("no entries found for key: " + key)
+	}
+	content, err := io.ReadAll(output.Body)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("%w: %w", EntryError("failed to read content"), err)
+	}
+	return content, strings.Trim(aws.ToString(output.ETag), `"`), false, nil
+}
+
+// ReadPrefix implements PrefixReader with a ranged GET, so only the prefix is
+// downloaded.
+func (s *S3Ops) ReadPrefix(ctx context.Context, key string, n int) ([]byte, error) {
+	if err := checkPrefixLength(n); err != nil {
+		return nil, err
+	}
+	output, err := s.s3Client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(s.bucket),
 		Key:    aws.String(key),
-		Body:   strings.NewReader(string(entry)),
+		Range:  aws.String(fmt.Sprintf("bytes=0-%d", n-1)),
 	})
 	if err != nil {
-		return fmt.Errorf("%w: %w", OpsInternalError("failed to replace entry"), err)
+		if isS3ErrorCode(err, "NoSuchKey", "NotFound") {
+			return nil, s.keyNotFound(ctx, key, err)
+		}
+		// A range cannot be satisfied by an empty object, as left by Create.
+		if isS3ErrorCode(err, "InvalidRange") {
+			return nil, EntryError("no entries found for key: " + key)
+		}
+		return nil, s3Error("failed to read entry prefix", err)
+	}
+	defer output.Body.Close()
+
+	content, err := io.ReadAll(output.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", EntryError("failed to read content"), err)
+	}
+	return content, nil
+}
+
+// uploader returns a multipart uploader configured with the instance's options.
+func (s *S3Ops) uploader() *manager.Uploader {
+	return manager.NewUploader(s.s3Client, func(u *manager.Uploader) {
+		if s.multipartPartSize > 0 {
+			u.PartSize = s.multipartPartSize
+		}
+		if s.multipartConcurrency > 0 {
+			u.Concurrency = s.multipartConcurrency
+		}
+	})
+}
+
+// Put replaces an entry to the file with the given key.
+// The ContentType option is stored as the object's content type.
+//
+// The tags of the object, such as the metadata put with PutMetadata, are read first
+// and written again with the new object, which costs a GetObjectTagging request per
+// Put. Tags put by a concurrent PutMetadata between the two requests are lost.
+//
+// Entries larger than the multipart threshold are sent as a multipart upload, which
+// is aborted, discarding the uploaded parts, if it fails or ctx is cancelled.
+func (s *S3Ops) Put(ctx context.Context, key string, entry []byte) error {
+	tagging, err := s.replaceTagging(ctx, key)
+	if err != nil {
+		return err
+	}
+	input := &s3.PutObjectInput{
+		Bucket:  aws.String(s.bucket),
+		Key:     aws.String(key),
+		Body:    bytes.NewReader(entry),
+		Tagging: tagging,
+	}
+	if contentType := opOptionsFrom(ctx).contentType; contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+
+	if int64(len(entry)) > s.multipartThreshold {
+		_, err = s.uploader().Upload(ctx, input)
+	} else {
+		_, err = s.s3Client.PutObject(ctx, input)
+	}
+	if err != nil {
+		return s3Error("failed to replace entry", err)
 	}
 	return nil
 }
 
 // Delete deletes the given key and associated content.
+// With WithPurgeOnDelete every version of the key is removed.
 func (s *S3Ops) Delete(ctx context.Context, key string) error {
+	if s.purgeOnDelete {
+		return s.purge(ctx, key)
+	}
 	_, err := s.s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
 		Bucket: aws.String(s.bucket),
 		Key:    aws.String(key),
@@ -149,28 +638,850 @@ func (s *S3Ops) Delete(ctx context.Context, key string) error {
 	if err != nil {
 		var nfe *types.NotFound
 		if errors.As(err, &nfe) {
-			return KeyNotFoundError("key not found: " + key)
+			return s.keyNotFound(ctx, key, err)
 		}
-		return fmt.Errorf("%w: %w", OpsInternalError("failed to delete key"), err)
+		return s3Error("failed to delete key", err)
 	}
 	return nil
 }
 
-// List lists all keys in the bucket-scope.
+// List lists all keys in the bucket-scope. S3 already lists keys in UTF-8 binary
+// order, which is the byte order of List.
+//
+// List holds every key in memory, so listing a bucket with millions of objects
+// without the ListLimit option is discouraged; ListSeq streams the keys page by page
+// instead.
 func (s *S3Ops) List(ctx context.Context) ([]string, error) {
 	var keys []string
-	paginator := s3.NewListObjectsV2Paginator(s.s3Client, &s3.ListObjectsV2Input{
-		Bucket: aws.String(s.bucket),
-	})
+	input := s.listInput()
+	limit := opOptionsFrom(ctx).listLimit
+	if limit > 0 {
+		keys = make([]string, 0, limit)
+		// Never request more keys per page than the limit.
+		input.MaxKeys = aws.Int32(int32(min(limit, int(aws.ToInt32(input.MaxKeys)))))
+	}
+	paginator := s3.NewListObjectsV2Paginator(s.s3Client, input)
 
 	for paginator.HasMorePages() {
 		page, err := paginator.NextPage(ctx)
 		if err != nil {
-			return nil, fmt.Errorf("%w: %w", OpsInternalError("failed to list keys"), err)
+			return nil, s3Error("failed to list keys", err)
 		}
 		for _, obj := range page.Contents {
 			keys = append(keys, *obj.Key)
+			if len(keys) == limit {
+				return keys, nil
+			}
 		}
 	}
 	return keys, nil
 }
+
+// ListChildren implements ChildLister with the Delimiter of ListObjectsV2, so only
+// one level of the hierarchy is listed.
+func (s *S3Ops) ListChildren(ctx context.Context, prefix, delimiter string) ([]string, []string, error) {
+	input := s.listInput()
+	input.Prefix = aws.String(prefix)
+	if delimiter != "" {
+		input.Delimiter = aws.String(delimiter)
+	}
+	paginator := s3.NewListObjectsV2Paginator(s.s3Client, input)
+
+	keys, commonPrefixes := []string{}, []string{}
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, nil, s3Error("failed to list children", err)
+		}
+		for _, obj := range page.Contents {
+			keys = append(keys, aws.ToString(obj.Key))
+		}
+		for _, p := range page.CommonPrefixes {
+			commonPrefixes = append(commonPrefixes, aws.ToString(p.Prefix))
+		}
+	}
+	return keys, commonPrefixes, nil
+}
+
+// ListPrefixPage implements PrefixPageLister with a single ListObjectsV2 request for
+// the prefix. Its cursors are S3 continuation tokens.
+func (s *S3Ops) ListPrefixPage(ctx context.Context, prefix, cursor string, limit int) ([]string, string, error) {
+	if err := checkPageLimit(limit); err != nil {
+		return nil, "", err
+	}
+	input := s.listInput()
+	input.Prefix = aws.String(prefix)
+	input.MaxKeys = aws.Int32(int32(min(limit, s3MaxListKeys)))
+	if cursor != "" {
+		input.ContinuationToken = aws.String(cursor)
+	}
+	page, err := s.s3Client.ListObjectsV2(ctx, input)
+	if err != nil {
+		return nil, "", s3Error("failed to list keys", err)
+	}
+	keys := make([]string, 0, len(page.Contents))
+	for _, obj := range page.Contents {
+		keys = append(keys, aws.ToString(obj.Key))
+	}
+	if !aws.ToBool(page.IsTruncated) {
+		return keys, "", nil
+	}
+	return keys, aws.ToString(page.NextContinuationToken), nil
+}
+
+// listInput returns the ListObjectsV2 request for the bucket, with the configured
+// page size.
+func (s *S3Ops) listInput() *s3.ListObjectsV2Input {
+	pageSize := s.listPageSize
+	if pageSize <= 0 {
+		pageSize = s3MaxListKeys
+	}
+	return &s3.ListObjectsV2Input{
+		Bucket:  aws.String(s.bucket),
+		MaxKeys: aws.Int32(pageSize),
+	}
+}
+
+// ListModifiedSince lists all keys whose object was last modified after since.
+func (s *S3Ops) ListModifiedSince(ctx context.Context, since time.Time) ([]string, error) {
+	keys := []string{}
+	paginator := s3.NewListObjectsV2Paginator(s.s3Client, s.listInput())
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, s3Error("failed to list keys", err)
+		}
+		for _, obj := range page.Contents {
+			if obj.LastModified != nil && obj.LastModified.After(since) {
+				keys = append(keys, *obj.Key)
+			}
+		}
+	}
+	return keys, nil
+}
+
+// ReadContentType implements ContentTypeReader.
+func (s *S3Ops) ReadContentType(ctx context.Context, key string) (string, error) {
+	output, err := s.s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var nfe *types.NotFound
+		if errors.As(err, &nfe) {
+			return "", s.keyNotFound(ctx, key, err)
+		}
+		return "", s3Error("failed to read content type", err)
+	}
+	return aws.ToString(output.ContentType), nil
+}
+
+// PutMetadata implements MetadataStore. The metadata is stored as object tags, so it
+// is limited to what S3 allows for tags: at most 10 per object, including those
+// WithS3ContextTags writes, which PutMetadata reads first and keeps. Fields starting
+// with "libstore-" are reserved and rejected with a KeyError. The writes replacing the
+// object, such as Put, drop the metadata unless WithS3PreserveTags is set.
+func (s *S3Ops) PutMetadata(ctx context.Context, key string, meta Metadata) error {
+	for field := range meta {
+		if strings.HasPrefix(field, s3ReservedTagPrefix) {
+			return KeyError(fmt.Sprintf("s3: metadata field %s uses the reserved prefix %s", field, s3ReservedTagPrefix))
+		}
+	}
+	current, err := s.s3Client.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if isS3ErrorCode(err, "NoSuchKey") {
+			return KeyNotFoundError("key not found: " + key)
+		}
+		return s3Error("failed to read tags", err)
+	}
+	tags := make([]types.Tag, 0, len(meta))
+	for _, tag := range current.TagSet {
+		if strings.HasPrefix(aws.ToString(tag.Key), s3ReservedTagPrefix) {
+			tags = append(tags, tag)
+		}
+	}
+	for field, value := range meta {
+		tags = append(tags, types.Tag{Key: aws.String(field), Value: aws.String(value)})
+	}
+	_, err = s.s3Client.PutObjectTagging(ctx, &s3.PutObjectTaggingInput{
+		Bucket:  aws.String(s.bucket),
+		Key:     aws.String(key),
+		Tagging: &types.Tagging{TagSet: tags},
+	})
+	if err != nil {
+		if isS3ErrorCode(err, "NoSuchKey") {
+			return KeyNotFoundError("key not found: " + key)
+		}
+		return s3Error("failed to put metadata", err)
+	}
+	return nil
+}
+
+// ReadMetadata implements MetadataStore by reading the object tags, leaving out those
+// under the reserved "libstore-" prefix.
+func (s *S3Ops) ReadMetadata(ctx context.Context, key string) (Metadata, error) {
+	output, err := s.s3Client.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if isS3ErrorCode(err, "NoSuchKey") {
+			return nil, KeyNotFoundError("key not found: " + key)
+		}
+		return nil, s3Error("failed to read metadata", err)
+	}
+	meta := make(Metadata, len(output.TagSet))
+	for _, tag := range output.TagSet {
+		if field := aws.ToString(tag.Key); !strings.HasPrefix(field, s3ReservedTagPrefix) {
+			meta[field] = aws.ToString(tag.Value)
+		}
+	}
+	return meta, nil
+}
+
+// ListWhere implements WhereLister. S3 cannot filter listings by tags, so the tags of
+// every object are read and matched client-side.
+func (s *S3Ops) ListWhere(ctx context.Context, predicate MetaPredicate) ([]string, error) {
+	return listWhere(ctx, s, s, predicate)
+}
+
+// CreateIfNotExists creates an empty object for the key unless it already exists.
+// It uses a conditional PutObject, so the check and the write are a single request.
+func (s *S3Ops) CreateIfNotExists(ctx context.Context, key string) (bool, error) {
+	_, err := s.s3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        strings.NewReader(""),
+		IfNoneMatch: aws.String("*"),
+		Tagging:     s.contextTagging(ctx),
+	})
+	if isS3ErrorCode(err, "PreconditionFailed", "ConditionalRequestConflict") {
+		return false, nil
+	}
+	if err != nil {
+		return false, s3Error("failed to create key", err)
+	}
+	return true, nil
+}
+
+// CreateBatch implements BatchCreator with conditional PutObject calls, up to
+// createBatchConcurrency at a time. With FailOnExisting the keys created are deleted
+// again once all calls are done, as S3 cannot create objects atomically, so a
+// concurrent reader may see them in the meantime.
+func (s *S3Ops) CreateBatch(ctx context.Context, keys []string) error {
+	keys = uniqueKeys(keys)
+	created := make([]bool, len(keys))
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(createBatchConcurrency)
+	for i, key := range keys {
+		g.Go(func() error {
+			var err error
+			created[i], err = s.CreateIfNotExists(gctx, key)
+			return err
+		})
+	}
+	err := g.Wait()
+
+	var createdKeys, existing []string
+	for i, key := range keys {
+		if created[i] {
+			createdKeys = append(createdKeys, key)
+		} else {
+			existing = append(existing, key)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 && opOptionsFrom(ctx).failOnExisting {
+		var errs []error
+		for _, key := range createdKeys {
+			errs = append(errs, s.Delete(ctx, key))
+		}
+		return errors.Join(append([]error{existingKeysError(existing)}, errs...)...)
+	}
+	return existingKeysError(existing)
+}
+
+// getOrCreateAttempts bounds how often GetOrCreate retries a conditional write that
+// conflicted with another one in flight.
+const getOrCreateAttempts = 3
+
+// GetOrCreate implements GetOrCreator with a conditional PutObject, falling back to
+// GetObject when the object already exists.
+func (s *S3Ops) GetOrCreate(ctx context.Context, key string, entry []byte) ([]byte, bool, error) {
+	var err error
+	for range getOrCreateAttempts {
+		_, err = s.s3Client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:      aws.String(s.bucket),
+			Key:         aws.String(key),
+			Body:        bytes.NewReader(entry),
+			IfNoneMatch: aws.String("*"),
+			Tagging:     s.contextTagging(ctx),
+		})
+		if err == nil {
+			return entry, true, nil
+		}
+		if isS3ErrorCode(err, "PreconditionFailed") {
+			value, err := s.Read(ctx, key)
+			return value, false, err
+		}
+		// Another conditional write to the key is in flight; try again once it settles.
+		if !isS3ErrorCode(err, "ConditionalRequestConflict") {
+			break
+		}
+	}
+	return nil, false, s3Error("failed to create key", err)
+}
+
+// SizeHistogram implements SizeHistogrammer from the object sizes in the listing.
+// Each object is a single entry; empty objects, as left by Create, hold none and are
+// not counted. With WithNativeVersioning only the latest version of each key is counted.
+func (s *S3Ops) SizeHistogram(ctx context.Context, buckets []int64) (map[int64]int, error) {
+	h := newSizeHistogram(buckets)
+	paginator := s3.NewListObjectsV2Paginator(s.s3Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, s3Error("failed to list keys", err)
+		}
+		for _, obj := range page.Contents {
+			if size := aws.ToInt64(obj.Size); size > 0 {
+				h.add(size, 1)
+			}
+		}
+	}
+	return h.counts, nil
+}
+
+// putAndGetPreviousAttempts bounds how often PutAndGetPrevious retries after the object
+// changed between its read and its conditional write.
+const putAndGetPreviousAttempts = 5
+
+// PutAndGetPrevious implements PreviousPutter. The object is read and then replaced
+// with a PutObject conditional on its ETag; if another writer replaced it in between,
+// both steps are retried. Like Put, it keeps the tags of the object.
+func (s *S3Ops) PutAndGetPrevious(ctx context.Context, key string, entry []byte) ([]byte, error) {
+	var err error
+	for range putAndGetPreviousAttempts {
+		var output *s3.GetObjectOutput
+		output, err = s.s3Client.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(key),
+		})
+		if isS3ErrorCode(err, "NoSuchKey", "NotFound") {
+			return nil, s.keyNotFound(ctx, key, err)
+		}
+		if err != nil {
+			return nil, s3Error("failed to read key", err)
+		}
+		previous, rerr := io.ReadAll(output.Body)
+		output.Body.Close()
+		if rerr != nil {
+			return nil, fmt.Errorf("%w: %w", EntryError("failed to read content"), rerr)
+		}
+		// An empty object, as left by Create, holds no entry.
+		if len(previous) == 0 {
+			previous = nil
+		}
+		tagging, terr := s.replaceTagging(ctx, key)
+		if terr != nil {
+			return nil, terr
+		}
+
+		input := &s3.PutObjectInput{
+			Bucket:  aws.String(s.bucket),
+			Key:     aws.String(key),
+			Body:    bytes.NewReader(entry),
+			IfMatch: output.ETag,
+			Tagging: tagging,
+		}
+		if contentType := opOptionsFrom(ctx).contentType; contentType != "" {
+			input.ContentType = aws.String(contentType)
+		}
+		_, err = s.s3Client.PutObject(ctx, input)
+		if err == nil {
+			return previous, nil
+		}
+		if !isS3ErrorCode(err, "PreconditionFailed", "ConditionalRequestConflict") {
+			return nil, s3Error("failed to replace entry", err)
+		}
+	}
+	return nil, fmt.Errorf("%w: %w", ConflictError("key kept changing: "+key), err)
+}
+
+// s3FenceTokenMetadata names the user metadata of an object, sent as
+// x-amz-meta-libstore-fence-token, in which PutFenced records the highest fence token.
+const s3FenceTokenMetadata = "libstore-fence-token"
+
+// putFencedAttempts bounds how often PutFenced retries after the object changed
+// between its read and its conditional write.
+const putFencedAttempts = 5
+
+// PutFenced implements FencedPutter. The highest fence token of a key is kept in the
+// user metadata of its object, which neither the metadata of PutMetadata nor
+// ReadMetadata touch. The object is read with HeadObject and replaced with a
+// PutObject conditional on its ETag that carries the new token; if another writer
+// replaced it in between, both steps are retried. Writes other than PutFenced
+// replace the object without the token, which resets the fence, so every writer of
+// a fenced key must use PutFenced. Like Put, it keeps the tags of the object.
+func (s *S3Ops) PutFenced(ctx context.Context, key string, entry []byte, fenceToken int64) error {
+	var err error
+	for range putFencedAttempts {
+		var head *s3.HeadObjectOutput
+		head, err = s.s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(key),
+		})
+		if isS3ErrorCode(err, "NoSuchKey", "NotFound") {
+			return s.keyNotFound(ctx, key, err)
+		}
+		if err != nil {
+			return s3Error("failed to read fence token", err)
+		}
+		if recorded, ok := head.Metadata[s3FenceTokenMetadata]; ok {
+			highest, perr := strconv.ParseInt(recorded, 10, 64)
+			if perr != nil {
+				return fmt.Errorf("%w: %w", EntryError("fence token of key "+key+" is not an integer"), perr)
+			}
+			if err := checkFence(key, highest, fenceToken); err != nil {
+				return err
+			}
+		}
+		tagging, terr := s.replaceTagging(ctx, key)
+		if terr != nil {
+			return terr
+		}
+
+		input := &s3.PutObjectInput{
+			Bucket:   aws.String(s.bucket),
+			Key:      aws.String(key),
+			Body:     bytes.NewReader(entry),
+			IfMatch:  head.ETag,
+			Tagging:  tagging,
+			Metadata: map[string]string{s3FenceTokenMetadata: strconv.FormatInt(fenceToken, 10)},
+		}
+		if contentType := opOptionsFrom(ctx).contentType; contentType != "" {
+			input.ContentType = aws.String(contentType)
+		}
+		_, err = s.s3Client.PutObject(ctx, input)
+		if err == nil {
+			return nil
+		}
+		if !isS3ErrorCode(err, "PreconditionFailed", "ConditionalRequestConflict") {
+			return s3Error("failed to write fenced entry", err)
+		}
+	}
+	return fmt.Errorf("%w: %w", ConflictError("key kept changing: "+key), err)
+}
+
+// readForUpdate implements conditionalWriter. The token is the ETag of the object,
+// and an empty object, as left by Create, holds no entry.
+func (s *S3Ops) readForUpdate(ctx context.Context, key string) ([]byte, string, error) {
+	output, err := s.s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if isS3ErrorCode(err, "NoSuchKey", "NotFound") {
+		return nil, "", s.keyNotFound(ctx, key, err)
+	}
+	if err != nil {
+		return nil, "", s3Error("failed to read key", err)
+	}
+	defer output.Body.Close()
+	entry, err := io.ReadAll(output.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %w", EntryError("failed to read content"), err)
+	}
+	if len(entry) == 0 {
+		entry = nil
+	}
+	return entry, aws.ToString(output.ETag), nil
+}
+
+// putIfUnchanged implements conditionalWriter with a PutObject conditional on the
+// ETag token, or on the object not existing if token is empty. Like Put, it keeps
+// the tags of the object.
+func (s *S3Ops) putIfUnchanged(ctx context.Context, key string, entry []byte, token string) error {
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(entry),
+	}
+	if token == "" {
+		input.IfNoneMatch = aws.String("*")
+		input.Tagging = s.contextTagging(ctx)
+	} else {
+		tagging, err := s.replaceTagging(ctx, key)
+		if err != nil {
+			return err
+		}
+		input.IfMatch, input.Tagging = aws.String(token), tagging
+	}
+	_, err := s.s3Client.PutObject(ctx, input)
+	// A conditional write to a deleted object fails with NoSuchKey.
+	if isS3ErrorCode(err, "PreconditionFailed", "ConditionalRequestConflict", "NoSuchKey") {
+		return fmt.Errorf("%w: %w", ConflictError("key changed since it was read: "+key), err)
+	}
+	if err != nil {
+		return s3Error("failed to write entry", err)
+	}
+	return nil
+}
+
+// ListSeq implements SeqLister, fetching one ListObjectsV2 page at a time.
+func (s *S3Ops) ListSeq(ctx context.Context) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		paginator := s3.NewListObjectsV2Paginator(s.s3Client, s.listInput())
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				yield("", s3Error("failed to list keys", err))
+				return
+			}
+			for _, obj := range page.Contents {
+				if !yield(*obj.Key, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// DeleteIfUnchanged implements ETagDeleter using a conditional DeleteObject.
+func (s *S3Ops) DeleteIfUnchanged(ctx context.Context, key string, etag string) error {
+	_, err := s.s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket:  aws.String(s.bucket),
+		Key:     aws.String(key),
+		IfMatch: aws.String(etag),
+	})
+	if err == nil {
+		return nil
+	}
+	var nfe *types.NotFound
+	var nsk *types.NoSuchKey
+	if errors.As(err, &nfe) || errors.As(err, &nsk) || isS3ErrorCode(err, "NoSuchKey", "NotFound") {
+		return s.keyNotFound(ctx, key, err)
+	}
+	if isS3ErrorCode(err, "PreconditionFailed", "ConditionalRequestConflict") {
+		return fmt.Errorf("%w: %w", ConflictError("etag mismatch for key: "+key), err)
+	}
+	return s3Error("failed to delete key", err)
+}
+
+// PutFrom implements StreamPutter. The body is streamed to S3 as a multipart upload
+// whenever it exceeds a single part, so its size need not be known in advance. Like
+// Put, it keeps the tags of the object.
+func (s *S3Ops) PutFrom(ctx context.Context, key string, r io.Reader) error {
+	tagging, err := s.replaceTagging(ctx, key)
+	if err != nil {
+		return err
+	}
+	input := &s3.PutObjectInput{
+		Bucket:  aws.String(s.bucket),
+		Key:     aws.String(key),
+		Body:    r,
+		Tagging: tagging,
+	}
+	if contentType := opOptionsFrom(ctx).contentType; contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+	if _, err := s.uploader().Upload(ctx, input); err != nil {
+		return s3Error("failed to replace entry", err)
+	}
+	return nil
+}
+
+// ReadTo implements StreamReader, copying the object body to w as it is received.
+func (s *S3Ops) ReadTo(ctx context.Context, key string, w io.Writer) error {
+	output, err := s.s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if isS3ErrorCode(err, "NoSuchKey", "NotFound") {
+			return s.keyNotFound(ctx, key, err)
+		}
+		return s3Error("failed to read key", err)
+	}
+	defer output.Body.Close()
+
+	if _, err := io.Copy(w, output.Body); err != nil {
+		return fmt.Errorf("%w: %w", EntryError("failed to copy content"), err)
+	}
+	return nil
+}
+
+// s3Error wraps an error returned by S3 in a BackendError carrying its error code,
+// itself wrapped in a LocationError if the bucket does not exist.
+func s3Error(op string, err error) error {
+	e := &BackendError{Backend: "s3", Op: op, Err: err}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		e.Code = apiErr.ErrorCode()
+	}
+	if e.Code == "NoSuchBucket" {
+		return fmt.Errorf("%w: %w", LocationError("s3: bucket does not exist"), e)
+	}
+	return annotateTimeout(op, e)
+}
+
+// keyNotFound returns the error for key missing from the bucket, err being the error
+// of the request that did not find it: a KeyNotFoundError, or a LocationError if the
+// bucket itself is gone. Responses to HEAD requests have no body to tell the two
+// apart, so unless err names a missing key the bucket is checked, and every miss of
+// a HEAD request costs an extra HeadBucket round trip. Misses of GET requests carry
+// the NoSuchKey code and cost nothing more.
+func (s *S3Ops) keyNotFound(ctx context.Context, key string, err error) error {
+	if !isS3ErrorCode(err, "NoSuchKey") {
+		_, err := s.s3Client.HeadBucket(ctx, &s3.HeadBucketInput{
+			Bucket: aws.String(s.bucket),
+		})
+		if isS3ErrorCode(err, "NotFound", "NoSuchBucket") {
+			return fmt.Errorf("%w: %w", LocationError("s3: bucket "+s.bucket+" does not exist"), err)
+		}
+	}
+	return KeyNotFoundError("key not found: " + key)
+}
+
+// isS3ErrorCode reports whether err is an S3 API error with one of the given codes.
+func isS3ErrorCode(err error, codes ...string) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return slices.Contains(codes, apiErr.ErrorCode())
+}
+
+// listVersions returns the versions and delete markers of key, newest first. If after
+// is not empty, the listing resumes after the version or delete marker with that ID,
+// so only older ones are returned.
+func (s *S3Ops) listVersions(ctx context.Context, key string, after string) ([]types.ObjectVersion, []types.DeleteMarkerEntry, error) {
+	var versions []types.ObjectVersion
+	var markers []types.DeleteMarkerEntry
+	input := &s3.ListObjectVersionsInput{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(key),
+	}
+	if after != "" {
+		input.KeyMarker = aws.String(key)
+		input.VersionIdMarker = aws.String(after)
+	}
+	for {
+		page, err := s.s3Client.ListObjectVersions(ctx, input)
+		if err != nil {
+			return nil, nil, s3Error("failed to list object versions", err)
+		}
+		// Keys sharing the prefix sort after key itself, so the first of them ends the listing.
+		done := !aws.ToBool(page.IsTruncated)
+		for _, v := range page.Versions {
+			if aws.ToString(v.Key) != key {
+				done = true
+				continue
+			}
+			versions = append(versions, v)
+		}
+		for _, m := range page.DeleteMarkers {
+			if aws.ToString(m.Key) != key {
+				done = true
+				continue
+			}
+			markers = append(markers, m)
+		}
+		if done {
+			return versions, markers, nil
+		}
+		input.KeyMarker = page.NextKeyMarker
+		input.VersionIdMarker = page.NextVersionIdMarker
+	}
+}
+
+// Versions returns the IDs of the versions of key written since it was last created,
+// oldest first, for use with ReadAt.
+//
+// Versions older than the latest delete marker belong to a deleted incarnation of the
+// key and are skipped. The empty object written by Create is skipped too, so putting
+// an empty entry as the very first version of a key is not reported.
+func (s *S3Ops) Versions(ctx context.Context, key string) ([]string, error) {
+	versions, err := s.liveVersions(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	for i, v := range versions {
+		// The empty object written by Create.
+		if i == 0 && v.Size != nil && *v.Size == 0 {
+			continue
+		}
+		ids = append(ids, aws.ToString(v.VersionId))
+	}
+	return ids, nil
+}
+
+// CreatedAt implements CreatedAtReader with the time of the oldest version written
+// since the key was last created. It requires WithNativeVersioning, as S3 keeps no
+// creation time for an object that is overwritten in place.
+func (s *S3Ops) CreatedAt(ctx context.Context, key string) (time.Time, error) {
+	if !s.nativeVersioning {
+		return time.Time{}, UnsupportedError("CreatedAt requires native versioning")
+	}
+	versions, err := s.liveVersions(ctx, key)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if len(versions) == 0 {
+		return time.Time{}, KeyNotFoundError("key not found: " + key)
+	}
+	return aws.ToTime(versions[0].LastModified), nil
+}
+
+// liveVersions returns the versions of key written since it was last created, oldest
+// first. It returns a KeyNotFoundError if the key has no versions or is deleted.
+//
+// S3 lists versions and delete markers together, newest first, but the SDK returns
+// them in separate slices, and their LastModified times can tie. The versions older
+// than the latest delete marker are therefore found by resuming the listing after it,
+// which costs one more request for a key that was ever deleted.
+func (s *S3Ops) liveVersions(ctx context.Context, key string) ([]types.ObjectVersion, error) {
+	versions, markers, err := s.listVersions(ctx, key, "")
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 || (len(markers) > 0 && aws.ToBool(markers[0].IsLatest)) {
+		return nil, KeyNotFoundError("key not found: " + key)
+	}
+
+	deleted := map[string]bool{}
+	if len(markers) > 0 {
+		older, _, err := s.listVersions(ctx, key, aws.ToString(markers[0].VersionId))
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range older {
+			deleted[aws.ToString(v.VersionId)] = true
+		}
+	}
+
+	var live []types.ObjectVersion
+	for _, v := range slices.Backward(versions) {
+		if !deleted[aws.ToString(v.VersionId)] {
+			live = append(live, v)
+		}
+	}
+	return live, nil
+}
+
+// ReadAt reads the given version of key, as returned by Versions.
+func (s *S3Ops) ReadAt(ctx context.Context, key string, versionID string) ([]byte, error) {
+	output, err := s.s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket:    aws.String(s.bucket),
+		Key:       aws.String(key),
+		VersionId: aws.String(versionID),
+	})
+	if err != nil {
+		if isS3ErrorCode(err, "NoSuchKey", "NoSuchVersion", "NotFound") {
+			return nil, KeyNotFoundError(fmt.Sprintf("version %s of key %s not found", versionID, key))
+		}
+		return nil, s3Error("failed to read version", err)
+	}
+	defer output.Body.Close()
+
+	content, err := io.ReadAll(output.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", EntryError("failed to read content"), err)
+	}
+	return content, nil
+}
+
+func (s *S3Ops) readAllVersions(ctx context.Context, key string) ([][]byte, error) {
+	ids, err := s.Versions(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([][]byte, 0, len(ids))
+	for _, id := range ids {
+		entry, err := s.ReadAt(ctx, key, id)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// purge removes every version and delete marker of key.
+func (s *S3Ops) purge(ctx context.Context, key string) error {
+	versions, markers, err := s.listVersions(ctx, key, "")
+	if err != nil {
+		return err
+	}
+	var objects []types.ObjectIdentifier
+	for _, v := range versions {
+		objects = append(objects, types.ObjectIdentifier{Key: v.Key, VersionId: v.VersionId})
+	}
+	for _, m := range markers {
+		objects = append(objects, types.ObjectIdentifier{Key: m.Key, VersionId: m.VersionId})
+	}
+	if len(versions) == 0 {
+		return KeyNotFoundError("key not found: " + key)
+	}
+
+	// DeleteObjects accepts at most 1000 objects per request.
+	for batch := range slices.Chunk(objects, 1000) {
+		output, err := s.s3Client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(s.bucket),
+			Delete: &types.Delete{Objects: batch, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return s3Error("failed to purge key", err)
+		}
+		if len(output.Errors) > 0 {
+			failed := output.Errors[0]
+			return &BackendError{
+				Backend: "s3",
+				Op:      "failed to purge key " + key,
+				Code:    aws.ToString(failed.Code),
+				Err:     errors.New(aws.ToString(failed.Message)),
+			}
+		}
+	}
+	return nil
+}
+
+// ReadAllKeys implements BulkReader, reading the listed objects concurrently.
+func (s *S3Ops) ReadAllKeys(ctx context.Context) (map[string][]byte, error) {
+	return readKeys(ctx, s, readAllKeysConcurrency)
+}
+
+var (
+	_ Ops                 = (*S3Ops)(nil)
+	_ BulkReader          = (*S3Ops)(nil)
+	_ IdempotentCreator   = (*S3Ops)(nil)
+	_ BatchCreator        = (*S3Ops)(nil)
+	_ GetOrCreator        = (*S3Ops)(nil)
+	_ PreviousPutter      = (*S3Ops)(nil)
+	_ SizeHistogrammer    = (*S3Ops)(nil)
+	_ SeqLister           = (*S3Ops)(nil)
+	_ ETagDeleter         = (*S3Ops)(nil)
+	_ TokenReader         = (*S3Ops)(nil)
+	_ ConditionalReader   = (*S3Ops)(nil)
+	_ StreamPutter        = (*S3Ops)(nil)
+	_ StreamReader        = (*S3Ops)(nil)
+	_ ModifiedSinceLister = (*S3Ops)(nil)
+	_ ContentTypeReader   = (*S3Ops)(nil)
+	_ MetadataStore       = (*S3Ops)(nil)
+	_ WhereLister         = (*S3Ops)(nil)
+	_ PrefixReader        = (*S3Ops)(nil)
+	_ CreatedAtReader     = (*S3Ops)(nil)
+	_ ChildLister         = (*S3Ops)(nil)
+	_ PrefixPageLister    = (*S3Ops)(nil)
+	_ conditionalWriter   = (*S3Ops)(nil)
+	_ FencedPutter        = (*S3Ops)(nil)
+)
@@ -0,0 +1,1074 @@
+package libstore_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/cecmp/libstore"
+)
+
+// fakeS3 serves the subset of the S3 API used by S3Ops for a single bucket, with
+// path-style addressing.
+type fakeS3 struct {
+	bucket  string
+	mu      sync.Mutex
+	objects map[string][]byte
+	tags    map[string][]fakeS3Tag
+	// modified holds the time each object was last written.
+	modified map[string]time.Time
+	// contentTypes holds the Content-Type each object was written with.
+	contentTypes map[string]string
+	// userMetadata holds the x-amz-meta- headers each object was written with.
+	userMetadata map[string]http.Header
+	// uploads holds the parts of the multipart uploads in progress, by upload ID.
+	uploads map[string]map[int][]byte
+	// completed and aborted count the multipart uploads completed and aborted.
+	completed, aborted int
+	// failParts makes every UploadPart call fail.
+	failParts bool
+	// lagging counts the GETs of a key still to answer NoSuchKey, as an eventually
+	// consistent store might right after a write.
+	lagging map[string]int
+	// versioned enables versioning on the bucket: every write and delete of a key
+	// is kept in versions, oldest first.
+	versioned bool
+	versions  map[string][]fakeS3Version
+}
+
+// fakeS3Version is a version or, if marker is set, a delete marker of an object.
+// Versions are dated to the second, so that versions and delete markers written in
+// quick succession tie, as they can on S3.
+type fakeS3Version struct {
+	id       string
+	body     []byte
+	marker   bool
+	modified time.Time
+}
+
+type fakeS3VersionEntry struct {
+	XMLName      xml.Name
+	Key          string
+	VersionId    string
+	IsLatest     bool
+	LastModified string
+	ETag         string `xml:",omitempty"`
+	Size         *int   `xml:",omitempty"`
+}
+
+type fakeS3VersionListing struct {
+	XMLName             xml.Name `xml:"ListVersionsResult"`
+	Name                string
+	Prefix              string
+	IsTruncated         bool
+	NextKeyMarker       string `xml:",omitempty"`
+	NextVersionIdMarker string `xml:",omitempty"`
+	Entries             []fakeS3VersionEntry
+}
+
+type fakeS3Versioning struct {
+	XMLName xml.Name `xml:"VersioningConfiguration"`
+	Status  string   `xml:",omitempty"`
+}
+
+type fakeS3Delete struct {
+	Objects []struct {
+		Key       string
+		VersionId string
+	} `xml:"Object"`
+}
+
+type fakeS3Tag struct {
+	Key   string
+	Value string
+}
+
+type fakeS3Tagging struct {
+	XMLName xml.Name    `xml:"Tagging"`
+	TagSet  []fakeS3Tag `xml:"TagSet>Tag"`
+}
+
+type fakeS3Object struct {
+	Key          string
+	Size         int
+	ETag         string
+	LastModified string
+}
+
+type fakeS3Listing struct {
+	XMLName               xml.Name `xml:"ListBucketResult"`
+	Name                  string
+	KeyCount              int
+	IsTruncated           bool
+	NextContinuationToken string `xml:",omitempty"`
+	Contents              []fakeS3Object
+	CommonPrefixes        []fakeS3Prefix
+}
+
+type fakeS3Prefix struct {
+	Prefix string
+}
+
+// fakeS3TimeFormat is the millisecond precision timestamp format of S3 listings.
+const fakeS3TimeFormat = "2006-01-02T15:04:05.000Z"
+
+func etag(body []byte) string {
+	sum := md5.Sum(body)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	bucket, key, _ := strings.Cut(path, "/")
+	if bucket != f.bucket {
+		fakeS3Error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	if key == "" {
+		query := r.URL.Query()
+		switch {
+		case r.Method == http.MethodGet && query.Has("versioning"):
+			versioning := fakeS3Versioning{}
+			if f.versioned {
+				versioning.Status = "Enabled"
+			}
+			w.Header().Set("Content-Type", "application/xml")
+			_ = xml.NewEncoder(w).Encode(versioning)
+			return
+		case r.Method == http.MethodGet && query.Has("versions"):
+			f.serveVersionListing(w, r)
+			return
+		case r.Method == http.MethodPost && query.Has("delete"):
+			f.serveDeleteObjects(w, r)
+			return
+		}
+		switch r.Method {
+		case http.MethodHead:
+		case http.MethodGet:
+			listing := fakeS3Listing{Name: f.bucket}
+			query := r.URL.Query()
+			prefix, after := query.Get("prefix"), query.Get("continuation-token")
+			delimiter := query.Get("delimiter")
+			maxKeys := 1000
+			if query.Has("max-keys") {
+				fmt.Sscan(query.Get("max-keys"), &maxKeys)
+			}
+			last := ""
+			for _, key := range slices.Sorted(maps.Keys(f.objects)) {
+				if !strings.HasPrefix(key, prefix) || key <= after || (delimiter != "" && strings.HasSuffix(after, delimiter) && strings.HasPrefix(key, after)) {
+					continue
+				}
+				if listing.KeyCount == maxKeys {
+					listing.IsTruncated = true
+					listing.NextContinuationToken = last
+					break
+				}
+				if i := strings.Index(key[len(prefix):], delimiter); delimiter != "" && i >= 0 {
+					commonPrefix := key[:len(prefix)+i+len(delimiter)]
+					if commonPrefix != last {
+						listing.CommonPrefixes = append(listing.CommonPrefixes, fakeS3Prefix{Prefix: commonPrefix})
+						listing.KeyCount++
+						last = commonPrefix
+					}
+					continue
+				}
+				body := f.objects[key]
+				listing.Contents = append(listing.Contents, fakeS3Object{Key: key, Size: len(body), ETag: etag(body), LastModified: f.modified[key].UTC().Format(fakeS3TimeFormat)})
+				listing.KeyCount++
+				last = key
+			}
+			w.Header().Set("Content-Type", "application/xml")
+			_ = xml.NewEncoder(w).Encode(listing)
+		default:
+			fakeS3Error(w, http.StatusNotImplemented, "NotImplemented")
+		}
+		return
+	}
+
+	if query := r.URL.Query(); query.Has("uploads") || query.Has("uploadId") {
+		f.serveMultipart(w, r, key)
+		return
+	}
+	if versionID := r.URL.Query().Get("versionId"); versionID != "" {
+		f.serveVersion(w, r, key, versionID)
+		return
+	}
+	body, exists := f.objects[key]
+	if r.URL.Query().Has("tagging") {
+		f.serveTagging(w, r, key, exists)
+		return
+	}
+	switch r.Method {
+	case http.MethodHead, http.MethodGet:
+		if f.lagging[key] > 0 && r.Method == http.MethodGet {
+			f.lagging[key]--
+			exists = false
+		}
+		if !exists {
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusNotFound)
+			} else {
+				fakeS3Error(w, http.StatusNotFound, "NoSuchKey")
+			}
+			return
+		}
+		w.Header().Set("ETag", etag(body))
+		if contentType, ok := f.contentTypes[key]; ok {
+			w.Header().Set("Content-Type", contentType)
+		}
+		for name, values := range f.userMetadata[key] {
+			w.Header()[name] = values
+		}
+		if r.Header.Get("If-None-Match") == etag(body) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if rng := r.Header.Get("Range"); rng != "" && r.Method == http.MethodGet {
+			var first, last int
+			if _, err := fmt.Sscanf(rng, "bytes=%d-%d", &first, &last); err != nil || first >= len(body) {
+				fakeS3Error(w, http.StatusRequestedRangeNotSatisfiable, "InvalidRange")
+				return
+			}
+			body = body[first:min(last+1, len(body))]
+			w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+			w.WriteHeader(http.StatusPartialContent)
+			_, _ = w.Write(body)
+			return
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(body)
+		}
+	case http.MethodPut:
+		if r.Header.Get("If-None-Match") == "*" && exists {
+			fakeS3Error(w, http.StatusPreconditionFailed, "PreconditionFailed")
+			return
+		}
+		if match := r.Header.Get("If-Match"); match != "" && (!exists || match != etag(body)) {
+			fakeS3Error(w, http.StatusPreconditionFailed, "PreconditionFailed")
+			return
+		}
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			fakeS3Error(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		tagging, err := url.ParseQuery(r.Header.Get("X-Amz-Tagging"))
+		if err != nil {
+			fakeS3Error(w, http.StatusBadRequest, "InvalidTag")
+			return
+		}
+		f.objects[key] = data
+		if f.modified == nil {
+			f.modified = map[string]time.Time{}
+		}
+		f.modified[key] = time.Now()
+		if f.contentTypes == nil {
+			f.contentTypes = map[string]string{}
+		}
+		f.contentTypes[key] = r.Header.Get("Content-Type")
+		delete(f.userMetadata, key)
+		for name, values := range r.Header {
+			if strings.HasPrefix(name, "X-Amz-Meta-") {
+				if f.userMetadata == nil {
+					f.userMetadata = map[string]http.Header{}
+				}
+				if f.userMetadata[key] == nil {
+					f.userMetadata[key] = http.Header{}
+				}
+				f.userMetadata[key][name] = values
+			}
+		}
+		delete(f.tags, key)
+		for _, name := range slices.Sorted(maps.Keys(tagging)) {
+			if f.tags == nil {
+				f.tags = map[string][]fakeS3Tag{}
+			}
+			f.tags[key] = append(f.tags[key], fakeS3Tag{Key: name, Value: tagging.Get(name)})
+		}
+		f.addVersion(key, fakeS3Version{body: data})
+		w.Header().Set("ETag", etag(data))
+	case http.MethodDelete:
+		if exists {
+			f.addVersion(key, fakeS3Version{marker: true})
+		}
+		delete(f.objects, key)
+		delete(f.tags, key)
+		delete(f.modified, key)
+		delete(f.contentTypes, key)
+		delete(f.userMetadata, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		fakeS3Error(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+type fakeS3Upload struct {
+	XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+	Bucket   string
+	Key      string
+	UploadId string
+}
+
+type fakeS3CompletedUpload struct {
+	XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+	Bucket  string
+	Key     string
+	ETag    string
+}
+
+type fakeS3CompleteRequest struct {
+	Parts []struct {
+		PartNumber int
+	} `xml:"Part"`
+}
+
+// serveMultipart serves CreateMultipartUpload, UploadPart, CompleteMultipartUpload
+// and AbortMultipartUpload for key.
+func (f *fakeS3) serveMultipart(w http.ResponseWriter, r *http.Request, key string) {
+	query := r.URL.Query()
+	uploadID := query.Get("uploadId")
+	parts, exists := f.uploads[uploadID]
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		if f.uploads == nil {
+			f.uploads = map[string]map[int][]byte{}
+		}
+		uploadID = fmt.Sprintf("upload-%d", len(f.uploads)+f.completed+f.aborted+1)
+		f.uploads[uploadID] = map[int][]byte{}
+		w.Header().Set("Content-Type", "application/xml")
+		_ = xml.NewEncoder(w).Encode(fakeS3Upload{Bucket: f.bucket, Key: key, UploadId: uploadID})
+	case !exists:
+		fakeS3Error(w, http.StatusNotFound, "NoSuchUpload")
+	case r.Method == http.MethodPut:
+		if f.failParts {
+			fakeS3Error(w, http.StatusBadRequest, "InvalidRequest")
+			return
+		}
+		var number int
+		fmt.Sscan(query.Get("partNumber"), &number)
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			fakeS3Error(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		parts[number] = data
+		w.Header().Set("ETag", etag(data))
+	case r.Method == http.MethodPost:
+		var req fakeS3CompleteRequest
+		if err := xml.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Parts) == 0 {
+			fakeS3Error(w, http.StatusBadRequest, "MalformedXML")
+			return
+		}
+		var body []byte
+		for _, part := range req.Parts {
+			data, ok := parts[part.PartNumber]
+			if !ok {
+				fakeS3Error(w, http.StatusBadRequest, "InvalidPart")
+				return
+			}
+			body = append(body, data...)
+		}
+		delete(f.uploads, uploadID)
+		f.completed++
+		f.objects[key] = body
+		if f.modified == nil {
+			f.modified = map[string]time.Time{}
+		}
+		f.modified[key] = time.Now()
+		delete(f.tags, key)
+		delete(f.userMetadata, key)
+		w.Header().Set("Content-Type", "application/xml")
+		_ = xml.NewEncoder(w).Encode(fakeS3CompletedUpload{Bucket: f.bucket, Key: key, ETag: etag(body)})
+	case r.Method == http.MethodDelete:
+		delete(f.uploads, uploadID)
+		f.aborted++
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		fakeS3Error(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+// addVersion records v as the latest version of key if the bucket is versioned.
+func (f *fakeS3) addVersion(key string, v fakeS3Version) {
+	if !f.versioned {
+		return
+	}
+	if f.versions == nil {
+		f.versions = map[string][]fakeS3Version{}
+	}
+	sum := md5.Sum(fmt.Appendf(nil, "%s-%d", key, len(f.versions[key])))
+	v.id = hex.EncodeToString(sum[:8])
+	v.modified = time.Now().Truncate(time.Second)
+	f.versions[key] = append(f.versions[key], v)
+}
+
+// serveVersion serves GetObject, HeadObject and DeleteObject of a version of key.
+func (f *fakeS3) serveVersion(w http.ResponseWriter, r *http.Request, key, versionID string) {
+	versions := f.versions[key]
+	i := slices.IndexFunc(versions, func(v fakeS3Version) bool { return v.id == versionID })
+	if i < 0 {
+		fakeS3Error(w, http.StatusNotFound, "NoSuchVersion")
+		return
+	}
+	switch r.Method {
+	case http.MethodHead, http.MethodGet:
+		if versions[i].marker {
+			fakeS3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+			return
+		}
+		w.Header().Set("ETag", etag(versions[i].body))
+		w.Header().Set("Content-Length", fmt.Sprint(len(versions[i].body)))
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(versions[i].body)
+		}
+	case http.MethodDelete:
+		f.deleteVersion(key, i)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		fakeS3Error(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+// deleteVersion removes the i-th version of key, making the one before it current.
+func (f *fakeS3) deleteVersion(key string, i int) {
+	versions := slices.Delete(f.versions[key], i, i+1)
+	f.versions[key] = versions
+	if len(versions) == 0 || versions[len(versions)-1].marker {
+		delete(f.objects, key)
+		return
+	}
+	f.objects[key] = versions[len(versions)-1].body
+}
+
+// serveVersionListing serves ListObjectVersions: keys in order, and the versions and
+// delete markers of each key newest first.
+func (f *fakeS3) serveVersionListing(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	prefix := query.Get("prefix")
+	keyMarker, versionMarker := query.Get("key-marker"), query.Get("version-id-marker")
+	maxKeys := 1000
+	if query.Has("max-keys") {
+		fmt.Sscan(query.Get("max-keys"), &maxKeys)
+	}
+	listing := fakeS3VersionListing{Name: f.bucket, Prefix: prefix}
+	for _, key := range slices.Sorted(maps.Keys(f.versions)) {
+		// Without a version marker the listing resumes after every version of keyMarker.
+		if !strings.HasPrefix(key, prefix) || key < keyMarker || (key == keyMarker && versionMarker == "") {
+			continue
+		}
+		skipping := key == keyMarker
+		for i, v := range slices.Backward(f.versions[key]) {
+			if skipping {
+				skipping = v.id != versionMarker
+				continue
+			}
+			if len(listing.Entries) == maxKeys {
+				last := listing.Entries[len(listing.Entries)-1]
+				listing.IsTruncated = true
+				listing.NextKeyMarker, listing.NextVersionIdMarker = last.Key, last.VersionId
+				break
+			}
+			entry := fakeS3VersionEntry{
+				XMLName:      xml.Name{Local: "Version"},
+				Key:          key,
+				VersionId:    v.id,
+				IsLatest:     i == len(f.versions[key])-1,
+				LastModified: v.modified.UTC().Format(fakeS3TimeFormat),
+			}
+			if v.marker {
+				entry.XMLName.Local = "DeleteMarker"
+			} else {
+				size := len(v.body)
+				entry.ETag, entry.Size = etag(v.body), &size
+			}
+			listing.Entries = append(listing.Entries, entry)
+		}
+		if listing.IsTruncated {
+			break
+		}
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	_ = xml.NewEncoder(w).Encode(listing)
+}
+
+// serveDeleteObjects serves DeleteObjects of versions.
+func (f *fakeS3) serveDeleteObjects(w http.ResponseWriter, r *http.Request) {
+	var req fakeS3Delete
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		fakeS3Error(w, http.StatusBadRequest, "MalformedXML")
+		return
+	}
+	for _, object := range req.Objects {
+		i := slices.IndexFunc(f.versions[object.Key], func(v fakeS3Version) bool { return v.id == object.VersionId })
+		if i >= 0 {
+			f.deleteVersion(object.Key, i)
+		}
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	fmt.Fprint(w, "<DeleteResult></DeleteResult>")
+}
+
+// serveTagging serves GetObjectTagging and PutObjectTagging for key.
+func (f *fakeS3) serveTagging(w http.ResponseWriter, r *http.Request, key string, exists bool) {
+	if !exists {
+		fakeS3Error(w, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/xml")
+		_ = xml.NewEncoder(w).Encode(fakeS3Tagging{TagSet: f.tags[key]})
+	case http.MethodPut:
+		var tagging fakeS3Tagging
+		if err := xml.NewDecoder(r.Body).Decode(&tagging); err != nil {
+			fakeS3Error(w, http.StatusBadRequest, "MalformedXML")
+			return
+		}
+		if f.tags == nil {
+			f.tags = map[string][]fakeS3Tag{}
+		}
+		f.tags[key] = tagging.TagSet
+	default:
+		fakeS3Error(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func fakeS3Error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+// newFakeS3Ops returns an S3Ops talking to a fakeS3 served for the test.
+func newFakeS3Ops(t *testing.T, opts ...libstore.S3Option) *libstore.S3Ops {
+	t.Helper()
+	ops, err := openFakeS3Ops(t, &fakeS3{bucket: "bucket", objects: map[string][]byte{}}, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ops
+}
+
+// openFakeS3Ops serves fake for the test and opens an S3Ops on its bucket "bucket".
+func openFakeS3Ops(t *testing.T, fake *fakeS3, opts ...libstore.S3Option) (*libstore.S3Ops, error) {
+	t.Helper()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_CONFIG_FILE", "/nonexistent")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/nonexistent")
+	opts = append([]libstore.S3Option{libstore.WithS3ClientOptions(func(o *s3.Options) {
+		o.BaseEndpoint = aws.String(server.URL)
+		o.UsePathStyle = true
+	})}, opts...)
+	return libstore.NewS3Ops(context.TODO(), "bucket", opts...)
+}
+
+func TestS3LazyConnect(t *testing.T) {
+	ctx := context.Background()
+	ops := newFakeS3Ops(t, libstore.WithS3LazyConnect(), libstore.WithRequestStats())
+	if stats := ops.Stats(); stats != (libstore.S3Stats{}) {
+		t.Errorf("Expected no request from NewS3Ops, Got: %+v", stats)
+	}
+	if err := ops.Create(ctx, "key"); err != nil {
+		t.Fatalf("Error creating key: %v", err)
+	}
+	if _, err := ops.List(ctx); err != nil {
+		t.Fatalf("Error listing keys: %v", err)
+	}
+	// The bucket is checked once, before the HeadObject of Create.
+	if stats := ops.Stats(); stats.Head != 2 {
+		t.Errorf("Expected a HeadBucket and a HeadObject, Got: %+v", stats)
+	}
+
+	// The bucket is missing: NewS3Ops succeeds and the operations report it.
+	ops, err := openFakeS3Ops(t, &fakeS3{bucket: "other", objects: map[string][]byte{}}, libstore.WithS3LazyConnect(), libstore.WithRequestStats())
+	if err != nil {
+		t.Fatalf("NewS3Ops() error = %v, want nil until the first operation", err)
+	}
+	for range 2 {
+		_, err := ops.Read(ctx, "key")
+		var locationErr libstore.LocationError
+		var notFound libstore.KeyNotFoundError
+		if !errors.As(err, &locationErr) || errors.As(err, &notFound) {
+			t.Errorf("Expected a LocationError for the missing bucket, Got: %T %v", err, err)
+		}
+	}
+	if stats := ops.Stats(); stats.Head != 1 || stats.Get != 0 {
+		t.Errorf("Expected the failed check to be cached, Got: %+v", stats)
+	}
+}
+
+func TestS3Stats(t *testing.T) {
+	ctx := context.Background()
+	ops := newFakeS3Ops(t, libstore.WithRequestStats())
+	if stats := ops.Stats(); stats != (libstore.S3Stats{Head: 1}) {
+		t.Errorf("Expected only the HeadBucket of NewS3Ops, Got: %+v", stats)
+	}
+
+	if err := ops.Create(ctx, "key"); err != nil {
+		t.Fatalf("Error creating key: %v", err)
+	}
+	if err := ops.Put(ctx, "key", []byte("entry")); err != nil {
+		t.Fatalf("Error putting entry: %v", err)
+	}
+	if entry, err := ops.Read(ctx, "key"); err != nil || string(entry) != "entry" {
+		t.Fatalf("Unexpected entry: %q, %v", entry, err)
+	}
+	if keys, err := ops.List(ctx); err != nil || !slices.Equal(keys, []string{"key"}) {
+		t.Fatalf("Unexpected keys: %v, %v", keys, err)
+	}
+	if err := ops.Delete(ctx, "key"); err != nil {
+		t.Fatalf("Error deleting key: %v", err)
+	}
+
+	// Create costs a HeadObject and a PutObject.
+	want := libstore.S3Stats{Get: 1, Put: 2, List: 1, Delete: 1, Head: 2}
+	if stats := ops.Stats(); stats != want {
+		t.Errorf("Expected %+v, Got: %+v", want, stats)
+	}
+
+	if stats := newFakeS3Ops(t).Stats(); stats != (libstore.S3Stats{}) {
+		t.Errorf("Expected no counts without WithRequestStats, Got: %+v", stats)
+	}
+}
+
+func TestS3ListPaging(t *testing.T) {
+	ctx := context.Background()
+	create := func(ops *libstore.S3Ops) {
+		for i := range 7 {
+			if err := ops.Create(ctx, fmt.Sprintf("key%d", i)); err != nil {
+				t.Fatalf("Error creating key: %v", err)
+			}
+		}
+	}
+
+	ops := newFakeS3Ops(t, libstore.WithRequestStats(), libstore.WithListPageSize(3))
+	create(ops)
+	before := ops.Stats().List
+	keys, err := ops.List(ctx)
+	if err != nil || len(keys) != 7 || !slices.IsSorted(keys) {
+		t.Fatalf("Unexpected keys: %v, %v", keys, err)
+	}
+	if pages := ops.Stats().List - before; pages != 3 {
+		t.Errorf("Expected 7 keys in 3 pages, Got: %d pages", pages)
+	}
+
+	before = ops.Stats().List
+	keys, err = ops.List(libstore.WithOpOptions(ctx, libstore.ListLimit(4)))
+	if err != nil || !slices.Equal(keys, []string{"key0", "key1", "key2", "key3"}) {
+		t.Fatalf("Expected the first 4 keys, Got: %v, %v", keys, err)
+	}
+	if pages := ops.Stats().List - before; pages != 2 {
+		t.Errorf("Expected List to stop after 2 pages, Got: %d", pages)
+	}
+
+	ops = newFakeS3Ops(t, libstore.WithRequestStats())
+	create(ops)
+	before = ops.Stats().List
+	keys, err = ops.List(libstore.WithOpOptions(ctx, libstore.ListLimit(2)))
+	if err != nil || !slices.Equal(keys, []string{"key0", "key1"}) {
+		t.Fatalf("Expected the first 2 keys, Got: %v, %v", keys, err)
+	}
+	if pages := ops.Stats().List - before; pages != 1 {
+		t.Errorf("Expected a single page of 2 keys, Got: %d pages", pages)
+	}
+
+	// The limit applies to the call it is passed to only.
+	if keys, err := ops.List(ctx); err != nil || len(keys) != 7 {
+		t.Errorf("Expected a List without the option to return every key, Got: %v, %v", keys, err)
+	}
+}
+
+func TestS3ReadWithToken(t *testing.T) {
+	testReadWithToken(t, newFakeS3Ops(t), "key")
+}
+
+func TestS3ContextTags(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeS3{bucket: "bucket", objects: map[string][]byte{}}
+	ops, err := openFakeS3Ops(t, fake, libstore.WithS3ContextTags("trace-id", "source"), libstore.WithS3PreserveTags())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags := func() map[string]string {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		tags := map[string]string{}
+		for _, tag := range fake.tags["key"] {
+			tags[tag.Key] = tag.Value
+		}
+		return tags
+	}
+
+	tagged := context.WithValue(ctx, libstore.S3TagKey("trace-id"), "abc 123")
+	tagged = context.WithValue(tagged, libstore.S3TagKey("source"), "billing&co")
+	tagged = context.WithValue(tagged, libstore.S3TagKey("unregistered"), "ignored")
+	if err := ops.Create(tagged, "key"); err != nil {
+		t.Fatalf("Error creating key: %v", err)
+	}
+	want := map[string]string{"libstore-trace-id": "abc 123", "libstore-source": "billing&co"}
+	if got := tags(); !maps.Equal(got, want) {
+		t.Errorf("Expected tags %v after Create, Got: %v", want, got)
+	}
+	if meta, err := ops.ReadMetadata(ctx, "key"); err != nil || len(meta) != 0 {
+		t.Errorf("Expected ReadMetadata to leave out the context tags, Got: %v, %v", meta, err)
+	}
+
+	// The metadata and the context tags are kept by the writes of each other.
+	if err := ops.PutMetadata(ctx, "key", libstore.Metadata{"owner": "alice"}); err != nil {
+		t.Fatalf("Error putting metadata: %v", err)
+	}
+	retagged := context.WithValue(ctx, libstore.S3TagKey("source"), "ledger")
+	if err := ops.Put(retagged, "key", []byte("entry")); err != nil {
+		t.Fatalf("Error putting entry: %v", err)
+	}
+	want = map[string]string{"libstore-trace-id": "abc 123", "libstore-source": "ledger", "owner": "alice"}
+	if got := tags(); !maps.Equal(got, want) {
+		t.Errorf("Expected tags %v after Put, Got: %v", want, got)
+	}
+	if err := ops.Put(ctx, "key", []byte("untagged")); err != nil {
+		t.Fatalf("Error putting entry: %v", err)
+	}
+	if err := ops.PutMetadata(ctx, "key", libstore.Metadata{"owner": "bob"}); err != nil {
+		t.Fatalf("Error putting metadata: %v", err)
+	}
+	want["owner"] = "bob"
+	if got := tags(); !maps.Equal(got, want) {
+		t.Errorf("Expected a context without values and PutMetadata to keep the context tags, Got: %v", got)
+	}
+	if meta, err := ops.ReadMetadata(ctx, "key"); err != nil || !maps.Equal(meta, libstore.Metadata{"owner": "bob"}) {
+		t.Errorf("ReadMetadata() = %v, %v, want only the metadata", meta, err)
+	}
+	keys, err := ops.ListWhere(ctx, libstore.MetaPredicate{{Field: "libstore-source", Op: libstore.MetaEquals, Value: "ledger"}})
+	if err != nil || len(keys) != 0 {
+		t.Errorf("Expected ListWhere not to match the context tags, Got: %v, %v", keys, err)
+	}
+
+	var keyErr libstore.KeyError
+	if err := ops.PutMetadata(ctx, "key", libstore.Metadata{"libstore-source": "forged"}); !errors.As(err, &keyErr) {
+		t.Errorf("Expected a KeyError for a reserved metadata field, Got: %v", err)
+	}
+}
+
+func TestS3PutKeepsMetadata(t *testing.T) {
+	ctx := context.Background()
+	ops := newFakeS3Ops(t, libstore.WithS3PreserveTags())
+	if err := ops.Create(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	want := libstore.Metadata{"owner": "alice", "status": "active"}
+	if err := ops.PutMetadata(ctx, "key", want); err != nil {
+		t.Fatal(err)
+	}
+
+	writes := map[string]func() error{
+		"Put":     func() error { return ops.Put(ctx, "key", []byte("a")) },
+		"PutFrom": func() error { return ops.PutFrom(ctx, "key", strings.NewReader("b")) },
+		"PutAndGetPrevious": func() error {
+			_, err := ops.PutAndGetPrevious(ctx, "key", []byte("c"))
+			return err
+		},
+	}
+	for name, write := range writes {
+		if err := write(); err != nil {
+			t.Fatalf("Error in %s: %v", name, err)
+		}
+		if meta, err := ops.ReadMetadata(ctx, "key"); err != nil || !maps.Equal(meta, want) {
+			t.Errorf("Expected %s to keep the metadata %v, Got: %v, %v", name, want, meta, err)
+		}
+	}
+	keys, err := ops.ListWhere(ctx, libstore.MetaPredicate{{Field: "owner", Op: libstore.MetaEquals, Value: "alice"}})
+	if err != nil || !slices.Equal(keys, []string{"key"}) {
+		t.Errorf("Expected the key to still match its metadata, Got: %v, %v", keys, err)
+	}
+}
+
+func TestS3PutDropsMetadataByDefault(t *testing.T) {
+	ctx := context.Background()
+	ops := newFakeS3Ops(t, libstore.WithRequestStats())
+	if err := ops.Create(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ops.PutMetadata(ctx, "key", libstore.Metadata{"owner": "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	before := ops.Stats()
+	if err := ops.Put(ctx, "key", []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	// Put is a single PutObject, without reading the tags first.
+	if stats := ops.Stats(); stats.Get != before.Get || stats.Put != before.Put+1 {
+		t.Errorf("Expected Put to cost one PutObject, Got: %+v after %+v", stats, before)
+	}
+	if meta, err := ops.ReadMetadata(ctx, "key"); err != nil || len(meta) != 0 {
+		t.Errorf("Expected Put to drop the metadata without WithS3PreserveTags, Got: %v, %v", meta, err)
+	}
+}
+
+func TestS3ReadConsistency(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeS3{bucket: "bucket", objects: map[string][]byte{"key": []byte("entry")}, lagging: map[string]int{}}
+	strong, err := openFakeS3Ops(t, fake)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventual, err := openFakeS3Ops(t, fake, libstore.WithS3ReadConsistency(libstore.Eventual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lag := func(gets int) {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		fake.lagging["key"] = gets
+	}
+
+	lag(1)
+	if _, err := strong.Read(ctx, "key"); err == nil {
+		t.Errorf("Expected the first read with Strong consistency to fail")
+	}
+	lag(1)
+	if entry, err := eventual.Read(ctx, "key"); err != nil || string(entry) != "entry" {
+		t.Errorf("Expected Read to retry until the entry shows up, Got: %q, %v", entry, err)
+	}
+	lag(2)
+	if entries, err := eventual.ReadAll(ctx, "key"); err != nil || len(entries) != 1 || string(entries[0]) != "entry" {
+		t.Errorf("Expected ReadAll to retry until the entry shows up, Got: %q, %v", entries, err)
+	}
+	lag(1)
+	if _, err := eventual.Read(libstore.WithOpOptions(ctx, libstore.ReadConsistency(libstore.Strong)), "key"); err == nil {
+		t.Errorf("Expected a read asking for Strong consistency not to be retried")
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	lag(1)
+	var timeoutErr libstore.TimeoutError
+	if _, err := eventual.Read(canceled, "key"); !errors.As(err, &timeoutErr) {
+		t.Errorf("Expected a TimeoutError once the context is done, Got: %v", err)
+	}
+}
+
+func TestS3BucketGone(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeS3{bucket: "bucket", objects: map[string][]byte{}}
+	ops, err := openFakeS3Ops(t, fake)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ops.Create(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	var notFoundErr libstore.KeyNotFoundError
+	if _, err := ops.ReadMetadata(ctx, "missing"); !errors.As(err, &notFoundErr) {
+		t.Fatalf("Expected a KeyNotFoundError while the bucket exists, Got: %v", err)
+	}
+	for name, read := range map[string]func() error{
+		"Read":    func() error { _, err := ops.Read(ctx, "missing"); return err },
+		"ReadAll": func() error { _, err := ops.ReadAll(ctx, "missing"); return err },
+	} {
+		if err := read(); !errors.As(err, &notFoundErr) {
+			t.Errorf("Expected a KeyNotFoundError from %s of a missing key while the bucket exists, Got: %v", name, err)
+		}
+	}
+	fake.mu.Lock()
+	fake.bucket = "removed"
+	fake.mu.Unlock()
+
+	calls := map[string]func() error{
+		"Create":       func() error { return ops.Create(ctx, "new") },
+		"Read":         func() error { _, err := ops.Read(ctx, "key"); return err },
+		"ReadAll":      func() error { _, err := ops.ReadAll(ctx, "key"); return err },
+		"Put":          func() error { return ops.Put(ctx, "key", []byte("entry")) },
+		"Delete":       func() error { return ops.Delete(ctx, "key") },
+		"List":         func() error { _, err := ops.List(ctx); return err },
+		"ReadMetadata": func() error { _, err := ops.ReadMetadata(ctx, "key"); return err },
+	}
+	for name, call := range calls {
+		err := call()
+		var locationErr libstore.LocationError
+		if !errors.As(err, &locationErr) || errors.As(err, &notFoundErr) {
+			t.Errorf("Expected a LocationError from %s once the bucket is gone, Got: %v", name, err)
+		}
+	}
+}
+
+func TestS3ContentType(t *testing.T) {
+	ctx := context.Background()
+	ops := newFakeS3Ops(t)
+	if err := ops.Create(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+
+	typed := libstore.WithOpOptions(ctx, libstore.ContentType("application/json"))
+	if err := ops.Put(typed, "key", []byte(`{"a":1}`)); err != nil {
+		t.Fatalf("Error putting entry: %v", err)
+	}
+	if contentType, err := ops.ReadContentType(ctx, "key"); err != nil || contentType != "application/json" {
+		t.Errorf("Expected application/json, Got: %q, %v", contentType, err)
+	}
+
+	// Later options keep the earlier ones they do not override.
+	durable := libstore.WithOpOptions(typed, libstore.Durable())
+	if err := ops.PutFrom(durable, "key", strings.NewReader(`{"a":2}`)); err != nil {
+		t.Fatalf("Error streaming entry: %v", err)
+	}
+	if contentType, err := ops.ReadContentType(ctx, "key"); err != nil || contentType != "application/json" {
+		t.Errorf("Expected the content type to survive adding an option, Got: %q, %v", contentType, err)
+	}
+	overridden := libstore.WithOpOptions(typed, libstore.ContentType("text/plain"))
+	if err := ops.Put(overridden, "key", []byte("a=3")); err != nil {
+		t.Fatal(err)
+	}
+	if contentType, err := ops.ReadContentType(ctx, "key"); err != nil || contentType != "text/plain" {
+		t.Errorf("Expected the later content type to win, Got: %q, %v", contentType, err)
+	}
+
+	var notFound libstore.KeyNotFoundError
+	if _, err := ops.ReadContentType(ctx, "missing"); !errors.As(err, &notFound) {
+		t.Errorf("Expected a KeyNotFoundError for a missing key, Got: %v", err)
+	}
+}
+
+func TestS3MultipartPut(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeS3{bucket: "bucket", objects: map[string][]byte{}}
+	const partSize = 5 * 1024 * 1024
+	ops, err := openFakeS3Ops(t, fake, libstore.WithMultipartThreshold(1024), libstore.WithMultipartPartSize(partSize), libstore.WithMultipartConcurrency(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ops.Create(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+
+	small := []byte("below the threshold")
+	if err := ops.Put(ctx, "key", small); err != nil {
+		t.Fatalf("Error putting entry: %v", err)
+	}
+	if fake.completed != 0 {
+		t.Errorf("Expected an entry below the threshold to be sent in one request, Got: %d uploads", fake.completed)
+	}
+
+	large := make([]byte, 2*partSize+1234)
+	for i := range large {
+		large[i] = byte(i % 251)
+	}
+	if err := ops.Put(ctx, "key", large); err != nil {
+		t.Fatalf("Error putting large entry: %v", err)
+	}
+	if fake.completed != 1 {
+		t.Errorf("Expected one multipart upload, Got: %d", fake.completed)
+	}
+	if entry, err := ops.Read(ctx, "key"); err != nil || !bytes.Equal(entry, large) {
+		t.Errorf("Expected the large entry to read back, Got: %d bytes, %v", len(entry), err)
+	}
+
+	fake.mu.Lock()
+	fake.failParts = true
+	fake.mu.Unlock()
+	var internalErr libstore.OpsInternalError
+	if err := ops.Put(ctx, "key", append(large, 'x')); !errors.As(err, &internalErr) {
+		t.Errorf("Expected an OpsInternalError for a failed upload, Got: %v", err)
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if fake.aborted != 1 || len(fake.uploads) != 0 {
+		t.Errorf("Expected the failed upload to be aborted, Got: %d aborted, %d in progress", fake.aborted, len(fake.uploads))
+	}
+	if !bytes.Equal(fake.objects["key"], large) {
+		t.Error("Expected the failed upload to leave the previous entry in place")
+	}
+}
+
+func TestS3NativeVersioning(t *testing.T) {
+	ctx := context.Background()
+	var locationErr libstore.LocationError
+	if _, err := openFakeS3Ops(t, &fakeS3{bucket: "bucket", objects: map[string][]byte{}}, libstore.WithNativeVersioning()); !errors.As(err, &locationErr) {
+		t.Errorf("Expected a LocationError for a bucket without versioning, Got: %v", err)
+	}
+
+	fake := &fakeS3{bucket: "bucket", objects: map[string][]byte{}, versioned: true}
+	ops, err := openFakeS3Ops(t, fake, libstore.WithNativeVersioning())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The fake dates versions to the second, so every version and delete marker
+	// below ties and only the listing order tells them apart.
+	if err := ops.Create(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range []string{"a", "b"} {
+		if err := ops.Put(ctx, "key", []byte(entry)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ids, err := ops.Versions(ctx, "key")
+	if err != nil || len(ids) != 2 {
+		t.Fatalf("Expected the 2 versions put, Got: %v, %v", ids, err)
+	}
+	if entry, err := ops.ReadAt(ctx, "key", ids[0]); err != nil || string(entry) != "a" {
+		t.Errorf("Expected the oldest version to be a, Got: %q, %v", entry, err)
+	}
+	var notFound libstore.KeyNotFoundError
+	if _, err := ops.ReadAt(ctx, "key", "missing"); !errors.As(err, &notFound) {
+		t.Errorf("Expected a KeyNotFoundError for a missing version, Got: %v", err)
+	}
+
+	if err := ops.Delete(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ops.ReadAll(ctx, "key"); !errors.As(err, &notFound) {
+		t.Errorf("Expected a KeyNotFoundError for a deleted key, Got: %v", err)
+	}
+	if err := ops.Create(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ops.Put(ctx, "key", []byte("c")); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := ops.ReadAll(ctx, "key")
+	if err != nil || len(entries) != 1 || string(entries[0]) != "c" {
+		t.Errorf("Expected only the version put since the key was recreated, Got: %q, %v", entries, err)
+	}
+	if entry, err := ops.Read(ctx, "key"); err != nil || string(entry) != "c" {
+		t.Errorf("Expected the latest version, Got: %q, %v", entry, err)
+	}
+
+	purging, err := openFakeS3Ops(t, fake, libstore.WithNativeVersioning(), libstore.WithPurgeOnDelete())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := purging.Delete(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	if versions := fake.versions["key"]; len(versions) != 0 {
+		t.Errorf("Expected every version and delete marker to be purged, Got: %d left", len(versions))
+	}
+	if err := purging.Delete(ctx, "key"); !errors.As(err, &notFound) {
+		t.Errorf("Expected a KeyNotFoundError purging a purged key, Got: %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package libstore
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// S3Stats counts the S3 requests sent by an S3Ops, grouped the way S3 prices them.
+type S3Stats struct {
+	// Get counts GetObject and other reads of a single resource.
+	Get int64
+	// Put counts PutObject, the calls of multipart uploads and other writes.
+	Put int64
+	// List counts ListObjectsV2, ListObjectVersions and other listings.
+	List int64
+	// Delete counts DeleteObject, DeleteObjects and aborted multipart uploads.
+	Delete int64
+	// Head counts HeadObject and HeadBucket.
+	Head int64
+}
+
+// s3Counters is the live, concurrency-safe form of S3Stats.
+type s3Counters struct {
+	get, put, list, delete, head atomic.Int64
+}
+
+// register adds a middleware counting every call made through the client to stack.
+// It runs once per SDK call, so retries of a call are not counted again.
+func (c *s3Counters) register(stack *middleware.Stack) error {
+	return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("libstoreRequestStats",
+		func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+			c.count(awsmiddleware.GetOperationName(ctx))
+			return next.HandleInitialize(ctx, in)
+		}), middleware.After)
+}
+
+func (c *s3Counters) count(operation string) {
+	switch {
+	case strings.HasPrefix(operation, "Head"):
+		c.head.Add(1)
+	case strings.HasPrefix(operation, "List"):
+		c.list.Add(1)
+	case strings.HasPrefix(operation, "Get"):
+		c.get.Add(1)
+	case strings.HasPrefix(operation, "Delete"), strings.HasPrefix(operation, "Abort"):
+		c.delete.Add(1)
+	default:
+		c.put.Add(1)
+	}
+}
+
+// Stats returns the number of requests sent so far, including the HeadBucket sent by
+// NewS3Ops and the HeadObject hidden in Create. It returns zero counts unless the
+// S3Ops was created with WithRequestStats.
+func (s *S3Ops) Stats() S3Stats {
+	if s.stats == nil {
+		return S3Stats{}
+	}
+	return S3Stats{
+		Get:    s.stats.get.Load(),
+		Put:    s.stats.put.Load(),
+		List:   s.stats.list.Load(),
+		Delete: s.stats.delete.Load(),
+		Head:   s.stats.head.Load(),
+	}
+}
@@ -0,0 +1,113 @@
+package libstore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// SchemaOption configures NewSchemaOps.
+type SchemaOption func(*schemaOps)
+
+// SchemaValidateReads makes Read and ReadAll validate every entry they return too, so
+// documents corrupted or written around the wrapper are reported instead of decoded.
+func SchemaValidateReads() SchemaOption {
+	return func(s *schemaOps) {
+		s.validateReads = true
+	}
+}
+
+// schemaOps rejects entries that are not JSON documents valid against its schema.
+type schemaOps struct {
+	ops           Ops
+	schema        *jsonschema.Schema
+	validateReads bool
+}
+
+// NewSchemaOps wraps ops so that every entry must be a JSON document valid against
+// schema: Put validates the entry and returns a ValidationError, wrapping the
+// *jsonschema.ValidationError with the details of each violation, without writing it.
+// With SchemaValidateReads, entries read back are validated as well.
+//
+// The schema is compiled by the caller, for instance with jsonschema.NewCompiler,
+// and is safe to share between wrappers.
+func NewSchemaOps(ops Ops, schema *jsonschema.Schema, opts ...SchemaOption) Ops {
+	s := schemaOps{ops: ops, schema: schema}
+	for _, opt := range opts {
+		opt(&s)
+	}
+	return s
+}
+
+// validate returns a ValidationError if entry is not valid JSON or violates the schema.
+func (s schemaOps) validate(key string, entry []byte) error {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(entry))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ValidationError("schema: entry of key "+key+" is not valid JSON"), err)
+	}
+	if err := s.schema.Validate(doc); err != nil {
+		return fmt.Errorf("%w: %w", ValidationError("schema: entry of key "+key+" violates the schema"), err)
+	}
+	return nil
+}
+
+// Unwrap implements Unwrapper.
+func (s schemaOps) Unwrap() Ops {
+	return s.ops
+}
+
+// Create implements Ops.
+func (s schemaOps) Create(ctx context.Context, key string) error {
+	return s.ops.Create(ctx, key)
+}
+
+// ReadAll implements Ops.
+func (s schemaOps) ReadAll(ctx context.Context, key string) ([][]byte, error) {
+	entries, err := s.ops.ReadAll(ctx, key)
+	if err != nil || !s.validateReads {
+		return entries, err
+	}
+	for _, entry := range entries {
+		if err := s.validate(key, entry); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// Read implements Ops.
+func (s schemaOps) Read(ctx context.Context, key string) ([]byte, error) {
+	entry, err := s.ops.Read(ctx, key)
+	if err != nil || !s.validateReads {
+		return entry, err
+	}
+	if err := s.validate(key, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// Put implements Ops.
+func (s schemaOps) Put(ctx context.Context, key string, entry []byte) error {
+	if err := s.validate(key, entry); err != nil {
+		return err
+	}
+	return s.ops.Put(ctx, key, entry)
+}
+
+// Delete implements Ops.
+func (s schemaOps) Delete(ctx context.Context, key string) error {
+	return s.ops.Delete(ctx, key)
+}
+
+// List implements Ops.
+func (s schemaOps) List(ctx context.Context) ([]string, error) {
+	return s.ops.List(ctx)
+}
+
+var (
+	_ Ops       = schemaOps{}
+	_ Unwrapper = schemaOps{}
+)
@@ -0,0 +1,98 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cecmp/libstore"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+const userSchema = `{
+	"type": "object",
+	"properties": {
+		"name": {"type": "string"},
+		"age": {"type": "integer", "minimum": 0}
+	},
+	"required": ["name"]
+}`
+
+func compileSchema(t *testing.T, schema string) *jsonschema.Schema {
+	t.Helper()
+	doc, err := jsonschema.UnmarshalJSON(strings.NewReader(schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := jsonschema.NewCompiler()
+	if err := c.AddResource("user.json", doc); err != nil {
+		t.Fatal(err)
+	}
+	compiled, err := c.Compile("user.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return compiled
+}
+
+func TestSchemaOps(t *testing.T) {
+	ctx := context.Background()
+	backend := libstore.NewInMemoryOps()
+	ops := libstore.NewSchemaOps(backend, compileSchema(t, userSchema))
+	if err := ops.Create(ctx, "user"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ops.Put(ctx, "user", []byte(`{"name": "ada", "age": 36}`)); err != nil {
+		t.Fatalf("Put() of a valid document error = %v", err)
+	}
+
+	for name, doc := range map[string]string{
+		"missing field": `{"age": 36}`,
+		"wrong type":    `{"name": "ada", "age": "old"}`,
+		"below minimum": `{"name": "ada", "age": -1}`,
+		"not JSON":      `{"name": `,
+	} {
+		err := ops.Put(ctx, "user", []byte(doc))
+		var validationErr libstore.ValidationError
+		if !errors.As(err, &validationErr) {
+			t.Errorf("%s: Expected a ValidationError, Got: %v", name, err)
+		}
+	}
+	var violation *jsonschema.ValidationError
+	if err := ops.Put(ctx, "user", []byte(`{"age": 36}`)); !errors.As(err, &violation) {
+		t.Errorf("Expected the violation details to be wrapped, Got: %v", err)
+	}
+
+	entries, err := backend.ReadAll(ctx, "user")
+	if err != nil || len(entries) != 1 {
+		t.Errorf("Expected only the valid document to be stored, Got: %q, %v", entries, err)
+	}
+}
+
+func TestSchemaOpsValidateReads(t *testing.T) {
+	ctx := context.Background()
+	backend := libstore.NewInMemoryOps()
+	schema := compileSchema(t, userSchema)
+	if err := backend.Create(ctx, "user"); err != nil {
+		t.Fatal(err)
+	}
+	// Written around the wrapper.
+	if err := backend.Put(ctx, "user", []byte(`{"age": 36}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := libstore.NewSchemaOps(backend, schema).Read(ctx, "user"); err != nil {
+		t.Errorf("Expected reads not to be validated by default, Got: %v", err)
+	}
+
+	ops := libstore.NewSchemaOps(backend, schema, libstore.SchemaValidateReads())
+	var validationErr libstore.ValidationError
+	if _, err := ops.Read(ctx, "user"); !errors.As(err, &validationErr) {
+		t.Errorf("Read() error = %v, want ValidationError", err)
+	}
+	if _, err := ops.ReadAll(ctx, "user"); !errors.As(err, &validationErr) {
+		t.Errorf("ReadAll() error = %v, want ValidationError", err)
+	}
+}
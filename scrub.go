@@ -0,0 +1,39 @@
+package libstore
+
+import (
+	"context"
+)
+
+// ScrubSummary counts the keys checked by Scrub.
+type ScrubSummary struct {
+	// Scanned is the number of keys read.
+	Scanned int
+	// Failed is the number of keys whose entries could not be read back.
+	Failed int
+}
+
+// Scrub reads back every entry of every key of cs and reports the keys that fail,
+// for instance because an entry of a CryptStore no longer decrypts or fails its
+// integrity or timestamp checks.
+//
+// report is called once per failing key and scrubbing continues with the next key.
+// Scrub stops early only if the keys cannot be listed or ctx is done, and returns
+// the counts so far along with that error. It works over any Ops, but is meant for
+// CryptStore and DerivedCryptStore, whose reads verify every entry.
+func Scrub(ctx context.Context, cs Ops, report func(key string, err error)) (ScrubSummary, error) {
+	var summary ScrubSummary
+	for key, err := range ListSeq(ctx, cs) {
+		if err != nil {
+			return summary, err
+		}
+		if err := ctx.Err(); err != nil {
+			return summary, annotateTimeout("scrub", err)
+		}
+		summary.Scanned++
+		if _, err := cs.ReadAll(ctx, key); err != nil {
+			summary.Failed++
+			report(key, err)
+		}
+	}
+	return summary, nil
+}
@@ -0,0 +1,164 @@
+package libstore
+
+import (
+	"context"
+	"sync"
+)
+
+// serializedOps runs every call on a single worker goroutine.
+type serializedOps struct {
+	ops   Ops
+	calls chan *serializedCall
+	done  chan struct{}
+
+	// mu guards closed; senders hold it for reading so calls is not closed under them.
+	mu     sync.RWMutex
+	closed bool
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// serializedCall is a call queued for the worker.
+type serializedCall struct {
+	ctx  context.Context
+	fn   func() error
+	err  error
+	done chan struct{}
+}
+
+// NewSerializedOps wraps ops so that no two of its calls ever run concurrently: every
+// call is handed to a single worker goroutine and run there, one at a time, in the
+// order the worker receives them. This makes a backend that is not safe for
+// concurrent use, such as one around an SQLite connection, safe to share.
+//
+// A call whose context ends while it waits for the worker is abandoned without
+// reaching ops, and returns the context's error. A call already running is waited
+// for; ops sees its context and can give up on its own.
+//
+// The returned Ops implements io.Closer and Drainer: Close and Drain let the calls
+// already handed over finish, stop the worker and then drain ops. Later calls fail
+// with a ClosedError.
+func NewSerializedOps(ops Ops) Ops {
+	s := &serializedOps{
+		ops:   ops,
+		calls: make(chan *serializedCall),
+		done:  make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// run is the worker, running each call unless its context has ended.
+func (s *serializedOps) run() {
+	defer close(s.done)
+	for call := range s.calls {
+		if err := call.ctx.Err(); err != nil {
+			call.err = annotateTimeout("serialized: queued call", err)
+		} else {
+			call.err = call.fn()
+		}
+		close(call.done)
+	}
+}
+
+// do hands fn to the worker and waits for its result.
+func (s *serializedOps) do(ctx context.Context, fn func() error) error {
+	call := &serializedCall{ctx: ctx, fn: fn, done: make(chan struct{})}
+
+	s.mu.RLock()
+	if s.closed {
+		s.mu.RUnlock()
+		return ClosedError("serialized: ops is closed")
+	}
+	select {
+	case s.calls <- call:
+		s.mu.RUnlock()
+	case <-ctx.Done():
+		s.mu.RUnlock()
+		return annotateTimeout("serialized: waiting for worker", ctx.Err())
+	}
+
+	<-call.done
+	return call.err
+}
+
+// Drain implements Drainer. Calling it again returns the result of the first call.
+func (s *serializedOps) Drain(ctx context.Context) error {
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		s.closed = true
+		close(s.calls)
+		s.mu.Unlock()
+		<-s.done
+		s.closeErr = Drain(ctx, s.ops)
+	})
+	return s.closeErr
+}
+
+// Close stops the worker and drains the underlying Ops.
+func (s *serializedOps) Close() error {
+	return s.Drain(context.Background())
+}
+
+// Unwrap implements Unwrapper.
+func (s *serializedOps) Unwrap() Ops {
+	return s.ops
+}
+
+// Create implements Ops.
+func (s *serializedOps) Create(ctx context.Context, key string) error {
+	return s.do(ctx, func() error {
+		return s.ops.Create(ctx, key)
+	})
+}
+
+// ReadAll implements Ops.
+func (s *serializedOps) ReadAll(ctx context.Context, key string) ([][]byte, error) {
+	var entries [][]byte
+	err := s.do(ctx, func() (err error) {
+		entries, err = s.ops.ReadAll(ctx, key)
+		return err
+	})
+	return entries, err
+}
+
+// Read implements Ops.
+func (s *serializedOps) Read(ctx context.Context, key string) ([]byte, error) {
+	var entry []byte
+	err := s.do(ctx, func() (err error) {
+		entry, err = s.ops.Read(ctx, key)
+		return err
+	})
+	return entry, err
+}
+
+// Put implements Ops.
+func (s *serializedOps) Put(ctx context.Context, key string, entry []byte) error {
+	return s.do(ctx, func() error {
+		return s.ops.Put(ctx, key, entry)
+	})
+}
+
+// Delete implements Ops.
+func (s *serializedOps) Delete(ctx context.Context, key string) error {
+	return s.do(ctx, func() error {
+		return s.ops.Delete(ctx, key)
+	})
+}
+
+// List implements Ops.
+func (s *serializedOps) List(ctx context.Context) ([]string, error) {
+	var keys []string
+	err := s.do(ctx, func() (err error) {
+		keys, err = s.ops.List(ctx)
+		return err
+	})
+	return keys, err
+}
+
+var (
+	_ Ops       = (*serializedOps)(nil)
+	_ Drainer   = (*serializedOps)(nil)
+	_ Unwrapper = (*serializedOps)(nil)
+)
@@ -0,0 +1,166 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cecmp/libstore"
+)
+
+// unsafeOps is an Ops without any locking that fails calls overlapping another call.
+type unsafeOps struct {
+	entries  map[string][][]byte
+	inFlight atomic.Int32
+	overlaps atomic.Int32
+	calls    int
+	// gate, if set, is received from at the start of every call.
+	gate chan struct{}
+}
+
+func (u *unsafeOps) enter() func() {
+	if u.inFlight.Add(1) > 1 {
+		u.overlaps.Add(1)
+	}
+	if u.gate != nil {
+		<-u.gate
+	}
+	u.calls++
+	time.Sleep(10 * time.Microsecond)
+	return func() { u.inFlight.Add(-1) }
+}
+
+func (u *unsafeOps) Create(ctx context.Context, key string) error {
+	defer u.enter()()
+	if _, ok := u.entries[key]; ok {
+		return libstore.KeyError("key exists: " + key)
+	}
+	u.entries[key] = nil
+	return nil
+}
+
+func (u *unsafeOps) ReadAll(ctx context.Context, key string) ([][]byte, error) {
+	defer u.enter()()
+	entries, ok := u.entries[key]
+	if !ok {
+		return nil, libstore.KeyNotFoundError("key not found: " + key)
+	}
+	return entries, nil
+}
+
+func (u *unsafeOps) Read(ctx context.Context, key string) ([]byte, error) {
+	defer u.enter()()
+	entries, ok := u.entries[key]
+	if !ok {
+		return nil, libstore.KeyNotFoundError("key not found: " + key)
+	}
+	if len(entries) == 0 {
+		return nil, libstore.EntryError("no entries: " + key)
+	}
+	return entries[len(entries)-1], nil
+}
+
+func (u *unsafeOps) Put(ctx context.Context, key string, entry []byte) error {
+	defer u.enter()()
+	if _, ok := u.entries[key]; !ok {
+		return libstore.KeyNotFoundError("key not found: " + key)
+	}
+	u.entries[key] = append(u.entries[key], entry)
+	return nil
+}
+
+func (u *unsafeOps) Delete(ctx context.Context, key string) error {
+	defer u.enter()()
+	delete(u.entries, key)
+	return nil
+}
+
+func (u *unsafeOps) List(ctx context.Context) ([]string, error) {
+	defer u.enter()()
+	var keys []string
+	for key := range u.entries {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func TestSerializedOpsConcurrentCallers(t *testing.T) {
+	backend := &unsafeOps{entries: map[string][][]byte{}}
+	ops := libstore.NewSerializedOps(backend)
+	defer ops.(io.Closer).Close()
+
+	const callers, puts = 16, 50
+	var wg sync.WaitGroup
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			key := fmt.Sprintf("key%d", i)
+			if err := ops.Create(context.Background(), key); err != nil {
+				t.Errorf("Error creating key: %v", err)
+				return
+			}
+			for j := range puts {
+				if err := ops.Put(context.Background(), key, []byte(fmt.Sprint(j))); err != nil {
+					t.Errorf("Error putting entry: %v", err)
+				}
+				if _, err := ops.List(context.Background()); err != nil {
+					t.Errorf("Error listing keys: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := backend.overlaps.Load(); n != 0 {
+		t.Errorf("Expected no overlapping backend calls, Got: %d", n)
+	}
+	for i := range callers {
+		entries, err := ops.ReadAll(context.Background(), fmt.Sprintf("key%d", i))
+		if err != nil || len(entries) != puts {
+			t.Errorf("Expected %d entries for key%d, Got: %d, %v", puts, i, len(entries), err)
+		}
+	}
+}
+
+func TestSerializedOpsAbandonsCancelledCalls(t *testing.T) {
+	backend := &unsafeOps{entries: map[string][][]byte{}, gate: make(chan struct{})}
+	ops := libstore.NewSerializedOps(backend)
+
+	// Occupy the worker until the gate opens.
+	first := make(chan error)
+	go func() { first <- ops.Create(context.Background(), "first") }()
+	for backend.inFlight.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := ops.Create(ctx, "abandoned"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the queued call to end with its context, Got: %v", err)
+	}
+
+	close(backend.gate)
+	if err := <-first; err != nil {
+		t.Fatalf("Error creating key: %v", err)
+	}
+	if _, err := ops.ReadAll(context.Background(), "abandoned"); !errors.As(err, new(libstore.KeyNotFoundError)) {
+		t.Errorf("Expected the abandoned Create not to reach the backend, Got: %v", err)
+	}
+
+	if err := ops.(io.Closer).Close(); err != nil {
+		t.Fatalf("Error closing: %v", err)
+	}
+	var closed libstore.ClosedError
+	if err := ops.Create(context.Background(), "late"); !errors.As(err, &closed) {
+		t.Errorf("Expected a ClosedError after Close, Got: %v", err)
+	}
+	if backend.calls != 2 {
+		t.Errorf("Expected 2 backend calls, Got: %d", backend.calls)
+	}
+}
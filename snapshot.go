@@ -0,0 +1,32 @@
+package libstore
+
+import (
+	"context"
+)
+
+// Snapshot reads several keys as of a single point in time, so writes made while it
+// is open are not observed and reads across keys are never torn.
+type Snapshot interface {
+	// Read reads the last entry of the given key as of the snapshot.
+	Read(ctx context.Context, key string) ([]byte, error)
+	// ReadAll reads all entries of the given key as of the snapshot.
+	ReadAll(ctx context.Context, key string) ([][]byte, error)
+	// Close releases the snapshot. It must be called once the snapshot is no longer needed.
+	Close() error
+}
+
+// Snapshotter is implemented by backends that can provide consistent multi-key reads.
+type Snapshotter interface {
+	// Snapshot captures the current state of the store.
+	Snapshot(ctx context.Context) (Snapshot, error)
+}
+
+// NewSnapshot captures the current state of ops.
+// It returns an UnsupportedError if ops does not implement Snapshotter.
+func NewSnapshot(ctx context.Context, ops Ops) (Snapshot, error) {
+	snapshotter, ok := ops.(Snapshotter)
+	if !ok {
+		return nil, UnsupportedError("snapshots are not supported by this store")
+	}
+	return snapshotter.Snapshot(ctx)
+}
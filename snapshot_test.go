@@ -0,0 +1,67 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestNewSnapshotUnsupported(t *testing.T) {
+	var unsupported libstore.UnsupportedError
+	if _, err := libstore.NewSnapshot(context.Background(), libstore.NewInMemoryOps()); !errors.As(err, &unsupported) {
+		t.Errorf("Expected an UnsupportedError for a store without snapshots, Got: %v", err)
+	}
+}
+
+func TestDBSnapshot(t *testing.T) {
+	ctx := context.Background()
+	ops := newTestDBOps(t)
+	a, b := testKey(t)+"/a", testKey(t)+"/b"
+	for _, key := range []string{a, b} {
+		if err := ops.Create(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+		if err := ops.Put(ctx, key, []byte("v1")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snapshot, err := libstore.NewSnapshot(ctx, ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snapshot.Close()
+
+	// Written after the snapshot was taken but before anything was read from it.
+	for _, key := range []string{a, b} {
+		if err := ops.Put(ctx, key, []byte("v2")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, key := range []string{a, b} {
+		if entry, err := snapshot.Read(ctx, key); err != nil || string(entry) != "v1" {
+			t.Errorf("Expected the snapshot to read v1 for %s, Got: %q, %v", key, entry, err)
+		}
+		if entries, err := snapshot.ReadAll(ctx, key); err != nil || len(entries) != 1 {
+			t.Errorf("Expected the snapshot to read 1 entry for %s, Got: %q, %v", key, entries, err)
+		}
+		if entry, err := ops.Read(ctx, key); err != nil || string(entry) != "v2" {
+			t.Errorf("Expected the store to read v2 for %s, Got: %q, %v", key, entry, err)
+		}
+	}
+
+	var notFound libstore.KeyNotFoundError
+	if _, err := snapshot.Read(ctx, testKey(t)+"/missing"); !errors.As(err, &notFound) {
+		t.Errorf("Expected a KeyNotFoundError for a missing key, Got: %v", err)
+	}
+
+	if err := snapshot.Close(); err != nil {
+		t.Errorf("Expected Close to succeed, Got: %v", err)
+	}
+	if err := snapshot.Close(); err != nil {
+		t.Errorf("Expected a second Close to succeed, Got: %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package libstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// StreamPutter is implemented by backends that can write an entry from a reader
+// without holding it in memory.
+type StreamPutter interface {
+	// PutFrom writes the content of r as a new entry of the given key, like Put.
+	PutFrom(ctx context.Context, key string, r io.Reader) error
+}
+
+// StreamReader is implemented by backends that can copy the latest entry of a key to
+// a writer without holding it in memory.
+type StreamReader interface {
+	// ReadTo writes the latest entry of the given key to w, like Read.
+	ReadTo(ctx context.Context, key string, w io.Writer) error
+}
+
+// PutFrom writes the content of r as a new entry of key.
+// Backends that do not implement StreamPutter receive the fully buffered entry via Put.
+func PutFrom(ctx context.Context, ops Ops, key string, r io.Reader) error {
+	if putter, ok := ops.(StreamPutter); ok {
+		return putter.PutFrom(ctx, key, r)
+	}
+	entry, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("%w: %w", EntryError("failed to read entry"), err)
+	}
+	return ops.Put(ctx, key, entry)
+}
+
+// ReadTo writes the latest entry of key to w.
+// Backends that do not implement StreamReader are read fully via Read first.
+func ReadTo(ctx context.Context, ops Ops, key string, w io.Writer) error {
+	if reader, ok := ops.(StreamReader); ok {
+		return reader.ReadTo(ctx, key, w)
+	}
+	entry, err := ops.Read(ctx, key)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(entry); err != nil {
+		return fmt.Errorf("%w: %w", EntryError("failed to write entry"), err)
+	}
+	return nil
+}
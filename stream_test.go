@@ -0,0 +1,85 @@
+package libstore_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestStream(t *testing.T) {
+	for name, newOps := range testBackends(t, "InMemory", "Fallback", "File", "S3") {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			ops := newOps(t)
+			key := filepath.Base(testKey(t))
+			if err := ops.Create(ctx, key); err != nil {
+				t.Fatal(err)
+			}
+			// Longer than the chunk the file backend scans backwards with.
+			large := strings.Repeat("x", 100*1024)
+			for _, entry := range []string{"v1", large} {
+				if err := libstore.PutFrom(ctx, ops, key, strings.NewReader(entry)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if entry, err := ops.Read(ctx, key); err != nil || string(entry) != large {
+				t.Errorf("Expected Read to return the streamed entry, Got: %d bytes, %v", len(entry), err)
+			}
+			var buf bytes.Buffer
+			if err := libstore.ReadTo(ctx, ops, key, &buf); err != nil || buf.String() != large {
+				t.Errorf("Expected ReadTo to write the latest entry, Got: %d bytes, %v", buf.Len(), err)
+			}
+
+			var notFound libstore.KeyNotFoundError
+			if err := libstore.ReadTo(ctx, ops, "missing", &buf); !errors.As(err, &notFound) {
+				t.Errorf("Expected a KeyNotFoundError for a missing key, Got: %v", err)
+			}
+		})
+	}
+}
+
+func TestFileReadTo(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	ops, err := libstore.NewFileOps(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ops.(libstore.StreamReader); !ok {
+		t.Fatal("Expected the file backend to implement StreamReader")
+	}
+
+	for content, want := range map[string]string{
+		"a\nb":       "b",
+		"a\nb\n":     "b",
+		"a\r\nb\r\n": "b",
+		"only":       "only",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, "key"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := libstore.ReadTo(ctx, ops, "key", &buf); err != nil || buf.String() != want {
+			t.Errorf("Expected %q for %q, Got: %q, %v", want, content, buf.String(), err)
+		}
+		entry, err := ops.Read(ctx, "key")
+		if err != nil || string(entry) != buf.String() {
+			t.Errorf("Expected ReadTo to match Read for %q, Got: %q, %v", content, entry, err)
+		}
+	}
+
+	if err := ops.Create(ctx, "empty"); err != nil {
+		t.Fatal(err)
+	}
+	var entryErr libstore.EntryError
+	if err := libstore.ReadTo(ctx, ops, "empty", &bytes.Buffer{}); !errors.As(err, &entryErr) {
+		t.Errorf("Expected an EntryError for an empty file, Got: %v", err)
+	}
+}
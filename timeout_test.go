@@ -0,0 +1,87 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cecmp/libstore"
+)
+
+// expiredContext returns a context whose deadline has already passed.
+func expiredContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestExpiredContextTimeoutError(t *testing.T) {
+	backends := testBackends(t, "S3", "DB")
+	backends["JetStream"] = newTestJetStreamOps
+	backends["HTTPClient"] = func(t *testing.T) libstore.Ops { return newHTTPClientOps(t, libstore.NewInMemoryOps()) }
+	backends["Serialized"] = func(t *testing.T) libstore.Ops {
+		ops := libstore.NewSerializedOps(newFakeS3Ops(t))
+		t.Cleanup(func() { libstore.Drain(context.Background(), ops) })
+		return ops
+	}
+
+	for name, newOps := range backends {
+		t.Run(name, func(t *testing.T) {
+			ops := newOps(t)
+			calls := map[string]func(ctx context.Context) error{
+				"Create": func(ctx context.Context) error { return ops.Create(ctx, "key") },
+				"Read": func(ctx context.Context) error {
+					_, err := ops.Read(ctx, "key")
+					return err
+				},
+				"ReadAll": func(ctx context.Context) error {
+					_, err := ops.ReadAll(ctx, "key")
+					return err
+				},
+				"Put":    func(ctx context.Context) error { return ops.Put(ctx, "key", []byte("entry")) },
+				"Delete": func(ctx context.Context) error { return ops.Delete(ctx, "key") },
+				"List": func(ctx context.Context) error {
+					_, err := ops.List(ctx)
+					return err
+				},
+			}
+			for op, call := range calls {
+				err := call(expiredContext(t))
+				var timeout libstore.TimeoutError
+				if !errors.As(err, &timeout) {
+					t.Errorf("%s: Expected a TimeoutError, Got: %v", op, err)
+				}
+				if !errors.Is(err, context.DeadlineExceeded) {
+					t.Errorf("%s: Expected the error to wrap context.DeadlineExceeded, Got: %v", op, err)
+				}
+			}
+		})
+	}
+}
+
+func TestTimeoutErrorOverHTTP(t *testing.T) {
+	backend := libstore.NewSerializedOps(newFakeS3Ops(t))
+	defer libstore.Drain(context.Background(), backend)
+
+	// The server's backend times out on a context of its own; the client's is still live.
+	client := newHTTPClientOps(t, libstore.Chain(backend, func(ops libstore.Ops) libstore.Ops {
+		return deadlineOps{ops}
+	}))
+	_, err := client.Read(context.Background(), "key")
+	var timeout libstore.TimeoutError
+	if !errors.As(err, &timeout) {
+		t.Errorf("Expected the server's TimeoutError to reach the client, Got: %v", err)
+	}
+}
+
+// deadlineOps reads with an already expired context, whatever the caller passed.
+type deadlineOps struct {
+	libstore.Ops
+}
+
+func (d deadlineOps) Read(ctx context.Context, key string) ([]byte, error) {
+	ctx, cancel := context.WithDeadline(ctx, time.Now().Add(-time.Second))
+	defer cancel()
+	return d.Ops.Read(ctx, key)
+}
@@ -0,0 +1,58 @@
+package libstore
+
+import "context"
+
+// TokenReader is implemented by backends that can identify the latest entry of a key
+// by a token cheaper or more precise than a hash of its content.
+type TokenReader interface {
+	// ReadWithToken returns the latest entry of key, like Read, along with a token
+	// identifying it.
+	ReadWithToken(ctx context.Context, key string) ([]byte, string, error)
+}
+
+// ConditionalReader is implemented by backends that can skip transferring an entry
+// the caller already holds.
+type ConditionalReader interface {
+	// ReadIfNoneMatch returns the latest entry of key unless its token is token, in
+	// which case it reports unchanged with a nil entry.
+	ReadIfNoneMatch(ctx context.Context, key string, token string) ([]byte, bool, error)
+}
+
+// ReadWithToken returns the latest entry of key along with an opaque token that
+// identifies it, for instance as an HTTP ETag. Reads of the same entry return the
+// same token, and the token changes whenever the content of the entry does.
+//
+// The token is the ETag of the object for S3Ops, the version and row of the entry
+// for the database backend, and the Checksum of the entry for backends not
+// implementing TokenReader. Tokens are only comparable with tokens read from the
+// same store.
+func ReadWithToken(ctx context.Context, ops Ops, key string) ([]byte, string, error) {
+	if reader, ok := ops.(TokenReader); ok {
+		return reader.ReadWithToken(ctx, key)
+	}
+	entry, err := ops.Read(ctx, key)
+	if err != nil {
+		return nil, "", err
+	}
+	return entry, Checksum(entry), nil
+}
+
+// ReadIfNoneMatch returns the latest entry of key unless it is still the one token,
+// as returned by ReadWithToken, identifies; then it reports unchanged and returns a
+// nil entry. Missing keys and keys without entries fail like Read.
+//
+// Backends implementing ConditionalReader do not transfer an unchanged entry. For
+// the others it is read with ReadWithToken and compared.
+func ReadIfNoneMatch(ctx context.Context, ops Ops, key string, token string) ([]byte, bool, error) {
+	if reader, ok := ops.(ConditionalReader); ok {
+		return reader.ReadIfNoneMatch(ctx, key, token)
+	}
+	entry, current, err := ReadWithToken(ctx, ops, key)
+	if err != nil {
+		return nil, false, err
+	}
+	if current == token {
+		return nil, true, nil
+	}
+	return entry, false, nil
+}
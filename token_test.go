@@ -0,0 +1,56 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+// testReadWithToken checks that tokens read from ops are stable across reads of an
+// entry and go stale once it is replaced.
+func testReadWithToken(t *testing.T, ops libstore.Ops, key string) {
+	t.Helper()
+	ctx := context.Background()
+	if err := ops.Create(ctx, key); err != nil {
+		t.Fatalf("Error creating key: %v", err)
+	}
+	var entryErr libstore.EntryError
+	if _, _, err := libstore.ReadWithToken(ctx, ops, key); !errors.As(err, &entryErr) {
+		t.Errorf("Expected an EntryError for a key without entries, Got: %v", err)
+	}
+	if err := ops.Put(ctx, key, []byte("first")); err != nil {
+		t.Fatalf("Error putting entry: %v", err)
+	}
+	entry, token, err := libstore.ReadWithToken(ctx, ops, key)
+	if err != nil || string(entry) != "first" || token == "" {
+		t.Fatalf("ReadWithToken() = %q, %q, %v", entry, token, err)
+	}
+	if _, again, err := libstore.ReadWithToken(ctx, ops, key); err != nil || again != token {
+		t.Errorf("Expected the token to be stable, Got: %q then %q, %v", token, again, err)
+	}
+	if entry, unchanged, err := libstore.ReadIfNoneMatch(ctx, ops, key, token); err != nil || !unchanged || entry != nil {
+		t.Errorf("ReadIfNoneMatch() with a matching token = %q, %v, %v, want unchanged", entry, unchanged, err)
+	}
+
+	if err := ops.Put(ctx, key, []byte("second")); err != nil {
+		t.Fatalf("Error putting entry: %v", err)
+	}
+	entry, unchanged, err := libstore.ReadIfNoneMatch(ctx, ops, key, token)
+	if err != nil || unchanged || string(entry) != "second" {
+		t.Errorf("ReadIfNoneMatch() with a stale token = %q, %v, %v, want the new entry", entry, unchanged, err)
+	}
+	if _, current, err := libstore.ReadWithToken(ctx, ops, key); err != nil || current == token {
+		t.Errorf("Expected a new token for the new entry, Got: %q, %v", current, err)
+	}
+
+	var notFound libstore.KeyNotFoundError
+	if _, _, err := libstore.ReadIfNoneMatch(ctx, ops, key+"-missing", token); !errors.As(err, &notFound) {
+		t.Errorf("Expected a KeyNotFoundError, Got: %v", err)
+	}
+}
+
+func TestReadWithTokenFallback(t *testing.T) {
+	testReadWithToken(t, newRecordingOps(libstore.NewInMemoryOps()), "key")
+}
@@ -0,0 +1,120 @@
+package libstore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// KeyRules describes the keys accepted by NewValidatedKeyOps.
+// Keys must always be non-empty, valid UTF-8.
+type KeyRules struct {
+	// MaxLength is the maximum key length in bytes, or 0 for no limit.
+	MaxLength int
+	// Allowed reports whether a rune may appear in a key. A nil Allowed accepts every rune.
+	Allowed func(r rune) bool
+}
+
+var (
+	// S3KeyRules matches the S3 limit of 1024 bytes per object key.
+	S3KeyRules = KeyRules{MaxLength: 1024}
+	// FileKeyRules matches the common 255-byte file name limit and rejects path
+	// separators and NUL, which cannot appear in a file name.
+	FileKeyRules = KeyRules{
+		MaxLength: 255,
+		Allowed: func(r rune) bool {
+			return r != 0 && r != '/' && r != '\\'
+		},
+	}
+	// DBKeyRules rejects NUL, which PostgreSQL TEXT columns cannot store.
+	DBKeyRules = KeyRules{
+		Allowed: func(r rune) bool {
+			return r != 0
+		},
+	}
+)
+
+// Validate returns a KeyError if key does not satisfy the rules.
+func (r KeyRules) Validate(key string) error {
+	if key == "" {
+		return KeyError("invalid key: key is empty")
+	}
+	if !utf8.ValidString(key) {
+		return KeyError(fmt.Sprintf("invalid key %q: not valid UTF-8", key))
+	}
+	if r.MaxLength > 0 && len(key) > r.MaxLength {
+		return KeyError(fmt.Sprintf("invalid key %q: %d bytes exceeds the maximum of %d", key, len(key), r.MaxLength))
+	}
+	if r.Allowed != nil {
+		if i := strings.IndexFunc(key, func(c rune) bool { return !r.Allowed(c) }); i >= 0 {
+			c, _ := utf8.DecodeRuneInString(key[i:])
+			return KeyError(fmt.Sprintf("invalid key %q: character %q at byte %d is not allowed", key, c, i))
+		}
+	}
+	return nil
+}
+
+// validatedKeyOps rejects keys that do not satisfy its rules before delegating.
+type validatedKeyOps struct {
+	ops   Ops
+	rules KeyRules
+}
+
+// NewValidatedKeyOps wraps ops so that every key is checked against rules before it
+// reaches the backend. Invalid keys are rejected with a KeyError.
+func NewValidatedKeyOps(ops Ops, rules KeyRules) Ops {
+	return validatedKeyOps{ops: ops, rules: rules}
+}
+
+// Unwrap implements Unwrapper.
+func (v validatedKeyOps) Unwrap() Ops {
+	return v.ops
+}
+
+// Create implements Ops.
+func (v validatedKeyOps) Create(ctx context.Context, key string) error {
+	if err := v.rules.Validate(key); err != nil {
+		return err
+	}
+	return v.ops.Create(ctx, key)
+}
+
+// ReadAll implements Ops.
+func (v validatedKeyOps) ReadAll(ctx context.Context, key string) ([][]byte, error) {
+	if err := v.rules.Validate(key); err != nil {
+		return nil, err
+	}
+	return v.ops.ReadAll(ctx, key)
+}
+
+// Read implements Ops.
+func (v validatedKeyOps) Read(ctx context.Context, key string) ([]byte, error) {
+	if err := v.rules.Validate(key); err != nil {
+		return nil, err
+	}
+	return v.ops.Read(ctx, key)
+}
+
+// Put implements Ops.
+func (v validatedKeyOps) Put(ctx context.Context, key string, entry []byte) error {
+	if err := v.rules.Validate(key); err != nil {
+		return err
+	}
+	return v.ops.Put(ctx, key, entry)
+}
+
+// Delete implements Ops.
+func (v validatedKeyOps) Delete(ctx context.Context, key string) error {
+	if err := v.rules.Validate(key); err != nil {
+		return err
+	}
+	return v.ops.Delete(ctx, key)
+}
+
+// List implements Ops.
+func (v validatedKeyOps) List(ctx context.Context) ([]string, error) {
+	return v.ops.List(ctx)
+}
+
+var _ Ops = validatedKeyOps{}
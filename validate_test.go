@@ -0,0 +1,53 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestValidatedKeyOps(t *testing.T) {
+	ops := libstore.NewValidatedKeyOps(libstore.NewInMemoryOps(), libstore.S3KeyRules)
+
+	tests := []struct {
+		name  string
+		key   string
+		valid bool
+	}{
+		{"empty", "", false},
+		{"max length", strings.Repeat("k", 1024), true},
+		{"over max length", strings.Repeat("k", 1025), false},
+		{"multi-byte over max length", strings.Repeat("é", 513), false},
+		{"invalid utf-8", "key\xff", false},
+		{"unicode", "clé/ключ", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ops.Create(context.TODO(), tt.key)
+			if tt.valid && err != nil {
+				t.Errorf("Expected key to be accepted, Got: %v", err)
+			}
+			var keyErr libstore.KeyError
+			if !tt.valid && !errors.As(err, &keyErr) {
+				t.Errorf("Expected a KeyError, Got: %v", err)
+			}
+		})
+	}
+}
+
+func TestFileKeyRulesCharset(t *testing.T) {
+	for _, key := range []string{"a/b", "a\\b", "a\x00b"} {
+		if err := libstore.FileKeyRules.Validate(key); err == nil {
+			t.Errorf("Expected key %q to be rejected", key)
+		}
+	}
+	if err := libstore.FileKeyRules.Validate(strings.Repeat("k", 255)); err != nil {
+		t.Errorf("Expected 255-byte key to be accepted, Got: %v", err)
+	}
+	if err := libstore.FileKeyRules.Validate(strings.Repeat("k", 256)); err == nil {
+		t.Error("Expected 256-byte key to be rejected")
+	}
+}
@@ -0,0 +1,115 @@
+package libstore
+
+import (
+	"context"
+	"fmt"
+)
+
+// VersionCapOption configures NewVersionCapOps.
+type VersionCapOption func(*versionCapOps)
+
+// VersionCapAutoCompact makes Put drop the oldest entries of a key beyond the cap
+// instead of rejecting the entry, with PutCapped. The underlying Ops must implement
+// CappedPutter.
+func VersionCapAutoCompact() VersionCapOption {
+	return func(v *versionCapOps) {
+		v.autoCompact = true
+	}
+}
+
+// versionCapOps bounds the number of entries of every key.
+type versionCapOps struct {
+	ops         Ops
+	maxVersions int
+	autoCompact bool
+}
+
+// NewVersionCapOps wraps ops so that no key grows beyond maxVersions entries, which
+// protects backends such as the database from the slow queries of keys with
+// unbounded histories. By default Put counts the entries of the key with Count and
+// returns a VersionLimitError without writing once there are maxVersions, so
+// callers must compact the key, for instance with PutCapped, to write again. With
+// VersionCapAutoCompact, Put appends and prunes the oldest entries in one step
+// instead, applying PutCapped store-wide.
+//
+// The default check and the write are separate calls, so concurrent Puts to a key
+// at the cap may each get through once. Put returns an EntryError if maxVersions is
+// not positive.
+//
+// On backends whose Put replaces the entry of a key, such as InMemoryOps and S3Ops,
+// a key never holds more than one entry, so a cap above one never applies and a cap
+// of one rejects every Put after the first unless VersionCapAutoCompact is set.
+func NewVersionCapOps(ops Ops, maxVersions int, opts ...VersionCapOption) Ops {
+	v := versionCapOps{ops: ops, maxVersions: maxVersions}
+	for _, opt := range opts {
+		opt(&v)
+	}
+	return v
+}
+
+// Put implements libstore.Ops, rejecting or compacting keys at the cap.
+func (v versionCapOps) Put(ctx context.Context, key string, entry []byte) error {
+	if v.maxVersions < 1 {
+		return EntryError(fmt.Sprintf("max versions must be positive, got %d", v.maxVersions))
+	}
+	if v.autoCompact {
+		return PutCapped(ctx, v.ops, key, entry, v.maxVersions)
+	}
+	count, err := Count(ctx, v.ops, key)
+	if err != nil {
+		return err
+	}
+	if count >= v.maxVersions {
+		return VersionLimitError(fmt.Sprintf("key %s has %d entries, the most allowed is %d", key, count, v.maxVersions))
+	}
+	return v.ops.Put(ctx, key, entry)
+}
+
+// PutCapped implements CappedPutter with the PutCapped of the underlying Ops,
+// keeping at most the smaller of maxEntries and the cap, so callers can compact a
+// key at the cap through the wrapper.
+func (v versionCapOps) PutCapped(ctx context.Context, key string, entry []byte, maxEntries int) error {
+	return PutCapped(ctx, v.ops, key, entry, min(maxEntries, v.maxVersions))
+}
+
+// Count implements EntryCounter.
+func (v versionCapOps) Count(ctx context.Context, key string) (int, error) {
+	return Count(ctx, v.ops, key)
+}
+
+// Create implements libstore.Ops.
+func (v versionCapOps) Create(ctx context.Context, key string) error {
+	return v.ops.Create(ctx, key)
+}
+
+// ReadAll implements libstore.Ops.
+func (v versionCapOps) ReadAll(ctx context.Context, key string) ([][]byte, error) {
+	return v.ops.ReadAll(ctx, key)
+}
+
+// Read implements libstore.Ops.
+func (v versionCapOps) Read(ctx context.Context, key string) ([]byte, error) {
+	return v.ops.Read(ctx, key)
+}
+
+// Delete implements libstore.Ops.
+func (v versionCapOps) Delete(ctx context.Context, key string) error {
+	return v.ops.Delete(ctx, key)
+}
+
+// List implements libstore.Ops.
+func (v versionCapOps) List(ctx context.Context) ([]string, error) {
+	return v.ops.List(ctx)
+}
+
+// Unwrap implements Unwrapper.
+func (v versionCapOps) Unwrap() Ops {
+	return v.ops
+}
+
+var (
+	_ Ops          = versionCapOps{}
+	_ CappedPutter = versionCapOps{}
+	_ EntryCounter = versionCapOps{}
+	_ Unwrapper    = versionCapOps{}
+)
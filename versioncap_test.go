@@ -0,0 +1,76 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+func TestVersionCapOps(t *testing.T) {
+	// Put appends to the file and database backends, so their keys grow.
+	backends := testBackends(t, "File", "DB")
+	for name, newOps := range backends {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			backend := newOps(t)
+			key := filepath.Base(testKey(t))
+			if err := backend.Create(ctx, key); err != nil {
+				t.Fatal(err)
+			}
+
+			ops := libstore.NewVersionCapOps(backend, 3)
+			for i := range 3 {
+				if err := ops.Put(ctx, key, []byte(fmt.Sprint(i))); err != nil {
+					t.Fatalf("Error putting entry %d below the cap: %v", i, err)
+				}
+			}
+			var limitErr libstore.VersionLimitError
+			if err := ops.Put(ctx, key, []byte("3")); !errors.As(err, &limitErr) {
+				t.Fatalf("Expected a VersionLimitError at the cap, Got: %v", err)
+			}
+			if count, err := libstore.Count(ctx, ops, key); err != nil || count != 3 {
+				t.Errorf("Expected the rejected entry not to be written, Got: %d entries, %v", count, err)
+			}
+
+			compacting := libstore.NewVersionCapOps(backend, 3, libstore.VersionCapAutoCompact())
+			for _, entry := range []string{"3", "4"} {
+				if err := compacting.Put(ctx, key, []byte(entry)); err != nil {
+					t.Fatalf("Error putting entry beyond the cap: %v", err)
+				}
+			}
+			entries, err := ops.ReadAll(ctx, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := [][]byte{[]byte("2"), []byte("3"), []byte("4")}; !slices.EqualFunc(entries, want, slices.Equal) {
+				t.Errorf("Expected the newest entries %q, Got: %q", want, entries)
+			}
+
+			// PutCapped through the wrapper compacts, and never beyond the cap.
+			if err := libstore.PutCapped(ctx, ops, key, []byte("5"), 10); err != nil {
+				t.Fatalf("Error in PutCapped through the wrapper: %v", err)
+			}
+			entries, err = ops.ReadAll(ctx, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := [][]byte{[]byte("3"), []byte("4"), []byte("5")}; !slices.EqualFunc(entries, want, slices.Equal) {
+				t.Errorf("Expected PutCapped to keep the newest entries %q, Got: %q", want, entries)
+			}
+		})
+	}
+
+	var notFound libstore.KeyNotFoundError
+	if err := libstore.NewVersionCapOps(libstore.NewInMemoryOps(), 3).Put(context.Background(), "missing", nil); !errors.As(err, &notFound) {
+		t.Errorf("Expected a KeyNotFoundError for a missing key, Got: %v", err)
+	}
+	var entryErr libstore.EntryError
+	if err := libstore.NewVersionCapOps(libstore.NewInMemoryOps(), 0).Put(context.Background(), "key", nil); !errors.As(err, &entryErr) {
+		t.Errorf("Expected an EntryError for a cap of 0, Got: %v", err)
+	}
+}
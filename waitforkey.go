@@ -0,0 +1,90 @@
+package libstore
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Watcher is implemented by backends that can push the writes to a key as they happen.
+type Watcher interface {
+	// Watch returns a channel receiving every entry written to key after Watch
+	// returns. The channel is closed once ctx ends; a receiver that falls behind
+	// holds up the watch rather than missing entries.
+	Watch(ctx context.Context, key string) (<-chan []byte, error)
+}
+
+// WaitForKey blocks until key holds an entry and returns its latest entry. A key
+// that already holds one is returned at once.
+//
+// On backends implementing Watcher it subscribes to key and returns as soon as an
+// entry is written; if the watch ends before ctx does, it falls back to polling. The
+// other backends are polled with Read every poll interval. A key created without
+// entries counts as absent. It returns a TimeoutError once ctx ends and an
+// EntryError if poll is not positive.
+func WaitForKey(ctx context.Context, ops Ops, key string, poll time.Duration) ([]byte, error) {
+	if poll <= 0 {
+		return nil, EntryError("wait: poll interval must be positive")
+	}
+	if watcher, ok := ops.(Watcher); ok {
+		entry, ok, err := waitWatched(ctx, watcher, ops, key)
+		if ok || err != nil {
+			return entry, err
+		}
+	}
+
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+	for {
+		entry, ok, err := readIfPresent(ctx, ops, key)
+		if ok || err != nil {
+			return entry, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, annotateTimeout("waiting for key "+key, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// waitWatched waits for key through watcher. It reports false without an error if
+// the watch ended while ctx is still live.
+func waitWatched(ctx context.Context, watcher Watcher, ops Ops, key string) ([]byte, bool, error) {
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	entries, err := watcher.Watch(watchCtx, key)
+	if err != nil {
+		return nil, false, err
+	}
+	// The key may have been written before the watch started.
+	if entry, ok, err := readIfPresent(ctx, ops, key); ok || err != nil {
+		return entry, ok, err
+	}
+	select {
+	case entry, ok := <-entries:
+		if ok {
+			return entry, true, nil
+		}
+	case <-ctx.Done():
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, false, annotateTimeout("waiting for key "+key, err)
+	}
+	return nil, false, nil
+}
+
+// readIfPresent reads the latest entry of key, reporting false if the key does not
+// exist or holds no entries.
+func readIfPresent(ctx context.Context, ops Ops, key string) ([]byte, bool, error) {
+	entry, err := ops.Read(ctx, key)
+	var notFound KeyNotFoundError
+	var entryErr EntryError
+	if errors.As(err, &notFound) || errors.As(err, &entryErr) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return entry, true, nil
+}
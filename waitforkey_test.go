@@ -0,0 +1,94 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cecmp/libstore"
+)
+
+// watchingOps is an InMemoryOps implementing Watcher by forwarding its own Puts.
+type watchingOps struct {
+	*libstore.InMemoryOps
+	mu       sync.Mutex
+	watchers map[string][]chan []byte
+}
+
+func newWatchingOps() *watchingOps {
+	return &watchingOps{InMemoryOps: libstore.NewInMemoryOps(), watchers: map[string][]chan []byte{}}
+}
+
+func (w *watchingOps) Watch(ctx context.Context, key string) (<-chan []byte, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	entries := make(chan []byte, 1)
+	w.watchers[key] = append(w.watchers[key], entries)
+	return entries, nil
+}
+
+func (w *watchingOps) Put(ctx context.Context, key string, entry []byte) error {
+	if err := w.InMemoryOps.Put(ctx, key, entry); err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, entries := range w.watchers[key] {
+		entries <- entry
+	}
+	delete(w.watchers, key)
+	return nil
+}
+
+func TestWaitForKey(t *testing.T) {
+	backends := map[string]struct {
+		ops libstore.Ops
+		// poll is long enough on the watching backend that only the watch can
+		// deliver the entry in time.
+		poll time.Duration
+	}{
+		"Polling":  {libstore.NewInMemoryOps(), 5 * time.Millisecond},
+		"Watching": {newWatchingOps(), time.Hour},
+	}
+
+	for name, b := range backends {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			if err := b.ops.Create(ctx, "lock"); err != nil {
+				t.Fatal(err)
+			}
+			go func() {
+				time.Sleep(20 * time.Millisecond)
+				if err := b.ops.Put(ctx, "lock", []byte("owner")); err != nil {
+					t.Error(err)
+				}
+			}()
+			entry, err := libstore.WaitForKey(ctx, b.ops, "lock", b.poll)
+			if err != nil || string(entry) != "owner" {
+				t.Fatalf("WaitForKey() = %q, %v, want owner", entry, err)
+			}
+
+			// A key already holding an entry is returned at once.
+			entry, err = libstore.WaitForKey(ctx, b.ops, "lock", b.poll)
+			if err != nil || string(entry) != "owner" {
+				t.Errorf("WaitForKey() on an existing key = %q, %v, want owner", entry, err)
+			}
+
+			short, cancelShort := context.WithTimeout(ctx, 20*time.Millisecond)
+			defer cancelShort()
+			var timeout libstore.TimeoutError
+			if _, err := libstore.WaitForKey(short, b.ops, "missing", b.poll); !errors.As(err, &timeout) {
+				t.Errorf("WaitForKey() on a missing key error = %v, want TimeoutError", err)
+			}
+		})
+	}
+
+	var entryErr libstore.EntryError
+	if _, err := libstore.WaitForKey(context.Background(), libstore.NewInMemoryOps(), "key", 0); !errors.As(err, &entryErr) {
+		t.Errorf("WaitForKey() with a zero poll interval error = %v, want EntryError", err)
+	}
+}
@@ -0,0 +1,140 @@
+package libstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// stagingSuffix is appended to a key to name the key its entry is staged under by
+// WriteAll.
+const stagingSuffix = ".libstore-staging"
+
+// WriteAll puts the entry of every key in writes, with the strongest atomicity ops
+// can offer. The keys must already exist.
+//
+// Backends implementing BatchPutter, such as the database backend, apply all writes
+// in one transaction, so either every key receives its entry or none does and no
+// reader sees a partial write. InMemoryOps writes them in one WithTx transaction,
+// with the same guarantee.
+//
+// Other backends, such as S3 and the file backend, cannot write several keys
+// atomically. For them WriteAll first stages every entry under a temporary key,
+// named after the target key with a ".libstore-staging" suffix, and only once all
+// entries are staged promotes them, reading each back from its staged key and
+// putting it to its target key. A failure while staging removes the staged keys and
+// leaves the targets untouched. Promotion itself is not atomic: readers may see some
+// keys updated before others. A failure during promotion rolls the keys promoted
+// before it back, by putting the entry each held before or, for a key that held
+// none, deleting and recreating it, so Read returns what it did before WriteAll; on
+// backends whose Put appends, ReadAll keeps both the promoted and the restored entry. Staged keys are visible to
+// List until WriteAll returns.
+func WriteAll(ctx context.Context, ops Ops, writes map[string][]byte) error {
+	keys := make([]string, 0, len(writes))
+	for key := range writes {
+		keys = append(keys, key)
+	}
+	// A stable order keeps concurrent calls from locking the same keys in
+	// opposite orders.
+	slices.Sort(keys)
+
+	if putter, ok := ops.(BatchPutter); ok {
+		batch := make([]BatchEntry, len(keys))
+		for i, key := range keys {
+			batch[i] = BatchEntry{Key: key, Entry: writes[key]}
+		}
+		return putter.PutBatch(ctx, batch)
+	}
+	if runner, ok := ops.(txRunner); ok {
+		return runner.WithTx(func(tx TxOps) error {
+			for _, key := range keys {
+				if err := tx.Put(ctx, key, writes[key]); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+
+	existing, err := ops.List(ctx)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if !slices.Contains(existing, key) {
+			return KeyNotFoundError(fmt.Sprintf("key %s not found", key))
+		}
+		if strings.HasSuffix(key, stagingSuffix) {
+			return KeyError(fmt.Sprintf("key %s uses the reserved suffix %s", key, stagingSuffix))
+		}
+	}
+
+	staged := make([]string, 0, len(keys))
+	unstage := func() error {
+		var errs []error
+		for _, key := range staged {
+			if err := ops.Delete(ctx, key); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	}
+	for _, key := range keys {
+		stagingKey := key + stagingSuffix
+		if err := ops.Create(ctx, stagingKey); err != nil {
+			return errors.Join(err, unstage())
+		}
+		staged = append(staged, stagingKey)
+		if err := ops.Put(ctx, stagingKey, writes[key]); err != nil {
+			return errors.Join(err, unstage())
+		}
+	}
+
+	// promoted holds the keys promoted so far, with the entry each held before.
+	var promoted []BatchEntry
+	rollback := func() error {
+		var errs []error
+		for _, p := range slices.Backward(promoted) {
+			if p.Entry == nil {
+				errs = append(errs, ops.Delete(ctx, p.Key), ops.Create(ctx, p.Key))
+				continue
+			}
+			errs = append(errs, ops.Put(ctx, p.Key, p.Entry))
+		}
+		return errors.Join(errs...)
+	}
+	for i, key := range keys {
+		previous, err := promote(ctx, ops, key, key+stagingSuffix)
+		if err != nil {
+			return errors.Join(fmt.Errorf("%w: %w", OpsInternalError(fmt.Sprintf("write all: promoting write %d of %d", i+1, len(keys))), err), rollback(), unstage())
+		}
+		promoted = append(promoted, BatchEntry{Key: key, Entry: previous})
+	}
+	return unstage()
+}
+
+// promote puts the entry staged under stagingKey to key and returns the latest entry
+// key held before, or nil if it held none.
+func promote(ctx context.Context, ops Ops, key, stagingKey string) ([]byte, error) {
+	entry, err := ops.Read(ctx, stagingKey)
+	if err != nil {
+		return nil, err
+	}
+	var previous []byte
+	if putter, ok := ops.(PreviousPutter); ok {
+		if previous, err = putter.PutAndGetPrevious(ctx, key, entry); err != nil {
+			return nil, err
+		}
+	} else {
+		var entryErr EntryError
+		if previous, err = ops.Read(ctx, key); err != nil && !errors.As(err, &entryErr) {
+			return nil, err
+		}
+		if err := ops.Put(ctx, key, entry); err != nil {
+			return nil, err
+		}
+	}
+	return previous, nil
+}
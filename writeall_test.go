@@ -0,0 +1,146 @@
+package libstore_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cecmp/libstore"
+)
+
+// failingPutOps fails every Put to a key containing fail.
+type failingPutOps struct {
+	libstore.Ops
+	fail string
+}
+
+func (f failingPutOps) Put(ctx context.Context, key string, entry []byte) error {
+	if strings.Contains(key, f.fail) {
+		return libstore.OpsInternalError("injected failure")
+	}
+	return f.Ops.Put(ctx, key, entry)
+}
+
+func TestWriteAll(t *testing.T) {
+	ctx := context.Background()
+	backend, err := libstore.NewFileOps(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		if err := backend.Create(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := libstore.WriteAll(ctx, backend, map[string][]byte{"a": []byte("1"), "b": []byte("2")}); err != nil {
+		t.Fatalf("Error writing keys: %v", err)
+	}
+	for key, want := range map[string]string{"a": "1", "b": "2"} {
+		if entry, err := backend.Read(ctx, key); err != nil || string(entry) != want {
+			t.Errorf("Unexpected entry of %s: %q, %v", key, entry, err)
+		}
+	}
+
+	// Staging fails on the second key, so no key may be written.
+	ops := failingPutOps{Ops: backend, fail: "c"}
+	err = libstore.WriteAll(ctx, ops, map[string][]byte{"a": []byte("x"), "c": []byte("y")})
+	var internal libstore.OpsInternalError
+	if !errors.As(err, &internal) {
+		t.Fatalf("Expected the injected failure, Got: %v", err)
+	}
+	if entry, err := backend.Read(ctx, "a"); err != nil || string(entry) != "1" {
+		t.Errorf("Expected a to be untouched, Got: %q, %v", entry, err)
+	}
+	keys, err := backend.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(keys)
+	if !slices.Equal(keys, []string{"a", "b", "c"}) {
+		t.Errorf("Expected staged keys to be removed, Got: %v", keys)
+	}
+
+	var notFound libstore.KeyNotFoundError
+	if err := libstore.WriteAll(ctx, backend, map[string][]byte{"a": []byte("x"), "missing": nil}); !errors.As(err, &notFound) {
+		t.Errorf("Expected a KeyNotFoundError, Got: %v", err)
+	}
+	if entry, err := backend.Read(ctx, "a"); err != nil || string(entry) != "1" {
+		t.Errorf("Expected a to be untouched, Got: %q, %v", entry, err)
+	}
+}
+
+// failingTargetOps fails every Put to the key fail itself, so that staging its entry
+// succeeds and promoting it fails.
+type failingTargetOps struct {
+	libstore.Ops
+	fail string
+}
+
+func (f failingTargetOps) Put(ctx context.Context, key string, entry []byte) error {
+	if key == f.fail {
+		return libstore.OpsInternalError("injected failure")
+	}
+	return f.Ops.Put(ctx, key, entry)
+}
+
+func TestWriteAllPromotionFailure(t *testing.T) {
+	ctx := context.Background()
+	backend, err := libstore.NewFileOps(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		if err := backend.Create(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := backend.Put(ctx, "a", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+
+	// a and b are promoted before c fails, and must be rolled back.
+	ops := failingTargetOps{Ops: backend, fail: "c"}
+	err = libstore.WriteAll(ctx, ops, map[string][]byte{"a": []byte("x"), "b": []byte("y"), "c": []byte("z")})
+	var internal libstore.OpsInternalError
+	if !errors.As(err, &internal) {
+		t.Fatalf("Expected the injected failure, Got: %v", err)
+	}
+	if entry, err := backend.Read(ctx, "a"); err != nil || string(entry) != "1" {
+		t.Errorf("Expected a to be rolled back, Got: %q, %v", entry, err)
+	}
+	var entryErr libstore.EntryError
+	for _, key := range []string{"b", "c"} {
+		if entry, err := backend.Read(ctx, key); !errors.As(err, &entryErr) {
+			t.Errorf("Expected %s to hold no entry, Got: %q, %v", key, entry, err)
+		}
+	}
+	keys, err := backend.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(keys)
+	if !slices.Equal(keys, []string{"a", "b", "c"}) {
+		t.Errorf("Expected staged keys to be removed, Got: %v", keys)
+	}
+}
+
+func TestWriteAllInMemory(t *testing.T) {
+	ctx := context.Background()
+	ops := libstore.NewInMemoryOps()
+	if err := ops.Create(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	var notFound libstore.KeyNotFoundError
+	if err := libstore.WriteAll(ctx, ops, map[string][]byte{"a": []byte("x"), "missing": nil}); !errors.As(err, &notFound) {
+		t.Errorf("Expected a KeyNotFoundError, Got: %v", err)
+	}
+	if entry, err := ops.Read(ctx, "a"); err == nil {
+		t.Errorf("Expected the transaction to be rolled back, Got: %q", entry)
+	}
+	if keys, err := ops.List(ctx); err != nil || !slices.Equal(keys, []string{"a"}) {
+		t.Errorf("Expected nothing to be staged, Got: %v, %v", keys, err)
+	}
+}